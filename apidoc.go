@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/andeya/faygo/swagger"
@@ -67,7 +68,8 @@ func (frame *Framework) regAPIdoc() {
 		frame.MuxAPI.NamedStaticFS("APIdoc-Swagger", frame.config.APIdoc.Path, fs)
 		frame.MuxAPI.NamedGET("APIdoc-Swagger-JSON", swaggerPath, newAPIdocJSONHandler())
 	} else {
-		allowApidoc := newIPFilter(frame.config.APIdoc.Whitelist, frame.config.APIdoc.RealIP)
+		allowApidoc := frame.newIPFilter("apidoc", frame.config.APIdoc.Whitelist, frame.config.APIdoc.Blacklist, frame.config.APIdoc.RealIP)
+		frame.apidocIPFilter = allowApidoc
 		frame.MuxAPI.NamedStaticFS("APIdoc-Swagger", frame.config.APIdoc.Path, fs).Use(allowApidoc)
 		frame.MuxAPI.NamedGET("APIdoc-Swagger-JSON", swaggerPath, newAPIdocJSONHandler(), allowApidoc)
 	}
@@ -86,15 +88,37 @@ func (frame *Framework) regAPIdoc() {
 
 func newAPIdocJSONHandler() HandlerFunc {
 	return func(ctx *Context) error {
-		if ctx.frame.apidoc == nil {
-			ctx.frame.initAPIdoc(ctx.R.Host)
+		frame := ctx.frame
+		frame.apidocLock.Lock()
+		if frame.apidoc == nil {
+			frame.initAPIdoc(ctx.R.Host)
 		}
-		ctx.frame.apidoc.Schemes = []string{ctx.Scheme()}
-		ctx.frame.apidoc.Host = ctx.R.Host
-		return ctx.JSON(200, ctx.frame.apidoc, true)
+		doc := *frame.apidoc
+		frame.apidocLock.Unlock()
+		doc.Schemes = []string{ctx.Scheme()}
+		doc.Host = ctx.R.Host
+		return ctx.JSON(200, &doc, true)
 	}
 }
 
+// AddAPIdoc adds a function which contributes entries to the API doc,
+// such as the operations dispatched by a single wildcard route.
+// The functions are called in order each time the API doc is generated.
+func (frame *Framework) AddAPIdoc(fn func(doc *swagger.Swagger)) {
+	frame.apidocLock.Lock()
+	frame.apidocFns = append(frame.apidocFns, fn)
+	frame.apidoc = nil
+	frame.apidocLock.Unlock()
+}
+
+// ResetAPIdoc discards the generated API doc, so that it is generated again
+// on the next request, such as after the entries of an AddAPIdoc function change.
+func (frame *Framework) ResetAPIdoc() {
+	frame.apidocLock.Lock()
+	frame.apidoc = nil
+	frame.apidocLock.Unlock()
+}
+
 func (frame *Framework) swaggerPath() string {
 	return strings.TrimRight(frame.config.APIdoc.Path, "/") + "_swagger.json"
 }
@@ -133,6 +157,9 @@ func (frame *Framework) initAPIdoc(host string) {
 		if (child.pattern == jsonPattern || strings.HasPrefix(child.pattern, frame.config.APIdoc.Path)) && child.HasMethod("GET") {
 			continue
 		}
+		if frame.config.LogAdmin.Enable && child.pattern == frame.config.LogAdmin.Path {
+			continue
+		}
 		if !child.IsGroup() {
 			addpath(child, rootTag)
 			continue
@@ -160,6 +187,9 @@ func (frame *Framework) initAPIdoc(host string) {
 			}
 		}
 	}
+	for _, fn := range frame.apidocFns {
+		fn(frame.apidoc)
+	}
 }
 
 // 添加API操作项
@@ -168,6 +198,10 @@ func addpath(mux *MuxAPI, tag *swagger.Tag) {
 	pid := apiCreatePath(mux.Path())
 	summary := apiSummary(mux.Name())
 	desc := apiDesc(mux.Notes())
+	security := apiSecurity(mux)
+	if requires := mux.Requires(); len(requires) > 0 {
+		desc = "<p>Requires: " + strings.Join(requires, ", ") + "</p>" + desc
+	}
 	for _, method := range mux.Methods() {
 		if method == "CONNECT" || method == "TRACE" {
 			continue
@@ -183,7 +217,12 @@ func addpath(mux *MuxAPI, tag *swagger.Tag) {
 			Consumes:    swagger.CommonMIMETypes,
 			Produces:    swagger.CommonMIMETypes,
 			Responses:   make(map[string]*swagger.Resp, 1),
-			// Security: []map[string][]string{},
+			Security:    security,
+			Permissions: mux.Requires(),
+		}
+		if len(o.Permissions) > 0 {
+			o.Responses["401"] = &swagger.Resp{Schema: &swagger.Schema{Type: "string"}, Description: "Unauthorized"}
+			o.Responses["403"] = &swagger.Resp{Schema: &swagger.Schema{Type: "string"}, Description: "Forbidden"}
 		}
 
 		for _, param := range mux.ParamInfos() {
@@ -270,6 +309,48 @@ func addpath(mux *MuxAPI, tag *swagger.Tag) {
 	}
 }
 
+// apiSecurity adds the securityDefinitions of the SecurityDefiner middlewares
+// used by the mux, and returns the security requirements of its operations.
+func apiSecurity(mux *MuxAPI) []map[string][]string {
+	var security []map[string][]string
+	for _, h := range mux.handlers {
+		definer, ok := h.(SecurityDefiner)
+		if !ok {
+			continue
+		}
+		defs := definer.SecurityDefinitions()
+		if len(defs) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(defs))
+		for name, def := range defs {
+			if mux.frame.apidoc.SecurityDefinitions == nil {
+				mux.frame.apidoc.SecurityDefinitions = map[string]map[string]interface{}{}
+			}
+			mux.frame.apidoc.SecurityDefinitions[name] = def
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if security == nil {
+			security = []map[string][]string{{}}
+		}
+		// every requirement so far combined with each alternative of the middleware
+		var combined []map[string][]string
+		for _, req := range security {
+			for _, name := range names {
+				r := make(map[string][]string, len(req)+1)
+				for k, v := range req {
+					r[k] = v
+				}
+				r[name] = []string{}
+				combined = append(combined, r)
+			}
+		}
+		security = combined
+	}
+	return security
+}
+
 func apiDefinitions(mux *MuxAPI, pname, method string, format interface{}) (ref string) {
 	upath := mux.Path()
 	ref = strings.Replace(path.Join(upath[1:], pname, method), "/", "@", -1)
@@ -332,50 +413,3 @@ func apiDesc(notes []Notes) string {
 	}
 	return ""
 }
-
-// newIPFilter creates middleware that intercepts the specified IP prefix.
-func newIPFilter(whitelist []string, realIP bool) HandlerFunc {
-	var noAccess bool
-	var match []string
-	var prefix []string
-
-	if len(whitelist) == 0 {
-		noAccess = true
-	} else {
-		for _, s := range whitelist {
-			if strings.HasSuffix(s, "*") {
-				prefix = append(prefix, s[:len(s)-1])
-			} else {
-				match = append(match, s)
-			}
-		}
-	}
-
-	return func(ctx *Context) error {
-		if noAccess {
-			ctx.Error(http.StatusForbidden, "no access")
-			return nil
-		}
-
-		var ip string
-		if realIP {
-			ip = ctx.RealIP()
-		} else {
-			ip = ctx.IP()
-		}
-		for _, ipMatch := range match {
-			if ipMatch == ip {
-				ctx.Next()
-				return nil
-			}
-		}
-		for _, ipPrefix := range prefix {
-			if strings.HasPrefix(ip, ipPrefix) {
-				ctx.Next()
-				return nil
-			}
-		}
-		ctx.Error(http.StatusForbidden, "not allow to access: "+ip)
-		return nil
-	}
-}
@@ -0,0 +1,228 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faygo
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// Authorizer decides whether a request has the permissions required by its
+	// route, which are declared by MuxAPI.Require.
+	// It returns nil if the request is allowed, ErrUnauthenticated if the user is
+	// not identified, or another error if the user is not allowed.
+	Authorizer interface {
+		Authorize(ctx *Context, required []string) error
+	}
+	// AuthorizerFunc is an adapter to allow the use of ordinary functions as Authorizer,
+	// such as a func over the claims of the JWT or the user in the session.
+	AuthorizerFunc func(ctx *Context, required []string) error
+	// RoutePermission is the permissions required by a route.
+	RoutePermission struct {
+		Name     string   `json:"name"`
+		Methods  []string `json:"methods"`
+		Path     string   `json:"path"`
+		Requires []string `json:"requires"`
+	}
+)
+
+var (
+	// ErrUnauthenticated is returned by an Authorizer when the user is not identified,
+	// the request is answered with 401, otherwise with 403.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrPermissionDenied is returned by an Authorizer when a permission is missing.
+	ErrPermissionDenied = errors.New("permission denied")
+	// errNoAuthorizer denies the requests when no Authorizer is set.
+	errNoAuthorizer = errors.New("no authorizer is set for the required permissions")
+)
+
+// Authorize implements the Authorizer.
+func (fn AuthorizerFunc) Authorize(ctx *Context, required []string) error {
+	return fn(ctx, required)
+}
+
+// SetAuthorizer sets the Authorizer which checks the permissions declared by
+// MuxAPI.Require. The requests of the routes which require permissions are
+// denied when no Authorizer is set.
+// note: it should be called before Run()
+func (frame *Framework) SetAuthorizer(authorizer Authorizer) *Framework {
+	frame.authorizer = authorizer
+	return frame
+}
+
+// RoutePermissions returns the routes which require permissions, sorted by path.
+func (frame *Framework) RoutePermissions() []RoutePermission {
+	var list []RoutePermission
+	for _, api := range frame.MuxAPIsForRouter() {
+		if len(api.requires) == 0 {
+			continue
+		}
+		list = append(list, RoutePermission{
+			Name:     api.name,
+			Methods:  api.methods,
+			Path:     api.path,
+			Requires: api.requires,
+		})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// NewRoutePermissionsHandler creates a handler which lists the routes which require
+// permissions, for the administrators. It should be protected, such as with
+// MuxAPI.Require or an IP filter.
+func NewRoutePermissionsHandler() HandlerFunc {
+	return func(ctx *Context) error {
+		return ctx.JSON(http.StatusOK, ctx.frame.RoutePermissions(), true)
+	}
+}
+
+// makeAuthorization makes the handler which checks the permissions required by a route.
+func (frame *Framework) makeAuthorization(required []string) HandlerFunc {
+	return func(ctx *Context) error {
+		var err = errNoAuthorizer
+		if frame.authorizer != nil {
+			err = frame.authorizer.Authorize(ctx, required)
+		}
+		if err == nil {
+			return nil
+		}
+		ctx.Stop()
+		if err == ErrUnauthenticated {
+			global.errorFunc(ctx, "Unauthorized", http.StatusUnauthorized)
+			return nil
+		}
+		frame.syslog.Warningf("[Authorize] %s %s %s requires %v: %s", ctx.RealIP(), ctx.Method(), ctx.Path(), required, err.Error())
+		global.errorFunc(ctx, "Forbidden", http.StatusForbidden)
+		return nil
+	}
+}
+
+// withAuthorization inserts the permission check right before the final handler
+// of the api, after all the middlewares, including those passed with the handler
+// such as frame.GET(pattern, auth, handler).
+func (frame *Framework) withAuthorization(api *MuxAPI) HandlerChain {
+	if len(api.requires) == 0 || len(api.handlers) == 0 {
+		return api.handlers
+	}
+	i := len(api.handlers) - 1
+	chain := make(HandlerChain, 0, len(api.handlers)+1)
+	chain = append(chain, api.handlers[:i]...)
+	chain = append(chain, frame.makeAuthorization(api.requires))
+	return append(chain, api.handlers[i:]...)
+}
+
+// distinctPermissions removes the duplicate permissions, keeping the order.
+func distinctPermissions(permissions []string) []string {
+	var list = permissions[:0]
+	var had = make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		if !had[p] {
+			had[p] = true
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// RBAC is a role-based Authorizer: a request is allowed if the roles of the
+// user grant all the required permissions. A role name is also a permission
+// granted by the role, and the permission "*" grants every permission.
+type RBAC struct {
+	// Roles returns the roles of the user of the request, and false if the user
+	// is not identified, such as the roles in the JWT claims or in the session.
+	Roles func(ctx *Context) ([]string, bool)
+
+	mu     sync.RWMutex
+	grants map[string]map[string]bool
+}
+
+var _ Authorizer = new(RBAC)
+
+// NewRBAC creates a role-based Authorizer.
+func NewRBAC(roles func(ctx *Context) ([]string, bool)) *RBAC {
+	return &RBAC{
+		Roles:  roles,
+		grants: make(map[string]map[string]bool),
+	}
+}
+
+// Grant grants the permissions to the role.
+func (r *RBAC) Grant(role string, permissions ...string) *RBAC {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	perms := r.grants[role]
+	if perms == nil {
+		perms = make(map[string]bool)
+		r.grants[role] = perms
+	}
+	for _, p := range permissions {
+		perms[p] = true
+	}
+	return r
+}
+
+// Revoke revokes the permissions from the role, or all of them if none is given.
+func (r *RBAC) Revoke(role string, permissions ...string) *RBAC {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(permissions) == 0 {
+		delete(r.grants, role)
+		return r
+	}
+	for _, p := range permissions {
+		delete(r.grants[role], p)
+	}
+	return r
+}
+
+// Authorize implements the Authorizer.
+func (r *RBAC) Authorize(ctx *Context, required []string) error {
+	roles, ok := r.Roles(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, p := range required {
+		if !r.granted(roles, p) {
+			return errors.New(ErrPermissionDenied.Error() + ": " + p)
+		}
+	}
+	return nil
+}
+
+func (r *RBAC) granted(roles []string, permission string) bool {
+	for _, role := range roles {
+		if role == permission {
+			return true
+		}
+		perms := r.grants[role]
+		if perms[permission] || perms["*"] {
+			return true
+		}
+		// "orders:*" grants "orders:read"
+		for p := range perms {
+			if strings.HasSuffix(p, ":*") && strings.HasPrefix(permission, p[:len(p)-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package faygo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDistinctPermissions(t *testing.T) {
+	got := distinctPermissions([]string{"a", "b", "a", "c", "b"})
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("distinctPermissions: got %v, want %v", got, want)
+	}
+}
+
+func TestRBAC(t *testing.T) {
+	rbac := NewRBAC(nil).
+		Grant("admin", "*").
+		Grant("editor", "posts:*", "users:read").
+		Grant("viewer", "posts:read")
+	var tests = []struct {
+		roles      []string
+		permission string
+		granted    bool
+	}{
+		{[]string{"admin"}, "anything", true},
+		{[]string{"editor"}, "posts:write", true},
+		{[]string{"editor"}, "users:read", true},
+		{[]string{"editor"}, "users:write", false},
+		{[]string{"viewer"}, "posts:write", false},
+		{[]string{"viewer", "editor"}, "posts:write", true},
+		{[]string{"viewer"}, "viewer", true},
+		{nil, "posts:read", false},
+	}
+	for _, test := range tests {
+		if got := rbac.granted(test.roles, test.permission); got != test.granted {
+			t.Errorf("granted(%v, %q) = %v, want %v", test.roles, test.permission, got, test.granted)
+		}
+	}
+	rbac.Revoke("editor", "posts:*")
+	if rbac.granted([]string{"editor"}, "posts:write") {
+		t.Error("expected the permission revoked")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	config := NewDefaultConfig()
+	config.APIdoc.Enable = false
+	frame := NewWithConfig(config, "authorize-test")
+	var order []string
+	mark := func(s string) HandlerFunc {
+		return func(ctx *Context) error {
+			order = append(order, s)
+			return nil
+		}
+	}
+	ok := HandlerFunc(func(ctx *Context) error {
+		return ctx.String(200, "ok")
+	})
+	frame.Route(
+		frame.NewGroup("admin",
+			frame.NewGET("users", ok).Require("users:read"),
+			frame.NewGET("open", ok),
+		).Use(mark("auth")).Require("admin"),
+		frame.NewGET("public", ok),
+	)
+	frame.SetAuthorizer(NewRBAC(func(ctx *Context) ([]string, bool) {
+		order = append(order, "authorize")
+		role := ctx.HeaderParam("Role")
+		return []string{role}, role != ""
+	}).Grant("admin", "users:read"))
+	frame.build()
+
+	serve := func(path, role string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		if role != "" {
+			req.Header.Set("Role", role)
+		}
+		w := httptest.NewRecorder()
+		frame.ServeHTTP(w, req)
+		return w.Code
+	}
+	var tests = []struct {
+		path, role string
+		code       int
+	}{
+		{"/public", "", 200},
+		{"/admin/open", "", http.StatusUnauthorized},
+		{"/admin/open", "guest", http.StatusForbidden},
+		{"/admin/open", "admin", 200},
+		{"/admin/users", "admin", 200},
+	}
+	for _, test := range tests {
+		if code := serve(test.path, test.role); code != test.code {
+			t.Errorf("GET %s as %q: got %d, want %d", test.path, test.role, code, test.code)
+		}
+	}
+	order = nil
+	serve("/admin/users", "admin")
+	if got := strings.Join(order, ","); got != "auth,authorize" {
+		t.Errorf("expected the authorization after the middlewares, got %s", got)
+	}
+
+	// the middlewares passed with the handler run before the authorization too
+	inline := NewWithConfig(config, "authorize-inline-test")
+	auth := HandlerFunc(func(ctx *Context) error {
+		order = append(order, "auth")
+		ctx.SetData("role", ctx.HeaderParam("Role"))
+		return nil
+	})
+	inline.GET("/inline", auth, ok).Require("admin")
+	inline.SetAuthorizer(NewRBAC(func(ctx *Context) ([]string, bool) {
+		order = append(order, "authorize")
+		role, _ := ctx.Data("role").(string)
+		return []string{role}, role != ""
+	}))
+	inline.build()
+	for role, code := range map[string]int{"": http.StatusUnauthorized, "guest": http.StatusForbidden, "admin": 200} {
+		order = nil
+		req := httptest.NewRequest("GET", "/inline", nil)
+		req.Header.Set("Role", role)
+		w := httptest.NewRecorder()
+		inline.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Errorf("GET /inline as %q: got %d, want %d", role, w.Code, code)
+		}
+		if got := strings.Join(order, ","); got != "auth,authorize" {
+			t.Errorf("expected the authorization after the inline middleware, got %s", got)
+		}
+	}
+
+	list := frame.RoutePermissions()
+	if len(list) != 2 || list[0].Path != "/admin/open" || list[1].Path != "/admin/users" ||
+		!reflect.DeepEqual(list[1].Requires, []string{"admin", "users:read"}) {
+		t.Errorf("unexpected route permissions: %+v", list)
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faygo
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RedactMask replaces the sensitive values in the printed request body.
+const RedactMask = "******"
+
+type (
+	// printBodyOption is the PrintBody setting of a MuxAPI node.
+	printBodyOption struct {
+		enable     bool
+		sampleRate float64
+	}
+	// bodyRedactor masks the sensitive information of the printed request.
+	bodyRedactor struct {
+		fields    map[string]bool
+		headers   map[string]bool
+		jsonPaths [][]string
+		regexps   []*regexp.Regexp
+	}
+)
+
+// PrintBody overrides the config item `print_body` for the node and its progeny.
+// sampleRate is the proportion (0,1] of the requests that are printed,
+// the default is 1.
+// note: it should be called before Run()
+func (mux *MuxAPI) PrintBody(enable bool, sampleRate ...float64) *MuxAPI {
+	opt := &printBodyOption{enable: enable, sampleRate: 1}
+	if len(sampleRate) > 0 {
+		opt.sampleRate = sampleRate[0]
+	}
+	mux.printBody = opt
+	return mux
+}
+
+// sampled reports whether the current request is selected with the rate.
+func sampled(rate float64) bool {
+	return rate >= 1 || rand.Float64() < rate
+}
+
+func newBodyRedactor(conf BodyLogConfig) (*bodyRedactor, error) {
+	r := &bodyRedactor{
+		fields:  make(map[string]bool, len(conf.RedactFields)),
+		headers: make(map[string]bool, len(conf.RedactHeaders)),
+	}
+	for _, f := range conf.RedactFields {
+		r.fields[strings.ToLower(f)] = true
+	}
+	for _, h := range conf.RedactHeaders {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, p := range conf.RedactJSONPaths {
+		r.jsonPaths = append(r.jsonPaths, strings.Split(strings.TrimPrefix(p, "$."), "."))
+	}
+	for _, s := range conf.RedactRegexps {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		r.regexps = append(r.regexps, re)
+	}
+	return r, nil
+}
+
+// redactForm returns a copy of the form values with the sensitive fields masked.
+func (r *bodyRedactor) redactForm(values url.Values) url.Values {
+	cp := make(url.Values, len(values))
+	for k, v := range values {
+		if r.fields[strings.ToLower(k)] {
+			masked := make([]string, len(v))
+			for i := range masked {
+				masked[i] = RedactMask
+			}
+			cp[k] = masked
+		} else {
+			cp[k] = v
+		}
+	}
+	return cp
+}
+
+// redactHeader returns a copy of the header with the sensitive values masked.
+func (r *bodyRedactor) redactHeader(header http.Header) http.Header {
+	cp := make(http.Header, len(header))
+	for k, v := range header {
+		if r.headers[http.CanonicalHeaderKey(k)] {
+			cp[k] = []string{RedactMask}
+		} else {
+			cp[k] = v
+		}
+	}
+	return cp
+}
+
+// redactJSON masks the sensitive fields and paths of a JSON body.
+// The body is returned as-is if it is not JSON.
+func (r *bodyRedactor) redactJSON(body []byte) []byte {
+	if len(r.fields) == 0 && len(r.jsonPaths) == 0 {
+		return body
+	}
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return body
+	}
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+	v = r.redactJSONFields(v)
+	for _, p := range r.jsonPaths {
+		v = redactJSONPath(v, p)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return b
+}
+
+func (r *bodyRedactor) redactJSONFields(v interface{}) interface{} {
+	if len(r.fields) == 0 {
+		return v
+	}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, val := range x {
+			if r.fields[strings.ToLower(k)] {
+				x[k] = RedactMask
+			} else {
+				x[k] = r.redactJSONFields(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range x {
+			x[i] = r.redactJSONFields(val)
+		}
+	}
+	return v
+}
+
+// redactJSONPath masks the value at the dot-separated path,
+// '*' matches any object key or array index.
+func redactJSONPath(v interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return RedactMask
+	}
+	key, rest := path[0], path[1:]
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, val := range x {
+			if key == "*" || key == k {
+				x[k] = redactJSONPath(val, rest)
+			}
+		}
+	case []interface{}:
+		for i, val := range x {
+			if key == "*" || key == strconv.Itoa(i) {
+				x[i] = redactJSONPath(val, rest)
+			}
+		}
+	}
+	return v
+}
+
+// redactRegexps masks the matches of the regexps. If a regexp has
+// capturing groups, only the groups are masked.
+func (r *bodyRedactor) redactRegexps(b []byte) []byte {
+	for _, re := range r.regexps {
+		if re.NumSubexp() == 0 {
+			b = re.ReplaceAll(b, []byte(RedactMask))
+			continue
+		}
+		var buf bytes.Buffer
+		last := 0
+		for _, loc := range re.FindAllSubmatchIndex(b, -1) {
+			for i := 2; i < len(loc); i += 2 {
+				if loc[i] < last {
+					continue
+				}
+				buf.Write(b[last:loc[i]])
+				buf.WriteString(RedactMask)
+				last = loc[i+1]
+			}
+		}
+		buf.Write(b[last:])
+		b = buf.Bytes()
+	}
+	return b
+}
+
+func (ctx *Context) recordBody() []byte {
+	if !ctx.printBody {
+		return nil
+	}
+	redactor := ctx.frame.config.bodyRedactor
+	var b []byte
+	formValues := ctx.FormParamAll()
+	if len(formValues) > 0 || ctx.IsUpload() {
+		v := multipart.Form{
+			Value: redactor.redactForm(formValues),
+		}
+		if ctx.R.MultipartForm != nil {
+			v.File = ctx.R.MultipartForm.File
+		}
+		b, _ = json.Marshal(v)
+	} else {
+		b = redactor.redactJSON(ctx.LimitedBodyBytes())
+	}
+	if ctx.frame.config.BodyLog.Headers {
+		h, _ := json.Marshal(redactor.redactHeader(ctx.R.Header))
+		if len(b) > 0 {
+			h = append(h, '\n')
+		}
+		b = append(h, b...)
+	}
+	b = redactor.redactRegexps(b)
+	if len(b) > 0 {
+		bb := make([]byte, len(b)+2)
+		bb[0] = '\n'
+		copy(bb[1:], b)
+		bb[len(bb)-1] = '\n'
+		return bb
+	}
+	return b
+}
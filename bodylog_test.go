@@ -0,0 +1,64 @@
+package faygo
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestBodyRedactor(t *testing.T) {
+	r, err := newBodyRedactor(BodyLogConfig{
+		RedactFields:    []string{"Password", "token"},
+		RedactHeaders:   []string{"authorization"},
+		RedactJSONPaths: []string{"user.cards.*.number", "$.meta.1"},
+		RedactRegexps:   []string{`\d{4}-\d{4}-\d{4}-\d{4}`, `sid=(\w+)`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form := r.redactForm(url.Values{"password": {"a", "b"}, "name": {"andeya"}})
+	if form.Get("name") != "andeya" || form["password"][0] != RedactMask || len(form["password"]) != 2 {
+		t.Errorf("unexpected form: %v", form)
+	}
+
+	header := r.redactHeader(http.Header{"Authorization": {"Bearer x"}, "Accept": {"*/*"}})
+	if header.Get("Authorization") != RedactMask || header.Get("Accept") != "*/*" {
+		t.Errorf("unexpected header: %v", header)
+	}
+
+	var jsonTests = []struct {
+		body, result string
+	}{
+		{`{"user":{"name":"a","PASSWORD":"p","cards":[{"number":"1","cvv":2}]},"meta":[1,2,3]}`,
+			`{"meta":[1,"******",3],"user":{"PASSWORD":"******","cards":[{"cvv":2,"number":"******"}],"name":"a"}}`},
+		{`[{"token":12345678901234567890}]`, `[{"token":"******"}]`},
+		{`token=abc`, `token=abc`},
+		{`{"broken":`, `{"broken":`},
+	}
+	for _, test := range jsonTests {
+		if got := string(r.redactJSON([]byte(test.body))); got != test.result {
+			t.Errorf("redactJSON(%s):\n got %s\nwant %s", test.body, got, test.result)
+		}
+	}
+
+	got := string(r.redactRegexps([]byte("card 1234-5678-9012-3456 cookie sid=abc; sid=def")))
+	if want := "card ****** cookie sid=******; sid=******"; got != want {
+		t.Errorf("redactRegexps:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestSampled(t *testing.T) {
+	if !sampled(1) {
+		t.Error("rate 1 must always be sampled")
+	}
+	var n int
+	for i := 0; i < 10000; i++ {
+		if sampled(0.01) {
+			n++
+		}
+	}
+	if n == 0 || n > 500 {
+		t.Errorf("unexpected samples at rate 0.01: %d", n)
+	}
+}
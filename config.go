@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
@@ -38,14 +39,19 @@ type (
 	// Config is the config information for each web instance
 	Config struct {
 		// RunMode         string      `ini:"run_mode" comment:"run mode: dev|prod"`
-		NetTypes          []string    `ini:"net_types" delim:"|" comment:"List of network type: http|https|unix_http|unix_https|letsencrypt|unix_letsencrypt"`
-		Addrs             []string    `ini:"addrs" delim:"|" comment:"List of multiple listening addresses"`
-		TLSCertFile       string      `ini:"tls_certfile" comment:"TLS certificate file path"`
-		TLSKeyFile        string      `ini:"tls_keyfile" comment:"TLS key file path"`
-		LetsencryptDir    string      `ini:"letsencrypt_dir" comment:"Let's Encrypt TLS certificate cache directory"`
-		UNIXFileMode      string      `ini:"unix_filemode" comment:"File permissions for UNIX listener, requires octal number"`
-		unixFileMode      os.FileMode `ini:"-"`
-		HttpRedirectHttps bool        `ini:"http_redirect_https" comment:"Redirect from 'http://hostname:port1' to 'https://hostname:port2'"`
+		NetTypes          []string      `ini:"net_types" delim:"|" comment:"List of network type: http|https|unix_http|unix_https|letsencrypt|unix_letsencrypt"`
+		Addrs             []string      `ini:"addrs" delim:"|" comment:"List of multiple listening addresses"`
+		TLSCertFile       string        `ini:"tls_certfile" comment:"TLS certificate file path"`
+		TLSKeyFile        string        `ini:"tls_keyfile" comment:"TLS key file path"`
+		LetsencryptDir    string        `ini:"letsencrypt_dir" comment:"Let's Encrypt TLS certificate cache directory"`
+		TLSClientCAFile   string        `ini:"tls_client_cafile" comment:"PEM bundle of the CAs verifying the client certificates (mTLS)"`
+		TLSClientAuth     string        `ini:"tls_client_auth" comment:"Client certificate verification: none|request|require; 'request' verifies the certificate if the client sends one, and the routes can require it"`
+		TLSReloadInterval time.Duration `ini:"tls_reload_interval" comment:"Interval of checking the TLS certificate, key and client CA files for changes, to reload them without a restart; 0 means never; ns|µs|ms|s|m|h"`
+		UNIXFileMode      string        `ini:"unix_filemode" comment:"File permissions for UNIX listener, requires octal number"`
+		unixFileMode      os.FileMode   `ini:"-"`
+		HttpRedirectHttps bool          `ini:"http_redirect_https" comment:"Redirect from 'http://hostname:port1' to 'https://hostname:port2'"`
+		TrustedProxies    []string      `ini:"trusted_proxies" delim:"|" comment:"CIDRs or IPs of the proxies whose X-Real-IP, Forwarded, X-Forwarded-For and X-Forwarded-Proto headers are trusted by RealIP() and Scheme(); '*' trusts any peer, empty trusts none"`
+		trustedProxies    *IPSet        `ini:"-"`
 		// Maximum duration for reading the full request (including body).
 		//
 		// This also limits the maximum duration for idle keep-alive
@@ -56,16 +62,21 @@ type (
 		// Maximum duration for writing the full response (including body).
 		//
 		// By default response write timeout is unlimited.
-		WriteTimeout          time.Duration `ini:"write_timeout" comment:"Maximum duration for writing the full response (including body); ns|µs|ms|s|m|h"`
-		MultipartMaxMemoryMB  int64         `ini:"multipart_maxmemory_mb" comment:"Maximum size of memory that can be used when receiving uploaded files"`
-		multipartMaxMemory    int64         `ini:"-"`
-		Router                RouterConfig  `ini:"router" comment:"Routing config section"`
-		XSRF                  XSRFConfig    `ini:"xsrf" comment:"XSRF security section"`
-		Session               SessionConfig `ini:"session" comment:"Session section"`
-		SlowResponseThreshold time.Duration `ini:"slow_response_threshold" comment:"When response time > slow_response_threshold, log level = 'WARNING'; 0 means not limited; ns|µs|ms|s|m|h"`
-		slowResponseThreshold time.Duration `ini:"-"`
-		PrintBody             bool          `ini:"print_body" comment:"Form requests are printed in JSON format, but other types are printed as-is"`
-		APIdoc                APIdocConfig  `ini:"apidoc" comment:"API documentation section"`
+		WriteTimeout          time.Duration       `ini:"write_timeout" comment:"Maximum duration for writing the full response (including body); ns|µs|ms|s|m|h"`
+		MultipartMaxMemoryMB  int64               `ini:"multipart_maxmemory_mb" comment:"Maximum size of memory that can be used when receiving uploaded files"`
+		multipartMaxMemory    int64               `ini:"-"`
+		RequestLimit          RequestLimitConfig  `ini:"request_limit" comment:"Request size and rate limits section"`
+		Router                RouterConfig        `ini:"router" comment:"Routing config section"`
+		XSRF                  XSRFConfig          `ini:"xsrf" comment:"XSRF security section"`
+		SecureHeaders         SecureHeadersConfig `ini:"secure_headers" comment:"Security response headers section"`
+		Session               SessionConfig       `ini:"session" comment:"Session section"`
+		SlowResponseThreshold time.Duration       `ini:"slow_response_threshold" comment:"When response time > slow_response_threshold, log level = 'WARNING'; 0 means not limited; ns|µs|ms|s|m|h"`
+		slowResponseThreshold time.Duration       `ini:"-"`
+		PrintBody             bool                `ini:"print_body" comment:"Form requests are printed in JSON format, but other types are printed as-is"`
+		BodyLog               BodyLogConfig       `ini:"body_log" comment:"Sampling and redaction rules for print_body"`
+		bodyRedactor          *bodyRedactor       `ini:"-"`
+		APIdoc                APIdocConfig        `ini:"apidoc" comment:"API documentation section"`
+		LogAdmin              LogAdminConfig      `ini:"log_admin" comment:"Runtime log level control section"`
 	}
 	// RouterConfig is the config about router
 	RouterConfig struct {
@@ -94,11 +105,21 @@ type (
 		HandleMethodNotAllowed bool `ini:"handle_method_not_allowed" comment:"Returns 405 if the requested method does not exist, otherwise returns 404"`
 		// If enabled, the router automatically replies to OPTIONS requests.
 		// Custom OPTIONS handlers take priority over automatic replies.
+		// The CORS preflight requests are answered by the faygo.Preflighter middlewares.
 		HandleOPTIONS   bool `ini:"handle_options" comment:"Automatic response OPTIONS request, you can set the default Handler in faygo"`
 		NoDefaultParams bool `ini:"no_default_params" comment:"If true, don't assign default request parameter values based on initial parameter values of the routing handler"`
 		DefaultUpload   bool `ini:"default_upload" comment:"Automatically register the default router: /upload/*filepath"`
 		DefaultStatic   bool `ini:"default_static" comment:"Automatically register the default router: /static/*filepath"`
 	}
+	// RequestLimitConfig is the config about the limits of the request size and read rate
+	RequestLimitConfig struct {
+		MaxBodySizeMB    int64         `ini:"max_body_size_mb" comment:"Maximum size of the request body, 413 is returned as soon as it is exceeded; 0 means not limited"`
+		maxBodySize      int64         `ini:"-"`
+		MinReadRate      int64         `ini:"min_read_rate" comment:"Minimum bytes per second of reading the request body after min_read_rate_grace, the slower clients are cut off with 408; 0 means not limited"`
+		MinReadRateGrace time.Duration `ini:"min_read_rate_grace" comment:"Duration of reading the request body before min_read_rate is checked; ns|µs|ms|s|m|h"`
+		MaxHeaderBytes   int           `ini:"max_header_bytes" comment:"Maximum size of the request headers, 431 is returned if it is exceeded; 0 means 1MB"`
+		MaxHeaderCount   int           `ini:"max_header_count" comment:"Maximum number of the request header fields, 431 is returned if it is exceeded; 0 means not limited"`
+	}
 	// GzipConfig is the config about gzip
 	GzipConfig struct {
 		// if EnableGzip, compress response content.
@@ -129,9 +150,28 @@ type (
 	}
 	// XSRFConfig is the config about XSRF filter
 	XSRFConfig struct {
-		Enable       bool   `ini:"enable" comment:"Whether enabled or not"`
-		Key          string `ini:"key" comment:"Encryption key"`
-		ExpireSecond int    `ini:"expire_second" comment:"Expire of XSRF token"`
+		Enable         bool     `ini:"enable" comment:"Whether enabled or not"`
+		Key            string   `ini:"key" comment:"Encryption key"`
+		ExpireSecond   int      `ini:"expire_second" comment:"Expire of XSRF token"`
+		Mode           string   `ini:"mode" comment:"Token transport: form|header; 'form' checks the secure cookie against the '_xsrf' field, 'header' checks a cookie readable by the scripts against a request header, for the single-page applications"`
+		CookieName     string   `ini:"cookie_name" comment:"Cookie of the token in header mode"`
+		HeaderName     string   `ini:"header_name" comment:"Request header of the token in header mode"`
+		CheckOrigin    bool     `ini:"check_origin" comment:"Whether to deny the unsafe requests whose Origin or Referer is neither the host nor a trusted origin; X-Forwarded-Host is the host only from the trusted proxies"`
+		TrustedOrigins []string `ini:"trusted_origins" delim:"|" comment:"Other origins allowed by check_origin, e.g. 'https://app.example.com|https://admin.example.com'"`
+	}
+	// SecureHeadersConfig is the config about the security response headers
+	SecureHeadersConfig struct {
+		Enable                bool   `ini:"enable" comment:"Whether to set the security headers on all the routes"`
+		HSTSMaxAge            int    `ini:"hsts_max_age" comment:"Strict-Transport-Security max-age (second) of the https requests; 0 means one year if a net type is https or letsencrypt; -1 means none"`
+		HSTSIncludeSubdomains bool   `ini:"hsts_include_subdomains" comment:"Whether the HSTS includes the subdomains"`
+		HSTSPreload           bool   `ini:"hsts_preload" comment:"Whether the HSTS allows the preload lists of the browsers"`
+		ContentTypeNosniff    bool   `ini:"content_type_nosniff" comment:"Whether to set 'X-Content-Type-Options: nosniff'"`
+		FrameOptions          string `ini:"frame_options" comment:"X-Frame-Options: DENY|SAMEORIGIN; empty means none"`
+		ReferrerPolicy        string `ini:"referrer_policy" comment:"Referrer-Policy; empty means none"`
+		PermissionsPolicy     string `ini:"permissions_policy" comment:"Permissions-Policy, e.g. 'camera=(), geolocation=()'; empty means none"`
+		CSP                   string `ini:"csp" comment:"Content-Security-Policy, '{nonce}' is replaced with the nonce of the request, e.g. \"default-src 'self'; script-src 'self' {nonce}\" wrapped in backquotes since ';' starts a comment; empty means none"`
+		CSPReportOnly         bool   `ini:"csp_report_only" comment:"Whether to only report the CSP violations, with Content-Security-Policy-Report-Only"`
+		CSPReportPath         string `ini:"csp_report_path" comment:"The URL path of the collector logging the CSP violation reports, added to the policy as report-uri; empty means none"`
 	}
 	// SessionConfig is the config about session
 	SessionConfig struct {
@@ -155,6 +195,41 @@ type (
 		FileEnable    bool   `ini:"file_enable" comment:"Whether enabled or not file logger"`
 		FileLevel     string `ini:"file_level" comment:"File logger level: critical|error|warning|notice|info|debug"`
 		AsyncLen      int    `ini:"async_len" comment:"The length of asynchronous buffer, 0 means synchronization"`
+		// Remote log shipping, used by the syslog and bizlog of every Framework.
+		SyslogEnable      bool          `ini:"syslog_enable" comment:"Whether enabled or not syslog (RFC 5424) logger"`
+		SyslogLevel       string        `ini:"syslog_level" comment:"Syslog logger level: critical|error|warning|notice|info|debug"`
+		SyslogNetwork     string        `ini:"syslog_network" comment:"Syslog transport: udp|tcp|unix|unixgram"`
+		SyslogAddr        string        `ini:"syslog_addr" comment:"Syslog server address, such as '127.0.0.1:514' or '/dev/log'"`
+		SyslogFacility    string        `ini:"syslog_facility" comment:"Syslog facility: user|daemon|local0...local7 etc."`
+		SyslogTag         string        `ini:"syslog_tag" comment:"Syslog APP-NAME, the default is the executable name"`
+		ShipEnable        bool          `ini:"ship_enable" comment:"Whether enabled or not the TCP/UDP line shipping logger"`
+		ShipLevel         string        `ini:"ship_level" comment:"Line shipping logger level: critical|error|warning|notice|info|debug"`
+		ShipNetwork       string        `ini:"ship_network" comment:"Line shipping transport: tcp|udp|unix|unixgram"`
+		ShipAddr          string        `ini:"ship_addr" comment:"Line shipping collector address"`
+		HTTPEnable        bool          `ini:"http_enable" comment:"Whether enabled or not the HTTP batch shipping logger (newline-delimited JSON POST)"`
+		HTTPLevel         string        `ini:"http_level" comment:"HTTP shipping logger level: critical|error|warning|notice|info|debug"`
+		HTTPURL           string        `ini:"http_url" comment:"HTTP collector URL"`
+		HTTPBatchSize     int           `ini:"http_batch_size" comment:"Maximum number of records per POST"`
+		HTTPFlushInterval time.Duration `ini:"http_flush_interval" comment:"Maximum delay before a partial batch is POSTed; ns|µs|ms|s|m|h"`
+		RemoteBufferLen   int           `ini:"remote_buffer_len" comment:"The length of the in-memory buffer of each remote logger, messages are dropped when it is full"`
+	}
+	// BodyLogConfig is the config about printing request bodies to the access log
+	BodyLogConfig struct {
+		SampleRate      float64  `ini:"sample_rate" comment:"Proportion (0,1] of the requests whose body is printed, out-of-range values mean 1"`
+		Headers         bool     `ini:"headers" comment:"Whether to print the request headers along with the body"`
+		RedactFields    []string `ini:"redact_fields" delim:"|" comment:"Form fields and JSON keys (at any depth) whose values are masked, case-insensitive"`
+		RedactHeaders   []string `ini:"redact_headers" delim:"|" comment:"Request headers whose values are masked"`
+		RedactJSONPaths []string `ini:"redact_json_paths" delim:"|" comment:"Dot-separated JSON paths whose values are masked, '*' matches any key or index, e.g. 'user.cards.*.number'"`
+		RedactRegexps   []string `ini:"redact_regexps" delim:"|" comment:"Regular expressions whose matches are masked, only the capturing groups if any"`
+	}
+	// LogAdminConfig is the config about the runtime log level control API
+	LogAdminConfig struct {
+		Enable    bool     `ini:"enable" comment:"Whether enabled or not"`
+		Path      string   `ini:"path" comment:"The URL path, GET lists the logger levels, PUT changes one with the params name, level and ttl"`
+		NoLimit   bool     `ini:"nolimit" comment:"If true, access is not restricted"`
+		RealIP    bool     `ini:"real_ip" comment:"if true, means verifying the real IP of the visitor"`
+		Whitelist []string `ini:"whitelist" delim:"|" comment:"'whitelist=192.168.0.0/16|202.122.246.170|::1' means: only IP addresses in the CIDR ranges or equal to the IPs are allowed; the prefixes like '10.*' are also supported"`
+		Blacklist []string `ini:"blacklist" delim:"|" comment:"CIDR ranges or IPs that are denied even if they are in the whitelist"`
 	}
 	// APIdocConfig is the config about API doc
 	APIdocConfig struct {
@@ -162,7 +237,8 @@ type (
 		Path       string   `ini:"path" comment:"The URL path"`
 		NoLimit    bool     `ini:"nolimit" comment:"If true, access is not restricted"`
 		RealIP     bool     `ini:"real_ip" comment:"if true, means verifying the real IP of the visitor"`
-		Whitelist  []string `ini:"whitelist" delim:"|" comment:"'whitelist=192.*|202.122.246.170' means: only IP addresses that are prefixed with '192.' or equal to '202.122.246.170' are allowed"`
+		Whitelist  []string `ini:"whitelist" delim:"|" comment:"'whitelist=192.168.0.0/16|202.122.246.170|::1' means: only IP addresses in the CIDR ranges or equal to the IPs are allowed; the prefixes like '10.*' are also supported"`
+		Blacklist  []string `ini:"blacklist" delim:"|" comment:"CIDR ranges or IPs that are denied even if they are in the whitelist"`
 		Desc       string   `ini:"desc" comment:"Description of the application"`
 		Email      string   `ini:"email" comment:"Technician's Email"`
 		TermsURL   string   `ini:"terms_url" comment:"Terms of service"`
@@ -226,19 +302,30 @@ var globalConfig = func() GlobalConfig {
 			Methods:       []string{"GET"},
 		},
 		Log: LogConfig{
-			ConsoleEnable: true,
-			ConsoleLevel:  "debug",
-			FileEnable:    false,
-			FileLevel:     "debug",
+			ConsoleEnable:     true,
+			ConsoleLevel:      "debug",
+			FileEnable:        false,
+			FileLevel:         "debug",
+			SyslogLevel:       "info",
+			SyslogNetwork:     "udp",
+			SyslogAddr:        "127.0.0.1:514",
+			SyslogFacility:    "user",
+			ShipLevel:         "info",
+			ShipNetwork:       "tcp",
+			HTTPLevel:         "info",
+			HTTPBatchSize:     100,
+			HTTPFlushInterval: time.Second,
+			RemoteBufferLen:   1024,
 		},
 	}
 	filename := filepath.Join(configDir, globalConfigFile)
 	err := SyncINI(
 		background,
 		func(onceUpdateFunc func() error) error {
-			if !(background.Log.ConsoleEnable || background.Log.FileEnable) {
+			if !(background.Log.ConsoleEnable || background.Log.FileEnable ||
+				background.Log.SyslogEnable || background.Log.ShipEnable || background.Log.HTTPEnable) {
 				background.Log.ConsoleEnable = true
-				background.warnMsg = "config: log::enable_console and log::enable_file can not be disabled at the same time, so automatically open console log."
+				background.warnMsg = "config: log::console_enable, log::file_enable, log::syslog_enable, log::ship_enable and log::http_enable can not be disabled at the same time, so automatically open console log."
 			}
 			return onceUpdateFunc()
 		},
@@ -259,7 +346,13 @@ func NewDefaultConfig() *Config {
 		NetTypes:             []string{NETTYPE_HTTP},
 		Addrs:                []string{fmt.Sprintf("0.0.0.0:%d", defaultPort+len(AllFrames()))},
 		UNIXFileMode:         "0666",
+		TLSClientAuth:        TLS_CLIENT_AUTH_NONE,
+		TrustedProxies:       []string{"127.0.0.0/8", "::1"},
 		MultipartMaxMemoryMB: defaultMultipartMaxMemoryMB,
+		RequestLimit: RequestLimitConfig{
+			MinReadRateGrace: 5 * time.Second,
+			MaxHeaderBytes:   http.DefaultMaxHeaderBytes,
+		},
 		Router: RouterConfig{
 			RedirectTrailingSlash:  true,
 			RedirectFixedPath:      true,
@@ -272,6 +365,18 @@ func NewDefaultConfig() *Config {
 			Enable:       false,
 			Key:          "faygoxsrf",
 			ExpireSecond: 3600,
+			Mode:         XSRF_MODE_FORM,
+			CookieName:   "XSRF-TOKEN",
+			HeaderName:   "X-XSRF-Token",
+			CheckOrigin:  false,
+		},
+		SecureHeaders: SecureHeadersConfig{
+			Enable:             false,
+			HSTSMaxAge:         0,
+			ContentTypeNosniff: true,
+			FrameOptions:       "SAMEORIGIN",
+			ReferrerPolicy:     "strict-origin-when-cross-origin",
+			CSPReportPath:      "/csp_report",
 		},
 		Session: SessionConfig{
 			Enable:                false,
@@ -293,9 +398,25 @@ func NewDefaultConfig() *Config {
 			NoLimit: false,
 			RealIP:  false,
 			Whitelist: []string{
-				"127.*",
-				"192.168.*",
-				"10.*",
+				"127.0.0.0/8",
+				"::1",
+				"192.168.0.0/16",
+				"10.0.0.0/8",
+			},
+		},
+		BodyLog: BodyLogConfig{
+			SampleRate:    1,
+			RedactFields:  []string{"password", "passwd", "secret", "token", "access_token", "refresh_token"},
+			RedactHeaders: []string{HeaderAuthorization, HeaderCookie, "Proxy-Authorization"},
+		},
+		LogAdmin: LogAdminConfig{
+			Enable:  false,
+			Path:    "/loglevel",
+			NoLimit: false,
+			RealIP:  false,
+			Whitelist: []string{
+				"127.0.0.0/8",
+				"::1",
 			},
 		},
 	}
@@ -320,6 +441,18 @@ func (c *Config) check() {
 			panic("Please set a valid config item `net_types`, refer to the following:" + __netTypes__)
 		}
 	}
+	c.TLSClientAuth = strings.ToLower(strings.TrimSpace(c.TLSClientAuth))
+	switch c.TLSClientAuth {
+	case "":
+		c.TLSClientAuth = TLS_CLIENT_AUTH_NONE
+	case TLS_CLIENT_AUTH_NONE:
+	case TLS_CLIENT_AUTH_REQUEST, TLS_CLIENT_AUTH_REQUIRE:
+		if c.TLSClientCAFile == "" {
+			panic("The config item `tls_client_cafile` is required by `tls_client_auth = " + c.TLSClientAuth + "`")
+		}
+	default:
+		panic("Please set a valid config item `tls_client_auth`, refer to the following:" + __tlsClientAuths__)
+	}
 	fileMode, err := strconv.ParseUint(c.UNIXFileMode, 8, 32)
 	if err != nil {
 		panic("The config item `unix_filemode` is not a valid octal number:" + c.UNIXFileMode)
@@ -327,12 +460,55 @@ func (c *Config) check() {
 	c.unixFileMode = os.FileMode(fileMode)
 	c.UNIXFileMode = fmt.Sprintf("%#o", fileMode)
 	c.multipartMaxMemory = c.MultipartMaxMemoryMB * MB
+	if c.RequestLimit.MaxBodySizeMB < 0 {
+		c.RequestLimit.MaxBodySizeMB = 0
+	}
+	c.RequestLimit.maxBodySize = c.RequestLimit.MaxBodySizeMB * MB
+	if c.RequestLimit.MinReadRate < 0 {
+		c.RequestLimit.MinReadRate = 0
+	}
+	if c.RequestLimit.MinReadRateGrace < 0 {
+		c.RequestLimit.MinReadRateGrace = 0
+	}
+	if c.RequestLimit.MaxHeaderBytes <= 0 {
+		c.RequestLimit.MaxHeaderBytes = http.DefaultMaxHeaderBytes
+	}
+	if c.RequestLimit.MaxHeaderCount < 0 {
+		c.RequestLimit.MaxHeaderCount = 0
+	}
 	if c.SlowResponseThreshold <= 0 {
 		c.slowResponseThreshold = time.Duration(math.MaxInt64)
 	} else {
 		c.slowResponseThreshold = c.SlowResponseThreshold
 	}
+	if c.BodyLog.SampleRate <= 0 || c.BodyLog.SampleRate > 1 {
+		c.BodyLog.SampleRate = 1
+	}
+	c.bodyRedactor, err = newBodyRedactor(c.BodyLog)
+	if err != nil {
+		panic("The config item `body_log::redact_regexps` is invalid: " + err.Error())
+	}
+	c.XSRF.Mode = strings.ToLower(strings.TrimSpace(c.XSRF.Mode))
+	switch c.XSRF.Mode {
+	case "":
+		c.XSRF.Mode = XSRF_MODE_FORM
+	case XSRF_MODE_FORM, XSRF_MODE_HEADER:
+	default:
+		panic("Please set a valid config item `xsrf::mode`, refer to the following:" + __xsrfModes__)
+	}
+	if c.XSRF.CookieName == "" {
+		c.XSRF.CookieName = "XSRF-TOKEN"
+	}
+	if c.XSRF.HeaderName == "" {
+		c.XSRF.HeaderName = "X-XSRF-Token"
+	}
+	c.trustedProxies, err = ParseIPSet(c.TrustedProxies)
+	if err != nil {
+		panic("The config item `trusted_proxies` is invalid: " + err.Error())
+	}
+	// the invalid entries of the IP lists are matched as prefixes, see newIPFilter
 	c.APIdoc.Comb()
+	c.LogAdmin.Comb()
 }
 
 func newConfigFromFileAndCheck(filename string) *Config {
@@ -354,16 +530,30 @@ func newConfigFromFileAndCheck(filename string) *Config {
 
 // Comb combs APIdoc config
 func (conf *APIdocConfig) Comb() {
-	ipPrefixMap := map[string]bool{}
-	for _, ipPrefix := range conf.Whitelist {
-		if len(ipPrefix) > 0 {
-			ipPrefixMap[ipPrefix] = true
+	conf.Whitelist = combIPs(conf.Whitelist)
+	conf.Blacklist = combIPs(conf.Blacklist)
+	conf.Path = "/" + strings.Trim(conf.Path, "/") + "/"
+}
+
+// Comb combs LogAdmin config
+func (conf *LogAdminConfig) Comb() {
+	conf.Whitelist = combIPs(conf.Whitelist)
+	conf.Blacklist = combIPs(conf.Blacklist)
+	conf.Path = "/" + strings.Trim(conf.Path, "/")
+}
+
+// combIPs removes the empty and duplicate items of the IP list, and sorts it.
+func combIPs(list []string) []string {
+	ipMap := map[string]bool{}
+	for _, ip := range list {
+		if ip = strings.TrimSpace(ip); len(ip) > 0 {
+			ipMap[ip] = true
 		}
 	}
-	conf.Whitelist = conf.Whitelist[:0]
-	for ipPrefix := range ipPrefixMap {
-		conf.Whitelist = append(conf.Whitelist, ipPrefix)
+	list = list[:0]
+	for ip := range ipMap {
+		list = append(list, ip)
 	}
-	sort.Strings(conf.Whitelist)
-	conf.Path = "/" + strings.Trim(conf.Path, "/") + "/"
+	sort.Strings(list)
+	return list
 }
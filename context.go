@@ -15,11 +15,9 @@
 package faygo
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
-	"mime/multipart"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -52,8 +50,10 @@ const (
 	HeaderVary                          = "Vary"
 	HeaderWWWAuthenticate               = "WWW-Authenticate"
 	HeaderXForwardedProto               = "X-Forwarded-Proto"
+	HeaderXForwardedHost                = "X-Forwarded-Host"
 	HeaderXHTTPMethodOverride           = "X-HTTP-Method-Override"
 	HeaderXForwardedFor                 = "X-Forwarded-For"
+	HeaderForwarded                     = "Forwarded"
 	HeaderXRealIP                       = "X-Real-IP"
 	HeaderXRequestedWith                = "X-Requested-With"
 	HeaderServer                        = "Server"
@@ -136,6 +136,9 @@ type (
 		xsrfExpire         int
 		_xsrfToken         string
 		_xsrfTokenReset    bool
+		xsrfExempt         bool        // whether the route is exempted from the XSRF check
+		printBody          bool        // whether to print the request body to the access log
+		body               requestBody // the wrapper of the request body enforcing the request limits
 	}
 )
 
@@ -148,10 +151,10 @@ func (ctx *Context) Log() *logging.Logger {
 // If specifiedExpiration is empty, the value in the configuration is used.
 func (ctx *Context) XSRFToken(specifiedExpiration ...int) string {
 	if ctx._xsrfToken == "" {
-		token, ok := ctx.SecureCookieParam(ctx.frame.config.XSRF.Key, "_xsrf")
+		token, ok := ctx.xsrfCookie()
 		if !ok {
 			ctx._xsrfTokenReset = true
-			token = RandomString(32)
+			token = ctx.newXSRFToken()
 			if len(specifiedExpiration) > 0 && specifiedExpiration[0] > 0 {
 				ctx.xsrfExpire = specifiedExpiration[0]
 			} else if ctx.xsrfExpire == 0 {
@@ -172,11 +175,15 @@ func (ctx *Context) XSRFFormHTML() string {
 // checkXSRFCookie checks xsrf token in this request is valid or not.
 // the token can provided in request cookie "_xsrf",
 // or in header "X-Xsrftoken" and "X-CsrfToken",
-// or in form field value named as "_xsrf".
+// or in form field value named as "_xsrf",
+// or only in the config item `xsrf::header_name` in header mode.
 func (ctx *Context) checkXSRFCookie() bool {
 	if !ctx.enableXSRF {
 		return true
 	}
+	if ctx.frame.config.XSRF.Mode == XSRF_MODE_HEADER {
+		return ctx.checkXSRFHeader()
+	}
 	token := ctx.BizParam("_xsrf")
 	if token == "" {
 		token = ctx.R.Header.Get("X-Xsrftoken")
@@ -366,16 +373,10 @@ func (ctx *Context) doHandler(handlerChain HandlerChain, pathParams PathParams)
 func (ctx *Context) prepare() bool {
 	var pass = true
 	// if XSRF is Enable then check cookie where there has any cookie in the request's cookie _csrf
-	if ctx.enableXSRF {
+	if ctx.enableXSRF && !ctx.xsrfExempt {
 		ctx.XSRFToken()
-		switch ctx.R.Method {
-		case "POST", "DELETE", "PUT":
-			pass = ctx.checkXSRFCookie()
-		default:
-			switch ctx.BizParam("_method") {
-			case "POST", "DELETE", "PUT":
-				pass = ctx.checkXSRFCookie()
-			}
+		if ctx.isXSRFUnsafe() {
+			pass = ctx.checkXSRFOrigin() && ctx.checkXSRFCookie()
 		}
 	}
 	return pass
@@ -407,7 +408,7 @@ func (ctx *Context) Next() {
 
 func (ctx *Context) beforeWriteHeader() {
 	if ctx._xsrfTokenReset {
-		ctx.SetSecureCookie(ctx.frame.config.XSRF.Key, "_xsrf", ctx._xsrfToken, ctx.xsrfExpire)
+		ctx.setXSRFCookie()
 	}
 	if ctx.enableSession {
 		if ctx.curSession != nil {
@@ -432,39 +433,14 @@ func (ctx *Context) IsBreak() bool {
 	return ctx.pos == stopExecutionposition
 }
 
-func (ctx *Context) recordBody() []byte {
-	if !ctx.frame.config.PrintBody {
-		return nil
-	}
-	var b []byte
-	formValues := ctx.FormParamAll()
-	if len(formValues) > 0 || ctx.IsUpload() {
-		v := multipart.Form{
-			Value: formValues,
-		}
-		if ctx.R.MultipartForm != nil {
-			v.File = ctx.R.MultipartForm.File
-		}
-		b, _ = json.Marshal(v)
-	} else {
-		b = ctx.LimitedBodyBytes()
-	}
-	if len(b) > 0 {
-		bb := make([]byte, len(b)+2)
-		bb[0] = '\n'
-		copy(bb[1:], b)
-		bb[len(bb)-1] = '\n'
-		return bb
-	}
-	return b
-}
-
 func (frame *Framework) getContext(w http.ResponseWriter, r *http.Request) *Context {
 	ctx := frame.contextPool.Get().(*Context)
 	ctx.R = r
 	ctx.W.reset(w)
 	ctx.data = make(map[interface{}]interface{})
-	if frame.config.PrintBody && !ctx.IsUpload() {
+	frame.limitBody(ctx)
+	ctx.printBody = frame.config.PrintBody && sampled(frame.config.BodyLog.SampleRate)
+	if ctx.printBody && !ctx.IsUpload() {
 		ctx.LimitedBodyBytes()
 	}
 	return ctx
@@ -481,5 +457,9 @@ func (frame *Framework) putContext(ctx *Context) {
 	ctx.queryParams = nil
 	ctx._xsrfToken = ""
 	ctx._xsrfTokenReset = false
+	ctx.xsrfExempt = false
+	ctx.printBody = false
+	ctx.body.release()
+	ctx.body = requestBody{}
 	frame.contextPool.Put(ctx)
 }
@@ -26,6 +26,7 @@ import (
 	"io"
 	"io/ioutil"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -72,9 +73,16 @@ func (ctx *Context) ModifyPath(p string) {
 }
 
 // Scheme returns request scheme as "http" or "https".
+// The Forwarded and X-Forwarded-Proto headers are used only if the peer is a
+// trusted proxy, refer to the config item `trusted_proxies`.
 func (ctx *Context) Scheme() string {
-	if scheme := ctx.HeaderParam(HeaderXForwardedProto); scheme != "" {
-		return scheme
+	if ctx.fromTrustedProxy() {
+		if _, proto := ctx.forwardedClient(); proto != "" {
+			return proto
+		}
+		if scheme := ctx.HeaderParam(HeaderXForwardedProto); scheme != "" {
+			return strings.ToLower(strings.TrimSpace(strings.Split(scheme, ",")[0]))
+		}
 	}
 	if ctx.R.URL.Scheme != "" {
 		return ctx.R.URL.Scheme
@@ -135,38 +143,56 @@ func portString(hostport string) string {
 
 // IP gets just the ip from the most direct one client.
 func (ctx *Context) IP() string {
-	ip := strings.Split(ctx.R.RemoteAddr, ":")[0]
-	if len(ip) == 0 {
-		return ""
-	}
-	if ip[0] != '[' {
-		return ip
+	ip, _, err := net.SplitHostPort(ctx.R.RemoteAddr)
+	if err != nil {
+		return ctx.R.RemoteAddr
 	}
-	return "127.0.0.1"
+	return ip
 }
 
 // RealIP returns request client ip.
-// if in proxy, return first proxy id.
-// if error, return 127.0.0.1.
+// If the most direct one client is a trusted proxy, refer to the config item
+// `trusted_proxies`, it returns the nearest untrusted IP of the Forwarded or
+// X-Forwarded-For chain, walked from the right, or the IP of X-Real-IP if there
+// is no chain; otherwise it returns IP().
 func (ctx *Context) RealIP() string {
-	var ip = ctx.R.Header.Get(HeaderXRealIP)
-	if len(ip) > 0 {
+	if !ctx.fromTrustedProxy() {
+		return ctx.IP()
+	}
+	if ip, _ := ctx.forwardedClient(); ip != "" {
 		return ip
 	}
-	ips := ctx.Proxy()
-	if len(ips) > 0 && ips[0] != "" {
-		ip = strings.Split(ips[0], ":")[0]
-		if len(ip) == 0 {
-			return ""
-		}
-		if ip[0] != '[' {
-			return ip
-		}
-		return "127.0.0.1"
+	if chain := ctx.Proxy(); len(chain) > 0 {
+		return ctx.frame.walkProxies(ctx.IP(), chain)
+	}
+	if ip := strings.TrimSpace(ctx.R.Header.Get(HeaderXRealIP)); net.ParseIP(ip) != nil {
+		return ip
 	}
 	return ctx.IP()
 }
 
+// fromTrustedProxy reports whether the most direct one client is a trusted proxy.
+func (ctx *Context) fromTrustedProxy() bool {
+	return ctx.frame.isTrustedProxy(ctx.IP())
+}
+
+// forwardedClient returns the IP and the protocol of the client in the RFC 7239
+// Forwarded headers, that is the nearest element not from a trusted proxy.
+func (ctx *Context) forwardedClient() (ip string, proto string) {
+	values := ctx.R.Header[HeaderForwarded]
+	if len(values) == 0 {
+		return "", ""
+	}
+	elems := parseForwarded(values)
+	for i := len(elems) - 1; i >= 0; i-- {
+		ip, proto = elems[i].forIP, elems[i].proto
+		if ip == "" || !ctx.frame.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return ip, proto
+}
+
 // Proxy returns proxy client ips slice.
 func (ctx *Context) Proxy() []string {
 	if ips := ctx.HeaderParam(HeaderXForwardedFor); ips != "" {
@@ -737,7 +763,7 @@ func (ctx *Context) BindXML(xmlObject interface{}) error {
 // LimitedBodyBytes returns the raw request body data as bytes.
 // Note:
 //  1.limited by maximum length;
-//  2.if frame.config.PrintBody==false and ctx.R.Body is readed, returns nil;
+//  2.if the request body is not printed (see frame.config.PrintBody) and ctx.R.Body is readed, returns nil;
 //  3.if ctx.IsUpload()==true and ctx.R.Body is readed, returns nil.
 func (ctx *Context) LimitedBodyBytes() []byte {
 	if ctx.limitedRequestBody != nil {
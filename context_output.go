@@ -368,6 +368,16 @@ func (ctx *Context) File(localFilename string, showFilename ...string) {
 
 // Render renders a template with data and sends a text/html response with status code.
 func (ctx *Context) Render(status int, name string, data Map) error {
+	if nonce, ok := ctx.Data(CSPNonceKey).(string); ok {
+		if _, ok = data[CSPNonceKey]; !ok {
+			d := make(Map, len(data)+1)
+			for k, v := range data {
+				d[k] = v
+			}
+			d[CSPNonceKey] = nonce
+			data = d
+		}
+	}
 	b, err := global.render.Render(name, data)
 	if err != nil {
 		return err
@@ -1,10 +1,17 @@
 package cron
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math/rand"
 	"runtime"
 	"sort"
+	"sync"
 	"time"
+
+	"github.com/andeya/faygo/ext/distlock"
 )
 
 // Cron keeps track of any number of entries, invoking the associated func as
@@ -14,17 +21,102 @@ type Cron struct {
 	entries  []*Entry
 	stop     chan struct{}
 	add      chan *Entry
+	remove   chan EntryID
 	snapshot chan []*Entry
 	running  bool
 	ErrorLog *log.Logger
 	location *time.Location
+
+	// HistorySize is the number of runs kept for each entry,
+	// DefaultHistorySize is used when it is 0.
+	HistorySize int
+
+	// Locker claims each scheduled run so that only one replica runs it,
+	// the runs are keyed by the entry name (or ID) and the scheduled time.
+	// LockTTL is how long a claim is kept, distlock.DefaultTTL is used when it is 0.
+	Locker  distlock.Locker
+	LockTTL time.Duration
+
+	// Store records the scheduled time of the runs, which is used to detect
+	// the runs missed while the service was down, see MisfireRunOnce.
+	Store RunStore
+
+	jobs   sync.WaitGroup
+	mu     sync.Mutex
+	nextID EntryID
+	index  map[EntryID]*Entry
 }
 
+// DefaultHistorySize is the default number of runs kept for each entry.
+const DefaultHistorySize = 10
+
+// ErrEntryNotFound is returned when the entry ID is not scheduled.
+var ErrEntryNotFound = errors.New("cron: entry not found")
+
+// EntryID identifies an entry within a Cron instance.
+type EntryID int
+
+// OverlapPolicy decides what happens when an entry is due while its previous run
+// is still running.
+type OverlapPolicy int
+
+const (
+	// OverlapAllow runs the job concurrently with the previous run (default).
+	OverlapAllow OverlapPolicy = iota
+	// OverlapSkip skips the run, which is recorded in the history as skipped.
+	OverlapSkip
+	// OverlapQueue delays the run until the previous run finishes.
+	OverlapQueue
+)
+
+// String returns the name of the policy.
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapSkip:
+		return "skip"
+	case OverlapQueue:
+		return "queue"
+	}
+	return "allow"
+}
+
+// ParseOverlapPolicy parses allow, skip or queue.
+func ParseOverlapPolicy(s string) (OverlapPolicy, error) {
+	switch s {
+	case "", "allow":
+		return OverlapAllow, nil
+	case "skip":
+		return OverlapSkip, nil
+	case "queue":
+		return OverlapQueue, nil
+	}
+	return OverlapAllow, fmt.Errorf("cron: unknown overlap policy %q", s)
+}
+
+// MisfirePolicy decides what happens to the runs missed while the service was down.
+type MisfirePolicy int
+
+const (
+	// MisfireSkip ignores the missed runs (default).
+	MisfireSkip MisfirePolicy = iota
+	// MisfireRunOnce runs the entry once when it is scheduled if a run was missed
+	// since the last run recorded by the Store of the Cron.
+	MisfireRunOnce
+)
+
 // Job is an interface for submitted cron jobs.
 type Job interface {
 	Run()
 }
 
+// ContextJob is a Job that accepts a context and reports an error.
+// The context is done when the timeout of the entry elapses,
+// and the error is recorded in the run history.
+type ContextJob interface {
+	Job
+	RunContext(ctx context.Context) error
+}
+
 // The Schedule describes a job's duty cycle.
 type Schedule interface {
 	// Return the next activation time, later than the given time.
@@ -34,6 +126,16 @@ type Schedule interface {
 
 // Entry consists of a schedule and the func to execute on that schedule.
 type Entry struct {
+	// ID is the cron-assigned ID of this entry, which may be used to look up a
+	// snapshot or remove it.
+	ID EntryID
+
+	// Name is an optional label of the entry, used by logs and the admin API.
+	Name string
+
+	// Spec is the spec string of the entry added by AddJob or AddFunc.
+	Spec string
+
 	// The schedule on which this job should be run.
 	Schedule Schedule
 
@@ -47,6 +149,87 @@ type Entry struct {
 
 	// The Job to run.
 	Job Job
+
+	// Overlap is the policy when the job is due while its previous run is still running.
+	Overlap OverlapPolicy
+
+	// Timeout is the deadline of the context passed to a ContextJob,
+	// and a run lasting longer is recorded as timed out. 0 means no timeout.
+	Timeout time.Duration
+
+	// Jitter is the maximum random delay added to each scheduled run, which
+	// spreads the load of the entries scheduled at the same time.
+	Jitter time.Duration
+
+	// Misfire is the policy for the runs missed while the service was down.
+	Misfire MisfirePolicy
+
+	// Paused reports whether the scheduled runs are suspended (snapshot only).
+	Paused bool
+
+	// Running is the number of the runs in progress or queued (snapshot only).
+	Running int
+
+	state *entryState
+}
+
+// EntryOption configures an entry when it is added.
+type EntryOption func(*Entry)
+
+// WithName sets the name of the entry.
+func WithName(name string) EntryOption {
+	return func(e *Entry) { e.Name = name }
+}
+
+// WithOverlap sets the overlap policy of the entry.
+func WithOverlap(policy OverlapPolicy) EntryOption {
+	return func(e *Entry) { e.Overlap = policy }
+}
+
+// WithTimeout sets the timeout of each run of the entry.
+func WithTimeout(timeout time.Duration) EntryOption {
+	return func(e *Entry) { e.Timeout = timeout }
+}
+
+// WithJitter sets the maximum random delay added to each scheduled run of the entry.
+func WithJitter(max time.Duration) EntryOption {
+	return func(e *Entry) { e.Jitter = max }
+}
+
+// WithMisfire sets the misfire policy of the entry.
+func WithMisfire(policy MisfirePolicy) EntryOption {
+	return func(e *Entry) { e.Misfire = policy }
+}
+
+func withSpec(spec string) EntryOption {
+	return func(e *Entry) { e.Spec = spec }
+}
+
+// Run is a record of one execution of an entry.
+type Run struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Manual   bool          `json:"manual,omitempty"`  // triggered by Trigger
+	Skipped  bool          `json:"skipped,omitempty"` // skipped by OverlapSkip
+	Error    string        `json:"error,omitempty"`
+	Panic    string        `json:"panic,omitempty"`
+	Stack    string        `json:"stack,omitempty"`
+}
+
+// entryState is the runtime state shared by an entry and its snapshots.
+type entryState struct {
+	mu      sync.Mutex
+	paused  bool
+	running int
+	history []Run
+	queue   sync.Mutex // serializes the runs of OverlapQueue
+}
+
+func (s *entryState) record(run Run, size int) {
+	s.history = append(s.history, run)
+	if n := len(s.history) - size; n > 0 {
+		s.history = append(s.history[:0], s.history[n:]...)
+	}
 }
 
 // byTime is a wrapper for sorting the entry array by time
@@ -78,11 +261,13 @@ func NewWithLocation(location *time.Location) *Cron {
 	return &Cron{
 		entries:  nil,
 		add:      make(chan *Entry),
+		remove:   make(chan EntryID),
 		stop:     make(chan struct{}),
 		snapshot: make(chan []*Entry),
 		running:  false,
 		ErrorLog: nil,
 		location: location,
+		index:    make(map[EntryID]*Entry),
 	}
 }
 
@@ -91,33 +276,131 @@ type FuncJob func()
 
 func (f FuncJob) Run() { f() }
 
+// ContextFunc is a wrapper that turns a func(context.Context) error into a cron.ContextJob.
+type ContextFunc func(ctx context.Context) error
+
+// Run runs the func without a deadline.
+func (f ContextFunc) Run() { f(context.Background()) }
+
+// RunContext runs the func.
+func (f ContextFunc) RunContext(ctx context.Context) error { return f(ctx) }
+
 // AddFunc adds a func to the Cron to be run on the given schedule.
-func (c *Cron) AddFunc(spec string, cmd func()) error {
-	return c.AddJob(spec, FuncJob(cmd))
+func (c *Cron) AddFunc(spec string, cmd func(), opts ...EntryOption) (EntryID, error) {
+	return c.AddJob(spec, FuncJob(cmd), opts...)
+}
+
+// AddContextFunc adds a func that accepts a context to the Cron to be run on the given schedule.
+func (c *Cron) AddContextFunc(spec string, cmd func(context.Context) error, opts ...EntryOption) (EntryID, error) {
+	return c.AddJob(spec, ContextFunc(cmd), opts...)
 }
 
 // AddJob adds a Job to the Cron to be run on the given schedule.
-func (c *Cron) AddJob(spec string, cmd Job) error {
+func (c *Cron) AddJob(spec string, cmd Job, opts ...EntryOption) (EntryID, error) {
 	schedule, err := Parse(spec)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	c.Schedule(schedule, cmd)
-	return nil
+	return c.Schedule(schedule, cmd, append([]EntryOption{withSpec(spec)}, opts...)...), nil
 }
 
 // Schedule adds a Job to the Cron to be run on the given schedule.
-func (c *Cron) Schedule(schedule Schedule, cmd Job) {
+func (c *Cron) Schedule(schedule Schedule, cmd Job, opts ...EntryOption) EntryID {
 	entry := &Entry{
 		Schedule: schedule,
 		Job:      cmd,
+		state:    new(entryState),
+	}
+	for _, opt := range opts {
+		opt(entry)
 	}
+	c.mu.Lock()
+	c.nextID++
+	entry.ID = c.nextID
+	c.index[entry.ID] = entry
+	c.mu.Unlock()
 	if !c.running {
 		c.entries = append(c.entries, entry)
-		return
+		return entry.ID
 	}
 
 	c.add <- entry
+	return entry.ID
+}
+
+// Remove removes the entry from being run in the future.
+func (c *Cron) Remove(id EntryID) error {
+	c.mu.Lock()
+	_, ok := c.index[id]
+	delete(c.index, id)
+	c.mu.Unlock()
+	if !ok {
+		return ErrEntryNotFound
+	}
+	if c.running {
+		c.remove <- id
+	} else {
+		c.removeEntry(id)
+	}
+	return nil
+}
+
+// Pause suspends the scheduled runs of the entry, it still can be triggered manually.
+func (c *Cron) Pause(id EntryID) error {
+	return c.setPaused(id, true)
+}
+
+// Resume resumes the scheduled runs of the paused entry.
+func (c *Cron) Resume(id EntryID) error {
+	return c.setPaused(id, false)
+}
+
+func (c *Cron) setPaused(id EntryID, paused bool) error {
+	e := c.lookup(id)
+	if e == nil {
+		return ErrEntryNotFound
+	}
+	e.state.mu.Lock()
+	e.state.paused = paused
+	e.state.mu.Unlock()
+	return nil
+}
+
+// Trigger runs the entry immediately in its own goroutine, following its overlap policy.
+func (c *Cron) Trigger(id EntryID) error {
+	e := c.lookup(id)
+	if e == nil {
+		return ErrEntryNotFound
+	}
+	c.startJob(e, true)
+	return nil
+}
+
+// History returns the recent runs of the entry, the oldest first.
+func (c *Cron) History(id EntryID) ([]Run, error) {
+	e := c.lookup(id)
+	if e == nil {
+		return nil, ErrEntryNotFound
+	}
+	e.state.mu.Lock()
+	defer e.state.mu.Unlock()
+	return append([]Run{}, e.state.history...), nil
+}
+
+// Entry returns a snapshot of the entry, or nil if it is not found.
+func (c *Cron) Entry(id EntryID) *Entry {
+	for _, e := range c.Entries() {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+func (c *Cron) lookup(id EntryID) *Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.index[id]
 }
 
 // Entries returns a snapshot of the cron entries.
@@ -153,16 +436,167 @@ func (c *Cron) Run() {
 	c.run()
 }
 
-func (c *Cron) runWithRecovery(j Job) {
+// runScheduled starts the scheduled run of the entry after recording it in the Store,
+// claiming it with the Locker and waiting for the jitter, if they are set.
+func (c *Cron) runScheduled(e *Entry, scheduled time.Time) {
+	e.state.mu.Lock()
+	paused := e.state.paused
+	e.state.mu.Unlock()
+	if paused {
+		// leave the run to the other replicas
+		return
+	}
+	if c.Locker == nil && c.Store == nil && e.Jitter <= 0 {
+		c.startJob(e, false)
+		return
+	}
+	c.jobs.Add(1)
+	go func() {
+		defer c.jobs.Done()
+		if c.Store != nil {
+			if err := c.Store.SaveRun(e.label(), scheduled); err != nil {
+				c.logf("cron: unable to record the run of job %s: %v", e.label(), err)
+			}
+		}
+		if c.Locker != nil {
+			ok, err := c.Locker.TryLock(e.label(), lockTime(e.Schedule, scheduled), c.LockTTL)
+			if err != nil {
+				c.logf("cron: unable to claim the run of job %s: %v", e.label(), err)
+				return
+			}
+			if !ok {
+				return
+			}
+		}
+		if e.Jitter > 0 {
+			time.Sleep(time.Duration(rand.Int63n(int64(e.Jitter))))
+		}
+		c.startJob(e, false)
+	}()
+}
+
+// maxMissedRuns bounds the search of the latest missed run.
+const maxMissedRuns = 1 << 20
+
+// checkMisfire runs the entry once if it follows MisfireRunOnce and a run was
+// missed since the last run recorded by the Store.
+func (c *Cron) checkMisfire(e *Entry, now time.Time) {
+	if e.Misfire != MisfireRunOnce || c.Store == nil {
+		return
+	}
+	c.jobs.Add(1)
+	go func() {
+		defer c.jobs.Done()
+		last, ok, err := c.Store.LastRun(e.label())
+		if err != nil {
+			c.logf("cron: unable to get the last run of job %s: %v", e.label(), err)
+			return
+		}
+		if !ok {
+			return
+		}
+		missed := e.Schedule.Next(last)
+		if missed.IsZero() || !missed.Before(now) {
+			return
+		}
+		// the run keyed by the latest missed time stands for all of them
+		for i := 0; i < maxMissedRuns; i++ {
+			next := e.Schedule.Next(missed)
+			if next.IsZero() || !next.Before(now) {
+				break
+			}
+			missed = next
+		}
+		c.logf("cron: job %s missed the run at %s, running it now", e.label(), missed)
+		c.runScheduled(e, missed)
+	}()
+}
+
+// lockTime returns the time that keys a run. The runs of @every are not aligned
+// between the replicas, so they are keyed by the interval they fall in.
+func lockTime(schedule Schedule, scheduled time.Time) time.Time {
+	if s, ok := schedule.(ConstantDelaySchedule); ok && s.Delay > 0 {
+		return scheduled.Truncate(s.Delay)
+	}
+	return scheduled
+}
+
+// startJob runs the entry in its own goroutine unless the overlap policy skips it.
+func (c *Cron) startJob(e *Entry, manual bool) {
+	s := e.state
+	s.mu.Lock()
+	if s.paused && !manual {
+		s.mu.Unlock()
+		return
+	}
+	if e.Overlap == OverlapSkip && s.running > 0 {
+		s.record(Run{Start: c.now(), Manual: manual, Skipped: true}, c.historySize())
+		s.mu.Unlock()
+		c.logf("cron: skip job %s, the previous run is still running", e.label())
+		return
+	}
+	s.running++
+	s.mu.Unlock()
+	c.jobs.Add(1)
+	go func() {
+		defer c.jobs.Done()
+		if e.Overlap == OverlapQueue {
+			s.queue.Lock()
+			defer s.queue.Unlock()
+		}
+		run := c.runWithRecovery(e, manual)
+		s.mu.Lock()
+		s.running--
+		s.record(run, c.historySize())
+		s.mu.Unlock()
+	}()
+}
+
+func (c *Cron) runWithRecovery(e *Entry, manual bool) (run Run) {
+	run = Run{Start: time.Now().In(c.location), Manual: manual}
+	ctx := context.Background()
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
 	defer func() {
+		run.Duration = time.Since(run.Start)
 		if r := recover(); r != nil {
 			const size = 64 << 10
 			buf := make([]byte, size)
 			buf = buf[:runtime.Stack(buf, false)]
+			run.Panic, run.Stack = fmt.Sprint(r), string(buf)
 			c.logf("cron: panic running job: %v\n%s", r, buf)
 		}
 	}()
-	j.Run()
+	var err error
+	if j, ok := e.Job.(ContextJob); ok {
+		err = j.RunContext(ctx)
+	} else {
+		e.Job.Run()
+	}
+	if err == nil && ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("cron: job timed out after %s", e.Timeout)
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	return
+}
+
+func (c *Cron) historySize() int {
+	if c.HistorySize > 0 {
+		return c.HistorySize
+	}
+	return DefaultHistorySize
+}
+
+func (e *Entry) label() string {
+	if len(e.Name) > 0 {
+		return e.Name
+	}
+	return fmt.Sprint(e.ID)
 }
 
 // Run the scheduler. this is private just due to the need to synchronize
@@ -172,6 +606,7 @@ func (c *Cron) run() {
 	now := c.now()
 	for _, entry := range c.entries {
 		entry.Next = entry.Schedule.Next(now)
+		c.checkMisfire(entry, now)
 	}
 
 	for {
@@ -196,7 +631,7 @@ func (c *Cron) run() {
 					if e.Next.After(now) || e.Next.IsZero() {
 						break
 					}
-					go c.runWithRecovery(e.Job)
+					c.runScheduled(e, e.Next)
 					e.Prev = e.Next
 					e.Next = e.Schedule.Next(now)
 				}
@@ -206,6 +641,12 @@ func (c *Cron) run() {
 				now = c.now()
 				newEntry.Next = newEntry.Schedule.Next(now)
 				c.entries = append(c.entries, newEntry)
+				c.checkMisfire(newEntry, now)
+
+			case id := <-c.remove:
+				timer.Stop()
+				now = c.now()
+				c.removeEntry(id)
 
 			case <-c.snapshot:
 				c.snapshot <- c.entrySnapshot()
@@ -230,6 +671,11 @@ func (c *Cron) logf(format string, args ...interface{}) {
 	}
 }
 
+// Wait waits for the running jobs to finish, it is usually called after Stop.
+func (c *Cron) Wait() {
+	c.jobs.Wait()
+}
+
 // Stop stops the cron scheduler if it is running; otherwise it does nothing.
 func (c *Cron) Stop() {
 	if !c.running {
@@ -243,16 +689,39 @@ func (c *Cron) Stop() {
 func (c *Cron) entrySnapshot() []*Entry {
 	entries := []*Entry{}
 	for _, e := range c.entries {
+		e.state.mu.Lock()
+		paused, running := e.state.paused, e.state.running
+		e.state.mu.Unlock()
 		entries = append(entries, &Entry{
+			ID:       e.ID,
+			Name:     e.Name,
+			Spec:     e.Spec,
 			Schedule: e.Schedule,
 			Next:     e.Next,
 			Prev:     e.Prev,
 			Job:      e.Job,
+			Overlap:  e.Overlap,
+			Timeout:  e.Timeout,
+			Jitter:   e.Jitter,
+			Misfire:  e.Misfire,
+			Paused:   paused,
+			Running:  running,
+			state:    e.state,
 		})
 	}
 	return entries
 }
 
+func (c *Cron) removeEntry(id EntryID) {
+	var entries []*Entry
+	for _, e := range c.entries {
+		if e.ID != id {
+			entries = append(entries, e)
+		}
+	}
+	c.entries = entries
+}
+
 // now returns current time in c location
 func (c *Cron) now() time.Time {
 	return time.Now().In(c.location).Round(time.Second)
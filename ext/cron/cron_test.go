@@ -1,10 +1,16 @@
 package cron
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/andeya/faygo/ext/distlock"
 )
 
 // Many tests schedule a job for every second, and then wait at most a second
@@ -336,6 +342,230 @@ func TestJobWithZeroTimeDoesNotRun(t *testing.T) {
 	}
 }
 
+func TestRemoveAndPause(t *testing.T) {
+	var removed, paused int32
+	cron := New()
+	id, _ := cron.AddFunc("* * * * * ?", func() { atomic.AddInt32(&removed, 1) })
+	pid, _ := cron.AddFunc("* * * * * ?", func() { atomic.AddInt32(&paused, 1) })
+	if err := cron.Pause(pid); err != nil {
+		t.Fatal(err)
+	}
+	cron.Start()
+	defer cron.Stop()
+	if err := cron.Remove(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := cron.Remove(id); err != ErrEntryNotFound {
+		t.Fatalf("remove twice: got %v", err)
+	}
+	<-time.After(OneSecond)
+	if atomic.LoadInt32(&removed) != 0 || atomic.LoadInt32(&paused) != 0 {
+		t.Fatalf("removed or paused entry ran: %d, %d", removed, paused)
+	}
+	if len(cron.Entries()) != 1 || !cron.Entry(pid).Paused {
+		t.Fatalf("entries: got %+v", cron.Entries())
+	}
+	// A paused entry still can be triggered manually.
+	if err := cron.Trigger(pid); err != nil {
+		t.Fatal(err)
+	}
+	cron.Resume(pid)
+	<-time.After(OneSecond)
+	if n := atomic.LoadInt32(&paused); n < 2 {
+		t.Fatalf("resumed entry: ran %d times, expected at least 2", n)
+	}
+}
+
+func TestOverlapPolicy(t *testing.T) {
+	for _, policy := range []OverlapPolicy{OverlapAllow, OverlapSkip, OverlapQueue} {
+		var running, max int32
+		cron := New()
+		id := cron.Schedule(Every(time.Hour), FuncJob(func() {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&max)
+				if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+					break
+				}
+			}
+			time.Sleep(100 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}), WithOverlap(policy))
+		cron.Trigger(id)
+		time.Sleep(10 * time.Millisecond)
+		cron.Trigger(id)
+		time.Sleep(300 * time.Millisecond)
+
+		history, _ := cron.History(id)
+		var skipped int
+		for _, run := range history {
+			if run.Skipped {
+				skipped++
+			}
+		}
+		switch policy {
+		case OverlapAllow:
+			if max != 2 || len(history) != 2 {
+				t.Errorf("allow: got %d concurrent runs and %d records", max, len(history))
+			}
+		case OverlapSkip:
+			if max != 1 || skipped != 1 || len(history) != 2 {
+				t.Errorf("skip: got %d concurrent runs and %d skipped of %d records", max, skipped, len(history))
+			}
+		case OverlapQueue:
+			if max != 1 || skipped != 0 || len(history) != 2 {
+				t.Errorf("queue: got %d concurrent runs and %d skipped of %d records", max, skipped, len(history))
+			}
+		}
+	}
+}
+
+func TestTimeoutAndHistory(t *testing.T) {
+	cron := New()
+	cron.HistorySize = 2
+	id, err := cron.AddContextFunc("@every 1h", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, WithTimeout(20*time.Millisecond), WithName("wait"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fid := cron.Schedule(Every(time.Hour), ContextFunc(func(context.Context) error {
+		return errors.New("failed")
+	}))
+	pid := cron.Schedule(Every(time.Hour), FuncJob(func() { panic("YOLO") }))
+	for i := 0; i < 3; i++ {
+		cron.Trigger(id)
+	}
+	cron.Trigger(fid)
+	cron.Trigger(pid)
+	time.Sleep(100 * time.Millisecond)
+
+	history, _ := cron.History(id)
+	if len(history) != 2 {
+		t.Fatalf("history: got %d runs, expected 2", len(history))
+	}
+	for _, run := range history {
+		if run.Error != context.DeadlineExceeded.Error() || !run.Manual || run.Duration < 20*time.Millisecond {
+			t.Errorf("timed out run: got %+v", run)
+		}
+	}
+	if history, _ = cron.History(fid); len(history) != 1 || history[0].Error != "failed" {
+		t.Errorf("failed run: got %+v", history)
+	}
+	if history, _ = cron.History(pid); len(history) != 1 || history[0].Panic != "YOLO" || len(history[0].Stack) == 0 {
+		t.Errorf("panic run: got %+v", history)
+	}
+	if e := cron.Entry(id); e == nil || e.Name != "wait" || e.Spec != "@every 1h" || e.Timeout != 20*time.Millisecond {
+		t.Errorf("entry: got %+v", e)
+	}
+	if _, err = cron.History(100); err != ErrEntryNotFound {
+		t.Errorf("unknown entry: got %v", err)
+	}
+}
+
+// Two replicas sharing a locker run each scheduled time once.
+func TestLocker(t *testing.T) {
+	var mu sync.Mutex
+	claims := map[string]bool{}
+	locker := distlock.LockerFunc(func(name string, scheduled time.Time, ttl time.Duration) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		key := distlock.Key(name, scheduled)
+		if claims[key] {
+			return false, nil
+		}
+		claims[key] = true
+		return true, nil
+	})
+	var calls int32
+	for i := 0; i < 2; i++ {
+		cron := New()
+		cron.Locker = locker
+		cron.AddFunc("* * * * * ?", func() { atomic.AddInt32(&calls, 1) }, WithName("job"))
+		cron.Start()
+		defer cron.Stop()
+	}
+	<-time.After(OneSecond + 500*time.Millisecond)
+	mu.Lock()
+	n := len(claims)
+	mu.Unlock()
+	if n == 0 || int(atomic.LoadInt32(&calls)) != n {
+		t.Fatalf("called %d times for %d scheduled runs", calls, n)
+	}
+}
+
+func TestLockTime(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 59, 0, time.UTC)
+	if got := lockTime(Every(time.Minute), at); !got.Equal(at.Truncate(time.Minute)) {
+		t.Errorf("@every: got %s", got)
+	}
+	spec, _ := Parse("* * * * * ?")
+	if got := lockTime(spec, at); !got.Equal(at) {
+		t.Errorf("spec: got %s", got)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	start := make(chan time.Time, 1)
+	cron := New()
+	cron.AddFunc("* * * * * ?", func() {
+		select {
+		case start <- time.Now():
+		default:
+		}
+	}, WithJitter(500*time.Millisecond))
+	cron.Start()
+	defer cron.Stop()
+	select {
+	case at := <-start:
+		if delay := at.Sub(at.Truncate(time.Second)); delay > 600*time.Millisecond {
+			t.Errorf("delayed %s, expected less than the jitter", delay)
+		}
+	case <-time.After(2 * OneSecond):
+		t.Fatal("expected job runs")
+	}
+}
+
+// A run missed while the cron was stopped is run once on start.
+func TestMisfireRunOnce(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "runs.json"))
+	if err := store.SaveRun("report", time.Now().Add(-3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var calls, skipped int32
+	cron := New()
+	cron.Store = store
+	cron.AddFunc("@hourly", func() { atomic.AddInt32(&calls, 1) }, WithName("report"), WithMisfire(MisfireRunOnce))
+	cron.AddFunc("@hourly", func() { atomic.AddInt32(&skipped, 1) }, WithName("other"), WithMisfire(MisfireRunOnce))
+	cron.Start()
+	<-time.After(200 * time.Millisecond)
+	cron.Stop()
+	cron.Wait()
+	if calls != 1 || skipped != 0 {
+		t.Fatalf("called %d and %d times, expected 1 and 0", calls, skipped)
+	}
+	// the latest missed run is recorded, so it is not run again
+	last, ok, err := NewFileStore(store.path).LastRun("report")
+	if err != nil || !ok || time.Since(last) > time.Hour {
+		t.Fatalf("last run: got %s, %v, %v", last, ok, err)
+	}
+}
+
+func TestWait(t *testing.T) {
+	var done int32
+	cron := New()
+	id := cron.Schedule(Every(time.Hour), FuncJob(func() {
+		time.Sleep(100 * time.Millisecond)
+		atomic.StoreInt32(&done, 1)
+	}))
+	cron.Trigger(id)
+	cron.Wait()
+	if atomic.LoadInt32(&done) != 1 {
+		t.Fatal("expected Wait returns after the job finishes")
+	}
+}
+
 func wait(wg *sync.WaitGroup) chan bool {
 	ch := make(chan bool)
 	go func() {
@@ -0,0 +1,104 @@
+// Package cronadmin provides the admin APIs to inspect and trigger the entries of a cron.
+package cronadmin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/ext/cron"
+)
+
+// entryInfo is the JSON view of an entry for the admin API.
+type entryInfo struct {
+	ID      cron.EntryID `json:"id"`
+	Name    string       `json:"name,omitempty"`
+	Spec    string       `json:"spec,omitempty"`
+	Next    time.Time    `json:"next"`
+	Prev    time.Time    `json:"prev"`
+	Overlap string       `json:"overlap"`
+	Timeout string       `json:"timeout,omitempty"`
+	Paused  bool         `json:"paused"`
+	Running int          `json:"running"`
+	History []cron.Run   `json:"history,omitempty"`
+}
+
+func newEntryInfo(e *cron.Entry) *entryInfo {
+	info := &entryInfo{
+		ID:      e.ID,
+		Name:    e.Name,
+		Spec:    e.Spec,
+		Next:    e.Next,
+		Prev:    e.Prev,
+		Overlap: e.Overlap.String(),
+		Paused:  e.Paused,
+		Running: e.Running,
+	}
+	if e.Timeout > 0 {
+		info.Timeout = e.Timeout.String()
+	}
+	return info
+}
+
+// Register registers the APIs to inspect and trigger the entries under the group:
+//
+//	GET  /entries              the entries
+//	GET  /entries/:id          the entry with its run history
+//	POST /entries/:id/trigger  runs the entry now
+//	POST /entries/:id/pause    pauses the scheduled runs
+//	POST /entries/:id/resume   resumes the scheduled runs
+//
+// The group should be protected, such as:
+//
+//	cronadmin.Register(frame.NamedGroup("CronAdmin", "/admin/cron", middleware.NewIPFilter(whitelist, true)), c)
+func Register(group *faygo.MuxAPI, c *cron.Cron) *faygo.MuxAPI {
+	group.NamedGET("CronAdmin-Entries", "/entries", entriesHandler(c))
+	group.NamedGET("CronAdmin-Entry", "/entries/:id", entryHandler(c))
+	group.NamedPOST("CronAdmin-Trigger", "/entries/:id/trigger", actionHandler(c, "trigger", c.Trigger))
+	group.NamedPOST("CronAdmin-Pause", "/entries/:id/pause", actionHandler(c, "pause", c.Pause))
+	group.NamedPOST("CronAdmin-Resume", "/entries/:id/resume", actionHandler(c, "resume", c.Resume))
+	return group
+}
+
+func entriesHandler(c *cron.Cron) faygo.HandlerFunc {
+	return func(ctx *faygo.Context) error {
+		infos := []*entryInfo{}
+		for _, e := range c.Entries() {
+			infos = append(infos, newEntryInfo(e))
+		}
+		return ctx.JSON(http.StatusOK, infos, true)
+	}
+}
+
+func entryHandler(c *cron.Cron) faygo.HandlerFunc {
+	return func(ctx *faygo.Context) error {
+		e := c.Entry(pathEntryID(ctx))
+		if e == nil {
+			return ctx.JSONMsg(http.StatusNotFound, http.StatusNotFound, cron.ErrEntryNotFound.Error())
+		}
+		info := newEntryInfo(e)
+		info.History, _ = c.History(e.ID)
+		return ctx.JSON(http.StatusOK, info, true)
+	}
+}
+
+func actionHandler(c *cron.Cron, action string, fn func(cron.EntryID) error) faygo.HandlerFunc {
+	return func(ctx *faygo.Context) error {
+		id := pathEntryID(ctx)
+		if err := fn(id); err != nil {
+			return ctx.JSONMsg(http.StatusNotFound, http.StatusNotFound, err.Error())
+		}
+		faygo.Warningf("[CronAdmin] %s %s entry: %d", ctx.IP(), action, id)
+		e := c.Entry(id)
+		if e == nil {
+			return ctx.JSONMsg(http.StatusNotFound, http.StatusNotFound, cron.ErrEntryNotFound.Error())
+		}
+		return ctx.JSON(http.StatusOK, newEntryInfo(e), true)
+	}
+}
+
+func pathEntryID(ctx *faygo.Context) cron.EntryID {
+	id, _ := strconv.Atoi(ctx.PathParam("id"))
+	return cron.EntryID(id)
+}
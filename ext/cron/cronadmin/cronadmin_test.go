@@ -0,0 +1,131 @@
+package cronadmin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/ext/cron"
+)
+
+// startAdmin runs a faygo application serving the admin APIs of c under /admin/cron.
+func startAdmin(t *testing.T, c *cron.Cron) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	config := faygo.NewDefaultConfig()
+	config.Addrs = []string{addr}
+	config.APIdoc.Enable = false
+	frame := faygo.NewWithConfig(config, "cronadmin-test")
+	Register(frame.NamedGroup("CronAdmin", "/admin/cron"), c)
+	go frame.Run()
+	for i := 0; i < 50; i++ {
+		if c, err := net.Dial("tcp", addr); err == nil {
+			c.Close()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return "http://" + addr + "/admin/cron"
+}
+
+func call(t *testing.T, method, u string, v interface{}) int {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusOK && v != nil {
+		if err = json.Unmarshal(body, v); err != nil {
+			t.Fatalf("%s %s: %v: %s", method, u, err, body)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAdmin(t *testing.T) {
+	c := cron.New()
+	runs := make(chan struct{}, 1)
+	id, err := c.AddFunc("@every 1h", func() { runs <- struct{}{} }, cron.WithName("report"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.AddFunc("@daily", func() {}); err != nil {
+		t.Fatal(err)
+	}
+	admin := startAdmin(t, c)
+
+	var list []entryInfo
+	if code := call(t, "GET", admin+"/entries", &list); code != http.StatusOK || len(list) != 2 {
+		t.Fatalf("expected the 2 entries, got %d %v", code, list)
+	}
+
+	var info entryInfo
+	if code := call(t, "GET", admin+"/entries/1", &info); code != http.StatusOK || info.ID != id || info.Name != "report" || info.Spec != "@every 1h" {
+		t.Fatalf("expected the entry %d, got %d %+v", id, code, info)
+	}
+
+	if code := call(t, "POST", admin+"/entries/1/trigger", &info); code != http.StatusOK || info.ID != id {
+		t.Fatalf("expected the entry triggered, got %d %+v", code, info)
+	}
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("expected the triggered entry to run")
+	}
+	var history []cron.Run
+	for i := 0; i < 50 && len(history) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		history, _ = c.History(id)
+	}
+	if len(history) != 1 || !history[0].Manual {
+		t.Fatalf("expected a manual run recorded, got %+v", history)
+	}
+	if code := call(t, "GET", admin+"/entries/1", &info); code != http.StatusOK || len(info.History) != 1 {
+		t.Fatalf("expected the run history of the entry, got %d %+v", code, info)
+	}
+
+	if code := call(t, "POST", admin+"/entries/1/pause", &info); code != http.StatusOK || !info.Paused {
+		t.Fatalf("expected the entry paused, got %d %+v", code, info)
+	}
+	if e := c.Entry(id); e == nil || !e.Paused {
+		t.Fatal("expected the cron entry paused")
+	}
+	if code := call(t, "POST", admin+"/entries/1/resume", &info); code != http.StatusOK || info.Paused {
+		t.Fatalf("expected the entry resumed, got %d %+v", code, info)
+	}
+	if e := c.Entry(id); e == nil || e.Paused {
+		t.Fatal("expected the cron entry resumed")
+	}
+
+	// unknown IDs
+	for _, test := range []struct{ method, path string }{
+		{"GET", "/entries/100"},
+		{"GET", "/entries/abc"},
+		{"POST", "/entries/100/trigger"},
+		{"POST", "/entries/100/pause"},
+		{"POST", "/entries/100/resume"},
+	} {
+		if code := call(t, test.method, admin+test.path, nil); code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404, got %d", test.method, test.path, code)
+		}
+	}
+	select {
+	case <-runs:
+		t.Fatal("expected no run of an unknown entry")
+	default:
+	}
+}
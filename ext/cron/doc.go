@@ -99,14 +99,71 @@ Note: The interval does not take the job runtime into account.  For example,
 if a job takes 3 minutes to run, and it is scheduled to run every 5 minutes,
 it will have only 2 minutes of idle time between each run.
 
+Managing entries
+
+AddFunc, AddJob and Schedule return an EntryID, which may be used to Remove,
+Pause, Resume or Trigger the entry, and to query its recent runs:
+
+	id, _ := c.AddContextFunc("@every 1m", func(ctx context.Context) error {
+		return report(ctx)
+	}, cron.WithName("report"), cron.WithTimeout(30*time.Second), cron.WithOverlap(cron.OverlapSkip))
+	..
+	c.Pause(id)
+	c.Trigger(id) // a paused entry still can be triggered manually
+	runs, _ := c.History(id)
+
+The overlap policy decides what happens when an entry is due while its previous
+run is still running: OverlapAllow runs it concurrently (the default), OverlapSkip
+skips it and OverlapQueue delays it until the previous run finishes.
+
+The context passed to a ContextJob is done when the timeout of the entry elapses.
+Jobs are never killed, a plain Job that runs longer than its timeout is only
+recorded as timed out.
+
+Each entry keeps the last HistorySize runs (DefaultHistorySize by default), with
+the start time, duration, error, panic and its stack. The package cronadmin
+provides the faygo admin APIs to inspect and trigger the entries.
+
+Replicas
+
+When several replicas of a service run the same entries, set a distlock.Locker
+so that each scheduled run is claimed by only one of them:
+
+	c.Locker = distlock.NewRedisLocker("127.0.0.1:6379")
+	c.AddFunc("0 0 3 * * *", cleanup, cron.WithName("cleanup"))
+
+The runs are keyed by the entry name (the ID when it has no name) and the
+scheduled time, so the entries should be named. The runs of @every are not
+aligned between the replicas, they are keyed by the interval they fall in.
+Paused entries do not claim their runs, and manual triggers are not claimed.
+
 Time zones
 
 All interpretation and scheduling is done in the machine's local time zone (as
-provided by the Go time package (http://www.golang.org/pkg/time).
+provided by the Go time package (http://www.golang.org/pkg/time), or in the
+location given to NewWithLocation. A spec may choose its own time zone with a
+CRON_TZ= (or TZ=) prefix:
+
+	c.AddFunc("CRON_TZ=Asia/Shanghai 0 30 9 * * *", report)
 
 Be aware that jobs scheduled during daylight-savings leap-ahead transitions will
 not be run!
 
+Jitter and misfires
+
+WithJitter delays each scheduled run by a random duration up to the given one,
+so that the replicas or the entries due at the same second do not all start at
+once. The jitter is applied after the run is claimed by the Locker.
+
+WithMisfire(MisfireRunOnce) runs the entry once when the Cron starts if a run was
+missed while the process was down. The missed runs are found from the last
+scheduled run saved in the Store of the Cron, so the entry must be named:
+
+	c.Store = cron.NewFileStore("cron.json")
+	c.AddFunc("@daily", backup, cron.WithName("backup"), cron.WithMisfire(cron.MisfireRunOnce))
+
+Stop does not wait for the running jobs, call Wait after it to let them finish.
+
 Thread safety
 
 Since the Cron service runs concurrently with the calling code, some amount of
@@ -75,10 +75,16 @@ func NewParser(options ParseOption) Parser {
 // Parse returns a new crontab schedule representing the given spec.
 // It returns a descriptive error if the spec is not valid.
 // It accepts crontab specs and features configured by NewParser.
+//
+// The spec may start with a time zone, such as "CRON_TZ=Asia/Shanghai 0 30 9 * * *",
+// (or "TZ=..."), otherwise the schedule uses the time zone of the Cron.
 func (p Parser) Parse(spec string) (Schedule, error) {
 	if len(spec) == 0 {
 		return nil, fmt.Errorf("Empty spec string")
 	}
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		return p.parseWithLocation(spec)
+	}
 	if spec[0] == '@' && p.options&Descriptor > 0 {
 		return parseDescriptor(spec)
 	}
@@ -138,6 +144,46 @@ func (p Parser) Parse(spec string) (Schedule, error) {
 	}, nil
 }
 
+// parseWithLocation parses the spec with a CRON_TZ= or TZ= prefix.
+func (p Parser) parseWithLocation(spec string) (Schedule, error) {
+	i := strings.IndexAny(spec, " \t")
+	if i < 0 {
+		return nil, fmt.Errorf("Missing schedule after the time zone: %s", spec)
+	}
+	name := spec[strings.IndexByte(spec, '=')+1 : i]
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("Provided bad location %s: %v", name, err)
+	}
+	schedule, err := p.Parse(strings.TrimSpace(spec[i:]))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := schedule.(ConstantDelaySchedule); ok {
+		return schedule, nil
+	}
+	return InLocation(schedule, loc), nil
+}
+
+// InLocation returns the schedule interpreted in the time zone loc,
+// regardless of the time zone of the Cron.
+func InLocation(schedule Schedule, loc *time.Location) Schedule {
+	return locationSchedule{schedule, loc}
+}
+
+type locationSchedule struct {
+	Schedule
+	location *time.Location
+}
+
+func (s locationSchedule) Next(t time.Time) time.Time {
+	next := s.Schedule.Next(t.In(s.location))
+	if next.IsZero() {
+		return next
+	}
+	return next.In(t.Location())
+}
+
 func expandFields(fields []string, options ParseOption) []string {
 	n := 0
 	count := len(fields)
@@ -232,3 +232,34 @@ func TestStandardSpecSchedule(t *testing.T) {
 		}
 	}
 }
+
+func TestParseLocation(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	utc := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, spec := range []string{"CRON_TZ=Asia/Shanghai 0 30 9 * * *", "TZ=Asia/Shanghai 0 30 9 * * *"} {
+		sched, err := Parse(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 09:30 in Shanghai is 01:30 UTC
+		next := sched.Next(utc)
+		if !next.Equal(time.Date(2026, 1, 1, 9, 30, 0, 0, shanghai)) || next.Location() != time.UTC {
+			t.Errorf("%s => got %s", spec, next)
+		}
+	}
+	sched, err := ParseStandard("CRON_TZ=Asia/Shanghai @daily")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := sched.Next(utc); !next.Equal(time.Date(2026, 1, 2, 0, 0, 0, 0, shanghai)) {
+		t.Errorf("@daily => got %s", next)
+	}
+	if sched, _ = Parse("CRON_TZ=Asia/Shanghai @every 1m"); sched != Every(time.Minute) {
+		t.Errorf("@every => got %v", sched)
+	}
+	for _, spec := range []string{"CRON_TZ=Mars/Base 0 * * * * *", "CRON_TZ=UTC"} {
+		if _, err = Parse(spec); err == nil {
+			t.Errorf("%s => expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package cron
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RunStore records the scheduled time of the last run of the entries, keyed by
+// the entry name (or ID), so that the runs missed while the service was down can
+// be detected when it starts again.
+type RunStore interface {
+	// LastRun returns the scheduled time of the last run, ok is false if none is recorded.
+	LastRun(name string) (scheduled time.Time, ok bool, err error)
+	// SaveRun records the scheduled time of a run.
+	SaveRun(name string, scheduled time.Time) error
+}
+
+// FileStore is a RunStore that keeps the runs in a JSON file.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+	runs map[string]time.Time
+}
+
+// NewFileStore creates a FileStore of the file path, which is created on the first run.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// LastRun returns the scheduled time of the last run of the entry name.
+func (s *FileStore) LastRun(name string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return time.Time{}, false, err
+	}
+	t, ok := s.runs[name]
+	return t, ok, nil
+}
+
+// SaveRun records the scheduled time of a run of the entry name.
+func (s *FileStore) SaveRun(name string, scheduled time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	if !scheduled.After(s.runs[name]) {
+		return nil
+	}
+	s.runs[name] = scheduled
+	b, err := json.Marshal(s.runs)
+	if err != nil {
+		return err
+	}
+	// write a temporary file and rename it, so that the file is never partially written
+	tmp := s.path + ".tmp"
+	if err = os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *FileStore) load() error {
+	if s.runs != nil {
+		return nil
+	}
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		s.runs = make(map[string]time.Time)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	runs := make(map[string]time.Time)
+	if err = json.Unmarshal(b, &runs); err != nil {
+		return err
+	}
+	s.runs = runs
+	return nil
+}
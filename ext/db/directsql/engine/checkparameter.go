@@ -9,7 +9,7 @@
           2017.05.28
 		  - 增加主从表关联id的服务端处理。
 */
-package directsql
+package engine
 
 import (
 	"errors"
@@ -109,6 +109,13 @@ func dealwithParameter(paras []*TSqlParameter, mp map[string]interface{}, ctx *f
 	result = make(map[string]interface{})
 	// 循环处理参数
 	for _, para := range paras {
+		// 强制参数忽略客户端提交的值，总是使用服务端的默认值
+		if para.Enforce {
+			delete(mp, para.Name)
+			if para.Default == DT_UNDEFINED {
+				return nil, errors.New("错误：强制参数[" + para.Name + "]未定义默认值！")
+			}
+		}
 		// 默认值处理，存在就不处理使用存在的值，不存在就增加并返回给客户端
 		_, exists := mp[para.Name]
 		// 不是从客户的传入的并且有默认值设置
@@ -142,6 +149,10 @@ func dealwithParameter(paras []*TSqlParameter, mp map[string]interface{}, ctx *f
 				mp[para.Name] = ctx.Data("__directsql__parentid")
 				faygo.Debug("Read parentid value:", mp[para.Name])
 			}
+			// 强制参数无法获取服务端的值（例如用户未登录）则不能执行
+			if para.Enforce && mp[para.Name] == nil {
+				return nil, errors.New("错误：强制参数[" + para.Name + "]无法获取服务端的值！")
+			}
 			// 如果需要返回
 			if para.Return {
 				result[para.Name] = mp[para.Name]
@@ -230,3 +241,15 @@ func dealwithParameter(paras []*TSqlParameter, mp map[string]interface{}, ctx *f
 	}
 	return result, nil
 }
+
+// 处理强制参数(enforce=true)，用于在查询缓存前确定其值
+func enforceParameters(paras []*TSqlParameter, mp map[string]interface{}, ctx *faygo.Context) error {
+	var list []*TSqlParameter
+	for _, para := range paras {
+		if para.Enforce {
+			list = append(list, para)
+		}
+	}
+	_, err := dealwithParameter(list, mp, ctx)
+	return err
+}
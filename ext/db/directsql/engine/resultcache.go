@@ -0,0 +1,289 @@
+/**
+* func   : 查询sql结果缓存处理单元
+* author : 畅雨
+* date   : 2016.11.25
+* desc   : 缓存查询的结果
+* history :
+           - 2106.11.30 -优化缓存的存储
+           - 缓存后端改为 Cache 接口，提供 freecache（本进程）与 redis 协议（多个节点共享）的实现；
+             sql 通过 tags 声明缓存标签，exec 类 sql 通过 invalidate 声明执行后失效的标签。
+             标签失效通过更新缓存中标签的版本实现，缓存结果的key包含其全部标签的版本，所以旧的结果不会再被命中。
+*/
+package engine
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/freecache"
+	"github.com/garyburd/redigo/redis"
+)
+
+// Cache is the backend of the query result cache.
+type Cache interface {
+	// Get returns the value of the key, ok is false if it does not exist or expired.
+	Get(key string) (value []byte, ok bool)
+	// Set sets the value of the key, expire <= 0 means it never expires.
+	Set(key string, value []byte, expire time.Duration) error
+	// Del deletes the key.
+	Del(key string) error
+}
+
+// 缓存的key前缀
+const (
+	cacheResultPrefix = "directsql:r:"
+	cacheTagPrefix    = "directsql:t:"
+	cacheAllTag       = "*" // 全部缓存的标签，ClearCache 使其失效
+)
+
+// 默认的 freecache 缓存大小，单个结果不能超过缓存大小的1/1024（256KB）
+const DefaultCacheSize = 256 * 1024 * 1024
+
+// 当前使用的缓存后端，未设置时第一次使用才创建默认的 freecache
+var resultCache = struct {
+	Cache
+	sync.RWMutex
+}{}
+
+// SetCacheBackend sets the backend of the query result cache,
+// it is set by the [cache] section of directsql.ini when loading.
+// The old backend is closed if it implements io.Closer, nil means the default freecache.
+func SetCacheBackend(c Cache) {
+	resultCache.Lock()
+	old := resultCache.Cache
+	resultCache.Cache = c
+	resultCache.Unlock()
+	if closer, ok := old.(io.Closer); ok && old != c {
+		if err := closer.Close(); err != nil {
+			faygo.Error("Error: directsql close the cache backend, " + err.Error())
+		}
+	}
+}
+
+func cacheBackend() Cache {
+	resultCache.RLock()
+	c := resultCache.Cache
+	resultCache.RUnlock()
+	if c != nil {
+		return c
+	}
+	resultCache.Lock()
+	defer resultCache.Unlock()
+	if resultCache.Cache == nil {
+		resultCache.Cache = NewFreeCache(DefaultCacheSize)
+	}
+	return resultCache.Cache
+}
+
+// NewFreeCache creates a Cache of the process memory, size is in bytes.
+// 只在本进程有效，多个节点时标签失效不会同步到其他节点，需要使用 NewRedisCache。
+func NewFreeCache(size int) Cache {
+	return &freeCache{cache: freecache.NewCache(size)}
+}
+
+type freeCache struct {
+	cache *freecache.Cache
+}
+
+func (c *freeCache) Get(key string) ([]byte, bool) {
+	value, err := c.cache.Get([]byte(key))
+	return value, err == nil
+}
+
+func (c *freeCache) Set(key string, value []byte, expire time.Duration) error {
+	return c.cache.Set([]byte(key), value, int(expire/time.Second))
+}
+
+func (c *freeCache) Del(key string) error {
+	c.cache.Del([]byte(key))
+	return nil
+}
+
+// NewRedisCache creates a Cache of a redis protocol server,
+// such as redis or the freecache server, which is shared by all nodes.
+// password and db are optional.
+func NewRedisCache(addr, password string, db int) Cache {
+	return &redisCache{pool: &redis.Pool{
+		MaxIdle:     16,
+		IdleTimeout: 4 * time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr,
+				redis.DialPassword(password),
+				redis.DialDatabase(db),
+				redis.DialConnectTimeout(5*time.Second),
+				redis.DialReadTimeout(5*time.Second),
+				redis.DialWriteTimeout(5*time.Second),
+			)
+		},
+	}}
+}
+
+type redisCache struct {
+	pool *redis.Pool
+}
+
+func (c *redisCache) Get(key string) ([]byte, bool) {
+	conn := c.pool.Get()
+	defer conn.Close()
+	value, err := redis.Bytes(conn.Do("GET", key))
+	if err != nil {
+		if err != redis.ErrNil {
+			faygo.Error("Error: directsql cache get " + key + ", " + err.Error())
+		}
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *redisCache) Set(key string, value []byte, expire time.Duration) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+	var err error
+	if seconds := int(expire / time.Second); seconds > 0 {
+		_, err = conn.Do("SETEX", key, seconds, value)
+	} else {
+		_, err = conn.Do("SET", key, value)
+	}
+	return err
+}
+
+func (c *redisCache) Del(key string) error {
+	conn := c.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("DEL", key)
+	return err
+}
+
+// Close closes the connection pool.
+func (c *redisCache) Close() error {
+	return c.pool.Close()
+}
+
+// 根据 directsql.ini 的 [cache] 配置创建缓存后端
+//   backend=freecache（默认）或 redis，size=freecache的大小(MB，默认256)，addr、password、db 为 redis 的配置
+func newCacheFromConfig(backend string, size int, addr, password string, db int) Cache {
+	switch backend {
+	case "redis":
+		return NewRedisCache(addr, password, db)
+	default:
+		if size <= 0 {
+			return NewFreeCache(DefaultCacheSize)
+		}
+		return NewFreeCache(size * 1024 * 1024)
+	}
+}
+
+// 标签的当前版本，不存在（从未失效或已被淘汰）则创建新版本
+func tagVersion(c Cache, tag string) string {
+	if v, ok := c.Get(cacheTagPrefix + tag); ok {
+		return string(v)
+	}
+	return newTagVersion(c, tag)
+}
+
+// 创建标签的新版本，之前版本的缓存结果都不会再被命中
+func newTagVersion(c Cache, tag string) string {
+	v := strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.FormatInt(rand.Int63(), 36)
+	if err := c.Set(cacheTagPrefix+tag, []byte(v), 0); err != nil {
+		faygo.Error("Error: directsql cache tag " + tag + ", " + err.Error())
+	}
+	return v
+}
+
+// 缓存结果的key：sql的key，参数后缀与全部标签的当前版本
+func resultKey(c Cache, key, suffix string, tags []string) string {
+	h := md5.New()
+	h.Write([]byte(suffix))
+	for _, tag := range append([]string{cacheAllTag, "sql:" + key}, tags...) {
+		h.Write([]byte{0})
+		h.Write([]byte(tagVersion(c, tag)))
+	}
+	return cacheResultPrefix + key + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// 缓存时间，-1为一直有效，-2为一月，-3为一周 -4为一天，其他单位为分钟
+func cacheDuration(timeout int) time.Duration {
+	switch timeout {
+	case -1:
+		return 0 // 一直有效
+	case -2:
+		return 30 * time.Duration(24) * time.Hour // 一月
+	case -3:
+		return 7 * time.Duration(24) * time.Hour // 一周
+	case -4:
+		return time.Duration(24) * time.Hour // 一天
+	default:
+		return time.Duration(timeout) * time.Minute // 分钟
+	}
+}
+
+// 根据key以及suffix后缀获取 sql 缓存的结果
+func getCache(se *TSql, key string, suffix string) (bool, []byte) {
+	c := cacheBackend()
+	result, ok := c.Get(resultKey(c, key, suffix, splitList(se.Tags)))
+	if ok {
+		faygo.Debug("Get Cache:[" + key + " - " + suffix + "]")
+	}
+	return ok, result
+}
+
+// 将 sql 的结果放入缓存
+func setCache(se *TSql, key string, suffix string, value []byte) {
+	if value == nil {
+		return
+	}
+	c := cacheBackend()
+	if err := c.Set(resultKey(c, key, suffix, splitList(se.Tags)), value, cacheDuration(se.Cachetime)); err != nil {
+		// 结果超过 freecache 单个值的大小限制（缓存大小的1/1024）等情况不缓存
+		if err == freecache.ErrLargeEntry {
+			faygo.Warningf("directsql: the result of [%s - %s] is %d bytes, too large to cache, increase the size of [cache] in directsql.ini", key, suffix, len(value))
+			return
+		}
+		faygo.Warning("Set Cache:[" + key + " - " + suffix + "] " + err.Error())
+	}
+}
+
+// 执行成功后使 sql 的 invalidate 属性声明的标签失效
+func invalidateCache(se *TSql) {
+	if tags := splitList(se.Invalidate); len(tags) > 0 {
+		InvalidateTags(tags...)
+	}
+}
+
+// 根据key以及suffix后缀获取缓存的结果 has 表示存在有效的result，result为结果
+func GetCache(key string, suffix string) (ok bool, result []byte) {
+	return getCache(&TSql{}, key, suffix)
+}
+
+// 将key以及suffix后缀的值放入到缓存中，如果存在则替换，timeout 为缓存时间(同 cachetime)
+func SetCache(key string, suffix string, value []byte, timeout int) {
+	setCache(&TSql{Cachetime: timeout}, key, suffix, value)
+}
+
+// InvalidateTags 使标签的全部缓存结果失效，使用共享的缓存后端时对全部节点有效
+func InvalidateTags(tags ...string) {
+	c := cacheBackend()
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); len(tag) > 0 {
+			newTagVersion(c, tag)
+			faygo.Debug("Invalidate Cache tag:[" + tag + "]")
+		}
+	}
+}
+
+// 清除key的缓存
+func RemoveCache(key string) {
+	InvalidateTags("sql:" + key)
+}
+
+// 清除全部缓存
+func ClearCache() {
+	InvalidateTags(cacheAllTag)
+}
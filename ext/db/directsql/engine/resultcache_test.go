@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"testing"
+)
+
+// 记录是否被关闭的缓存后端
+type closerCache struct {
+	Cache
+	closed bool
+}
+
+func (c *closerCache) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestInvalidateTags(t *testing.T) {
+	SetCacheBackend(NewFreeCache(1024 * 1024))
+	defer SetCacheBackend(nil)
+	users, orders := &TSql{Tags: "user"}, &TSql{Tags: "order,user"}
+	value := []byte("[]")
+	setCache(users, "test/users", "", value)
+	setCache(orders, "test/orders", "", value)
+	if ok, _ := getCache(users, "test/users", ""); !ok {
+		t.Fatal("want the cached users")
+	}
+	InvalidateTags("order")
+	if ok, _ := getCache(users, "test/users", ""); !ok {
+		t.Fatal("the users are not tagged order: want cached")
+	}
+	if ok, _ := getCache(orders, "test/orders", ""); ok {
+		t.Fatal("the orders are invalidated: want not cached")
+	}
+	setCache(orders, "test/orders", "", value)
+	invalidateCache(&TSql{Invalidate: "user"})
+	for key, se := range map[string]*TSql{"test/users": users, "test/orders": orders} {
+		if ok, _ := getCache(se, key, ""); ok {
+			t.Fatalf("%s tagged user: want not cached", key)
+		}
+	}
+	// 不同的参数后缀分别缓存，RemoveCache 使 sql 的全部结果失效
+	setCache(users, "test/users", "a", value)
+	setCache(users, "test/users", "b", value)
+	if ok, _ := getCache(users, "test/users", "c"); ok {
+		t.Fatal("other suffix: want not cached")
+	}
+	RemoveCache("test/users")
+	if ok, _ := getCache(users, "test/users", "a"); ok {
+		t.Fatal("removed: want not cached")
+	}
+	setCache(users, "test/users", "b", value)
+	ClearCache()
+	if ok, _ := getCache(users, "test/users", "b"); ok {
+		t.Fatal("cleared: want not cached")
+	}
+}
+
+func TestCachedSelectInvalidate(t *testing.T) {
+	SetCacheBackend(NewFreeCache(1024 * 1024))
+	defer SetCacheBackend(nil)
+	db := setupTestModel(t)
+	insertUsers(t, "a")
+	findModel("test").findSql("cachedlist").Cached = true
+	want := `[{"code":"a","id":1}]`
+	if code, body := postSQL(t, "cachedlist", nil, `{}`); code != 200 || body != want {
+		t.Fatalf("got %d %s", code, body)
+	}
+	// 不经过 directsql 修改的数据，缓存的结果不变
+	if _, err := db.Exec("UPDATE user SET code = 'b'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := postSQL(t, "cachedlist", nil, `{}`); body != want {
+		t.Fatalf("want the cached result, got %s", body)
+	}
+	// 声明 invalidate="user" 的 sql 执行后缓存失效
+	if _, err := ExecMap("test", "rename", map[string]interface{}{"id": 1, "code": "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := postSQL(t, "cachedlist", nil, `{}`); body != `[{"code":"c","id":1}]` {
+		t.Fatalf("want the invalidated result, got %s", body)
+	}
+}
+
+func TestSetCacheBackend(t *testing.T) {
+	c1 := &closerCache{Cache: NewFreeCache(1024 * 1024)}
+	c2 := &closerCache{Cache: NewFreeCache(1024 * 1024)}
+	SetCacheBackend(c1)
+	SetCacheBackend(c1)
+	if c1.closed {
+		t.Fatal("the same backend is closed")
+	}
+	SetCacheBackend(c2)
+	if !c1.closed || c2.closed {
+		t.Fatalf("want the old backend closed, got %v %v", c1.closed, c2.closed)
+	}
+	// nil 恢复为第一次使用时创建的默认 freecache
+	SetCacheBackend(nil)
+	if !c2.closed {
+		t.Fatal("want the old backend closed")
+	}
+	if c := cacheBackend(); c == nil || c == Cache(c2) {
+		t.Fatalf("want the default backend, got %v", c)
+	}
+	SetCacheBackend(nil)
+}
+
+func TestCacheLargeResult(t *testing.T) {
+	defer SetCacheBackend(nil)
+	se := &TSql{Cachetime: 1}
+	value := make([]byte, 100*1024)
+	// 默认大小可以缓存 100KB 的结果
+	SetCacheBackend(newCacheFromConfig("freecache", 0, "", "", 0))
+	setCache(se, "/test/large", "", value)
+	if ok, result := getCache(se, "/test/large", ""); !ok || len(result) != len(value) {
+		t.Fatalf("want the result cached, got %v %d", ok, len(result))
+	}
+	// 超过缓存大小的1/1024的结果不缓存
+	SetCacheBackend(newCacheFromConfig("freecache", 32, "", "", 0))
+	setCache(se, "/test/large", "", value)
+	if ok, _ := getCache(se, "/test/large", ""); ok {
+		t.Fatal("want the result larger than 1/1024 of the cache not cached")
+	}
+}
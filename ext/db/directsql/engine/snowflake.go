@@ -6,7 +6,7 @@
 * history :
 
  */
-package directsql
+package engine
 
 import (
 	"strconv"
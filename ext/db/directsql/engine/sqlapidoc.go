@@ -0,0 +1,345 @@
+/**
+* desc   : 根据已载入的模型生成API文档(swagger)
+* desc   : 每个 model/sql 生成一个API路径，包含参数定义与返回结果的结构，
+*          模型文件重新载入（sqlwatcher 或 reload）后自动重新生成。
+*/
+package engine
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/swagger"
+)
+
+// 已注册API文档的 DirectSQL 路由
+var apidocRoutes = struct {
+	list []*apidocRoute
+	sync.Mutex
+}{}
+
+type apidocRoute struct {
+	frame    *faygo.Framework
+	prefix   string // 路由前缀，例如 /bos
+	wildcard string // 通配路由在API文档中的路径，例如 /bos/{path}
+}
+
+// RegAPIdoc adds an API doc entry for every sql of the loaded models to the frame.
+// pattern is the path of the DirectSQL() route, such as "/bos/*path",
+// which replaces its wildcard entry.
+// The entries are regenerated when the model files are reloaded.
+func RegAPIdoc(frame *faygo.Framework, pattern string) {
+	r := &apidocRoute{frame: frame, prefix: strings.TrimRight(pattern, "/")}
+	if i := strings.Index(pattern, "/*"); i >= 0 {
+		r.prefix = pattern[:i]
+		r.wildcard = pattern[:i] + "/{" + pattern[i+2:] + "}"
+	}
+	apidocRoutes.Lock()
+	apidocRoutes.list = append(apidocRoutes.list, r)
+	apidocRoutes.Unlock()
+	frame.AddAPIdoc(r.build)
+}
+
+// 模型变化后丢弃已生成的API文档，下次访问时重新生成
+func resetAPIdocs() {
+	apidocRoutes.Lock()
+	defer apidocRoutes.Unlock()
+	for _, r := range apidocRoutes.list {
+		r.frame.ResetAPIdoc()
+	}
+}
+
+// 生成全部 model/sql 的API文档
+func (r *apidocRoute) build(doc *swagger.Swagger) {
+	if len(r.wildcard) > 0 {
+		delete(doc.Paths, r.wildcard)
+	}
+	if doc.Definitions == nil {
+		doc.Definitions = map[string]*swagger.Definition{}
+	}
+	ms := models
+	ms.loadLock.RLock()
+	defer ms.loadLock.RUnlock()
+	modelIds := make([]string, 0, len(ms.modelsqls))
+	for id := range ms.modelsqls {
+		modelIds = append(modelIds, id)
+	}
+	sort.Strings(modelIds)
+	for _, modelId := range modelIds {
+		m := ms.modelsqls[modelId]
+		tag := &swagger.Tag{Name: r.prefix + "/" + modelId, Description: "DirectSQL model: " + modelId}
+		doc.Tags = append(doc.Tags, tag)
+		sqlIds := make([]string, 0, len(m.Sqls))
+		for id := range m.Sqls {
+			sqlIds = append(sqlIds, id)
+		}
+		sort.Strings(sqlIds)
+		for _, sqlId := range sqlIds {
+			addSqlAPIdoc(doc, tag.Name, tag.Name+"/"+sqlId, m.Sqls[sqlId])
+		}
+	}
+}
+
+// 添加一个 sql 的API操作项
+func addSqlAPIdoc(doc *swagger.Swagger, tag, urlpath string, se *TSql) {
+	if len(se.Cmds) == 0 {
+		return
+	}
+	ref := strings.Replace(strings.TrimPrefix(urlpath, "/"), "/", "@", -1)
+	summary := se.Id + " (" + se.Sqltypestr + ")"
+	if len(se.Desc) > 0 {
+		summary = strings.TrimSpace(strings.Split(strings.TrimSpace(se.Desc), "\n")[0])
+	}
+	method := "post"
+	o := &swagger.Opera{
+		Tags:        []string{tag},
+		Summary:     summary,
+		Description: "DirectSQL type: " + se.Sqltypestr,
+		Consumes:    []string{faygo.MIMEApplicationJSON},
+		Produces:    []string{faygo.MIMEApplicationJSON},
+		Responses: map[string]*swagger.Resp{
+			"400": {Description: "invalid parameters", Schema: &swagger.Schema{Ref: "#/definitions/" + msgDefinition(doc)}},
+			"404": {Description: "sql error", Schema: &swagger.Schema{Ref: "#/definitions/" + msgDefinition(doc)}},
+		},
+	}
+	if requiresAuth(se) {
+		o.Responses["403"] = &swagger.Resp{Description: "permission denied", Schema: &swagger.Schema{Ref: "#/definitions/" + msgDefinition(doc)}}
+	}
+	ok := func(schema *swagger.Schema) {
+		o.Responses["200"] = &swagger.Resp{Description: "successful operation", Schema: schema}
+	}
+	bodyParam := func(schema *swagger.Schema) {
+		o.Parameters = append(o.Parameters, &swagger.Parameter{
+			In:       "body",
+			Name:     "body",
+			Required: true,
+			Schema:   schema,
+		})
+	}
+	refSchema := func(name string) *swagger.Schema {
+		return &swagger.Schema{Ref: "#/definitions/" + name}
+	}
+	rowsSchema := &swagger.Schema{Type: "array", Items: &swagger.Items{Type: "object"}}
+
+	switch se.Sqltype {
+	case ST_SELECT, ST_NESTEDSELECT:
+		doc.Definitions[ref+"@param"] = paramDefinition(ref+"@param", cmdParameters(se.Cmds), true)
+		bodyParam(refSchema(ref + "@param"))
+		ok(rowsSchema)
+
+	case ST_PAGINGSELECT:
+		doc.Definitions[ref+"@param"] = paramDefinition(ref+"@param", cmdParameters(se.Cmds), true)
+		bodyParam(refSchema(ref + "@param"))
+		doc.Definitions[ref+"@result"] = &swagger.Definition{
+			Type: "object",
+			Properties: map[string]*swagger.Property{
+				"total": {Type: "integer", Description: "total number of records"},
+				"data":  {Type: "array", Items: &swagger.Items{Type: "object"}, Description: "records of the page"},
+			},
+			Xml: &swagger.Xml{Name: ref + "@result"},
+		}
+		ok(refSchema(ref + "@result"))
+
+	case ST_CURSORSELECT:
+		param := paramDefinition(ref+"@param", cmdParameters(se.Cmds), true)
+		param.Properties["cursor"] = &swagger.Property{Type: "string", Description: "the next cursor of the previous page, empty for the first page"}
+		param.Properties["limit"] = &swagger.Property{Type: "integer", Description: "page size", Maximum: float64(se.Pagesize)}
+		doc.Definitions[ref+"@param"] = param
+		bodyParam(refSchema(ref + "@param"))
+		doc.Definitions[ref+"@result"] = &swagger.Definition{
+			Type: "object",
+			Properties: map[string]*swagger.Property{
+				"data": {Type: "array", Items: &swagger.Items{Type: "object"}, Description: "records of the page"},
+				"next": {Type: "string", Description: "the cursor of the next page, absent on the last page"},
+			},
+			Xml: &swagger.Xml{Name: ref + "@result"},
+		}
+		ok(refSchema(ref + "@result"))
+
+	case ST_EXPORT:
+		param := paramDefinition(ref+"@param", cmdParameters(se.Cmds[:1]), false)
+		param.Properties["format"] = &swagger.Property{Type: "string", Description: "csv, excel or ndjson, or chosen by the Accept header, default csv"}
+		doc.Definitions[ref+"@param"] = param
+		bodyParam(refSchema(ref + "@param"))
+		o.Produces = []string{"text/csv", "application/x-ndjson"}
+		ok(&swagger.Schema{Type: "file"})
+
+	case ST_MULTISELECT:
+		doc.Definitions[ref+"@param"] = paramDefinition(ref+"@param", cmdParameters(se.Cmds), true)
+		bodyParam(refSchema(ref + "@param"))
+		result := &swagger.Definition{
+			Type:       "object",
+			Properties: map[string]*swagger.Property{},
+			Xml:        &swagger.Xml{Name: ref + "@result"},
+		}
+		for i, cmd := range se.Cmds {
+			// 与 multiSelectMap 的结果集命名一致
+			name := cmd.Rout
+			if len(name) == 0 {
+				name = "data" + strconv.Itoa(i)
+			}
+			result.Properties[name] = &swagger.Property{Type: "array", Items: &swagger.Items{Type: "object"}}
+		}
+		doc.Definitions[ref+"@result"] = result
+		ok(refSchema(ref + "@result"))
+
+	case ST_EXEC:
+		doc.Definitions[ref+"@param"] = paramDefinition(ref+"@param", cmdParameters(se.Cmds[:1]), false)
+		bodyParam(refSchema(ref + "@param"))
+		ok(refSchema(msgDefinition(doc)))
+
+	case ST_BATCHEXEC:
+		doc.Definitions[ref+"@param"] = paramDefinition(ref+"@param", cmdParameters(se.Cmds[:1]), false)
+		bodyParam(&swagger.Schema{Type: "array", Items: &swagger.Items{Ref: "#/definitions/" + ref + "@param"}})
+		ok(refSchema(msgDefinition(doc)))
+
+	case ST_BATCHMULTIEXEC:
+		param := &swagger.Definition{
+			Type:       "object",
+			Properties: map[string]*swagger.Property{},
+			Xml:        &swagger.Xml{Name: ref + "@param"},
+		}
+		for _, cmd := range se.Cmds {
+			if _, ok := param.Properties[cmd.Pin]; ok {
+				continue
+			}
+			name := ref + "@param@" + cmd.Pin
+			doc.Definitions[name] = paramDefinition(name, cmdParameters([]*TCmd{cmd}), false)
+			param.Properties[cmd.Pin] = &swagger.Property{Type: "array", Items: &swagger.Items{Ref: "#/definitions/" + name}}
+			param.Required = append(param.Required, cmd.Pin)
+		}
+		doc.Definitions[ref+"@param"] = param
+		bodyParam(refSchema(ref + "@param"))
+		ok(refSchema(msgDefinition(doc)))
+
+	case ST_GETBLOB:
+		method = "get"
+		o.Consumes = nil
+		o.Produces = []string{"application/octet-stream", faygo.MIMEApplicationJSON}
+		o.Parameters = queryParameters(cmdParameters(se.Cmds[:1]))
+		ok(&swagger.Schema{Type: "file"})
+
+	case ST_SETBLOB:
+		o.Consumes = []string{faygo.MIMEMultipartForm}
+		o.Parameters = append(queryParameters(cmdParameters(se.Cmds[:1])), &swagger.Parameter{
+			In:          "formData",
+			Name:        "inputfile",
+			Type:        "file",
+			Description: "the binary content",
+			Required:    true,
+		})
+		ok(refSchema(msgDefinition(doc)))
+
+	default:
+		return
+	}
+	o.OperationId = urlpath + "-" + strings.ToUpper(method)
+	doc.Paths[urlpath] = map[string]*swagger.Opera{method: o}
+}
+
+// 合并 cmd 的参数定义，同名参数只取第一个，强制参数由服务端设置，不列出
+func cmdParameters(cmds []*TCmd) []*TSqlParameter {
+	var paras []*TSqlParameter
+	seen := map[string]bool{}
+	for _, cmd := range cmds {
+		for _, para := range cmd.Parameters {
+			if !seen[para.Name] && !para.Enforce {
+				seen[para.Name] = true
+				paras = append(paras, para)
+			}
+		}
+	}
+	return paras
+}
+
+// 参数定义转换为 swagger 对象定义，没有服务端默认值的参数客户端必须提交
+func paramDefinition(name string, paras []*TSqlParameter, callback bool) *swagger.Definition {
+	def := &swagger.Definition{
+		Type:       "object",
+		Properties: map[string]*swagger.Property{},
+		Xml:        &swagger.Xml{Name: name},
+	}
+	for _, para := range paras {
+		def.Properties[para.Name] = paramProperty(para)
+		if para.Default == DT_UNDEFINED {
+			def.Required = append(def.Required, para.Name)
+		}
+	}
+	if callback {
+		def.Properties["callback"] = &swagger.Property{Type: "string", Description: "JSONP callback function name (optional)"}
+	}
+	return def
+}
+
+func paramProperty(para *TSqlParameter) *swagger.Property {
+	p := &swagger.Property{Type: "string", Description: para.Desc}
+	switch para.Paratype {
+	case PT_STRING:
+		p.MinLength, p.MaxLength = para.Minlen, para.Maxlen
+		if para.Required && p.MinLength == 0 {
+			p.MinLength = 1
+		}
+	case PT_INT:
+		p.Type = "integer"
+		p.Minimum, p.Maximum = para.MinValue, para.MaxValue
+	case PT_FLOAT:
+		p.Type = "number"
+		p.Minimum, p.Maximum = para.MinValue, para.MaxValue
+	case PT_DATE:
+		p.Format = "date"
+		p.Example = "2006-01-02"
+	case PT_DATETIME:
+		p.Format = "datetime"
+		p.Example = "2006-01-02 15:04"
+	case PT_EMAIL:
+		p.Format = "email"
+	case PT_BLOB:
+		p.Format = "binary"
+	}
+	if para.Default != DT_UNDEFINED {
+		if len(p.Description) > 0 {
+			p.Description += ", "
+		}
+		p.Description += "server default: " + para.Defaultstr
+	}
+	return p
+}
+
+// 参数定义转换为 URL 查询参数
+func queryParameters(paras []*TSqlParameter) []*swagger.Parameter {
+	var list []*swagger.Parameter
+	for _, para := range paras {
+		if para.Paratype == PT_BLOB {
+			continue
+		}
+		prop := paramProperty(para)
+		list = append(list, &swagger.Parameter{
+			In:          "query",
+			Name:        para.Name,
+			Description: prop.Description,
+			Required:    para.Default == DT_UNDEFINED,
+			Type:        prop.Type,
+			Format:      prop.Format,
+		})
+	}
+	return list
+}
+
+// faygo.JSONMsg 的对象定义
+func msgDefinition(doc *swagger.Swagger) string {
+	const name = "directsql@JSONMsg"
+	if _, ok := doc.Definitions[name]; !ok {
+		doc.Definitions[name] = &swagger.Definition{
+			Type: "object",
+			Properties: map[string]*swagger.Property{
+				"code": {Type: "integer"},
+				"info": {Type: "object", Description: "message, or the server generated parameters to return"},
+			},
+			Xml: &swagger.Xml{Name: name},
+		}
+	}
+	return name
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/andeya/faygo/swagger"
+)
+
+func TestAPIdoc(t *testing.T) {
+	m, err := models.parseTModel("testdata/apidoc.msql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	models.loadLock.Lock()
+	models.modelsqls["doc"] = m
+	models.loadLock.Unlock()
+	defer func() {
+		models.loadLock.Lock()
+		delete(models.modelsqls, "doc")
+		models.loadLock.Unlock()
+	}()
+
+	doc := &swagger.Swagger{Paths: map[string]map[string]*swagger.Opera{"/bos/{path}": {"post": {}}}}
+	r := &apidocRoute{prefix: "/bos", wildcard: "/bos/{path}"}
+	r.build(doc)
+
+	// 路径与方法，通配路由被替换
+	if _, ok := doc.Paths["/bos/{path}"]; ok {
+		t.Fatal("want the wildcard path removed")
+	}
+	for path, method := range map[string]string{
+		"/bos/doc/list":   "post",
+		"/bos/doc/insert": "post",
+		"/bos/doc/photo":  "get",
+		"/bos/doc/save":   "post",
+	} {
+		o, ok := doc.Paths[path][method]
+		if !ok {
+			t.Fatalf("%s: want the %s operation, got %v", path, method, doc.Paths[path])
+		}
+		if len(o.Tags) != 1 || o.Tags[0] != "/bos/doc" {
+			t.Errorf("%s: got the tags %v", path, o.Tags)
+		}
+	}
+	if o := doc.Paths["/bos/doc/list"]["post"]; o.Summary != "用户列表" || o.Responses["200"] == nil || o.Responses["400"] == nil {
+		t.Errorf("list: got %+v", o)
+	}
+
+	// 参数列表，强制参数由服务端设置，不列出
+	list := doc.Definitions["bos@doc@list@param"]
+	if list == nil {
+		t.Fatal("want the parameters of list")
+	}
+	if got, want := propertyNames(list), []string{"age", "callback", "code"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list: got the parameters %v, want %v", got, want)
+	}
+	if p := list.Properties["code"]; p.Type != "string" || p.MinLength != 1 || p.MaxLength != 20 || p.Description != "帐号" {
+		t.Errorf("code: got %+v", p)
+	}
+	if p := list.Properties["age"]; p.Type != "integer" || p.Minimum != 1 || p.Maximum != 150 {
+		t.Errorf("age: got %+v", p)
+	}
+	// 没有服务端默认值的参数是必须的
+	if got, want := sorted(list.Required), []string{"age", "code"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list: got the required %v, want %v", got, want)
+	}
+
+	insert := doc.Definitions["bos@doc@insert@param"]
+	if got, want := propertyNames(insert), []string{"code", "id"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("insert: got the parameters %v, want %v", got, want)
+	}
+	if got, want := insert.Required, []string{"code"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("insert: got the required %v, want %v", got, want)
+	}
+	if p := insert.Properties["id"]; p.Description != "server default: uuid" {
+		t.Errorf("id: got the description %q", p.Description)
+	}
+
+	// getblob 的查询参数
+	photo := doc.Paths["/bos/doc/photo"]["get"].Parameters
+	if len(photo) != 1 || photo[0].In != "query" || photo[0].Name != "id" || photo[0].Type != "integer" || !photo[0].Required {
+		t.Errorf("photo: got the parameters %+v", photo)
+	}
+
+	// batchmultiexec 的每个输入数组
+	save := doc.Definitions["bos@doc@save@param"]
+	if got, want := propertyNames(save), []string{"roles", "users"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("save: got the parameters %v, want %v", got, want)
+	}
+	if p := save.Properties["users"]; p.Type != "array" || p.Items.Ref != "#/definitions/bos@doc@save@param@users" {
+		t.Errorf("users: got %+v", p)
+	}
+	if got, want := propertyNames(doc.Definitions["bos@doc@save@param@users"]), []string{"code", "id"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("users: got the parameters %v, want %v", got, want)
+	}
+}
+
+func propertyNames(def *swagger.Definition) []string {
+	var names []string
+	for name := range def.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sorted(list []string) []string {
+	list = append([]string(nil), list...)
+	sort.Strings(list)
+	return list
+}
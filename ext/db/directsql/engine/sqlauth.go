@@ -0,0 +1,195 @@
+/**
+* desc   : sql语句级别的授权检查
+* desc   : sql、cmd 节点通过 roles/permission 属性声明执行需要的角色与权限，
+*          DirectSQL 执行前由注册的 Authorizer 检查当前请求的用户是否满足，
+*          roles 为逗号分隔的角色列表，满足其中之一即可；permission 为需要的权限。
+*          未声明 roles/permission 的 sql 不检查；声明了但未设置 Authorizer 的一律拒绝。
+*/
+package engine
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/ext/middleware/jwt"
+)
+
+// Authorizer checks whether the user of the request may execute a sql.
+type Authorizer interface {
+	// Authorize reports whether the user has one of the roles (if any)
+	// and the permission (if not empty).
+	Authorize(ctx *faygo.Context, roles []string, permission string) bool
+}
+
+// AuthorizerFunc is a function that implements Authorizer.
+type AuthorizerFunc func(ctx *faygo.Context, roles []string, permission string) bool
+
+// Authorize implements Authorizer.
+func (fn AuthorizerFunc) Authorize(ctx *faygo.Context, roles []string, permission string) bool {
+	return fn(ctx, roles, permission)
+}
+
+// 当前使用的授权检查器
+var authorizer = struct {
+	Authorizer
+	sync.RWMutex
+}{}
+
+// SetAuthorizer sets the Authorizer of the sql with roles or permission.
+func SetAuthorizer(a Authorizer) {
+	authorizer.Lock()
+	authorizer.Authorizer = a
+	authorizer.Unlock()
+}
+
+// SessionAuthorizer reads the roles and permissions of the user from the session,
+// the values are []string, []interface{} or comma separated string.
+type SessionAuthorizer struct {
+	RolesKey       string
+	PermissionsKey string
+}
+
+// Authorize implements Authorizer.
+func (a *SessionAuthorizer) Authorize(ctx *faygo.Context, roles []string, permission string) bool {
+	var has, perms []string
+	if len(a.RolesKey) > 0 {
+		has = toStrings(ctx.GetSession(a.RolesKey))
+	}
+	if len(a.PermissionsKey) > 0 {
+		perms = toStrings(ctx.GetSession(a.PermissionsKey))
+	}
+	return matchAuth(has, perms, roles, permission)
+}
+
+// JWTAuthorizer reads the roles and permissions of the user from the claims of
+// the JWT token, the jwt middleware must be used before the DirectSQL route.
+type JWTAuthorizer struct {
+	RolesClaim       string
+	PermissionsClaim string
+}
+
+// Authorize implements Authorizer.
+func (a *JWTAuthorizer) Authorize(ctx *faygo.Context, roles []string, permission string) bool {
+	claims := jwt.ExtractClaims(ctx)
+	var has, perms []string
+	if len(a.RolesClaim) > 0 {
+		has = toStrings(claims[a.RolesClaim])
+	}
+	if len(a.PermissionsClaim) > 0 {
+		perms = toStrings(claims[a.PermissionsClaim])
+	}
+	return matchAuth(has, perms, roles, permission)
+}
+
+// SessionValue returns a function to register by RegAny,
+// which gets the value of the key from the session.
+// 例如：RegAny("tenantid", SessionValue("tenant_id"))
+func SessionValue(key string) func(ctx *faygo.Context) interface{} {
+	return func(ctx *faygo.Context) interface{} {
+		return ctx.GetSession(key)
+	}
+}
+
+// ClaimValue returns a function to register by RegAny,
+// which gets the value of the claim from the JWT token.
+// 例如：RegAny("tenantid", ClaimValue("tenant_id"))
+func ClaimValue(claim string) func(ctx *faygo.Context) interface{} {
+	return func(ctx *faygo.Context) interface{} {
+		return jwt.ExtractClaims(ctx)[claim]
+	}
+}
+
+// 检查是否可以执行该 sql 及其全部 cmd
+func authorize(ctx *faygo.Context, se *TSql) bool {
+	if !authorizeNode(ctx, se.Roles, se.Permission) {
+		return false
+	}
+	for _, cmd := range se.Cmds {
+		if !authorizeNode(ctx, cmd.Roles, cmd.Permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// 该 sql 或其 cmd 是否声明了 roles/permission
+func requiresAuth(se *TSql) bool {
+	if len(splitList(se.Roles)) > 0 || len(strings.TrimSpace(se.Permission)) > 0 {
+		return true
+	}
+	for _, cmd := range se.Cmds {
+		if len(splitList(cmd.Roles)) > 0 || len(strings.TrimSpace(cmd.Permission)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func authorizeNode(ctx *faygo.Context, roles, permission string) bool {
+	rolelist := splitList(roles)
+	permission = strings.TrimSpace(permission)
+	if len(rolelist) == 0 && len(permission) == 0 {
+		return true
+	}
+	authorizer.RLock()
+	a := authorizer.Authorizer
+	authorizer.RUnlock()
+	if a == nil {
+		faygo.Error("Error: directsql authorizer is not set, the sql with roles or permission is denied")
+		return false
+	}
+	return a.Authorize(ctx, rolelist, permission)
+}
+
+// 用户具有 roles 之一（未定义则不检查）并且具有 permission（未定义则不检查），权限 * 表示全部权限
+func matchAuth(has, perms, roles []string, permission string) bool {
+	if len(roles) > 0 && !containsAny(has, roles) {
+		return false
+	}
+	if len(permission) > 0 && !containsAny(perms, []string{permission, "*"}) {
+		return false
+	}
+	return true
+}
+
+func containsAny(list, values []string) bool {
+	for _, s := range list {
+		for _, v := range values {
+			if s == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 逗号分隔的列表
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// session或claims中的值转换为[]string
+func toStrings(v interface{}) []string {
+	switch vv := v.(type) {
+	case []string:
+		return vv
+	case []interface{}:
+		list := make([]string, 0, len(vv))
+		for _, i := range vv {
+			if s, ok := i.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	case string:
+		return splitList(vv)
+	}
+	return nil
+}
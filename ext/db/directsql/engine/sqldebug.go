@@ -0,0 +1,288 @@
+/**
+* desc   : sql 调试模式与慢查询日志
+* desc   : 请求头 X-DirectSQL-Debug 或 URL 参数 debug 指定调试模式，只允许 directsql.ini [debug] 配置的IP或角色使用：
+*          - dryrun ：验证参数并生成绑定参数后的SQL，不执行
+*          - explain：执行查询前先执行数据库的 explain，返回执行计划
+*          - trace  ：返回每条语句的执行时间与影响的记录数
+*          调试信息在JSON响应的 debug 字段中返回；超过 slowms 的语句总是记录日志。
+*/
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andeya/faygo"
+)
+
+// 调试模式
+const (
+	DM_DRYRUN  = "dryrun"  // 验证参数并生成SQL，不执行
+	DM_EXPLAIN = "explain" // 返回查询的执行计划
+	DM_TRACE   = "trace"   // 返回每条语句的执行时间与影响的记录数
+)
+
+// 请求调试模式的请求头
+const HeaderDebug = "X-DirectSQL-Debug"
+
+// 调试模式配置，directsql.ini 的 [debug]
+type debugConfig struct {
+	whitelist []string      // 允许使用调试模式的IP，支持前缀匹配 192.168.*
+	realip    bool          // 是否使用 RealIP 匹配
+	roles     string        // 允许使用调试模式的角色，通过 SetAuthorizer 设置的授权检查器检查
+	slow      time.Duration // 慢查询的阈值
+	explain   string        // explain 语句的前缀
+}
+
+// 默认的慢查询阈值
+const DefaultSlowThreshold = time.Second
+
+// 一次请求的调试信息
+type sqlDebug struct {
+	Mode       string            `json:"mode"`
+	Statements []*debugStatement `json:"statements"`
+}
+
+// 一条语句的调试信息
+type debugStatement struct {
+	Cmd          int                      `json:"cmd"`  // cmd 在 sql 中的序号
+	Sql          string                   `json:"sql"`  // 绑定参数后的SQL
+	Args         []interface{}            `json:"args"` // SQL的参数值
+	Elapsed      string                   `json:"elapsed,omitempty"`
+	RowsAffected *int64                   `json:"rows_affected,omitempty"`
+	Plan         []map[string]interface{} `json:"plan,omitempty"`
+	Error        string                   `json:"error,omitempty"`
+}
+
+// 带调试信息的 JSONMsg
+type debugMsg struct {
+	faygo.JSONMsg
+	Debug *sqlDebug `json:"debug"`
+}
+
+// 当前配置，未配置 [debug] 时不允许调试，只记录慢查询
+func currentDebugConfig() *debugConfig {
+	models.loadLock.RLock()
+	conf := models.debug
+	models.loadLock.RUnlock()
+	if conf != nil {
+		return conf
+	}
+	return &debugConfig{slow: DefaultSlowThreshold, explain: "EXPLAIN"}
+}
+
+// 获取请求的调试模式，未请求时返回nil，模式无效时返回错误
+func requestDebug(ctx *faygo.Context) (*sqlDebug, error) {
+	mode := ctx.HeaderParam(HeaderDebug)
+	if len(mode) == 0 {
+		mode = ctx.QueryParam("debug")
+	}
+	if len(mode) == 0 {
+		return nil, nil
+	}
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case DM_DRYRUN, DM_EXPLAIN, DM_TRACE:
+	default:
+		return nil, errors.New("错误：无效的调试模式[" + mode + "]！")
+	}
+	return &sqlDebug{Mode: mode, Statements: []*debugStatement{}}, nil
+}
+
+// IP在白名单中或者具有配置的角色则允许使用调试模式
+func debugAllowed(ctx *faygo.Context, conf *debugConfig) bool {
+	ip := ctx.IP()
+	if conf.realip {
+		ip = ctx.RealIP()
+	}
+	for _, s := range conf.whitelist {
+		if s == ip || (strings.HasSuffix(s, "*") && strings.HasPrefix(ip, s[:len(s)-1])) {
+			return true
+		}
+	}
+	if len(splitList(conf.roles)) > 0 {
+		return authorizeNode(ctx, conf.roles, "")
+	}
+	return false
+}
+
+// 开始调试：调试信息保存到ctx，返回记录调试信息的模型副本
+func startDebug(ctx *faygo.Context, m *TModel, d *sqlDebug) *TModel {
+	ctx.SetData("__directsql__debug", d)
+	return m.withDebug(d)
+}
+
+// 当前请求的调试信息
+func debugOf(ctx *faygo.Context) *sqlDebug {
+	d, _ := ctx.Data("__directsql__debug").(*sqlDebug)
+	return d
+}
+
+// 使用调试信息的模型副本执行
+func (m *TModel) withDebug(d *sqlDebug) *TModel {
+	if d == nil {
+		return m
+	}
+	dm := *m
+	dm.debug = d
+	return &dm
+}
+
+// 发送JSONMsg响应，调试模式下增加 debug 字段
+func sendMsg(ctx *faygo.Context, status int, code int, info interface{}) error {
+	if d := debugOf(ctx); d != nil {
+		return ctx.JSON(status, debugMsg{JSONMsg: faygo.JSONMsg{Code: code, Info: info}, Debug: d})
+	}
+	return ctx.JSONMsg(status, code, info)
+}
+
+// dry-run 不执行语句，之前 cmd 的输出以 null 代替
+func dryRunParams(se *TSql, mp map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	for _, name := range outputNames(se) {
+		out[name] = nil
+	}
+	return withOutputs(se, mp, out)
+}
+
+// 生成语句的调试信息
+func (d *sqlDebug) add(se *TSql, cmd *TCmd, mp map[string]interface{}) *debugStatement {
+	stmt := &debugStatement{Cmd: -1}
+	for i, c := range se.Cmds {
+		if c == cmd {
+			stmt.Cmd = i
+			break
+		}
+	}
+	query, args, err := bindNamed(cmd.Sql, mp)
+	if err != nil {
+		stmt.Sql, stmt.Error = cmd.Sql, err.Error()
+	} else {
+		stmt.Sql, stmt.Args = strings.TrimSpace(query), args
+		for i, arg := range args {
+			// 二进制参数只返回长度
+			if b, ok := arg.([]byte); ok {
+				stmt.Args[i] = "<" + strconv.Itoa(len(b)) + " bytes>"
+			}
+		}
+	}
+	d.Statements = append(d.Statements, stmt)
+	return stmt
+}
+
+// dry-run：根据sql类型与参数生成将要执行的全部语句，不执行
+// para 为处理后的客户端参数：map[string]interface{}、[]map[string]interface{} 或 map[string][]map[string]interface{}
+func (d *sqlDebug) dryRun(se *TSql, para interface{}) {
+	switch p := para.(type) {
+	case map[string]interface{}:
+		cmds := se.Cmds
+		switch se.Sqltype {
+		case ST_SELECT, ST_NESTEDSELECT, ST_EXPORT, ST_GETBLOB, ST_SETBLOB:
+			cmds = se.Cmds[:1]
+		case ST_CURSORSELECT:
+			// 第一页，游标参数由服务端设置
+			for _, field := range splitList(se.Cursor) {
+				p["cursor_"+field] = nil
+			}
+			p["limit"] = se.Pagesize + 1
+			cmds = se.Cmds[:1]
+		}
+		p = dryRunParams(se, p)
+		for _, cmd := range cmds {
+			d.add(se, cmd, p)
+		}
+	case []map[string]interface{}:
+		for _, mp := range p {
+			mp = dryRunParams(se, mp)
+			for _, cmd := range se.Cmds {
+				d.add(se, cmd, mp)
+			}
+		}
+	case map[string][]map[string]interface{}:
+		for _, cmd := range se.Cmds {
+			for _, mp := range p[cmd.Pin] {
+				d.add(se, cmd, dryRunParams(se, mp))
+			}
+		}
+	}
+}
+
+// 发送 dry-run 的结果
+func sendDryRun(ctx *faygo.Context, se *TSql, para interface{}) error {
+	d := debugOf(ctx)
+	d.dryRun(se, para)
+	return sendMsg(ctx, 200, 200, "Info: dry-run, the sql is not executed!")
+}
+
+// 是否 dry-run 模式
+func isDryRun(ctx *faygo.Context) bool {
+	d := debugOf(ctx)
+	return d != nil && d.Mode == DM_DRYRUN
+}
+
+// 执行查询语句：记录慢查询，调试模式下记录执行信息与执行计划
+func traceQuery(e Executor, d *sqlDebug, se *TSql, cmd *TCmd, mp map[string]interface{}) (*sql.Rows, error) {
+	conf := currentDebugConfig()
+	query, args, err := bindNamed(cmd.Sql, mp)
+	if err != nil {
+		return nil, err
+	}
+	var stmt *debugStatement
+	if d != nil {
+		stmt = d.add(se, cmd, mp)
+		if d.Mode == DM_EXPLAIN && len(conf.explain) > 0 {
+			if rows, err := e.Query(conf.explain+" "+query, args...); err != nil {
+				stmt.Error = err.Error()
+			} else {
+				stmt.Plan, err = rows2mapObjects(rows)
+				rows.Close()
+				if err != nil {
+					stmt.Error = err.Error()
+				}
+			}
+		}
+	}
+	start := time.Now()
+	rows, err := e.Query(query, args...)
+	traceDone(conf, stmt, se, cmd, time.Since(start), nil, err)
+	return rows, err
+}
+
+// 执行 exec 语句：记录慢查询，调试模式下记录执行信息
+func traceExec(e Executor, d *sqlDebug, se *TSql, cmd *TCmd, mp map[string]interface{}) (sql.Result, error) {
+	conf := currentDebugConfig()
+	query, args, err := bindNamed(cmd.Sql, mp)
+	if err != nil {
+		return nil, err
+	}
+	var stmt *debugStatement
+	if d != nil {
+		stmt = d.add(se, cmd, mp)
+	}
+	start := time.Now()
+	result, err := e.Exec(query, args...)
+	traceDone(conf, stmt, se, cmd, time.Since(start), result, err)
+	return result, err
+}
+
+func traceDone(conf *debugConfig, stmt *debugStatement, se *TSql, cmd *TCmd, elapsed time.Duration, result sql.Result, err error) {
+	if conf.slow > 0 && elapsed >= conf.slow {
+		faygo.Warningf("[directsql] slow sql %s (%s): %s", se.Id, elapsed, strings.TrimSpace(cmd.Sql))
+	}
+	if stmt == nil {
+		return
+	}
+	stmt.Elapsed = elapsed.String()
+	if err != nil {
+		stmt.Error = err.Error()
+		return
+	}
+	if result != nil {
+		if n, err := result.RowsAffected(); err == nil {
+			stmt.RowsAffected = &n
+		}
+	}
+}
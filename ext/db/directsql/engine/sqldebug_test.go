@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// 设置 [debug] 配置，测试结束后恢复为未配置
+func setDebugConfig(t *testing.T, conf *debugConfig) {
+	models.loadLock.Lock()
+	models.debug = conf
+	models.loadLock.Unlock()
+	t.Cleanup(func() {
+		models.loadLock.Lock()
+		models.debug = nil
+		models.loadLock.Unlock()
+	})
+}
+
+func TestDryRun(t *testing.T) {
+	db := setupTestModel(t)
+	insertUsers(t, "a")
+	dryrun := http.Header{HeaderDebug: {DM_DRYRUN}}
+	para := `{"id":1,"code":"b"}`
+	// 未配置 [debug] 时不允许调试，也不执行
+	if code, body := postSQL(t, "rename", dryrun, para); code != 403 {
+		t.Fatalf("debug disabled: got %d %s", code, body)
+	}
+	setDebugConfig(t, &debugConfig{whitelist: []string{"10.0.*"}, roles: "dev", slow: DefaultSlowThreshold})
+	if code, body := postSQL(t, "rename", dryrun, para); code != 403 {
+		t.Fatalf("not in the whitelist: got %d %s", code, body)
+	}
+	setDebugConfig(t, &debugConfig{whitelist: []string{"127.0.0.*"}, slow: DefaultSlowThreshold})
+	if code, body := postSQL(t, "rename", http.Header{HeaderDebug: {"unknown"}}, para); code != 400 {
+		t.Fatalf("invalid mode: got %d %s", code, body)
+	}
+
+	code, body := postSQL(t, "rename", dryrun, para)
+	var msg struct {
+		Code  int
+		Debug sqlDebug
+	}
+	if err := json.Unmarshal([]byte(body), &msg); err != nil || code != 200 {
+		t.Fatalf("dry-run: got %d %s", code, body)
+	}
+	if len(msg.Debug.Statements) != 1 {
+		t.Fatalf("dry-run: got %s", body)
+	}
+	stmt := msg.Debug.Statements[0]
+	if stmt.Sql != "UPDATE user SET code = ? WHERE id = ?" || fmt.Sprint(stmt.Args) != "[b 1]" {
+		t.Fatalf("dry-run: got %q %v", stmt.Sql, stmt.Args)
+	}
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM user WHERE code = 'a'").Scan(&n); err != nil || n != 1 {
+		t.Fatalf("dry-run executed the sql: %d %v", n, err)
+	}
+
+	// 查询的 dry-run 不返回数据
+	code, body = postSQL(t, "select?debug=dryrun", nil, `{"code":"a"}`)
+	var result struct {
+		Data  json.RawMessage
+		Debug sqlDebug
+	}
+	if err := json.Unmarshal([]byte(body), &result); err != nil || code != 200 || result.Data != nil || len(result.Debug.Statements) != 1 {
+		t.Fatalf("select dry-run: got %d %s", code, body)
+	}
+	if result.Debug.Statements[0].Sql != "SELECT id, code, nick FROM user WHERE code = ?" {
+		t.Fatalf("select dry-run: got %s", body)
+	}
+
+	// trace 执行并返回执行时间
+	code, body = postSQL(t, "select", http.Header{HeaderDebug: {DM_TRACE}}, `{"code":"a"}`)
+	if err := json.Unmarshal([]byte(body), &result); err != nil || code != 200 {
+		t.Fatalf("trace: got %d %s", code, body)
+	}
+	if string(result.Data) != `[{"code":"a","id":1}]` {
+		t.Fatalf("trace: got %s", body)
+	}
+	if len(result.Debug.Statements) != 1 || len(result.Debug.Statements[0].Elapsed) == 0 {
+		t.Fatalf("trace: got %s", body)
+	}
+}
@@ -0,0 +1,198 @@
+/**
+* desc   : 数据库访问接口
+* desc   : 引擎通过 DB 接口访问数据库，sql 中的命名参数 ?name 由引擎统一转换为 ? 与参数值后执行，
+*          database/sql 的实现为 NewSQLDB，xorm 与 sqlx 的实现见子包 xormdb、sqlxdb。
+*          model 文件的 database 属性为数据库的名称：先查找 RegDB 注册的数据库，再依次调用 RegDBResolver 注册的函数，
+*          都没有则使用默认（名称为空）的数据库。数据库在执行时才获取，所以可以在载入 model 文件之后注册。
+*          directsql（xorm 的数据库）与 directsqlx（sqlx 的数据库）共用本引擎以及载入的 model，
+*          两者通过 RegFrontend 注册默认的数据库，同一个程序只能导入其中一个，都导入时第二个初始化时 panic；
+*          需要同时使用 xorm 与 sqlx 的数据库时导入一个，另一个的数据库通过 RegDB 与 xormdb.New、sqlxdb.New 注册。
+*/
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Executor executes the sql with the ? placeholders.
+type Executor interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// DB is the database used by the directsql engine.
+type DB interface {
+	Executor
+	Begin() (Tx, error)
+}
+
+// Tx is a transaction of the DB.
+type Tx interface {
+	Executor
+	Commit() error
+	Rollback() error
+}
+
+// NewSQLDB creates a DB of the database/sql DB.
+// rebind converts the ? placeholders to those of the driver (such as $1 of postgres),
+// nil keeps the ? placeholders.
+func NewSQLDB(db *sql.DB, rebind func(query string) string) DB {
+	return &sqlDB{db: db, rebind: rebind}
+}
+
+type sqlDB struct {
+	db     *sql.DB
+	rebind func(query string) string
+}
+
+func (d *sqlDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.Query(bindvar(d.rebind, query), args...)
+}
+
+func (d *sqlDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.db.Exec(bindvar(d.rebind, query), args...)
+}
+
+func (d *sqlDB) Begin() (Tx, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{tx: tx, rebind: d.rebind}, nil
+}
+
+type sqlTx struct {
+	tx     *sql.Tx
+	rebind func(query string) string
+}
+
+func (t *sqlTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.Query(bindvar(t.rebind, query), args...)
+}
+
+func (t *sqlTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.Exec(bindvar(t.rebind, query), args...)
+}
+
+func (t *sqlTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqlTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+func bindvar(rebind func(string) string, query string) string {
+	if rebind == nil {
+		return query
+	}
+	return rebind(query)
+}
+
+// 已注册的数据库与获取数据库的函数
+var databases = struct {
+	sync.RWMutex
+	dbs       map[string]DB
+	resolvers []func(name string) (DB, bool)
+	frontend  string // 注册了默认数据库的入口包，directsql 或 directsqlx
+}{dbs: make(map[string]DB)}
+
+// RegDB registers the database of the name used by the database attribute
+// of the model files, the name "" is the default database.
+func RegDB(name string, db DB) {
+	databases.Lock()
+	databases.dbs[name] = db
+	databases.Unlock()
+}
+
+// RegDBResolver registers a function that gets the database of the name
+// which is not registered by RegDB, the name "" is the default database.
+func RegDBResolver(fn func(name string) (DB, bool)) {
+	databases.Lock()
+	databases.resolvers = append(databases.resolvers, fn)
+	databases.Unlock()
+}
+
+// RegFrontend registers the databases of the front-end package directsql or directsqlx,
+// which share the engine and the loaded models.
+// It panics if the other one is registered, a program can import only one of them.
+func RegFrontend(pkg string, fn func(name string) (DB, bool)) {
+	databases.Lock()
+	defer databases.Unlock()
+	if len(databases.frontend) > 0 && databases.frontend != pkg {
+		panic("directsql: both " + databases.frontend + " and " + pkg + " are imported, they share the engine and the models, " +
+			"so the default database would depend on the init order; import only one of them and register the databases of the other with RegDB")
+	}
+	databases.frontend = pkg
+	databases.resolvers = append(databases.resolvers, fn)
+}
+
+// 获取名称对应的数据库，不存在则使用默认数据库，都不存在时返回的DB执行时返回错误
+func getDB(name string) DB {
+	databases.RLock()
+	defer databases.RUnlock()
+	for _, n := range []string{name, ""} {
+		if db, ok := databases.dbs[n]; ok {
+			return db
+		}
+		for _, fn := range databases.resolvers {
+			if db, ok := fn(n); ok {
+				return db
+			}
+		}
+		if len(name) == 0 {
+			break
+		}
+	}
+	return errDB{errors.New("错误：数据库[" + name + "]未注册，请使用 RegDB 注册或者导入 xormdb、sqlxdb 子包！")}
+}
+
+// 未注册的数据库，执行时返回错误
+type errDB struct {
+	err error
+}
+
+func (d errDB) Query(string, ...interface{}) (*sql.Rows, error) { return nil, d.err }
+func (d errDB) Exec(string, ...interface{}) (sql.Result, error)  { return nil, d.err }
+func (d errDB) Begin() (Tx, error)                               { return nil, d.err }
+
+// 命名参数 ?name，之前 cmd 的行输出的字段为 ?name.field
+var namedParam = regexp.MustCompile(`[?](\w+(?:\.\w+)?)`)
+
+// 将 sql 中的命名参数 ?name 替换为 ?，返回替换后的 sql 与按顺序的参数值
+func bindNamed(query string, mp map[string]interface{}) (string, []interface{}, error) {
+	args := make([]interface{}, 0, len(mp))
+	var err error
+	query = namedParam.ReplaceAllStringFunc(query, func(src string) string {
+		v, ok := lookupParam(mp, src[1:])
+		if !ok {
+			err = errors.New("错误：缺少sql参数[" + src[1:] + "]！")
+		}
+		args = append(args, v)
+		return "?"
+	})
+	return query, args, err
+}
+
+// 获取参数值，name.field 的行输出为 null（没有记录或者 cmd 未执行）时字段也为 null
+func lookupParam(mp map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := mp[name]; ok {
+		return v, true
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		if v, ok := mp[name[:i]]; ok && v == nil {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+// 结构体转换为命名参数，st 为结构体或结构体指针
+func structToMap(st interface{}) map[string]interface{} {
+	return Struct2Map(reflect.Indirect(reflect.ValueOf(st)).Interface())
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// 使用 sqlite 数据库载入 testdata/test.msql 为模型 test，并创建 user 表
+func setupTestModel(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegDB("", NewSQLDB(db, nil))
+	t.Cleanup(func() {
+		db.Close()
+		databases.Lock()
+		delete(databases.dbs, "")
+		databases.Unlock()
+	})
+	m, err := models.parseTModel("testdata/test.msql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	models.loadLock.Lock()
+	models.modelsqls["test"] = m
+	models.loadLock.Unlock()
+	if _, err = ExecMap("test", "create", map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestExecAndSelect(t *testing.T) {
+	setupTestModel(t)
+	if _, err := ExecMap("test", "insert", map[string]interface{}{"id": 1, "code": "a", "nick": "andeya"}); err != nil {
+		t.Fatal(err)
+	}
+	err := BacthExecMap("test", "batchinsert", []map[string]interface{}{
+		{"id": 2, "code": "b", "nick": nil},
+		{"id": 3, "code": "c", "nick": "c"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := SelectMapToMap("test", "select", map[string]interface{}{"code": "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || fmt.Sprint(rows[0]["id"]) != "1" || fmt.Sprint(rows[0]["nick"]) != "andeya" {
+		t.Fatalf("select: got %v", rows)
+	}
+	ok, err := SelectMapIsExist("test", "select", map[string]interface{}{"code": "x"})
+	if err != nil || ok {
+		t.Fatalf("exist: got %v, %v", ok, err)
+	}
+	// 缺少参数
+	if _, err = SelectMapToMap("test", "select", map[string]interface{}{}); err == nil {
+		t.Fatal("select without the parameter: want error")
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	db := setupTestModel(t)
+	// 第二条记录主键重复，整个事务回滚
+	err := BacthExecMap("test", "batchinsert", []map[string]interface{}{
+		{"id": 1, "code": "a", "nick": nil},
+		{"id": 1, "code": "b", "nick": nil},
+	})
+	if err == nil {
+		t.Fatal("duplicate key: want error")
+	}
+	var n int
+	if err = db.QueryRow("SELECT count(*) FROM user").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("after rollback: got %d rows, want 0", n)
+	}
+}
+
+func TestPagingAndCursor(t *testing.T) {
+	setupTestModel(t)
+	var sp []map[string]interface{}
+	for i := 1; i <= 5; i++ {
+		sp = append(sp, map[string]interface{}{"id": i, "code": fmt.Sprint("c", i), "nick": nil})
+	}
+	if err := BacthExecMap("test", "batchinsert", sp); err != nil {
+		t.Fatal(err)
+	}
+	page, err := PagingSelectMapToMap("test", "paging", map[string]interface{}{"limit": 2, "offset": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 5 || len(page.Data) != 2 || fmt.Sprint(page.Data[0]["id"]) != "3" {
+		t.Fatalf("paging: got %+v", page)
+	}
+	var ids []string
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		result, err := CursorSelectMapToMap("test", "cursor", map[string]interface{}{}, cursor, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range result.Data {
+			ids = append(ids, fmt.Sprint(row["id"]))
+		}
+		if cursor = result.Next; len(cursor) == 0 {
+			break
+		}
+	}
+	if fmt.Sprint(ids) != "[1 2 3 4 5]" {
+		t.Fatalf("cursor: got %v", ids)
+	}
+}
+
+func TestGetDB(t *testing.T) {
+	db := NewSQLDB(nil, nil)
+	RegDBResolver(func(name string) (DB, bool) {
+		return db, name == "resolved"
+	})
+	defer func() {
+		databases.Lock()
+		databases.resolvers = nil
+		databases.Unlock()
+	}()
+	if getDB("resolved") != db {
+		t.Fatal("want the resolved db")
+	}
+	// 未注册的数据库执行时返回错误
+	if _, err := getDB("unknown").Exec("SELECT 1"); err == nil {
+		t.Fatal("unknown database: want error")
+	}
+}
+
+func TestRegFrontend(t *testing.T) {
+	databases.Lock()
+	frontend, resolvers := databases.frontend, databases.resolvers
+	databases.frontend = ""
+	databases.Unlock()
+	defer func() {
+		databases.Lock()
+		databases.frontend, databases.resolvers = frontend, resolvers
+		databases.Unlock()
+	}()
+	db := NewSQLDB(nil, nil)
+	RegFrontend("directsql", func(name string) (DB, bool) { return db, name == "" })
+	RegFrontend("directsql", func(name string) (DB, bool) { return db, name == "other" })
+	if getDB("other") != db {
+		t.Fatal("want the db of the same front-end")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("want a panic when the other front-end registers")
+		}
+	}()
+	RegFrontend("directsqlx", func(name string) (DB, bool) { return db, true })
+}
+
+func TestBindNamed(t *testing.T) {
+	query, args, err := bindNamed("SELECT * FROM t WHERE a=?a AND (b=?b OR ?b IS NULL)", map[string]interface{}{"a": 1, "b": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "SELECT * FROM t WHERE a=? AND (b=? OR ? IS NULL)" || fmt.Sprint(args) != "[1 <nil> <nil>]" {
+		t.Fatalf("got %q %v", query, args)
+	}
+	if _, _, err = bindNamed("SELECT ?c", map[string]interface{}{}); err == nil {
+		t.Fatal("missing parameter: want error")
+	}
+}
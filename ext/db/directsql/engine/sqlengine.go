@@ -13,14 +13,15 @@
 	         ST_SETBLOB  //11 保存BLOB (binary large object)，二进制大对象到数据库
          -2016.11.20 将执行sql的execMap修改该可以执行多个配置的cmd，采用相同的参数
 */
-package directsql
+package engine
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/andeya/faygo"
-	"xorm.io/core"
 )
 
 // 根据sqlid获取 *TSql
@@ -36,7 +37,7 @@ func (m *TModel) findSql(sqlid string) *TSql {
 func (m *TModel) selectMap(se *TSql, mp map[string]interface{}) ([]map[string]interface{}, error) {
 	faygo.Debug("selectMap parameters :", mp)
 	// 执行sql
-	rows, err := m.DB.QueryMap(se.Cmds[0].Sql, &mp)
+	rows, err := traceQuery(m.db(), m.debug, se, se.Cmds[0], mp)
 	if err != nil {
 		return nil, err
 	}
@@ -44,6 +45,12 @@ func (m *TModel) selectMap(se *TSql, mp map[string]interface{}) ([]map[string]in
 	return rows2mapObjects(rows)
 }
 
+// 执行普通的单个查询SQL  mp 是MAP类型命名参数，返回 *sql.Rows 供流式导出，调用者负责关闭
+func (m *TModel) selectRows(se *TSql, mp map[string]interface{}) (*sql.Rows, error) {
+	faygo.Debug("selectRows parameters :", mp)
+	return traceQuery(m.db(), m.debug, se, se.Cmds[0], mp)
+}
+
 // 分頁查詢的返回結果
 type PagingSelectResult struct {
 	Total int                      `json:"total"`
@@ -54,22 +61,21 @@ type PagingSelectResult struct {
 func (m *TModel) pagingSelectMap(se *TSql, mp map[string]interface{}) (*PagingSelectResult, error) {
 	faygo.Debug("pagingSelectMap parameters :", mp)
 	// 获取总页数，約定該SQL放到第二條，並且只返回一條記錄一個字段
-	trows, err := m.DB.QueryMap(se.Cmds[0].Sql, &mp)
+	trows, err := traceQuery(m.db(), m.debug, se, se.Cmds[0], mp)
 	if err != nil {
 		return nil, err
 	}
 	defer trows.Close()
 	for trows.Next() {
 		var total = make([]int, 1)
-		err := trows.ScanSlice(&total)
-		if err != nil {
-			return nil, err
-		}
-		if len(total) != 1 {
+		if fields, err := trows.Columns(); err != nil || len(fields) != 1 {
 			return nil, errors.New("错误：获取总页数的SQL执行结果非唯一记录！")
 		}
+		if err := trows.Scan(&total[0]); err != nil {
+			return nil, err
+		}
 		// 2.获取当前页數據，約定該SQL放到第二條
-		rows, err := m.DB.QueryMap(se.Cmds[1].Sql, &mp)
+		rows, err := traceQuery(m.db(), m.debug, se, se.Cmds[1], mp)
 		if err != nil {
 			return nil, err
 		}
@@ -83,6 +89,75 @@ func (m *TModel) pagingSelectMap(se *TSql, mp map[string]interface{}) (*PagingSe
 	return nil, err
 }
 
+// 游标分页查询的返回结果，next 为下一页的游标，没有下一页时为空
+type CursorSelectResult struct {
+	Data []map[string]interface{} `json:"data"`
+	Next string                   `json:"next,omitempty"`
+}
+
+// 执行游标(keyset)分页查询SQL  mp 是MAP类型命名参数，cursor 为上一页返回的游标，为空则查询第一页
+// 第一个cmd查询第一页，第二个cmd（可选）根据游标查询之后的页，上一页最后一条记录的游标字段值作为参数 cursor_字段名，
+// 未定义第二个cmd则都执行第一个cmd，第一页的游标参数为 null；参数 limit 为每页记录数+1，用于判断是否存在下一页
+func (m *TModel) cursorSelectMap(se *TSql, mp map[string]interface{}, cursor string, limit int) (*CursorSelectResult, error) {
+	faygo.Debug("cursorSelectMap parameters :", mp)
+	fields := splitList(se.Cursor)
+	if len(fields) == 0 {
+		return nil, errors.New("错误：游标分页查询未配置 cursor 属性！")
+	}
+	cmd := se.Cmds[0]
+	var values []interface{}
+	if len(cursor) > 0 {
+		var err error
+		if values, err = decodeCursor(cursor, len(fields)); err != nil {
+			return nil, err
+		}
+		if len(se.Cmds) > 1 {
+			cmd = se.Cmds[1]
+		}
+	}
+	for i, field := range fields {
+		if values != nil {
+			mp["cursor_"+field] = values[i]
+		} else {
+			mp["cursor_"+field] = nil
+		}
+	}
+	if limit <= 0 || limit > se.Pagesize {
+		limit = se.Pagesize
+	}
+	mp["limit"] = limit + 1
+	rows, err := traceQuery(m.db(), m.debug, se, cmd, mp)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	data, err := rows2mapObjects(rows)
+	if err != nil {
+		return nil, err
+	}
+	result := &CursorSelectResult{Data: data}
+	if result.Data == nil {
+		result.Data = []map[string]interface{}{}
+	}
+	// 多查询的一条记录存在则说明存在下一页
+	if len(data) > limit {
+		result.Data = data[:limit]
+		last := result.Data[limit-1]
+		values = make([]interface{}, len(fields))
+		for i, field := range fields {
+			v, ok := last[field]
+			if !ok {
+				return nil, errors.New("错误：游标字段[" + field + "]不在查询结果中或者为null！")
+			}
+			values[i] = v
+		}
+		if result.Next, err = encodeCursor(values); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
 // 执行返回多個結果集的多個查询SQL， mp 是MAP类型命名参数 返回结果 map[string][]map[string][]string
 func (m *TModel) multiSelectMap(se *TSql, mp map[string]interface{}) (map[string][]map[string]interface{}, error) {
 	result := make(map[string][]map[string]interface{})
@@ -90,7 +165,7 @@ func (m *TModel) multiSelectMap(se *TSql, mp map[string]interface{}) (map[string
 	// 循環每個sql定義
 	for i, cmd := range se.Cmds {
 		faygo.Debug("MultiSelectMap :" + cmd.Sql)
-		rows, err := m.DB.QueryMap(cmd.Sql, &mp)
+		rows, err := traceQuery(m.db(), m.debug, se, cmd, mp)
 		if err != nil {
 			return nil, err
 		}
@@ -100,7 +175,7 @@ func (m *TModel) multiSelectMap(se *TSql, mp map[string]interface{}) (map[string
 		}
 		rows.Close()
 		if len(cmd.Rout) == 0 {
-			result["data"+string(i)] = single
+			result["data"+strconv.Itoa(i)] = single
 		} else {
 			result[cmd.Rout] = single
 		}
@@ -112,7 +187,7 @@ func (m *TModel) multiSelectMap(se *TSql, mp map[string]interface{}) (map[string
 // 根据 Idfield、Pidfield 构建嵌套的 map 结果集
 func (m *TModel) nestedSelectMap(se *TSql, mp map[string]interface{}) ([]map[string]interface{}, error) {
 	faygo.Debug("NestedSelectMap :" + se.Cmds[0].Sql)
-	rows, err := m.DB.QueryMap(se.Cmds[0].Sql, &mp)
+	rows, err := traceQuery(m.db(), m.debug, se, se.Cmds[0], mp)
 	defer rows.Close()
 	if err != nil {
 		return nil, err
@@ -142,14 +217,15 @@ type Execresult struct {
 */
 // 说明，将执行sql的execMap修改该可以执行多个配置的cmd，采用相同的参数---2016.11.20
 // 执行 UPDATE、DELETE、INSERT，mp 是 map[string]interface{}，可以配置多个sql语句，使用相同的参数执行。
+// 后面的 cmd 可以使用之前 cmd 的输出作为参数，见 execCmd
 func (m *TModel) execMap(se *TSql, mp map[string]interface{}) error {
 	faygo.Debug("ExecMap parameters :", mp)
-	return transact(m.DB, func(tx *core.Tx) error {
+	return transact(m.db(), func(tx Tx) error {
+		out := make(map[string]interface{})
 		// 循環每個sql定義
 		for _, cmd := range se.Cmds {
 			faygo.Debug("ExecMap sql:" + cmd.Sql)
-			_, err := tx.ExecMap(cmd.Sql, &mp)
-			if err != nil {
+			if err := m.execCmd(tx, se, cmd, mp, out); err != nil {
 				return err
 			}
 		}
@@ -157,36 +233,64 @@ func (m *TModel) execMap(se *TSql, mp map[string]interface{}) error {
 	})
 }
 
+// 每次循环使用单独事务时各个事务的错误，失败的事务回滚，其他事务照常提交
+type batchErrors struct {
+	first      error
+	optimistic bool
+}
+
+func (e *batchErrors) add(err error) {
+	if err == nil {
+		return
+	}
+	if e.first == nil {
+		e.first = err
+	}
+	if err == ErrOptimisticLock {
+		e.optimistic = true
+	}
+}
+
+// 返回第一个错误，有事务乐观锁检查失败时返回 ErrOptimisticLock，以便响应 409
+func (e *batchErrors) err() error {
+	if e.optimistic {
+		return ErrOptimisticLock
+	}
+	return e.first
+}
+
 // 批量执行 UPDATE、INSERT、sp 是MAP类型命名参数
 func (m *TModel) bacthExecMap(se *TSql, sp []map[string]interface{}) error {
 	faygo.Debug("BacthExecMap parameters :", sp)
 	// 如果执行sql的语句组使用每次循环使用单独的事务，则如下
 	if se.Eachtran {
+		var errs batchErrors
 		for _, p := range sp {
-			transact(m.DB, func(tx *core.Tx) error {
+			errs.add(transact(m.db(), func(tx Tx) error {
+				out := make(map[string]interface{})
 				// //////////////////////////////
 				// 循環每個sql定義
 				for _, cmd := range se.Cmds {
 					faygo.Debug("BacthExecMap sql:" + cmd.Sql)
-					_, err := tx.ExecMap(cmd.Sql, &p)
-					if err != nil {
+					if err := m.execCmd(tx, se, cmd, p, out); err != nil {
 						return err
 					}
 				}
 				return nil
 				// //////////////////////////////////
-			})
+			}))
 		}
+		return errs.err()
 		// 所有循环使用同一个事务
 	} else {
-		return transact(m.DB, func(tx *core.Tx) error {
+		return transact(m.db(), func(tx Tx) error {
 			for _, p := range sp {
+				out := make(map[string]interface{})
 				// //////////////////////////////
 				// 循環每個sql定義
 				for _, cmd := range se.Cmds {
 					faygo.Debug("BacthExecMap sql:" + cmd.Sql)
-					_, err := tx.ExecMap(cmd.Sql, &p)
-					if err != nil {
+					if err := m.execCmd(tx, se, cmd, p, out); err != nil {
 						return err
 					}
 				}
@@ -194,13 +298,12 @@ func (m *TModel) bacthExecMap(se *TSql, sp []map[string]interface{}) error {
 			return nil
 		})
 	}
-	return nil
 }
 
 /*根据循环参数在一个事务中执行sql语句组（一个sql下多个cmd）
 func (m *TModel) bacthExecMap(se *TSql, sp []map[string]interface{}) error {
 	faygo.Debug("BacthExecMap parameters :", sp)
-	return transact(m.DB, func(tx *core.Tx) error {
+	return transact(m.db(), func(tx Tx) error {
 		for _, p := range sp {
 			////////////////////////////////
 			//循環每個sql定義
@@ -220,7 +323,7 @@ func (m *TModel) bacthExecMap(se *TSql, sp []map[string]interface{}) error {
 /*原来的之只能根据参数循环执行一个exec语句
 func (m *TModel) bacthExecMap(se *TSql, sp []map[string]interface{}) error {
 	faygo.Debug("BacthExecMap parameters :", sp)
-	return transact(m.DB, func(tx *core.Tx) error {
+	return transact(m.db(), func(tx Tx) error {
 		for _, p := range sp {
 			faygo.Debug("BacthExecMap :" + se.Cmds[0].Sql)
 			if _, err := tx.ExecMap(se.Cmds[0].Sql, &p); err != nil {
@@ -238,36 +341,46 @@ func (m *TModel) bacthMultiExecMap(se *TSql, mp map[string][]map[string]interfac
 	// 比如有4个SQL ，其中 1，2使用同一个参数组则1，2组合在一起每次循环使用一个事务，多次循环多个事务
 	// ，3，4分别使用不同的参数组则各自也在不同的事务，规则同1，2
 	if se.Eachtran {
+		var errs batchErrors
 		// 根据参数循环
 		for key, sp := range mp {
 			for _, p := range sp {
-				transact(m.DB, func(tx *core.Tx) error {
+				errs.add(transact(m.db(), func(tx Tx) error {
+					out := make(map[string]interface{})
 					// 循環每個sql定義
 					for _, cmd := range se.Cmds {
 						// 将使用相同参数的在一个事务执行
 						if cmd.Pin == key {
 							faygo.Debug("BacthMultiExecMap-EachTran :" + cmd.Sql)
-							if _, err := tx.ExecMap(cmd.Sql, &p); err != nil {
+							if err := m.execCmd(tx, se, cmd, p, out); err != nil {
 								return err
 							}
 						}
 					}
 					return nil
-				})
+				}))
 			}
 		}
+		return errs.err()
 	} else { // 原来的方式，在所有sql同一个事务中执行
-		return transact(m.DB, func(tx *core.Tx) error {
+		return transact(m.db(), func(tx Tx) error {
+			out := make(map[string]interface{})
 			// 循環每個sql定義
 			for _, cmd := range se.Cmds {
 				// 循環其批量參數
 				if sp, ok := mp[cmd.Pin]; ok {
 					for _, p := range sp {
 						faygo.Debug("BacthMultiExecMap :" + cmd.Sql)
-						if _, err := tx.ExecMap(cmd.Sql, &p); err != nil {
+						if err := m.execCmd(tx, se, cmd, p, out); err != nil {
 							return err
 						}
 					}
+				} else if len(cmd.Pin) == 0 {
+					// 未配置 in 的 cmd 只使用之前 cmd 的输出执行一次
+					faygo.Debug("BacthMultiExecMap :" + cmd.Sql)
+					if err := m.execCmd(tx, se, cmd, map[string]interface{}{}, out); err != nil {
+						return err
+					}
 				} else {
 					return errors.New("错误：传入的参数与SQL节点定义的sql.pin名称不匹配！")
 				}
@@ -275,12 +388,11 @@ func (m *TModel) bacthMultiExecMap(se *TSql, mp map[string][]map[string]interfac
 			return nil
 		})
 	}
-	return nil
 }
 
 /* 原来代码 批量执行 BacthMultiExec、mp 是map[string][]map[string]interface{}参数,在同一个事务中依次执行
 func (m *TModel) bacthMultiExecMap(se *TSql, mp map[string][]map[string]interface{}) error {
-	return transact(m.DB, func(tx *core.Tx) error {
+	return transact(m.db(), func(tx Tx) error {
 		//循環每個sql定義
 		for _, cmd := range se.Cmds {
 			//循環其批量參數
@@ -306,7 +418,7 @@ func addData(db *sql.DB, data []byte) error {
 }*/
 func (m *TModel) setBLOB(se *TSql, mp map[string]interface{}) error {
 	faygo.Debug("setBLOB :" + se.Cmds[0].Sql)
-	_, err := m.DB.ExecMap(se.Cmds[0].Sql, &mp)
+	_, err := traceExec(m.db(), m.debug, se, se.Cmds[0], mp)
 	if err != nil {
 		return err
 	}
@@ -317,7 +429,7 @@ func (m *TModel) setBLOB(se *TSql, mp map[string]interface{}) error {
 func (m *TModel) getBLOB(se *TSql, mp map[string]interface{}) ([]byte, error) {
 	faygo.Debug("getBLOB parameters :", mp)
 	// 执行sql
-	rows, err := m.DB.QueryMap(se.Cmds[0].Sql, &mp)
+	rows, err := traceQuery(m.db(), m.debug, se, se.Cmds[0], mp)
 	if err != nil {
 		return nil, err
 	}
@@ -345,7 +457,7 @@ func (m *TModel) getBLOB(se *TSql, mp map[string]interface{}) ([]byte, error) {
 }
 
 // ransaction handler 封装在一个事务中执行多个SQL语句
-func transact(db *core.DB, txFunc func(*core.Tx) error) (err error) {
+func transact(db DB, txFunc func(Tx) error) (err error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return
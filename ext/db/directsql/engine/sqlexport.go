@@ -0,0 +1,191 @@
+/**
+* desc   : 查询结果的流式导出
+* desc   : 直接从 sql.Rows 逐行写入响应，不在内存中缓存整个结果集，
+*          支持 CSV、Excel 兼容的 CSV（UTF-8 BOM、CRLF换行、防公式注入）与 NDJSON（每行一个JSON对象）。
+*/
+package engine
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/andeya/faygo"
+)
+
+// 导出格式
+const (
+	EF_CSV    = "csv"    // CSV
+	EF_EXCEL  = "excel"  // Excel 兼容的 CSV，可以直接用 Excel 打开
+	EF_NDJSON = "ndjson" // 每行一个 JSON 对象
+)
+
+// 导出时每写入多少行刷新一次响应
+var exportFlushRows = 500
+
+// 根据参数 format（POST 参数或 URL 查询参数）或 Accept 头确定导出格式，不导出则返回空。
+// 只有值为导出格式的 POST 参数 format 才从参数中移除，否则仍作为 sql 的参数 ?format
+func exportFormat(ctx *faygo.Context, mp map[string]interface{}) string {
+	if s, ok := mp["format"].(string); ok {
+		if format := parseExportFormat(s); len(format) > 0 {
+			delete(mp, "format")
+			return format
+		}
+	}
+	if format := parseExportFormat(ctx.QueryParam("format")); len(format) > 0 {
+		return format
+	}
+	for _, accept := range strings.Split(ctx.HeaderParam(faygo.HeaderAccept), ",") {
+		switch strings.TrimSpace(strings.Split(accept, ";")[0]) {
+		case "text/csv":
+			return EF_CSV
+		case "application/vnd.ms-excel", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+			return EF_EXCEL
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			return EF_NDJSON
+		}
+	}
+	return ""
+}
+
+// 导出格式的名称，不是导出格式则返回空
+func parseExportFormat(format string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "csv":
+		return EF_CSV
+	case "excel", "xlsx", "xls":
+		return EF_EXCEL
+	case "ndjson", "jsonl":
+		return EF_NDJSON
+	}
+	return ""
+}
+
+// 将查询结果流式发送到客户端，name 为下载的文件名（不含扩展名）
+func sendExport(ctx *faygo.Context, name, format string, rows *sql.Rows) error {
+	defer rows.Close()
+	contentType, ext := "text/csv; charset=utf-8", ".csv"
+	if format == EF_NDJSON {
+		contentType, ext = "application/x-ndjson", ".ndjson"
+	}
+	ctx.SetHeader(faygo.HeaderContentType, contentType)
+	ctx.SetHeader(faygo.HeaderContentDisposition, `attachment; filename="`+name+ext+`"`)
+	ctx.W.WriteHeader(200)
+	if err := exportRows(ctx.W, rows, format); err != nil {
+		// 响应已经开始发送，只能记录错误
+		faygo.Error("Error: directsql export " + name + ", " + err.Error())
+	}
+	return nil
+}
+
+// 将 rows 按格式逐行写入 w，w 实现 http.Flusher 则每 exportFlushRows 行刷新一次
+func exportRows(w io.Writer, rows *sql.Rows, format string) error {
+	fields, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	var (
+		csvw *csv.Writer
+		enc  *json.Encoder
+	)
+	if format == EF_NDJSON {
+		enc = json.NewEncoder(bw)
+	} else {
+		if format == EF_EXCEL {
+			// UTF-8 BOM，Excel 据此识别编码
+			bw.WriteString("\xEF\xBB\xBF")
+		}
+		csvw = csv.NewWriter(bw)
+		csvw.UseCRLF = format == EF_EXCEL
+		if err = csvw.Write(fields); err != nil {
+			return err
+		}
+	}
+	flush := func() error {
+		if csvw != nil {
+			csvw.Flush()
+			if err := csvw.Error(); err != nil {
+				return err
+			}
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+	values := make([]interface{}, len(fields))
+	containers := make([]interface{}, len(fields))
+	for i := range values {
+		containers[i] = &values[i]
+	}
+	record := make([]string, len(fields))
+	for n := 1; rows.Next(); n++ {
+		for i := range values {
+			values[i] = nil
+		}
+		if err = rows.Scan(containers...); err != nil {
+			return err
+		}
+		if enc != nil {
+			obj := make(map[string]interface{}, len(fields))
+			for i, field := range fields {
+				obj[field] = nil
+				if values[i] == nil {
+					continue
+				}
+				rawValue := reflect.ValueOf(values[i])
+				if obj[field], err = value2Object(&rawValue); err != nil {
+					return err
+				}
+			}
+			err = enc.Encode(obj)
+		} else {
+			for i := range fields {
+				record[i] = ""
+				if values[i] == nil {
+					continue
+				}
+				rawValue := reflect.ValueOf(values[i])
+				if record[i], err = value2String(&rawValue); err != nil {
+					return err
+				}
+				if format == EF_EXCEL {
+					switch values[i].(type) {
+					case string, []byte:
+						record[i] = escapeFormula(record[i])
+					}
+				}
+			}
+			err = csvw.Write(record)
+		}
+		if err != nil {
+			return err
+		}
+		if n%exportFlushRows == 0 {
+			if err = flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// 防止 Excel 将文本当作公式执行（CSV注入）
+func escapeFormula(s string) string {
+	if len(s) > 0 && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
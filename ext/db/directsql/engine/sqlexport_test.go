@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	setupTestModel(t)
+	insertUsers(t, "a", "=1+1")
+	var tests = []struct {
+		sqlid  string
+		header http.Header
+		para   string
+		want   string
+	}{
+		{"select", nil, `{"code":"a","format":"csv"}`, "id,code,nick\n1,a,\n"},
+		{"select?format=ndjson", nil, `{"code":"a"}`, `{"code":"a","id":1,"nick":null}` + "\n"},
+		{"select", http.Header{"Accept": {"text/csv"}}, `{"code":"a"}`, "id,code,nick\n1,a,\n"},
+		{"select", nil, `{"code":"=1+1","format":"xlsx"}`, "\xEF\xBB\xBFid,code,nick\r\n2,'=1+1,\r\n"},
+		{"export", nil, `{}`, "id,code,nick\n1,a,\n2,=1+1,\n"},
+		{"export", nil, `{"format":"jsonl"}`, `{"code":"a","id":1,"nick":null}` + "\n" + `{"code":"=1+1","id":2,"nick":null}` + "\n"},
+		// 不是导出格式的 format 作为 sql 的参数
+		{"byformat", nil, `{"format":"a"}`, `[{"code":"a","id":1}]`},
+	}
+	for _, test := range tests {
+		code, body := postSQL(t, test.sqlid, test.header, test.para)
+		if code != 200 || body != test.want {
+			t.Errorf("%s %s: got %d %q, want %q", test.sqlid, test.para, code, body, test.want)
+		}
+	}
+}
+
+func TestExportMap(t *testing.T) {
+	setupTestModel(t)
+	insertUsers(t, "a", "b")
+	var buf bytes.Buffer
+	if err := ExportMap("test", "export", map[string]interface{}{}, EF_EXCEL, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "\xEF\xBB\xBFid,code,nick\r\n1,a,\r\n2,b,\r\n" {
+		t.Fatalf("got %q", got)
+	}
+	if err := ExportMap("test", "insert", map[string]interface{}{}, EF_CSV, &buf); err == nil {
+		t.Fatal("export of an insert sql: want error")
+	}
+}
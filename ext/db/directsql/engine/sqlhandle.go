@@ -7,7 +7,7 @@
          2016.10.17：优化 select与pagingselect返回结果，当数据为空时返回[]
 *
 */
-package directsql
+package engine
 
 import (
 	"bytes"
@@ -37,7 +37,26 @@ func DirectSQL() faygo.HandlerFunc {
 			faygo.Error("Error: sql is not defined in the model file, " + modelId + "/" + sqlId) // 错误：Model文件中未定义sql:
 			return ctx.JSONMsg(404, 404, "Error: sql is not defined in the model file, "+modelId+"/"+sqlId)
 		}
-		// 4.根据SQL类型分别处理执行并返回结果信息
+		// 4.检查当前用户是否有权执行该sql
+		if !authorize(ctx, se) {
+			faygo.Error("Error: permission denied, " + modelId + "/" + sqlId)
+			return ctx.JSONMsg(403, 403, "Error: permission denied, "+modelId+"/"+sqlId)
+		}
+		// 调试模式：只允许配置的IP或角色使用，调试时不使用缓存
+		d, err := requestDebug(ctx)
+		if err != nil {
+			faygo.Error(err.Error())
+			return ctx.JSONMsg(400, 400, err.Error())
+		}
+		debugging := d != nil
+		if debugging {
+			if !debugAllowed(ctx, currentDebugConfig()) {
+				faygo.Error("Error: debug mode is not allowed, " + modelId + "/" + sqlId)
+				return ctx.JSONMsg(403, 403, "Error: debug mode is not allowed, "+modelId+"/"+sqlId)
+			}
+			m = startDebug(ctx, m, d)
+		}
+		// 5.根据SQL类型分别处理执行并返回结果信息
 		switch se.Sqltype {
 		case ST_PAGINGSELECT: // 分页选择SQL，分頁查詢結果cache第一次查询的结果
 			// .1 获取POST参数並轉換
@@ -60,7 +79,14 @@ func DirectSQL() faygo.HandlerFunc {
 				}
 			}
 			// .2 判断是否是缓存的并存在有效缓存，存在则直接从缓存返回
-			if se.Cached {
+			if se.Cached && !debugging {
+				// 强制参数的值作为缓存key的一部分，避免不同用户共享缓存
+				for _, cmd := range se.Cmds {
+					if err := enforceParameters(cmd.Parameters, jsonpara, ctx); err != nil {
+						faygo.Error(err.Error())
+						return sendMsg(ctx, 400, 400, err.Error())
+					}
+				}
 				// 构造缓存查询key
 				key := modelId + "/" + sqlId
 				// 缓存识别的后缀
@@ -70,7 +96,7 @@ func DirectSQL() faygo.HandlerFunc {
 				}
 				suffix := string(sf)
 				// 如果OK则直接返回缓存
-				if ok, jsonb := GetCache(key, suffix); ok {
+				if ok, jsonb := getCache(se, key, suffix); ok {
 					faygo.Debug("Directsql getCache:[" + key + " - " + suffix + "] result from cache.")
 					// 发送JSON(P)响应
 					return sendJSON(ctx, callback, jsonb)
@@ -79,37 +105,41 @@ func DirectSQL() faygo.HandlerFunc {
 			// .3 检查sql语句配置个数
 			if len(se.Cmds) != 2 {
 				faygo.Error("Error: paging query must define two sql nodes, one for total number and one for data query!") // 错误：分页查询必须定义2个SQL节点，一个获取总页数另一个用于查询数据！
-				return ctx.JSONMsg(404, 404, "Error: paging query must define two sql nodes, one for total number and one for data query!")
+				return sendMsg(ctx, 404, 404, "Error: paging query must define two sql nodes, one for total number and one for data query!")
 			}
 
-			// .5 参数验证并处理(参数定义到真正查询结果的cmd下)－OK
-			_, err = dealwithParameter(se.Cmds[1].Parameters, jsonpara, ctx)
-			if err != nil {
-				faygo.Error(err.Error())
-				return ctx.JSONMsg(400, 400, err.Error())
+			// .5 参数验证并处理(计数与查询结果的cmd都要处理，两者共用参数)－OK
+			for _, cmd := range se.Cmds {
+				if _, err = dealwithParameter(cmd.Parameters, jsonpara, ctx); err != nil {
+					faygo.Error(err.Error())
+					return sendMsg(ctx, 400, 400, err.Error())
+				}
+			}
+			if isDryRun(ctx) {
+				return sendDryRun(ctx, se, jsonpara)
 			}
 			// .6 執行並返回結果
 			data, err := m.pagingSelectMap(se, jsonpara)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				return sendMsg(ctx, 404, 404, err.Error())
 			}
 			// .7 如果需要缓存则缓存结果集(cached=true 并且缓存不存在或失效才会执行)
 			jsonb, err := intface2json(data)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				return sendMsg(ctx, 404, 404, err.Error())
 			}
 			// 结果集为空响应
 			if data.Total == 0 {
-				err := ctx.JSONBlob(200, []byte(`{"total":0,"data":[]}`))
+				err := sendJSON(ctx, "", []byte(`{"total":0,"data":[]}`))
 				if err != nil {
 					return err
 				}
 				return nil
 			}
 			// 如果需要缓存则
-			if se.Cached {
+			if se.Cached && !debugging {
 				// 构造缓存查询key
 				key := modelId + "/" + sqlId
 				// 缓存识别的后缀
@@ -118,7 +148,7 @@ func DirectSQL() faygo.HandlerFunc {
 					sf = nil
 				}
 				suffix := string(sf)
-				SetCache(key, suffix, jsonb, se.Cachetime)
+				setCache(se, key, suffix, jsonb)
 				faygo.Debug("Directsql setCache:[" + key + "] result to cache.")
 			}
 			// 发送JSON(P)响应
@@ -145,8 +175,19 @@ func DirectSQL() faygo.HandlerFunc {
 					delete(jsonpara, "callback")
 				}
 			}
+			// . 导出格式，导出时流式返回全部结果，不使用缓存
+			if format := exportFormat(ctx, jsonpara); len(format) > 0 {
+				return exportSelect(ctx, m, se, jsonpara, format)
+			}
 			// .2 判断是否是缓存的并存在有效缓存，存在则直接从缓存返回
-			if se.Cached {
+			if se.Cached && !debugging {
+				// 强制参数的值作为缓存key的一部分，避免不同用户共享缓存
+				for _, cmd := range se.Cmds {
+					if err := enforceParameters(cmd.Parameters, jsonpara, ctx); err != nil {
+						faygo.Error(err.Error())
+						return sendMsg(ctx, 400, 400, err.Error())
+					}
+				}
 				// 构造缓存查询key
 				key := modelId + "/" + sqlId
 				// 缓存识别的后缀
@@ -156,7 +197,7 @@ func DirectSQL() faygo.HandlerFunc {
 				}
 				suffix := string(sf)
 				// 如果OK则直接返回缓存
-				if ok, jsonb := GetCache(key, suffix); ok {
+				if ok, jsonb := getCache(se, key, suffix); ok {
 					faygo.Debug("Directsql getCache:[" + key + "] result from cache.")
 					// 发送JSON(P)响应
 					return sendJSON(ctx, callback, jsonb)
@@ -166,30 +207,33 @@ func DirectSQL() faygo.HandlerFunc {
 			_, err = dealwithParameter(se.Cmds[0].Parameters, jsonpara, ctx)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(400, 400, err.Error())
+				return sendMsg(ctx, 400, 400, err.Error())
+			}
+			if isDryRun(ctx) {
+				return sendDryRun(ctx, se, jsonpara)
 			}
 			// .4 執行並返回結果
 			data, err := m.selectMap(se, jsonpara)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				return sendMsg(ctx, 404, 404, err.Error())
 			}
 			// .7 如果需要缓存则缓存结果集(cached=true 并且缓存不存在或失效才会执行)
 			jsonb, err := intface2json(data)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				return sendMsg(ctx, 404, 404, err.Error())
 			}
 			// 结果集为空响应
 			if len(data) == 0 {
-				err := ctx.JSONBlob(200, []byte(`[]`))
+				err := sendJSON(ctx, "", []byte(`[]`))
 				if err != nil {
 					return err
 				}
 				return nil
 			}
 			// 如果需要缓存则
-			if se.Cached {
+			if se.Cached && !debugging {
 				// 构造缓存查询key
 				key := modelId + "/" + sqlId
 				// 缓存识别的后缀
@@ -198,18 +242,82 @@ func DirectSQL() faygo.HandlerFunc {
 					sf = nil
 				}
 				suffix := string(sf)
-				SetCache(key, suffix, jsonb, se.Cachetime)
+				setCache(se, key, suffix, jsonb)
 				faygo.Debug("Directsql setCache:[" + key + "] result to cache.")
 			}
 			// 发送JSON(P)响应
 			return sendJSON(ctx, callback, jsonb)
+		case ST_EXPORT: // 导出查询结果，格式由参数 format 或 Accept 头确定，默认为 CSV
+			var jsonpara map[string]interface{}
+			err := ctx.BindJSON(&jsonpara) // 从Body获取JSON参数
+			if err != nil {
+				faygo.Debug("Info: POST para is empty," + err.Error())
+				if jsonpara == nil {
+					jsonpara = make(map[string]interface{})
+				}
+			}
+			format := exportFormat(ctx, jsonpara)
+			if len(format) == 0 {
+				format = EF_CSV
+			}
+			return exportSelect(ctx, m, se, jsonpara, format)
+
+		case ST_CURSORSELECT: // 游标分页查询
+			// .1 获取POST参数並轉換
+			var jsonpara map[string]interface{}
+			err := ctx.BindJSON(&jsonpara) // 从Body获取JSON参数
+			if err != nil {
+				faygo.Debug("Info: POST para is empty," + err.Error())
+				if jsonpara == nil {
+					jsonpara = make(map[string]interface{})
+				}
+			}
+			// . 常規參數處理：callback、游标与每页记录数
+			var callback, cursor string
+			var limit int
+			if v, ok := jsonpara["callback"].(string); ok {
+				callback = v
+			}
+			if v, ok := jsonpara["cursor"].(string); ok {
+				cursor = v
+			}
+			if v, ok := jsonpara["limit"].(float64); ok {
+				limit = int(v)
+			}
+			delete(jsonpara, "callback")
+			delete(jsonpara, "cursor")
+			delete(jsonpara, "limit")
+			// .2 参数验证并处理
+			for _, cmd := range se.Cmds {
+				if _, err = dealwithParameter(cmd.Parameters, jsonpara, ctx); err != nil {
+					faygo.Error(err.Error())
+					return sendMsg(ctx, 400, 400, err.Error())
+				}
+			}
+			if isDryRun(ctx) {
+				return sendDryRun(ctx, se, jsonpara)
+			}
+			// .3 執行並返回結果
+			data, err := m.cursorSelectMap(se, jsonpara, cursor, limit)
+			if err != nil {
+				faygo.Error(err.Error())
+				return sendMsg(ctx, 404, 404, err.Error())
+			}
+			jsonb, err := intface2json(data)
+			if err != nil {
+				faygo.Error(err.Error())
+				return sendMsg(ctx, 404, 404, err.Error())
+			}
+			// 发送JSON(P)响应
+			return sendJSON(ctx, callback, jsonb)
+
 		case ST_MULTISELECT: // 返回多結果集選擇
 			// .1 获取POST参数並轉換
 			var jsonpara map[string]interface{}
 			err := ctx.BindJSON(&jsonpara) // 从Body获取JSON参数
 			if err != nil {
 				faygo.Info("Info:POST para is empty," + err.Error())
-				// return ctx.JSONMsg(404, 404, err.Error())
+				// return sendMsg(ctx, 404, 404, err.Error())
 				// 如果参数为空则会触发EOF错误,不应该退出因为可能本来就没有参数，也就是jsonpara仍旧为空，需要创建该变量，后续sql中的参数处理需要
 				if jsonpara == nil {
 					jsonpara = make(map[string]interface{})
@@ -225,7 +333,14 @@ func DirectSQL() faygo.HandlerFunc {
 				}
 			}
 			// .2 判断是否是缓存的并存在有效缓存，存在则直接从缓存返回
-			if se.Cached {
+			if se.Cached && !debugging {
+				// 强制参数的值作为缓存key的一部分，避免不同用户共享缓存
+				for _, cmd := range se.Cmds {
+					if err := enforceParameters(cmd.Parameters, jsonpara, ctx); err != nil {
+						faygo.Error(err.Error())
+						return sendMsg(ctx, 400, 400, err.Error())
+					}
+				}
 				// 构造缓存查询key
 				key := modelId + "/" + sqlId
 				// 缓存识别的后缀
@@ -235,7 +350,7 @@ func DirectSQL() faygo.HandlerFunc {
 				}
 				suffix := string(sf)
 				// 如果OK则直接返回缓存
-				if ok, jsonb := GetCache(key, suffix); ok {
+				if ok, jsonb := getCache(se, key, suffix); ok {
 					faygo.Debug("GetCache:[" + key + " - " + suffix + "] result from cache.")
 					// 发送JSON(P)响应
 					return sendJSON(ctx, callback, jsonb)
@@ -250,31 +365,34 @@ func DirectSQL() faygo.HandlerFunc {
 				_, err = dealwithParameter(cmd.Parameters, jsonpara, ctx)
 				if err != nil {
 					faygo.Error(err.Error())
-					return ctx.JSONMsg(400, 400, err.Error())
+					return sendMsg(ctx, 400, 400, err.Error())
 				}
 			}
+			if isDryRun(ctx) {
+				return sendDryRun(ctx, se, jsonpara)
+			}
 			// .4 執行並返回結果
 			data, err := m.multiSelectMap(se, jsonpara)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				return sendMsg(ctx, 404, 404, err.Error())
 			}
 			// .7 如果需要缓存则缓存结果集(cached=true 并且缓存不存在或失效才会执行)
 			jsonb, err := intface2json(data)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				return sendMsg(ctx, 404, 404, err.Error())
 			}
 			// 结果集为空响应
 			if len(data) == 0 {
-				err := ctx.JSONBlob(200, []byte(`[]`))
+				err := sendJSON(ctx, "", []byte(`[]`))
 				if err != nil {
 					return err
 				}
 				return nil
 			}
 			// 如果需要缓存则
-			if se.Cached {
+			if se.Cached && !debugging {
 				// 构造缓存查询key
 				key := modelId + "/" + sqlId
 				// 缓存识别的后缀
@@ -283,7 +401,7 @@ func DirectSQL() faygo.HandlerFunc {
 					sf = nil
 				}
 				suffix := string(sf)
-				SetCache(key, suffix, jsonb, se.Cachetime)
+				setCache(se, key, suffix, jsonb)
 				faygo.Debug("Directsql setCache:[" + key + " - " + suffix + "] result to cache.")
 			}
 			// 发送JSON(P)响应
@@ -295,7 +413,7 @@ func DirectSQL() faygo.HandlerFunc {
 			err := ctx.BindJSON(&jsonpara) // 从Body获取 json参数
 			if err != nil {
 				faygo.Info("Info: POST para is empty," + err.Error())
-				// return ctx.JSONMsg(404, 404, err.Error())
+				// return sendMsg(ctx, 404, 404, err.Error())
 				// 如果参数为空则会触发EOF错误,不应该退出因为可能本来就没有参数，也就是jsonpara仍旧为空，需要创建该变量，后续sql中的参数处理需要
 				if jsonpara == nil {
 					jsonpara = make(map[string]interface{})
@@ -305,20 +423,25 @@ func DirectSQL() faygo.HandlerFunc {
 			result, err := dealwithParameter(se.Cmds[0].Parameters, jsonpara, ctx)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(400, 400, err.Error())
+				return sendMsg(ctx, 400, 400, err.Error())
+			}
+			if isDryRun(ctx) {
+				return sendDryRun(ctx, se, jsonpara)
 			}
 			// .3.执行sql
 			err = m.execMap(se, jsonpara)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				status := execErrorStatus(err)
+				return sendMsg(ctx, status, status, err.Error())
 			}
+			invalidateCache(se)
 			// return ctx.JSON(200, result)
 			// 如果存在服务端生成的uuid参数的则返回到客户端
 			if (result != nil) && (len(result) > 0) {
-				return ctx.JSONMsg(200, 200, result)
+				return sendMsg(ctx, 200, 200, result)
 			} else {
-				return ctx.JSONMsg(200, 200, "Info: Exec sql ok!")
+				return sendMsg(ctx, 200, 200, "Info: Exec sql ok!")
 			}
 
 		case ST_BATCHEXEC: // 批量执行--原来的批量插入
@@ -327,7 +450,7 @@ func DirectSQL() faygo.HandlerFunc {
 			err := ctx.BindJSON(&jsonpara) // 从Body获取 json参数
 			if err != nil {
 				faygo.Info("Info: POST para is empty," + err.Error())
-				// return ctx.JSONMsg(404, 404, err.Error())
+				// return sendMsg(ctx, 404, 404, err.Error())
 				// 如果参数为空则会触发EOF错误,不应该退出因为可能本来就没有参数，也就是jsonpara仍旧为空，需要创建该变量，后续sql中的参数处理需要
 				if jsonpara == nil {
 					jsonpara = make([]map[string]interface{}, 0)
@@ -343,7 +466,7 @@ func DirectSQL() faygo.HandlerFunc {
 					result, err := dealwithParameter(se.Cmds[0].Parameters, jp, ctx)
 					if err != nil {
 						faygo.Error(err.Error())
-						return ctx.JSONMsg(400, 400, err.Error())
+						return sendMsg(ctx, 400, 400, err.Error())
 					}
 					//
 					if len(result) > 0 {
@@ -352,17 +475,22 @@ func DirectSQL() faygo.HandlerFunc {
 
 				}
 			}
+			if isDryRun(ctx) {
+				return sendDryRun(ctx, se, jsonpara)
+			}
 			// .3.执行sql并返回结果
 			err = m.bacthExecMap(se, jsonpara)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				status := execErrorStatus(err)
+				return sendMsg(ctx, status, status, err.Error())
 			}
+			invalidateCache(se)
 			// 如果存在服务端生成的uuid参数的则返回到客户端
 			if (results != nil) && (len(results) > 0) {
-				return ctx.JSONMsg(200, 200, results)
+				return sendMsg(ctx, 200, 200, results)
 			} else {
-				return ctx.JSONMsg(200, 200, "Bacth exec sql ok!")
+				return sendMsg(ctx, 200, 200, "Bacth exec sql ok!")
 			}
 
 		case ST_BATCHMULTIEXEC: // 批量複合語句
@@ -371,7 +499,7 @@ func DirectSQL() faygo.HandlerFunc {
 			err := ctx.BindJSON(&jsonpara) // 从Body获取 json参数
 			if err != nil {
 				faygo.Info("Info: POST para is empty," + err.Error())
-				// return ctx.JSONMsg(404, 404, err.Error())
+				// return sendMsg(ctx, 404, 404, err.Error())
 				// 如果参数为空则会触发EOF错误,不应该退出因为可能本来就没有参数，也就是jsonpara仍旧为空，需要创建该变量，后续sql中的参数处理需要
 				if jsonpara == nil {
 					jsonpara = make(map[string][]map[string]interface{})
@@ -394,7 +522,7 @@ func DirectSQL() faygo.HandlerFunc {
 						result2, err := dealwithParameter(cmd.Parameters, p, ctx)
 						if err != nil {
 							faygo.Error(err.Error())
-							return ctx.JSONMsg(400, 400, err.Error())
+							return sendMsg(ctx, 400, 400, err.Error())
 						}
 						if len(result2) > 0 {
 							result1 = append(result1, result2)
@@ -405,17 +533,22 @@ func DirectSQL() faygo.HandlerFunc {
 					}
 				}
 			}
+			if isDryRun(ctx) {
+				return sendDryRun(ctx, se, jsonpara)
+			}
 			// .3.执行sql并返回结果
 			err = m.bacthMultiExecMap(se, jsonpara)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				status := execErrorStatus(err)
+				return sendMsg(ctx, status, status, err.Error())
 			}
+			invalidateCache(se)
 			// 如果存在服务端生成的uuid参数的则返回到客户端
 			if (results != nil) && (len(results) > 0) {
-				return ctx.JSONMsg(200, 200, results)
+				return sendMsg(ctx, 200, 200, results)
 			} else {
-				return ctx.JSONMsg(200, 200, "Bacth Multi Exec sql ok!")
+				return sendMsg(ctx, 200, 200, "Bacth Multi Exec sql ok!")
 			}
 		case ST_GETBLOB: // 执行sql从数据库中获取BLOB字段的二进制流
 			// faygo.Info("Info: getblob sql")
@@ -430,20 +563,23 @@ func DirectSQL() faygo.HandlerFunc {
 			_, err := dealwithParameter(se.Cmds[0].Parameters, jsonpara, ctx)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(400, 400, err.Error())
+				return sendMsg(ctx, 400, 400, err.Error())
+			}
+			if isDryRun(ctx) {
+				return sendDryRun(ctx, se, jsonpara)
 			}
 			// 執行並返回結果
 			data, err := m.getBLOB(se, jsonpara)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				return sendMsg(ctx, 404, 404, err.Error())
 			}
 			// faygo.Debug("getblob  :", data)
 			// 流方式返回二进制结果
 			err = ctx.Bytes(200, "application/octet-stream", data)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				return sendMsg(ctx, 404, 404, err.Error())
 			}
 			return nil
 
@@ -452,13 +588,13 @@ func DirectSQL() faygo.HandlerFunc {
 			// 如果不存在提交的文件
 			if !ctx.HasFormFile("inputfile") {
 				// faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, errors.New("error: Not has inputfile tag!"))
+				return sendMsg(ctx, 404, 404, errors.New("error: Not has inputfile tag!"))
 			}
 			// 获取提交的二进制数据
 			f, _, err := ctx.R.FormFile("inputfile")
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(400, 400, err.Error())
+				return sendMsg(ctx, 400, 400, err.Error())
 			}
 			defer func() {
 				err2 := f.Close()
@@ -470,7 +606,7 @@ func DirectSQL() faygo.HandlerFunc {
 			blobdata, err := ioutil.ReadAll(f)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(400, 400, err.Error())
+				return sendMsg(ctx, 400, 400, err.Error())
 			}
 			var jsonpara map[string]interface{}
 			// query paramaters
@@ -492,25 +628,29 @@ func DirectSQL() faygo.HandlerFunc {
 			result, err := dealwithParameter(se.Cmds[0].Parameters, jsonpara, ctx)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(400, 400, err.Error())
+				return sendMsg(ctx, 400, 400, err.Error())
 			}
 
 			// jsonpara["doc"] = blobdata
 			// faygo.Debug("setblob para:", jsonpara)
+			if isDryRun(ctx) {
+				return sendDryRun(ctx, se, jsonpara)
+			}
 			// 执行 setBLOB 操作，保存数据。
 			err = m.setBLOB(se, jsonpara)
 			if err != nil {
 				faygo.Error(err.Error())
-				return ctx.JSONMsg(404, 404, err.Error())
+				return sendMsg(ctx, 404, 404, err.Error())
 			}
+			invalidateCache(se)
 			// 如果存在服务端生成并需要返回的参数的则返回到客户端
 			if (result != nil) && (len(result) > 0) {
-				return ctx.JSONMsg(200, 200, result)
+				return sendMsg(ctx, 200, 200, result)
 			}
-			return ctx.JSONMsg(200, 200, "Exec setBLOB sql ok!")
+			return sendMsg(ctx, 200, 200, "Exec setBLOB sql ok!")
 
 		}
-		return ctx.JSONMsg(404, 404, "Undefined sqltype!")
+		return sendMsg(ctx, 404, 404, "Undefined sqltype!")
 	}
 }
 
@@ -534,8 +674,36 @@ func DirectSQLReloadModel() faygo.HandlerFunc {
 	}
 }
 
+// 验证参数后执行查询SQL，并将结果流式导出到客户端
+func exportSelect(ctx *faygo.Context, m *TModel, se *TSql, jsonpara map[string]interface{}, format string) error {
+	_, err := dealwithParameter(se.Cmds[0].Parameters, jsonpara, ctx)
+	if err != nil {
+		faygo.Error(err.Error())
+		return ctx.JSONMsg(400, 400, err.Error())
+	}
+	if isDryRun(ctx) {
+		return sendDryRun(ctx, se, jsonpara)
+	}
+	rows, err := m.selectRows(se, jsonpara)
+	if err != nil {
+		faygo.Error(err.Error())
+		return ctx.JSONMsg(404, 404, err.Error())
+	}
+	return sendExport(ctx, se.Id, format, rows)
+}
+
 // 发送JSON(P)响应
 func sendJSON(ctx *faygo.Context, callback string, b []byte) error {
+	// 调试模式下结果放到 data 字段，调试信息放到 debug 字段
+	if d := debugOf(ctx); d != nil {
+		var err error
+		if b, err = json.Marshal(struct {
+			Data  json.RawMessage `json:"data"`
+			Debug *sqlDebug       `json:"debug"`
+		}{b, d}); err != nil {
+			return err
+		}
+	}
 	// 发送JSONP响应
 	if len(callback) > 0 {
 		callback = template.JSEscapeString(callback)
@@ -548,3 +716,11 @@ func sendJSON(ctx *faygo.Context, callback string, b []byte) error {
 	// 正常有数据JSON响应
 	return ctx.JSONBlob(200, b)
 }
+
+// 执行出错的响应状态：乐观锁检查失败为 409，其他为 404
+func execErrorStatus(err error) int {
+	if err == ErrOptimisticLock {
+		return 409
+	}
+	return 404
+}
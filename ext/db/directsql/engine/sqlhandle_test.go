@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andeya/faygo"
+)
+
+// 执行 DirectSQL 的测试服务，所有测试共用
+var testServer struct {
+	once sync.Once
+	url  string
+}
+
+// 启动测试服务，路由 /bos/*path
+func serveTest(t *testing.T) string {
+	testServer.once.Do(func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := l.Addr().String()
+		l.Close()
+		config := faygo.NewDefaultConfig()
+		config.Addrs = []string{addr}
+		config.APIdoc.Enable = false
+		frame := faygo.NewWithConfig(config, "directsql-test")
+		frame.POST("/bos/*path", DirectSQL())
+		go frame.Run()
+		for i := 0; i < 50; i++ {
+			if c, err := net.Dial("tcp", addr); err == nil {
+				c.Close()
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		testServer.url = "http://" + addr
+	})
+	return testServer.url
+}
+
+// 以JSON参数请求 /bos/test/<sqlid>，返回状态码与响应
+func postSQL(t *testing.T, sqlid string, header http.Header, para string) (int, string) {
+	req, err := http.NewRequest("POST", serveTest(t)+"/bos/test/"+sqlid, strings.NewReader(para))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func insertUsers(t *testing.T, codes ...string) {
+	var sp []map[string]interface{}
+	for i, code := range codes {
+		sp = append(sp, map[string]interface{}{"id": i + 1, "code": code, "nick": nil})
+	}
+	if err := BacthExecMap("test", "batchinsert", sp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPagingEnforce(t *testing.T) {
+	setupTestModel(t)
+	insertUsers(t, "a", "b", "b")
+	RegAny("tenant", func(ctx *faygo.Context) interface{} {
+		if v := ctx.HeaderParam("X-Tenant"); len(v) > 0 {
+			return v
+		}
+		return nil
+	})
+	// 强制参数只定义在计数的cmd，客户端提交的值被忽略
+	code, body := postSQL(t, "tenantpaging", http.Header{"X-Tenant": {"a"}}, `{"tenant":"b","limit":10,"offset":0}`)
+	var page struct {
+		Total int64
+		Data  []map[string]interface{}
+	}
+	if err := json.Unmarshal([]byte(body), &page); err != nil || code != 200 {
+		t.Fatalf("got %d %s", code, body)
+	}
+	if page.Total != 1 || len(page.Data) != 1 || fmt.Sprint(page.Data[0]["code"]) != "a" {
+		t.Fatalf("enforced tenant: got %s", body)
+	}
+	// 无法获取服务端的值
+	if code, body = postSQL(t, "tenantpaging", nil, `{"tenant":"b","limit":10,"offset":0}`); code != 400 {
+		t.Fatalf("without the tenant: got %d %s", code, body)
+	}
+	// 查询结果的cmd的参数验证
+	if code, body = postSQL(t, "tenantpaging", http.Header{"X-Tenant": {"a"}}, `{"limit":1000,"offset":0}`); code != 400 {
+		t.Fatalf("invalid limit: got %d %s", code, body)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	setupTestModel(t)
+	insertUsers(t, "a")
+	defer SetAuthorizer(nil)
+	// 未设置授权检查器时拒绝声明了 roles/permission 的 sql
+	if code, body := postSQL(t, "secret", nil, `{}`); code != 403 {
+		t.Fatalf("without authorizer: got %d %s", code, body)
+	}
+	if code, body := postSQL(t, "select", nil, `{"code":"a"}`); code != 200 {
+		t.Fatalf("sql without roles: got %d %s", code, body)
+	}
+	SetAuthorizer(AuthorizerFunc(func(ctx *faygo.Context, roles []string, permission string) bool {
+		return matchAuth(splitList(ctx.HeaderParam("X-Roles")), splitList(ctx.HeaderParam("X-Perms")), roles, permission)
+	}))
+	var tests = []struct {
+		roles, perms string
+		code         int
+	}{
+		{"owner", "user:read", 200},
+		{"admin", "*", 200},
+		{"guest", "user:read", 403},
+		{"admin", "user:write", 403},
+		{"", "", 403},
+	}
+	for _, test := range tests {
+		header := http.Header{"X-Roles": {test.roles}, "X-Perms": {test.perms}}
+		if code, body := postSQL(t, "secret", header, `{}`); code != test.code {
+			t.Errorf("roles %q, permissions %q: got %d %s, want %d", test.roles, test.perms, code, body, test.code)
+		}
+	}
+}
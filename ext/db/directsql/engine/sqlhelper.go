@@ -1,20 +1,23 @@
 /*
  功能：辅助函数
 */
-package directsqlx
+package engine
 
 import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
 	"time"
-
-	json "github.com/json-iterator/go"
-
-	"github.com/jmoiron/sqlx"
 )
 
+// time.Time 的类型
+var timeType = reflect.TypeOf(time.Time{})
+
 //-------解析参数的函数------------
 //将s从左边开始c出现的第n(>=1)次的位置之前的去掉 比如 'aa / bb / cc' -> 'bb / cc'
 func trimBefore(s string, c byte, n int) string {
@@ -55,14 +58,8 @@ func trimBeforeSplitRight(s string, c byte, n int) (left, right string) {
 	return s, ""
 }
 
-var (
-	x_TIME_DEFAULT time.Time
-	timeType       = reflect.TypeOf(x_TIME_DEFAULT)
-)
-
 //------------ rows转换相关函数 -------------------------
 func reflect2value(rawValue *reflect.Value) (str string, err error) {
-
 	aa := reflect.TypeOf((*rawValue).Interface())
 	vv := reflect.ValueOf((*rawValue).Interface())
 	switch aa.Kind() {
@@ -124,7 +121,7 @@ func value2String(rawValue *reflect.Value) (data string, err error) {
 	return
 }
 
-func rows2Strings(rows *sqlx.Rows) (resultsSlice []map[string]string, err error) {
+func rows2Strings(rows *sql.Rows) (resultsSlice []map[string]string, err error) {
 	fields, err := rows.Columns()
 	if err != nil {
 		return nil, err
@@ -140,7 +137,7 @@ func rows2Strings(rows *sqlx.Rows) (resultsSlice []map[string]string, err error)
 	return resultsSlice, nil
 }
 
-func rows2maps(rows *sqlx.Rows) (resultsSlice []map[string][]byte, err error) {
+func rows2maps(rows *sql.Rows) (resultsSlice []map[string][]byte, err error) {
 	fields, err := rows.Columns()
 	if err != nil {
 		return nil, err
@@ -156,7 +153,7 @@ func rows2maps(rows *sqlx.Rows) (resultsSlice []map[string][]byte, err error) {
 	return resultsSlice, nil
 }
 
-func row2map(rows *sqlx.Rows, fields []string) (resultsMap map[string][]byte, err error) {
+func row2map(rows *sql.Rows, fields []string) (resultsMap map[string][]byte, err error) {
 	result := make(map[string][]byte)
 	scanResultContainers := make([]interface{}, len(fields))
 	for i := 0; i < len(fields); i++ {
@@ -184,7 +181,7 @@ func row2map(rows *sqlx.Rows, fields []string) (resultsMap map[string][]byte, er
 	return result, nil
 }
 
-func row2mapStr(rows *sqlx.Rows, fields []string) (resultsMap map[string]string, err error) {
+func row2mapStr(rows *sql.Rows, fields []string) (resultsMap map[string]string, err error) {
 	result := make(map[string]string)
 	scanResultContainers := make([]interface{}, len(fields))
 	for i := 0; i < len(fields); i++ {
@@ -244,6 +241,67 @@ func intface2json(i interface{}) ([]byte, error) {
 	return b, nil
 }
 
+//------------ 游标分页的游标 -------------------------
+// 游标中的时间值
+type cursorTime struct {
+	T time.Time `json:"t"`
+}
+
+// 将游标字段的值编码为不透明的游标字符串
+func encodeCursor(values []interface{}) (string, error) {
+	list := make([]interface{}, len(values))
+	for i, v := range values {
+		if t, ok := v.(time.Time); ok {
+			list[i] = cursorTime{T: t}
+		} else {
+			list[i] = v
+		}
+	}
+	b, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// 解码游标字符串为 n 个游标字段的值
+func decodeCursor(cursor string, n int) ([]interface{}, error) {
+	invalid := errors.New("错误：无效的游标！")
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var list []interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err = dec.Decode(&list); err != nil || len(list) != n {
+		return nil, invalid
+	}
+	for i, v := range list {
+		switch vv := v.(type) {
+		case json.Number:
+			if i64, err := vv.Int64(); err == nil {
+				list[i] = i64
+			} else if f, err := vv.Float64(); err == nil {
+				list[i] = f
+			} else {
+				return nil, invalid
+			}
+		case map[string]interface{}:
+			s, _ := vv["t"].(string)
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, invalid
+			}
+			list[i] = t
+		case string, bool:
+		default:
+			return nil, invalid
+		}
+	}
+	return list, nil
+}
+
 //-----------------------------------------------------------------------------------------
 func reflect2object(rawValue *reflect.Value) (value interface{}, err error) {
 	aa := reflect.TypeOf((*rawValue).Interface())
@@ -297,7 +355,7 @@ func value2Object(rawValue *reflect.Value) (data interface{}, err error) {
 	return
 }
 
-func rows2mapObjects(rows *sqlx.Rows) (resultsSlice []map[string]interface{}, err error) {
+func rows2mapObjects(rows *sql.Rows) (resultsSlice []map[string]interface{}, err error) {
 	fields, err := rows.Columns()
 	if err != nil {
 		return nil, err
@@ -313,7 +371,7 @@ func rows2mapObjects(rows *sqlx.Rows) (resultsSlice []map[string]interface{}, er
 	return resultsSlice, nil
 }
 
-func rows2mapObject(rows *sqlx.Rows, fields []string) (resultsMap map[string]interface{}, err error) {
+func rows2mapObject(rows *sql.Rows, fields []string) (resultsMap map[string]interface{}, err error) {
 	result := make(map[string]interface{})
 	scanResultContainers := make([]interface{}, len(fields))
 	for i := 0; i < len(fields); i++ {
@@ -342,7 +400,7 @@ func rows2mapObject(rows *sqlx.Rows, fields []string) (resultsMap map[string]int
 }
 
 //rows 转换为嵌套的map,父子关系根据 Idfield,Pidfield
-func rows2nestedMapObjects(rows *sqlx.Rows, idfield, pidfield string) (resultsSlice []map[string]interface{}, err error) {
+func rows2nestedMapObjects(rows *sql.Rows, idfield, pidfield string) (resultsSlice []map[string]interface{}, err error) {
 	fields, err := rows.Columns()
 	if err != nil {
 		return nil, err
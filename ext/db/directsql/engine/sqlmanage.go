@@ -18,7 +18,7 @@
          	 ST_GETBLOB  //10 获取BLOB (binary large object)，二进制大对象从数据库
 	         ST_SETBLOB  //11 保存BLOB (binary large object)，二进制大对象到数据库
 */
-package directsql
+package engine
 
 import (
 	"encoding/xml"
@@ -28,12 +28,11 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/andeya/faygo"
-	faygoxorm "github.com/andeya/faygo/ext/db/xorm"
 	confpkg "github.com/andeya/ini"
 	"github.com/fsnotify/fsnotify"
-	"xorm.io/core"
 )
 
 // var modelsqls map[string]*TModel
@@ -53,6 +52,9 @@ type TModels struct {
 	cachetime      int                // 默认缓存的时间，如果使用缓存并且未配置缓存时间则使用该默认时间，单位为分钟，-1为一直有效，-2为一月，-3为一周 -4为一天，单位为分钟
 	servernodeid   int64              // 服务器节点id（必须为整数）：必须介于0~63之间
 	starttimestamp int64              // 生成shortuuit，int64uuid号码开始时间戳，必须是当前时间的过往日期，一旦使用后绝对不能修改，否则会产生重号！！！
+
+	// 调试模式与慢查询日志的配置
+	debug *debugConfig
 }
 
 // 全局所有业务模型对象
@@ -61,9 +63,12 @@ var models = &TModels{
 
 // sqlmodel 一个配置文件的SQLModel对应的结构
 type TModel struct {
-	Id   string           // root起用映射、不带扩展名的文件名
-	DB   *core.DB         // 本模块的db引擎 *xorm.Engine.DB()
-	Sqls map[string]*TSql // sqlentity key=sqlentity.id
+	Id       string           // root起用映射、不带扩展名的文件名
+	Database string           // 本模块的数据库名称，执行时通过 getDB 获取
+	Sqls     map[string]*TSql // sqlentity key=sqlentity.id
+
+	// 调试模式下记录本次请求执行的语句，见 withDebug
+	debug *sqlDebug
 }
 
 // 临时转换用，因为 XML 不支持解析到 map，所以先读入到[]然后再根据[]创建map
@@ -80,6 +85,7 @@ type TSql struct {
 	Id         string   `xml:"id,attr"` // sqlid
 	Sqltypestr string   `xml:"type,attr"`
 	Sqltype    TSqltype `xml:"-"`              // SQL类型
+	Desc       string   `xml:"desc,attr"`      // SQL说明，用于API文档
 	Idfield    string   `xml:"idfield,attr"`   // SQlType为6=嵌套jsoin树时的ID字段
 	Pidfield   string   `xml:"pidfield,attr"`  // SQlType为6=嵌套jsoin树时的ParentID字段
 	Cmds       []*TCmd  `xml:"cmd"`            // sqlcmd(sqltype为分页查询时的计数SQL放第一个，结果SQL放第二个)
@@ -87,6 +93,17 @@ type TSql struct {
 	Cachetime  int      `xml:"cachetime,attr"` // 默认缓存的时间，单位为分钟，-1为一直有效，-2为一月，-3为一周 -4为一天，单位为分钟
 	Eachtran   bool     `xml:"eachtran,attr"`  // 对于 batchexec、batchmultiexec类型SQL 如果为 false则所有SQL在一个事务执行，true则每一个批次在一个事务中
 
+	// 授权：执行需要的角色（逗号分隔，满足之一即可）与权限，见 SetAuthorizer
+	Roles      string `xml:"roles,attr"`
+	Permission string `xml:"permission,attr"`
+
+	// cursorselect：游标字段（逗号分隔，与 ORDER BY 的字段一致）与每页最大记录数
+	Cursor   string `xml:"cursor,attr"`
+	Pagesize int    `xml:"pagesize,attr"`
+
+	// 缓存标签（逗号分隔）；exec 类 sql 执行成功后使 invalidate 声明的标签的缓存结果失效
+	Tags       string `xml:"tags,attr"`
+	Invalidate string `xml:"invalidate,attr"`
 }
 
 // TCmd  <Select/>等节点的下级节点<sql />对应结构
@@ -96,6 +113,18 @@ type TCmd struct {
 	Rout       string           `xml:"out,attr"`  // 输出结果标示
 	Sql        string           `xml:",chardata"` // SQL
 	Parameters []*TSqlParameter `xml:"parameters>parameter"`
+
+	// 授权：执行该cmd需要的角色与权限，与sql节点的同时检查
+	Roles      string `xml:"roles,attr"`
+	Permission string `xml:"permission,attr"`
+
+	// 事务脚本：lastid/scalar/row 为输出的名称，之后的cmd可以作为参数使用；if 为执行条件；
+	// optimistic 为 true 时影响的记录数为0则回滚事务，见 execCmd
+	Lastid     string `xml:"lastid,attr"`
+	Scalar     string `xml:"scalar,attr"`
+	Row        string `xml:"row,attr"`
+	If         string `xml:"if,attr"`
+	Optimistic bool   `xml:"optimistic,attr"`
 }
 
 // TSql 类型
@@ -114,22 +143,28 @@ const (
 	ST_REPORT                         // 9 报表用的SQL：通过xlsx模板创建报表的SQL
 	ST_GETBLOB                        // 10 获取BLOB (binary large object)，二进制大对象从数据库
 	ST_SETBLOB                        // 11 保存BLOB (binary large object)，二进制大对象到数据库
+	ST_CURSORSELECT                   // 12 游标(keyset)分页查询，返回下一页的游标
 )
 
+// 游标分页查询未配置 pagesize 时的每页记录数
+const DefaultPagesize = 20
+
 // TSqlParameter 参数校验定义
 type TSqlParameter struct {
 	Name        string       `xml:"name,attr"`     // 参数名称必须与cmd中的对应
+	Desc        string       `xml:"desc,attr"`     // 参数描述，用于API文档
 	Paratypestr string       `xml:"type,attr"`     // string/number/email/date/datetime/time  -不定义则不需要验证
-	Paratype    TParaType    `-`                   // 数值类型
+	Paratype    TParaType    `xml:"-"`             // 数值类型
 	Required    bool         `xml:"required,attr"` // 0=不是必须的   1=必须的不能为空
 	Minlen      int          `xml:"minlen,attr"`   // 最小长度
 	Maxlen      int          `xml:"maxlen,attr"`   // 最大长度
 	MinValue    float64      `xml:"minvalue,attr"` // 最小值
 	MaxValue    float64      `xml:"maxvalue,attr"` // 最大值
 	Defaultstr  string       `xml:"default,attr"`  // 默认值 undefined/uuid/userid/usercode/username/rootgroupid/rootgroupname/groupid/groupname/nowdate/nowtime
-	Default     TDefaultType `-`                   // 数值类型
+	Default     TDefaultType `xml:"-"`             // 数值类型
 	Return      bool         `xml:"return,attr"`   // 服务端生成的默认值是否返回到客户端： 0(false)=默认，不返回   1(true)=返回到客户端
 	Parentid    bool         `xml:"parentid,attr"` // 是否作为从表的关联本表（主表）的id的值
+	Enforce     bool         `xml:"enforce,attr"`  // 强制使用服务端默认值，忽略客户端提交的值（例如根据登录用户绑定的 tenant_id）
 }
 
 // 参数类型：string/number/email/date/datetime/time
@@ -182,6 +217,30 @@ func (ms *TModels) loadTModels() {
 	// 是否缓存与缓存时间
 	ms.cached = cfg.Section("").Key("cached").MustBool(false)
 	ms.cachetime = cfg.Section("").Key("cachetime").MustInt(30)
+	// 缓存后端
+	if sec, err := cfg.GetSection("cache"); err == nil {
+		SetCacheBackend(newCacheFromConfig(
+			sec.Key("backend").MustString("freecache"),
+			sec.Key("size").MustInt(0),
+			sec.Key("addr").MustString("127.0.0.1:6379"),
+			sec.Key("password").String(),
+			sec.Key("db").MustInt(0),
+		))
+	}
+	// 调试模式与慢查询
+	debug := &debugConfig{slow: DefaultSlowThreshold, explain: "EXPLAIN"}
+	if sec, err := cfg.GetSection("debug"); err == nil {
+		for _, ip := range strings.Split(sec.Key("whitelist").String(), "|") {
+			if ip = strings.TrimSpace(ip); len(ip) > 0 {
+				debug.whitelist = append(debug.whitelist, ip)
+			}
+		}
+		debug.realip = sec.Key("realip").MustBool(false)
+		debug.roles = sec.Key("roles").String()
+		debug.slow = time.Duration(sec.Key("slowms").MustInt(1000)) * time.Millisecond
+		debug.explain = sec.Key("explain").MustString("EXPLAIN")
+	}
+	ms.debug = debug
 	// SQL参数默认值 shortuuit，int64uuid的配置参数
 	ms.servernodeid = cfg.Section("uuid").Key("servernodeid").MustInt64(0)
 	ms.starttimestamp = cfg.Section("uuid").Key("starttimestamp").MustInt64(1535252860333)
@@ -271,14 +330,8 @@ func (ms *TModels) parseTModel(msqlfile string) (*TModel, error) {
 	if err != nil {
 		return nil, err
 	}
-	// 设置数据库
-	dbe, ok := faygoxorm.DB(tempresult.Database)
-	if ok == false {
-		dbe = faygoxorm.MustDB()
-		// faygo.Log.Debug("database:", tempresult.Database)
-	}
-	// 定义一个 TModel将 tempTModel 转换为 TModel
-	result := &TModel{Id: tempresult.Id, DB: dbe.DB(), Sqls: make(map[string]*TSql)}
+	// 定义一个 TModel将 tempTModel 转换为 TModel，数据库在执行时获取
+	result := &TModel{Id: tempresult.Id, Database: tempresult.Database, Sqls: make(map[string]*TSql)}
 	// 处理一遍：设置数据库访问引擎，设置TSql的类型
 	for _, se := range tempresult.Sqls {
 		// 处理SQL类型与查询类语句缓存的配置参数
@@ -314,6 +367,14 @@ func (ms *TModels) parseTModel(msqlfile string) (*TModel, error) {
 			if se.Cached && se.Cachetime == 0 {
 				se.Cachetime = ms.cachetime
 			}
+		case "cursorselect":
+			se.Sqltype = ST_CURSORSELECT
+			if len(splitList(se.Cursor)) == 0 {
+				faygo.Error(errors.New("错误：配置文件[ " + msqlfile + " ]中游标分页查询[ " + se.Id + " ]未配置 cursor 属性!"))
+			}
+			if se.Pagesize <= 0 {
+				se.Pagesize = DefaultPagesize
+			}
 		case "exec", "insert", "update", "delete":
 			se.Sqltype = ST_EXEC
 		case "batchexec", "batchinsert", "batchupdate", "batchdelete":
@@ -337,6 +398,14 @@ func (ms *TModels) parseTModel(msqlfile string) (*TModel, error) {
 		// faygo.Debug(se)
 		// sql下的每个cmd循环处理
 		for _, cmd := range se.Cmds {
+			// 事务脚本只在 exec 类 sql 的事务中有效
+			if len(cmd.Lastid+cmd.Scalar+cmd.Row+cmd.If) > 0 || cmd.Optimistic {
+				switch se.Sqltype {
+				case ST_EXEC, ST_BATCHEXEC, ST_BATCHMULTIEXEC:
+				default:
+					faygo.Error(errors.New("错误：配置文件[ " + msqlfile + " ]中sql[ " + se.Id + " ]不是 exec 类型，cmd 的 lastid/scalar/row/if/optimistic 属性无效!"))
+				}
+			}
 			// 每个cmd下的参数循环处理参数类型与默认值类型
 			for _, para := range cmd.Parameters {
 				// 参数类型
@@ -388,6 +457,9 @@ func (ms *TModels) parseTModel(msqlfile string) (*TModel, error) {
 						para.Default = DT_UNDEFINED // 未定义默认值
 					}
 				}
+				if para.Enforce && para.Default == DT_UNDEFINED {
+					faygo.Error(errors.New("错误：配置文件[ " + msqlfile + " ]中sql[ " + se.Id + " ]的强制参数[ " + para.Name + " ]未定义默认值!"))
+				}
 			}
 		}
 	}
@@ -405,10 +477,10 @@ func (ms *TModels) findsql(modelid string, sqlid string) *TSql {
 }
 
 // 获取sqlentity SQL的执行实体与DB执行引擎
-func (ms *TModels) findsqlanddb(modelid string, sqlid string) (*TSql, *core.DB) {
+func (ms *TModels) findsqlanddb(modelid string, sqlid string) (*TSql, DB) {
 	if sm, ok := ms.modelsqls[modelid]; ok {
 		if se, ok := sm.Sqls[sqlid]; ok {
-			return se, sm.DB
+			return se, sm.db()
 		}
 	}
 	return nil, nil
@@ -424,6 +496,11 @@ func (ms *TModels) getSqlType(modelid string, sqlid string) TSqltype {
 	return -1
 }
 
+// 模型的数据库
+func (m *TModel) db() DB {
+	return getDB(m.Database)
+}
+
 // 根据路径加文件名(不带文件扩展名)获取其TModel
 func (ms *TModels) findmodel(modelid string) *TModel {
 	if sm, ok := ms.modelsqls[modelid]; ok {
@@ -444,6 +521,7 @@ func (ms *TModels) refreshModelFile(msqlfile string) error {
 	}
 	// 将本文件对应的TModel放入到TModels
 	ms.modelsqls[ms.filenameToModelId(msqlfile)] = m
+	resetAPIdocs()
 	return nil
 }
 
@@ -452,6 +530,7 @@ func (ms *TModels) removeModelFile(msqlfile string) error {
 	ms.loadLock.Lock()
 	defer ms.loadLock.Unlock()
 	delete(ms.modelsqls, ms.filenameToModelId(msqlfile))
+	resetAPIdocs()
 	return nil
 }
 
@@ -464,7 +543,7 @@ func (ms *TModels) renameModelFile(msqlfile, newfilename string) error {
 
 // 单元访问文件--------------------------------------------------------------
 // 获取sqlentity SQL的执行实体与数据库引擎
-func findSqlAndDB(modelid string, sqlid string) (*TSql, *core.DB) {
+func findSqlAndDB(modelid string, sqlid string) (*TSql, DB) {
 	return models.findsqlanddb(modelid, sqlid)
 }
 
@@ -473,6 +552,22 @@ func GetSqlType(modelid string, sqlid string) TSqltype {
 	return models.getSqlType(modelid, sqlid)
 }
 
+// 获取模型的数据库名称（model 文件的 database 属性），模型不存在时 ok 为 false
+func GetDatabase(modelid string) (database string, ok bool) {
+	if m := models.findmodel(modelid); m != nil {
+		return m.Database, true
+	}
+	return "", false
+}
+
+// 获取sqlentity SQL的执行实体与所属的模型
+func findModelAndSql(modelid string, sqlid string) (*TModel, *TSql) {
+	if m := models.findmodel(modelid); m != nil {
+		return m, m.findSql(sqlid)
+	}
+	return nil, nil
+}
+
 // 获取sqlentity SQL的执行实体
 func findSql(modelid string, sqlid string) *TSql {
 	// faygo.Debug("Model Path: " + modelid + " ,SqlId: " + sqlid)
@@ -490,6 +585,7 @@ func ReloadAll() {
 	models = &TModels{
 		modelsqls: make(map[string]*TModel)}
 	models.loadTModels()
+	resetAPIdocs()
 }
 
 // 重新载入单个模型文件---未测试！！！
@@ -2,7 +2,7 @@
   动态SQL路由注册
   特别说明：将router定义代码放到 sysrouter.go中
 */
-package directsql
+package engine
 
 /*
 import (
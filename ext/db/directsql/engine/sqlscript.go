@@ -0,0 +1,259 @@
+/**
+* desc   : 事务脚本：同一个 sql 下的多个 cmd 在一个事务中执行时
+*          - lastid/scalar/row 属性声明 cmd 的输出：插入的自增id、查询结果第一行第一列的值、查询结果的第一行，
+*            之后的 cmd 可以作为参数使用：?名称，行的字段为 ?名称.字段名
+*          - if 属性为执行条件，根据参数或之前 cmd 的输出决定是否执行该 cmd
+*          - optimistic="true" 为乐观锁检查，影响的记录数为0时返回 ErrOptimisticLock，整个事务回滚
+*/
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrOptimisticLock is returned when the cmd with optimistic="true" affects no rows,
+// the transaction is rolled back and DirectSQL responds 409.
+var ErrOptimisticLock = errors.New("错误：乐观锁检查失败，记录已被修改或者不存在！")
+
+// 在事务中执行一个 cmd，out 为本事务中之前 cmd 的输出，执行后加入本 cmd 的输出
+func (m *TModel) execCmd(tx Tx, se *TSql, cmd *TCmd, mp map[string]interface{}, out map[string]interface{}) error {
+	params := withOutputs(se, mp, out)
+	if len(cmd.If) > 0 {
+		ok, err := evalCond(cmd.If, params)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// 未执行的 cmd 输出为 null
+			for _, name := range []string{cmd.Lastid, cmd.Scalar, cmd.Row} {
+				if len(name) > 0 {
+					out[name] = nil
+				}
+			}
+			return nil
+		}
+	}
+	// 查询类的输出
+	if len(cmd.Scalar) > 0 || len(cmd.Row) > 0 {
+		rows, err := traceQuery(tx, m.debug, se, cmd, params)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		fields, err := rows.Columns()
+		if err != nil {
+			return err
+		}
+		var row map[string]interface{}
+		if rows.Next() {
+			if row, err = rows2mapObject(rows, fields); err != nil {
+				return err
+			}
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		if len(cmd.Scalar) > 0 {
+			out[cmd.Scalar] = nil
+			if row != nil && len(fields) > 0 {
+				out[cmd.Scalar] = row[fields[0]]
+			}
+		}
+		if len(cmd.Row) > 0 {
+			if row == nil {
+				out[cmd.Row] = nil
+			} else {
+				out[cmd.Row] = row
+			}
+		}
+		return nil
+	}
+	result, err := traceExec(tx, m.debug, se, cmd, params)
+	if err != nil {
+		return err
+	}
+	if cmd.Optimistic {
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrOptimisticLock
+		}
+	}
+	if len(cmd.Lastid) > 0 {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		out[cmd.Lastid] = id
+	}
+	return nil
+}
+
+// 参数加上之前 cmd 的输出，输出优先；行输出的字段为 名称.字段名。
+// 客户端参数中与 se 声明的输出同名或者以 名称. 开头的参数被删除，以免冒充未执行或者没有结果的 cmd 的输出
+func withOutputs(se *TSql, mp map[string]interface{}, out map[string]interface{}) map[string]interface{} {
+	names := outputNames(se)
+	if len(names) == 0 {
+		return mp
+	}
+	params := make(map[string]interface{}, len(mp)+len(out))
+	for k, v := range mp {
+		if !isOutputParam(names, k) {
+			params[k] = v
+		}
+	}
+	for k, v := range out {
+		params[k] = v
+		if row, ok := v.(map[string]interface{}); ok {
+			for field, fv := range row {
+				params[k+"."+field] = fv
+			}
+		}
+	}
+	return params
+}
+
+// se 中所有 cmd 声明的输出名称
+func outputNames(se *TSql) []string {
+	var names []string
+	for _, cmd := range se.Cmds {
+		for _, name := range []string{cmd.Lastid, cmd.Scalar, cmd.Row} {
+			if len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// 参数 k 是否为输出或者行输出的字段
+func isOutputParam(names []string, k string) bool {
+	for _, name := range names {
+		if k == name || strings.HasPrefix(k, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// 条件运算符，两个字符的在前
+var condOperators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+// 计算 if 条件：name 或 !name 判断值是否为真（非null、非false、非0、非空字符串，行输出存在）；
+// name 运算符 值 的运算符为 == != > >= < <=，值为数字、'字符串'、null、true、false
+func evalCond(cond string, mp map[string]interface{}) (bool, error) {
+	cond = strings.TrimSpace(cond)
+	// 第一个运算符之前为参数名，之后为值
+	for i := 1; i < len(cond); i++ {
+		for _, op := range condOperators {
+			if !strings.HasPrefix(cond[i:], op) {
+				continue
+			}
+			name := strings.TrimSpace(cond[:i])
+			v, ok := lookupParam(mp, name)
+			if !ok {
+				return false, errors.New("错误：条件[" + cond + "]中的参数[" + name + "]不存在！")
+			}
+			return compareCond(v, op, strings.TrimSpace(cond[i+len(op):]))
+		}
+	}
+	not := strings.HasPrefix(cond, "!")
+	name := strings.TrimSpace(strings.TrimPrefix(cond, "!"))
+	if len(name) == 0 {
+		return false, errors.New("错误：无效的条件[" + cond + "]！")
+	}
+	v, ok := lookupParam(mp, name)
+	if !ok {
+		return false, errors.New("错误：条件[" + cond + "]中的参数[" + name + "]不存在！")
+	}
+	return truthy(v) != not, nil
+}
+
+// 比较值 v 与条件中的字面值
+func compareCond(v interface{}, op, literal string) (bool, error) {
+	var c int
+	switch {
+	case literal == "null":
+		if op != "==" && op != "!=" {
+			return false, errors.New("错误：null 只能使用 == 或 != 比较！")
+		}
+		return (v == nil) == (op == "=="), nil
+	case literal == "true" || literal == "false":
+		if op != "==" && op != "!=" {
+			return false, errors.New("错误：" + literal + " 只能使用 == 或 != 比较！")
+		}
+		return (truthy(v) == (literal == "true")) == (op == "=="), nil
+	case len(literal) >= 2 && (literal[0] == '\'' || literal[0] == '"') && literal[len(literal)-1] == literal[0]:
+		if v == nil {
+			return op == "!=", nil
+		}
+		c = strings.Compare(toString(v), literal[1:len(literal)-1])
+	default:
+		f, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return false, errors.New("错误：条件中无效的值[" + literal + "]！")
+		}
+		if v == nil {
+			return op == "!=", nil
+		}
+		n, err := strconv.ParseFloat(toString(v), 64)
+		if err != nil {
+			return op == "!=", nil
+		}
+		switch {
+		case n < f:
+			c = -1
+		case n > f:
+			c = 1
+		}
+	}
+	switch op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	case "<":
+		return c < 0, nil
+	default:
+		return c <= 0, nil
+	}
+}
+
+// 值是否为真
+func truthy(v interface{}) bool {
+	switch vv := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return vv
+	case string:
+		return len(vv) > 0
+	case []byte:
+		return len(vv) > 0
+	case map[string]interface{}:
+		return vv != nil
+	}
+	if f, err := strconv.ParseFloat(toString(v), 64); err == nil {
+		return f != 0
+	}
+	return true
+}
+
+func toString(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case []byte:
+		return string(vv)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func count(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestScriptOutputs(t *testing.T) {
+	db := setupTestModel(t)
+	if _, err := ExecMap("test", "insert", map[string]interface{}{"id": 7, "code": "a", "nick": nil}); err != nil {
+		t.Fatal(err)
+	}
+	// 用户存在：插入订单，明细使用订单的自增id，金额大于100时更新用户
+	if _, err := ExecMap("test", "neworder", map[string]interface{}{"code": "a", "amount": 200, "item": "book"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, db, "SELECT count(*) FROM order_item i JOIN orders o ON o.id = i.order_id WHERE o.user_id = 7"); n != 1 {
+		t.Fatalf("order items: got %d, want 1", n)
+	}
+	var nick sql.NullString
+	if err := db.QueryRow("SELECT nick FROM user WHERE id = 7").Scan(&nick); err != nil {
+		t.Fatal(err)
+	}
+	if nick.String != "vip" {
+		t.Fatalf("nick: got %q, want vip", nick.String)
+	}
+	// 用户不存在：之后的 cmd 都不执行
+	if _, err := ExecMap("test", "neworder", map[string]interface{}{"code": "x", "amount": 200, "item": "pen"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, db, "SELECT count(*) FROM orders"); n != 1 {
+		t.Fatalf("orders: got %d, want 1", n)
+	}
+}
+
+func TestScriptOutputsNotFromClient(t *testing.T) {
+	se := &TSql{Cmds: []*TCmd{{Lastid: "orderid"}, {Row: "order"}}}
+	mp := map[string]interface{}{"code": "a", "orderid": 1, "order": map[string]interface{}{"amount": 200}, "order.amount": 200, "orders": 2}
+	// 行输出没有结果
+	params := withOutputs(se, mp, map[string]interface{}{"orderid": int64(3), "order": nil})
+	if ok, err := evalCond("order.amount > 100", params); err != nil || ok {
+		t.Fatalf("order.amount > 100: got %v %v, want false", ok, err)
+	}
+	if params["orderid"] != int64(3) || params["code"] != "a" || params["orders"] != 2 {
+		t.Fatalf("params: got %v", params)
+	}
+	// 之前的 cmd 未执行
+	params = withOutputs(se, mp, map[string]interface{}{})
+	for _, name := range []string{"orderid", "order", "order.amount"} {
+		if _, ok := params[name]; ok {
+			t.Fatalf("%s: got the client value %v", name, params[name])
+		}
+	}
+}
+
+func TestScriptOptimisticLock(t *testing.T) {
+	db := setupTestModel(t)
+	if _, err := db.Exec("INSERT INTO orders (id, user_id, amount) VALUES (1, 1, 10)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExecMap("test", "updateorder", map[string]interface{}{"id": 1, "version": 0, "amount": 20, "item": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	// 版本已经改变，整个事务回滚
+	_, err := ExecMap("test", "updateorder", map[string]interface{}{"id": 1, "version": 0, "amount": 30, "item": "b"})
+	if err != ErrOptimisticLock {
+		t.Fatalf("stale version: got %v, want ErrOptimisticLock", err)
+	}
+	if n := count(t, db, "SELECT count(*) FROM order_item"); n != 1 {
+		t.Fatalf("after rollback: got %d items, want 1", n)
+	}
+	if n := count(t, db, "SELECT amount FROM orders WHERE id = 1"); n != 20 {
+		t.Fatalf("amount: got %d, want 20", n)
+	}
+}
+
+func TestBatchOptimisticLock(t *testing.T) {
+	db := setupTestModel(t)
+	if _, err := db.Exec("INSERT INTO orders (id, user_id, amount) VALUES (1, 1, 10), (2, 1, 10)"); err != nil {
+		t.Fatal(err)
+	}
+	// 每个批次一个事务：版本过期的批次回滚，其他批次提交，返回 ErrOptimisticLock
+	err := BacthExecMap("test", "batchupdateorder", []map[string]interface{}{
+		{"id": 1, "version": 0, "amount": 20, "item": "a"},
+		{"id": 2, "version": 5, "amount": 20, "item": "b"},
+	})
+	if err != ErrOptimisticLock {
+		t.Fatalf("batch: got %v, want ErrOptimisticLock", err)
+	}
+	if n := count(t, db, "SELECT count(*) FROM order_item"); n != 1 {
+		t.Fatalf("batch: got %d items, want 1", n)
+	}
+	if n := count(t, db, "SELECT amount FROM orders WHERE id = 2"); n != 10 {
+		t.Fatalf("batch: amount of the stale order: got %d, want 10", n)
+	}
+	err = BacthMultiExecMap("test", "multiupdateorder", map[string][]map[string]interface{}{
+		"orders": {
+			{"id": 1, "version": 0, "amount": 30, "item": "c"},
+			{"id": 2, "version": 0, "amount": 30, "item": "d"},
+		},
+	})
+	if err != ErrOptimisticLock {
+		t.Fatalf("multi: got %v, want ErrOptimisticLock", err)
+	}
+	if n := count(t, db, "SELECT count(*) FROM order_item"); n != 2 {
+		t.Fatalf("multi: got %d items, want 2", n)
+	}
+	if n := count(t, db, "SELECT amount FROM orders WHERE id = 2"); n != 30 {
+		t.Fatalf("multi: amount: got %d, want 30", n)
+	}
+}
+
+func TestEvalCond(t *testing.T) {
+	mp := map[string]interface{}{
+		"n":    int64(5),
+		"s":    "abc",
+		"zero": 0,
+		"nil":  nil,
+		"row":  map[string]interface{}{"a": 1},
+		"b":    []byte("12"),
+	}
+	cases := []struct {
+		cond string
+		want bool
+	}{
+		{"n", true},
+		{"!n", false},
+		{"zero", false},
+		{"nil", false},
+		{"!nil", true},
+		{"row", true},
+		{"n == 5", true},
+		{"n>=5", true},
+		{"n > 5", false},
+		{"n < 10", true},
+		{"n != 5", false},
+		{"b == 12", true},
+		{"s == 'abc'", true},
+		{`s != "abc"`, false},
+		{"nil == null", true},
+		{"n != null", true},
+		{"nil > 1", false},
+		{"n == true", true},
+		{"zero == false", true},
+	}
+	for _, c := range cases {
+		got, err := evalCond(c.cond, mp)
+		if err != nil {
+			t.Fatalf("%s: %v", c.cond, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: got %v, want %v", c.cond, got, c.want)
+		}
+	}
+	for _, cond := range []string{"missing", "n > x", "nil > null", "!"} {
+		if _, err := evalCond(cond, mp); err == nil {
+			t.Errorf("%s: want error", cond)
+		}
+	}
+}
@@ -12,15 +12,16 @@
 	   增加 PagingSelectMapToMap func
 
 */
-package directsql
+package engine
 
 import (
 	"database/sql"
 	"errors"
+	"io"
 	"reflect"
+	"strconv"
 
 	"github.com/andeya/faygo"
-	"xorm.io/core"
 )
 
 var notFoundError = func(sqlid string) error {
@@ -45,8 +46,8 @@ func DealwithParameter(modelId, sqlId string, mp map[string]interface{}, sqlinde
 	return nil
 }
 
-// 查询 根据modelId，sqlId ，mp:map[string]interface{}命名参数,返回*core.Rows
-func SelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (*core.Rows, error) {
+// 查询 根据modelId，sqlId ，mp:map[string]interface{}命名参数,返回*sql.Rows
+func SelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (*sql.Rows, error) {
 	// 获取Sqlentity,db
 	se, db := findSqlAndDB(modelId, sqlId)
 	if se == nil {
@@ -56,10 +57,10 @@ func SelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (*core.Ro
 	if se.Sqltype != ST_SELECT {
 		return nil, notMatchError()
 	}
-	return db.QueryMap(se.Cmds[0].Sql, &mp)
+	return traceQuery(db, nil, se, se.Cmds[0], mp)
 }
 
-// 判断记录是否存在，根据modelId，sqlId ，mp:map[string]interface{}命名参数,返回*core.Row
+// 判断记录是否存在，根据modelId，sqlId ，mp:map[string]interface{}命名参数,返回*sql.Row
 
 func SelectMapIsExist(modelId, sqlId string, mp map[string]interface{}) (bool, error) {
 	// 获取Sqlentity,db
@@ -71,7 +72,7 @@ func SelectMapIsExist(modelId, sqlId string, mp map[string]interface{}) (bool, e
 	if se.Sqltype != ST_SELECT {
 		return false, notMatchError()
 	}
-	rows, err := db.QueryMap(se.Cmds[0].Sql, &mp)
+	rows, err := traceQuery(db, nil, se, se.Cmds[0], mp)
 	if err != nil {
 		return false, err
 	}
@@ -122,8 +123,8 @@ func SelectMapToStructPro(modelId, sqlId string, mp map[string]interface{}, dest
 }
 
 // 执行返回多個結果集的多個查询根据modelId，sqlId ，SQLmp:map[string]interface{}命名参数 返回结果 map[string]*Rows
-func MultiSelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (map[string]*core.Rows, error) {
-	result := make(map[string]*core.Rows)
+func MultiSelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (map[string]*sql.Rows, error) {
+	result := make(map[string]*sql.Rows)
 	// 获取Sqlentity,db
 	se, db := findSqlAndDB(modelId, sqlId)
 	if se == nil {
@@ -136,12 +137,12 @@ func MultiSelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (map
 	// 循環每個sql定義
 	for i, cmd := range se.Cmds {
 		faygo.Debug("MultiSelectMap :" + cmd.Sql)
-		rows, err := db.QueryMap(cmd.Sql, &mp)
+		rows, err := traceQuery(db, nil, se, cmd, mp)
 		if err != nil {
 			return nil, err
 		}
 		if len(cmd.Rout) == 0 {
-			result["data"+string(i)] = rows
+			result["data"+strconv.Itoa(i)] = rows
 		} else {
 			result[cmd.Rout] = rows
 		}
@@ -152,29 +153,28 @@ func MultiSelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (map
 // 分頁查詢的返回結果
 type PagingSelectRows struct {
 	Total int `json:"total"`
-	Rows  *core.Rows
+	Rows  *sql.Rows
 }
 
 // 执行分页查询SQL  mp 是MAP类型命名参数 返回结果 int,[]map[string][]interface{}
 func PagingSelectMapToMap(modelId, sqlId string, mp map[string]interface{}) (*PagingSelectResult, error) {
 	se, db := findSqlAndDB(modelId, sqlId)
 	// 获取总页数，約定該SQL放到第二條，並且只返回一條記錄一個字段
-	trows, err := db.QueryMap(se.Cmds[0].Sql, &mp)
+	trows, err := traceQuery(db, nil, se, se.Cmds[0], mp)
 	if err != nil {
 		return nil, err
 	}
 	defer trows.Close()
 	for trows.Next() {
 		var total = make([]int, 1)
-		err := trows.ScanSlice(&total)
-		if err != nil {
-			return nil, err
-		}
-		if len(total) != 1 {
+		if fields, err := trows.Columns(); err != nil || len(fields) != 1 {
 			return nil, errors.New("错误：获取总页数的SQL执行结果非唯一记录！")
 		}
+		if err := trows.Scan(&total[0]); err != nil {
+			return nil, err
+		}
 		// 2.获取当前页數據，約定該SQL放到第二條
-		rows, err := db.QueryMap(se.Cmds[1].Sql, &mp)
+		rows, err := traceQuery(db, nil, se, se.Cmds[1], mp)
 		if err != nil {
 			return nil, err
 		}
@@ -192,22 +192,21 @@ func PagingSelectMapToMap(modelId, sqlId string, mp map[string]interface{}) (*Pa
 func PagingSelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (*PagingSelectRows, error) {
 	se, db := findSqlAndDB(modelId, sqlId)
 	// 获取总页数，約定該SQL放到第二條，並且只返回一條記錄一個字段
-	trows, err := db.QueryMap(se.Cmds[0].Sql, &mp)
+	trows, err := traceQuery(db, nil, se, se.Cmds[0], mp)
 	if err != nil {
 		return nil, err
 	}
 	defer trows.Close()
 	for trows.Next() {
 		var total = make([]int, 1)
-		err := trows.ScanSlice(&total)
-		if err != nil {
-			return nil, err
-		}
-		if len(total) != 1 {
+		if fields, err := trows.Columns(); err != nil || len(fields) != 1 {
 			return nil, errors.New("错误：获取总页数的SQL执行结果非唯一记录！")
 		}
+		if err := trows.Scan(&total[0]); err != nil {
+			return nil, err
+		}
 		// 2.获取当前页數據，約定該SQL放到第二條
-		rows, err := db.QueryMap(se.Cmds[1].Sql, &mp)
+		rows, err := traceQuery(db, nil, se, se.Cmds[1], mp)
 		if err != nil {
 			return nil, err
 		}
@@ -216,6 +215,35 @@ func PagingSelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (*P
 	return nil, err
 }
 
+// 执行游标分页查询SQL  mp 是MAP类型命名参数，cursor 为上一页返回的游标，为空则查询第一页，limit<=0 则使用配置的 pagesize
+func CursorSelectMapToMap(modelId, sqlId string, mp map[string]interface{}, cursor string, limit int) (*CursorSelectResult, error) {
+	m, se := findModelAndSql(modelId, sqlId)
+	if se == nil {
+		return nil, notFoundError(modelId + "/" + sqlId)
+	}
+	if se.Sqltype != ST_CURSORSELECT {
+		return nil, notMatchError()
+	}
+	return m.cursorSelectMap(se, mp, cursor, limit)
+}
+
+// 执行查询SQL（select、export类型）并将结果按 format（EF_CSV、EF_EXCEL、EF_NDJSON）逐行写入 w
+func ExportMap(modelId, sqlId string, mp map[string]interface{}, format string, w io.Writer) error {
+	se, db := findSqlAndDB(modelId, sqlId)
+	if se == nil {
+		return notFoundError(modelId + "/" + sqlId)
+	}
+	if se.Sqltype != ST_SELECT && se.Sqltype != ST_EXPORT {
+		return notMatchError()
+	}
+	rows, err := traceQuery(db, nil, se, se.Cmds[0], mp)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return exportRows(w, rows, format)
+}
+
 // 多個查询 返回 map[string][]map[string]interface{}
 func MultiSelectMapToMap(modelId, sqlId string, mp map[string]interface{}) (map[string][]map[string]interface{}, error) {
 	multirows, err := MultiSelectMapToRows(modelId, sqlId, mp)
@@ -235,8 +263,8 @@ func MultiSelectMapToMap(modelId, sqlId string, mp map[string]interface{}) (map[
 
 // 执行EXEC (UPDATE、DELETE、INSERT)，mp 是MAP类型命名参数 返回结果 sql.Result
 func ExecMap(modelId, sqlId string, mp map[string]interface{}) (sql.Result, error) {
-	// 获取Sqlentity,db
-	se, db := findSqlAndDB(modelId, sqlId)
+	// 获取Sqlentity,model
+	m, se := findModelAndSql(modelId, sqlId)
 	if se == nil {
 		return nil, notFoundError(modelId + "/" + sqlId)
 	}
@@ -244,47 +272,23 @@ func ExecMap(modelId, sqlId string, mp map[string]interface{}) (sql.Result, erro
 	if se.Sqltype != ST_EXEC {
 		return nil, notMatchError()
 	}
-	// return db.ExecMap(se.Cmds[0].Sql, &mp)
-	return nil, transact(db, func(tx *core.Tx) error {
-		// 循環每個sql定義
-		for _, cmd := range se.Cmds {
-			// faygo.Debug("ExecMap sql:" + cmd.Sql)
-			if _, err := tx.ExecMap(cmd.Sql, &mp); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
+	err := m.execMap(se, mp)
+	if err == nil {
+		invalidateCache(se)
+	}
+	return nil, err
 }
 
 // 执行EXEC (UPDATE、DELETE、INSERT)，SQL参数是struct  返回结果 sql.Result
 func ExecStruct(modelId, sqlId string, st interface{}) (sql.Result, error) {
-	// 获取Sqlentity,db
-	se, db := findSqlAndDB(modelId, sqlId)
-	if se == nil {
-		return nil, notFoundError(modelId + "/" + sqlId)
-	}
-	// 判断类型不是EXEC 就返回错误
-	if se.Sqltype != ST_EXEC {
-		return nil, notMatchError()
-	}
-	// return db.ExecStruct(se.Cmds[0].Sql, st)
-	return nil, transact(db, func(tx *core.Tx) error {
-		// 循環每個sql定義
-		for _, cmd := range se.Cmds {
-			// faygo.Debug("ExecMap sql:" + cmd.Sql)
-			if _, err := tx.ExecStruct(cmd.Sql, st); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
+	// 结构体的字段名作为命名参数
+	return ExecMap(modelId, sqlId, structToMap(st))
 }
 
 // 批量执行 UPDATE、INSERT、DELETE、mp 是MAP类型命名参数
 func BacthExecMap(modelId, sqlId string, sp []map[string]interface{}) error {
-	// 获取Sqlentity,db
-	se, db := findSqlAndDB(modelId, sqlId)
+	// 获取Sqlentity,model
+	m, se := findModelAndSql(modelId, sqlId)
 	if se == nil {
 		return notFoundError(modelId + "/" + sqlId)
 	}
@@ -292,21 +296,17 @@ func BacthExecMap(modelId, sqlId string, sp []map[string]interface{}) error {
 	if se.Sqltype != ST_BATCHEXEC {
 		return notMatchError()
 	}
-	return transact(db, func(tx *core.Tx) error {
-		for _, p := range sp {
-			faygo.Debug("BacthExecMap :" + se.Cmds[0].Sql)
-			if _, err := tx.ExecMap(se.Cmds[0].Sql, &p); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
+	err := m.bacthExecMap(se, sp)
+	if err == nil {
+		invalidateCache(se)
+	}
+	return err
 }
 
 // 批量执行 BacthComplex、mp 是MAP类型命名参数,事务中依次执行
 func BacthMultiExecMap(modelId, sqlId string, mp map[string][]map[string]interface{}) error {
-	// 获取Sqlentity,db
-	se, db := findSqlAndDB(modelId, sqlId)
+	// 获取Sqlentity,model
+	m, se := findModelAndSql(modelId, sqlId)
 	if se == nil {
 		return notFoundError(modelId + "/" + sqlId)
 	}
@@ -314,21 +314,9 @@ func BacthMultiExecMap(modelId, sqlId string, mp map[string][]map[string]interfa
 	if se.Sqltype != ST_BATCHMULTIEXEC {
 		return notMatchError()
 	}
-	return transact(db, func(tx *core.Tx) error {
-		// 循環每個sql定義
-		for _, cmd := range se.Cmds {
-			// 循環其批量參數
-			if sp, ok := mp[cmd.Pin]; ok {
-				for _, p := range sp {
-					faygo.Debug("BacthMultiExecMap :" + cmd.Sql)
-					if _, err := tx.ExecMap(cmd.Sql, &p); err != nil {
-						return err
-					}
-				}
-			} else {
-				return errors.New("错误：传入的参数与SQL节点定义的sql.pin名称不匹配！")
-			}
-		}
-		return nil
-	})
+	err := m.bacthMultiExecMap(se, mp)
+	if err == nil {
+		invalidateCache(se)
+	}
+	return err
 }
@@ -6,7 +6,7 @@
        1) 文件改名检测到但无法获知改名后文件故未更新----->可以用ReloadAll重新载入即可
    更新记录：
 */
-package directsql
+package engine
 
 import (
 	"strings"
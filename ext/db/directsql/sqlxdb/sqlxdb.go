@@ -0,0 +1,36 @@
+// Package sqlxdb lets directsql use the databases of ext/db/sqlx.
+//
+// The package directsqlx registers DB, so the database attribute of the model
+// files is the name of the database in config/sqlx.ini by default.
+// Use New and directsql.RegDB to use the sqlx databases from directsql.
+package sqlxdb
+
+import (
+	"github.com/andeya/faygo/ext/db/directsql/engine"
+	faygosqlx "github.com/andeya/faygo/ext/db/sqlx"
+	"github.com/jmoiron/sqlx"
+)
+
+// DB gets the directsql DB of the sqlx database of the name,
+// the name "" is the default database.
+func DB(name string) (engine.DB, bool) {
+	var (
+		db *sqlx.DB
+		ok bool
+	)
+	if len(name) == 0 {
+		db, ok = faygosqlx.DB()
+	} else {
+		db, ok = faygosqlx.DB(name)
+	}
+	if !ok || db == nil {
+		return nil, false
+	}
+	return New(db), true
+}
+
+// New creates a directsql DB of the sqlx database,
+// the ? placeholders are converted to those of its driver.
+func New(db *sqlx.DB) engine.DB {
+	return engine.NewSQLDB(db.DB, db.Rebind)
+}
@@ -0,0 +1,57 @@
+// Package xormdb lets directsql use the database engines of ext/db/xorm.
+//
+// The package directsql registers DB, so the database attribute of the model
+// files is the name of the engine in config/xorm.ini by default.
+// Use New and directsqlx.RegDB to use the xorm engines from directsqlx.
+package xormdb
+
+import (
+	"github.com/andeya/faygo/ext/db/directsql/engine"
+	faygoxorm "github.com/andeya/faygo/ext/db/xorm"
+	"xorm.io/core"
+	"xorm.io/xorm"
+)
+
+// DB gets the directsql DB of the xorm engine of the name,
+// the name "" is the default engine.
+func DB(name string) (engine.DB, bool) {
+	var (
+		e  *xorm.Engine
+		ok bool
+	)
+	if len(name) == 0 {
+		e, ok = faygoxorm.DB()
+	} else {
+		e, ok = faygoxorm.DB(name)
+	}
+	if !ok || e == nil {
+		return nil, false
+	}
+	return New(e), true
+}
+
+// New creates a directsql DB of the xorm engine,
+// the ? placeholders are converted to those of its dialect, such as $1 of postgres.
+func New(e *xorm.Engine) engine.DB {
+	return engine.NewSQLDB(e.DB().DB, Rebind(e.Dialect()))
+}
+
+// Rebind returns the function converting the ? placeholders to those of the dialect,
+// nil if the dialect uses the ? placeholders.
+func Rebind(dialect core.Dialect) func(query string) string {
+	var seqs []core.Filter
+	for _, f := range dialect.Filters() {
+		if _, ok := f.(*core.SeqFilter); ok {
+			seqs = append(seqs, f)
+		}
+	}
+	if len(seqs) == 0 {
+		return nil
+	}
+	return func(query string) string {
+		for _, f := range seqs {
+			query = f.Do(query, dialect, nil)
+		}
+		return query
+	}
+}
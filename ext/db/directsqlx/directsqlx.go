@@ -0,0 +1,415 @@
+/**
+* desc   : directsqlx 已合并到 directsql，这里的类型、常量与函数都是 directsql 执行引擎（子包 engine）的别名
+* desc   : 默认使用 ext/db/sqlx 配置的数据库（导入了子包 sqlxdb），与合并之前的 directsqlx 相同；
+*          SelectMapToRows 等函数仍然返回 *sqlx.Rows。
+*          directsql 与 directsqlx 共用同一个引擎与 model，同一个程序只能导入其中一个，都导入时初始化 panic；
+*          这时 xorm 的数据库通过 RegDB("名称", xormdb.New(engine)) 注册。
+*/
+package directsqlx
+
+import (
+	"database/sql"
+	"io"
+
+	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/ext/db/directsql/engine"
+	"github.com/andeya/faygo/ext/db/directsql/sqlxdb"
+	faygosqlx "github.com/andeya/faygo/ext/db/sqlx"
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// 默认使用 sqlx 的数据库
+func init() {
+	engine.RegFrontend("directsqlx", sqlxdb.DB)
+}
+
+type (
+	Authorizer         = engine.Authorizer
+	AuthorizerFunc     = engine.AuthorizerFunc
+	Cache              = engine.Cache
+	CursorSelectResult = engine.CursorSelectResult
+	DB                 = engine.DB
+	Execresult         = engine.Execresult
+	Executor           = engine.Executor
+	JWTAuthorizer      = engine.JWTAuthorizer
+	PagingSelectResult = engine.PagingSelectResult
+	SessionAuthorizer  = engine.SessionAuthorizer
+	TCmd               = engine.TCmd
+	TDefaultType       = engine.TDefaultType
+	TModel             = engine.TModel
+	TModels            = engine.TModels
+	TParaType          = engine.TParaType
+	TSql               = engine.TSql
+	TSqlParameter      = engine.TSqlParameter
+	TSqltype           = engine.TSqltype
+	Tx                 = engine.Tx
+	Worker             = engine.Worker
+)
+
+const (
+	Int                  = engine.Int
+	Float                = engine.Float
+	Email                = engine.Email
+	DefaultCacheSize     = engine.DefaultCacheSize
+	DM_DRYRUN            = engine.DM_DRYRUN
+	DM_EXPLAIN           = engine.DM_EXPLAIN
+	DM_TRACE             = engine.DM_TRACE
+	HeaderDebug          = engine.HeaderDebug
+	DefaultSlowThreshold = engine.DefaultSlowThreshold
+	EF_CSV               = engine.EF_CSV
+	EF_EXCEL             = engine.EF_EXCEL
+	EF_NDJSON            = engine.EF_NDJSON
+	MSCONFIGFILE         = engine.MSCONFIGFILE
+	ST_SELECT            = engine.ST_SELECT
+	ST_PAGINGSELECT      = engine.ST_PAGINGSELECT
+	ST_NESTEDSELECT      = engine.ST_NESTEDSELECT
+	ST_MULTISELECT       = engine.ST_MULTISELECT
+	ST_EXEC              = engine.ST_EXEC
+	ST_BATCHEXEC         = engine.ST_BATCHEXEC
+	ST_BATCHMULTIEXEC    = engine.ST_BATCHMULTIEXEC
+	ST_IMPORT            = engine.ST_IMPORT
+	ST_EXPORT            = engine.ST_EXPORT
+	ST_REPORT            = engine.ST_REPORT
+	ST_GETBLOB           = engine.ST_GETBLOB
+	ST_SETBLOB           = engine.ST_SETBLOB
+	ST_CURSORSELECT      = engine.ST_CURSORSELECT
+	DefaultPagesize      = engine.DefaultPagesize
+	PT_STRING            = engine.PT_STRING
+	PT_INT               = engine.PT_INT
+	PT_FLOAT             = engine.PT_FLOAT
+	PT_DATE              = engine.PT_DATE
+	PT_DATETIME          = engine.PT_DATETIME
+	PT_EMAIL             = engine.PT_EMAIL
+	PT_BLOB              = engine.PT_BLOB
+	DT_UNDEFINED         = engine.DT_UNDEFINED
+	DT_UUID              = engine.DT_UUID
+	DT_INT64UUID         = engine.DT_INT64UUID
+	DT_SHORTUUID         = engine.DT_SHORTUUID
+	DT_NOWDATE           = engine.DT_NOWDATE
+	DT_NOWDATETIME       = engine.DT_NOWDATETIME
+	DT_NOW_UNIX          = engine.DT_NOW_UNIX
+	DT_CUSTOM            = engine.DT_CUSTOM
+	DT_PARENTID          = engine.DT_PARENTID
+	DT_VALUE             = engine.DT_VALUE
+)
+
+var (
+	ErrOptimisticLock = engine.ErrOptimisticLock
+)
+
+// 字符串是否合法Email地址
+func IsEmail(str string) bool {
+	return engine.IsEmail(str)
+}
+
+// 字符串是否整数，空也是合法的.
+func IsInt(str interface{}) bool {
+	return engine.IsInt(str)
+}
+
+// 字符串是否浮点数
+func IsFloat(str interface{}) bool {
+	return engine.IsFloat(str)
+}
+
+// 字符串是否有效的长度
+func IsVaildLength(str string, min, max int) bool {
+	return engine.IsVaildLength(str, min, max)
+}
+
+// 给定的数值是否在范围内
+func IsVaildValue(value, min, max float64) bool {
+	return engine.IsVaildValue(value, min, max)
+}
+
+// 给定的字符串是否合法的日期时间
+func IsVaildDatetime(str string) bool {
+	return engine.IsVaildDatetime(str)
+}
+
+// 给定的字符串是否合法的日期(YYYY-MM-DD)
+func IsVaildDate(str string) bool {
+	return engine.IsVaildDate(str)
+}
+
+// 检查是否必须的
+func CheckRequired(str string) bool {
+	return engine.CheckRequired(str)
+}
+
+// SetCacheBackend sets the backend of the query result cache,
+// it is set by the [cache] section of directsql.ini when loading.
+// The old backend is closed if it implements io.Closer, nil means the default freecache.
+func SetCacheBackend(c Cache) {
+	engine.SetCacheBackend(c)
+}
+
+// NewFreeCache creates a Cache of the process memory, size is in bytes.
+// 只在本进程有效，多个节点时标签失效不会同步到其他节点，需要使用 NewRedisCache。
+func NewFreeCache(size int) Cache {
+	return engine.NewFreeCache(size)
+}
+
+// NewRedisCache creates a Cache of a redis protocol server,
+// such as redis or the freecache server, which is shared by all nodes.
+// password and db are optional.
+func NewRedisCache(addr, password string, db int) Cache {
+	return engine.NewRedisCache(addr, password, db)
+}
+
+// 根据key以及suffix后缀获取缓存的结果 has 表示存在有效的result，result为结果
+func GetCache(key string, suffix string) (ok bool, result []byte) {
+	return engine.GetCache(key, suffix)
+}
+
+// 将key以及suffix后缀的值放入到缓存中，如果存在则替换，timeout 为缓存时间(同 cachetime)
+func SetCache(key string, suffix string, value []byte, timeout int) {
+	engine.SetCache(key, suffix, value, timeout)
+}
+
+// InvalidateTags 使标签的全部缓存结果失效，使用共享的缓存后端时对全部节点有效
+func InvalidateTags(tags ...string) {
+	engine.InvalidateTags(tags...)
+}
+
+// 清除key的缓存
+func RemoveCache(key string) {
+	engine.RemoveCache(key)
+}
+
+// 清除全部缓存
+func ClearCache() {
+	engine.ClearCache()
+}
+
+// UUIDService 获取uuid的入口函数
+func UUIDService() *Worker {
+	return engine.UUIDService()
+}
+
+// RegAPIdoc adds an API doc entry for every sql of the loaded models to the frame.
+// pattern is the path of the DirectSQL() route, such as "/bos/*path",
+// which replaces its wildcard entry.
+// The entries are regenerated when the model files are reloaded.
+func RegAPIdoc(frame *faygo.Framework, pattern string) {
+	engine.RegAPIdoc(frame, pattern)
+}
+
+// SetAuthorizer sets the Authorizer of the sql with roles or permission.
+func SetAuthorizer(a Authorizer) {
+	engine.SetAuthorizer(a)
+}
+
+// SessionValue returns a function to register by RegAny,
+// which gets the value of the key from the session.
+// 例如：RegAny("tenantid", SessionValue("tenant_id"))
+func SessionValue(key string) func(ctx *faygo.Context) interface{} {
+	return engine.SessionValue(key)
+}
+
+// ClaimValue returns a function to register by RegAny,
+// which gets the value of the claim from the JWT token.
+// 例如：RegAny("tenantid", ClaimValue("tenant_id"))
+func ClaimValue(claim string) func(ctx *faygo.Context) interface{} {
+	return engine.ClaimValue(claim)
+}
+
+// 注册新的变量或函数到map
+func RegAny(name string, fn interface{}) (err error) {
+	return engine.RegAny(name, fn)
+}
+
+// NewSQLDB creates a DB of the database/sql DB.
+// rebind converts the ? placeholders to those of the driver (such as $1 of postgres),
+// nil keeps the ? placeholders.
+func NewSQLDB(db *sql.DB, rebind func(query string) string) DB {
+	return engine.NewSQLDB(db, rebind)
+}
+
+// RegDB registers the database of the name used by the database attribute
+// of the model files, the name "" is the default database.
+func RegDB(name string, db DB) {
+	engine.RegDB(name, db)
+}
+
+// RegDBResolver registers a function that gets the database of the name
+// which is not registered by RegDB, the name "" is the default database.
+func RegDBResolver(fn func(name string) (DB, bool)) {
+	engine.RegDBResolver(fn)
+}
+
+// DirectSQL handler 定义
+func DirectSQL() faygo.HandlerFunc {
+	return engine.DirectSQL()
+}
+
+// 重新载入全部ModelSql配置文件
+func DirectSQLReloadAll() faygo.HandlerFunc {
+	return engine.DirectSQLReloadAll()
+}
+
+// 重新载入单个ModelSql配置文件
+func DirectSQLReloadModel() faygo.HandlerFunc {
+	return engine.DirectSQLReloadModel()
+}
+
+// 将s 根据从右边第一个出现的c进行分割成两个stirng,比如 'aa / bb / cc' -> 'aa / bb','cc'
+func SplitRight(s string, c byte) (left, right string) {
+	return engine.SplitRight(s, c)
+}
+
+// 转换 interface{} 到 JSON
+func JSONString(v interface{}, Indent bool) (string, error) {
+	return engine.JSONString(v, Indent)
+}
+
+// Struct2Map
+func Struct2Map(st interface{}) map[string]interface{} {
+	return engine.Struct2Map(st)
+}
+
+// map值转化到struct中
+func Map2Struct(mp map[string]interface{}, st interface{}) error {
+	return engine.Map2Struct(mp, st)
+}
+
+func GetSqlType(modelid string, sqlid string) TSqltype {
+	return engine.GetSqlType(modelid, sqlid)
+}
+
+// 获取模型的数据库名称（model 文件的 database 属性），模型不存在时 ok 为 false
+func GetDatabase(modelid string) (database string, ok bool) {
+	return engine.GetDatabase(modelid)
+}
+
+// 重置配置文件全部重新载入,API：/bom/reload  handle调用
+func ReloadAll() {
+	engine.ReloadAll()
+}
+
+// 重新载入单个模型文件---未测试！！！
+func ReloadModel(msqlfile string) error {
+	return engine.ReloadModel(msqlfile)
+}
+
+// 默认参数处理
+func DealwithParameter(modelId, sqlId string, mp map[string]interface{}, sqlindex int, ctx *faygo.Context) error {
+	return engine.DealwithParameter(modelId, sqlId, mp, sqlindex, ctx)
+}
+
+func SelectMapIsExist(modelId, sqlId string, mp map[string]interface{}) (bool, error) {
+	return engine.SelectMapIsExist(modelId, sqlId, mp)
+}
+
+// 查询  根据modelId，sqlId ,SQL参数 map  返回 []map[string]interface{}
+func SelectMapToMap(modelId, sqlId string, mp map[string]interface{}) ([]map[string]interface{}, error) {
+	return engine.SelectMapToMap(modelId, sqlId, mp)
+}
+
+// 查询 根据modelId，sqlId ，SQL参数是map, 返回 []struct
+// 目前使用比较繁琐：st －－是结构体的一个空实例，返回的是 改结构体的实例的slice，再使用返还结果时还的需要转换下类型。
+func SelectMapToStruct(modelId, sqlId string, mp map[string]interface{}, st interface{}) (*[]interface{}, error) {
+	return engine.SelectMapToStruct(modelId, sqlId, mp, st)
+}
+
+// 查询 根据modelId，sqlId ，SQL参数是map,dest 是待填充的返回结果 []*Struct ---未完成
+func SelectMapToStructPro(modelId, sqlId string, mp map[string]interface{}, dest interface{}) error {
+	return engine.SelectMapToStructPro(modelId, sqlId, mp, dest)
+}
+
+// 执行分页查询SQL  mp 是MAP类型命名参数 返回结果 int,[]map[string][]interface{}
+func PagingSelectMapToMap(modelId, sqlId string, mp map[string]interface{}) (*PagingSelectResult, error) {
+	return engine.PagingSelectMapToMap(modelId, sqlId, mp)
+}
+
+// 执行游标分页查询SQL  mp 是MAP类型命名参数，cursor 为上一页返回的游标，为空则查询第一页，limit<=0 则使用配置的 pagesize
+func CursorSelectMapToMap(modelId, sqlId string, mp map[string]interface{}, cursor string, limit int) (*CursorSelectResult, error) {
+	return engine.CursorSelectMapToMap(modelId, sqlId, mp, cursor, limit)
+}
+
+// 执行查询SQL（select、export类型）并将结果按 format（EF_CSV、EF_EXCEL、EF_NDJSON）逐行写入 w
+func ExportMap(modelId, sqlId string, mp map[string]interface{}, format string, w io.Writer) error {
+	return engine.ExportMap(modelId, sqlId, mp, format, w)
+}
+
+// 多個查询 返回 map[string][]map[string]interface{}
+func MultiSelectMapToMap(modelId, sqlId string, mp map[string]interface{}) (map[string][]map[string]interface{}, error) {
+	return engine.MultiSelectMapToMap(modelId, sqlId, mp)
+}
+
+// 执行EXEC (UPDATE、DELETE、INSERT)，mp 是MAP类型命名参数 返回结果 sql.Result
+func ExecMap(modelId, sqlId string, mp map[string]interface{}) (sql.Result, error) {
+	return engine.ExecMap(modelId, sqlId, mp)
+}
+
+// 执行EXEC (UPDATE、DELETE、INSERT)，SQL参数是struct  返回结果 sql.Result
+func ExecStruct(modelId, sqlId string, st interface{}) (sql.Result, error) {
+	return engine.ExecStruct(modelId, sqlId, st)
+}
+
+// 批量执行 UPDATE、INSERT、DELETE、mp 是MAP类型命名参数
+func BacthExecMap(modelId, sqlId string, sp []map[string]interface{}) error {
+	return engine.BacthExecMap(modelId, sqlId, sp)
+}
+
+// 批量执行 BacthComplex、mp 是MAP类型命名参数,事务中依次执行
+func BacthMultiExecMap(modelId, sqlId string, mp map[string][]map[string]interface{}) error {
+	return engine.BacthMultiExecMap(modelId, sqlId, mp)
+}
+
+// 分頁查詢的返回結果
+type PagingSelectRows struct {
+	Total int `json:"total"`
+	Rows  *sqlx.Rows
+}
+
+// 查询 根据modelId，sqlId ，mp:map[string]interface{}命名参数,返回*sqlx.Rows
+func SelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (*sqlx.Rows, error) {
+	rows, err := engine.SelectMapToRows(modelId, sqlId, mp)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlx.Rows{Rows: rows, Mapper: mapperOf(modelId)}, nil
+}
+
+// 执行返回多個結果集的多個查询根据modelId，sqlId ，SQLmp:map[string]interface{}命名参数 返回结果 map[string]*sqlx.Rows
+func MultiSelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (map[string]*sqlx.Rows, error) {
+	multirows, err := engine.MultiSelectMapToRows(modelId, sqlId, mp)
+	if err != nil {
+		return nil, err
+	}
+	mapper := mapperOf(modelId)
+	result := make(map[string]*sqlx.Rows, len(multirows))
+	for k, rows := range multirows {
+		result[k] = &sqlx.Rows{Rows: rows, Mapper: mapper}
+	}
+	return result, nil
+}
+
+// 执行分页查询SQL  mp 是MAP类型命名参数 返回结果 int,Rows
+func PagingSelectMapToRows(modelId, sqlId string, mp map[string]interface{}) (*PagingSelectRows, error) {
+	page, err := engine.PagingSelectMapToRows(modelId, sqlId, mp)
+	if err != nil || page == nil {
+		return nil, err
+	}
+	return &PagingSelectRows{Total: page.Total, Rows: &sqlx.Rows{Rows: page.Rows, Mapper: mapperOf(modelId)}}, nil
+}
+
+// 模型的 sqlx 数据库的字段映射，用于 StructScan
+func mapperOf(modelId string) *reflectx.Mapper {
+	var (
+		db *sqlx.DB
+		ok bool
+	)
+	if name, _ := engine.GetDatabase(modelId); len(name) > 0 {
+		db, ok = faygosqlx.DB(name)
+	}
+	if !ok {
+		db, ok = faygosqlx.DB()
+	}
+	if ok && db != nil && db.Mapper != nil {
+		return db.Mapper
+	}
+	return reflectx.NewMapperFunc("db", sqlx.NameMapper)
+}
@@ -1,20 +0,0 @@
-/*
-  动态SQL路由注册
-  特别说明：将router定义代码放到 sysrouter.go中
-*/
-package directsqlx
-
-/*
-import (
-	"github.com/andeya/faygo"
-)
-
-//注册路由
-func SysRoute(frame *faygo.Framework) {
-	// bos 执行SQL定义的路由
-	frame.NamedAPI("DirectSQL", "GET POST", "/bos/*path", directsql.DirectSQL())                       //.Use(middleware.CheckLogin())
-	frame.NamedGET("DirectSQL ModelSql Reload", "/bom/reloadall", directsql.DirectSQLReloadAll())      //.Use(middleware.CheckLogin())
-	frame.NamedGET("DirectSQL ModelSql Reload", "/bom/reload/*path", directsql.DirectSQLReloadModel()) //.Use(middleware.CheckLogin())
-
-}
-}*/
@@ -17,6 +17,11 @@ type DBConfig struct {
 	MaxOpenConns int    `ini:"max_open_conns"`
 	MaxIdleConns int    `ini:"max_idle_conns"`
 	ShowSql      bool   `ini:"show_sql" comment:"print sql"`
+
+	// read/write splitting
+	Replicas          []string `ini:"replicas" delim:"|" comment:"Read-only replica connect strings, separated by |"`
+	HealthCheckSecond int      `ini:"health_check_second" comment:"Replica health check interval, default is 10s"`
+	StickySecond      int      `ini:"sticky_second" comment:"Read-your-writes window: the reads of a client go to the primary for seconds after its write, 0 disables it"`
 }
 
 // default constant
@@ -2,8 +2,10 @@ package gorm
 
 import (
 	"errors"
+	"time"
 
 	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/ext/db/replica"
 	"github.com/jinzhu/gorm"
 )
 
@@ -34,6 +36,62 @@ func List() map[string]*gorm.DB {
 	return dbService.List
 }
 
+// MustReadDB gets a healthy read-only replica of the specified database
+// in round-robin order, or the default DB if no name is specified.
+// It falls back to the primary engine when there is no healthy replica.
+func MustReadDB(name ...string) *gorm.DB {
+	engine, ok := ReadDB(name...)
+	if !ok {
+		faygo.Panicf("[gorm] the database engine `%s` is not configured", name[0])
+	}
+	return engine
+}
+
+// ReadDB is similar to MustReadDB, but safe.
+func ReadDB(name ...string) (*gorm.DB, bool) {
+	dbName := DEFAULTDB_NAME
+	if len(name) > 0 {
+		dbName = name[0]
+	}
+	engine, ok := dbService.List[dbName]
+	if !ok {
+		return nil, false
+	}
+	if idx := dbService.pools[dbName].Next(); idx >= 0 {
+		return dbService.Replicas[dbName][idx], true
+	}
+	return engine, true
+}
+
+// CtxReadDB is similar to MustReadDB, but returns the primary engine if the
+// client of ctx wrote to the database within the config item `sticky_second`.
+func CtxReadDB(ctx *faygo.Context, name ...string) *gorm.DB {
+	conf := MustConfig(name...)
+	if conf.StickySecond > 0 && replica.Sticky(ctx, conf.Name) {
+		return MustDB(name...)
+	}
+	return MustReadDB(name...)
+}
+
+// CtxWriteDB is similar to MustDB, and makes the following reads of the client
+// of ctx go to the primary engine within the config item `sticky_second`.
+func CtxWriteDB(ctx *faygo.Context, name ...string) *gorm.DB {
+	engine := MustDB(name...)
+	conf := MustConfig(name...)
+	replica.MarkWrite(ctx, conf.Name, time.Duration(conf.StickySecond)*time.Second)
+	return engine
+}
+
+// Stats gets the connection pool statistics of the primary and the replicas
+// of each database.
+func Stats() map[string]replica.Stats {
+	stats := make(map[string]replica.Stats, len(dbService.List))
+	for name, engine := range dbService.List {
+		stats[name] = dbService.pools[name].Stats(engine.DB())
+	}
+	return stats
+}
+
 // MustConfig gets the configuration information for the specified database,
 // or returns the default if no name is specified.
 func MustConfig(name ...string) DBConfig {
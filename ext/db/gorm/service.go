@@ -1,9 +1,11 @@
 package gorm
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jinzhu/gorm"
 
@@ -13,17 +15,26 @@ import (
 	// _ "github.com/jinzhu/gorm/dialects/sqlite"   //github.com/mattn/go-sqlite3
 
 	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/ext/db/replica"
 )
 
 // DBService is a database engine object.
 type DBService struct {
 	Default *gorm.DB            // the default database engine
 	List    map[string]*gorm.DB // database engine list
+	// read-only replica engines of each database
+	Replicas map[string][]*gorm.DB
+	pools    map[string]*replica.Pool
 }
 
+// gormLogger is shared by all engines, its level can be changed at runtime by name "gorm".
+var gormLogger = faygo.NewModuleLog("gorm")
+
 var dbService = func() (serv *DBService) {
 	serv = &DBService{
-		List: map[string]*gorm.DB{},
+		List:     map[string]*gorm.DB{},
+		Replicas: map[string][]*gorm.DB{},
+		pools:    map[string]*replica.Pool{},
 	}
 
 	var errs []string
@@ -46,17 +57,15 @@ var dbService = func() (serv *DBService) {
 		if !conf.Enable {
 			continue
 		}
-		engine, err := gorm.Open(conf.Driver, conf.Connstring)
+		engine, err := newEngine(conf, conf.Connstring)
 		if err != nil {
+			if engine != nil {
+				engine.Close()
+			}
 			faygo.Critical("[gorm]", err.Error())
 			errs = append(errs, err.Error())
 			continue
 		}
-		engine.SetLogger(faygo.NewLog())
-		engine.LogMode(conf.ShowSql)
-
-		engine.DB().SetMaxOpenConns(conf.MaxOpenConns)
-		engine.DB().SetMaxIdleConns(conf.MaxIdleConns)
 
 		if conf.Driver == "sqlite3" && !faygo.FileExists(conf.Connstring) {
 			os.MkdirAll(filepath.Dir(conf.Connstring), 0777)
@@ -69,10 +78,51 @@ var dbService = func() (serv *DBService) {
 			}
 		}
 
+		var replicas []*gorm.DB
+		var dbs []*sql.DB
+		var unhealthy []int
+		for i, connstring := range conf.Replicas {
+			r, err := newEngine(conf, connstring)
+			if r == nil {
+				faygo.Warningf("[gorm] skip the replica #%d of %s: %s", i, conf.Name, err.Error())
+				continue
+			}
+			if err != nil {
+				// the health check uses it once it is reachable
+				faygo.Warningf("[gorm] the replica #%d of %s is unhealthy: %s", i, conf.Name, err.Error())
+				unhealthy = append(unhealthy, len(replicas))
+			}
+			replicas = append(replicas, r)
+			dbs = append(dbs, r.DB())
+		}
+
 		serv.List[conf.Name] = engine
+		serv.Replicas[conf.Name] = replicas
+		serv.pools[conf.Name] = replica.NewPool(conf.Name, dbs, time.Duration(conf.HealthCheckSecond)*time.Second, unhealthy...)
 		if DEFAULTDB_NAME == conf.Name {
 			serv.Default = engine
 		}
 	}
 	return
 }()
+
+// newEngine creates an engine of the config section connected to connstring.
+// If only the ping fails, the engine is returned with the error.
+func newEngine(conf *DBConfig, connstring string) (*gorm.DB, error) {
+	db, err := sql.Open(conf.Driver, connstring)
+	if err != nil {
+		return nil, err
+	}
+	// gorm keeps the given *sql.DB open when the ping fails
+	engine, err := gorm.Open(conf.Driver, db)
+	if engine == nil {
+		db.Close()
+		return nil, err
+	}
+	engine.SetLogger(gormLogger)
+	engine.LogMode(conf.ShowSql)
+
+	engine.DB().SetMaxOpenConns(conf.MaxOpenConns)
+	engine.DB().SetMaxIdleConns(conf.MaxIdleConns)
+	return engine, err
+}
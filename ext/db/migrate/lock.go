@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andeya/faygo"
+)
+
+// lockRetryInterval is the interval between two attempts to take the lock.
+var lockRetryInterval = 500 * time.Millisecond
+
+// lock takes the migration lock, a single row of the table Table + "_lock",
+// waiting up to LockTimeout for another process to release it.
+// Locks older than LockTTL are considered left by a crashed process and removed,
+// so the lock is renewed every LockTTL/3 until unlock is called.
+// It is a no-op in dry-run mode.
+func (m *Migrator) lock() (unlock func(), err error) {
+	if m.DryRun {
+		return func() {}, nil
+	}
+	table := m.Table + "_lock"
+	if err = m.ensureTable(table, "id INT PRIMARY KEY, owner VARCHAR(128) NOT NULL, locked_at BIGINT NOT NULL"); err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	owner := hostname + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(rand.Int63(), 36)
+	deadline := time.Now().Add(m.LockTimeout)
+	var waiting bool
+	for {
+		now := time.Now()
+		m.DB.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = 1 AND locked_at < %s", table, m.placeholder(1)),
+			now.Add(-m.LockTTL).Unix())
+		_, err = m.DB.Exec(fmt.Sprintf("INSERT INTO %s (id, owner, locked_at) VALUES (1, %s, %s)",
+			table, m.placeholder(1), m.placeholder(2)), owner, now.Unix())
+		if err == nil {
+			break
+		}
+		if !isUniqueViolation(err) {
+			return nil, fmt.Errorf("[migrate] unable to take the lock %s: %s", table, err.Error())
+		}
+		if now.After(deadline) {
+			return nil, fmt.Errorf("[migrate] timeout waiting for the lock %s: %s", table, err.Error())
+		}
+		if !waiting {
+			waiting = true
+			faygo.Warningf("[migrate] waiting for another process to finish migrating")
+		}
+		time.Sleep(lockRetryInterval)
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go m.renewLock(table, owner, stop, done)
+	return func() {
+		close(stop)
+		<-done
+		_, err := m.DB.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = 1 AND owner = %s", table, m.placeholder(1)), owner)
+		if err != nil {
+			faygo.Errorf("[migrate] unable to release the lock %s: %s", table, err.Error())
+		}
+	}, nil
+}
+
+// renewLock refreshes the time of the lock until stop is closed,
+// so that a migration longer than LockTTL is not taken over.
+func (m *Migrator) renewLock(table, owner string, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	if m.LockTTL/3 <= 0 {
+		<-stop
+		return
+	}
+	ticker := time.NewTicker(m.LockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		res, err := m.DB.Exec(fmt.Sprintf("UPDATE %s SET locked_at = %s WHERE id = 1 AND owner = %s",
+			table, m.placeholder(1), m.placeholder(2)), time.Now().Unix(), owner)
+		if err != nil {
+			faygo.Errorf("[migrate] unable to renew the lock %s: %s", table, err.Error())
+			continue
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			faygo.Errorf("[migrate] the lock %s was taken over by another process", table)
+			return
+		}
+	}
+}
+
+// isUniqueViolation reports whether the error is a violation of the primary key,
+// that is, the lock is held by another process.
+func isUniqueViolation(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"unique",      // sqlite3, postgres (23505), oracle (ORA-00001)
+		"duplicate",   // mysql (1062), postgres
+		"primary key", // mssql (2627)
+		"23505",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
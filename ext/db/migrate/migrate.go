@@ -0,0 +1,324 @@
+// Package migrate runs versioned schema migrations on a database/sql DB.
+//
+// A migration has a version, a name, and an up and an optional down step,
+// written either as SQL (see LoadDir and LoadFS) or as Go functions
+// (see Register). The applied versions are recorded in the table
+// `schema_migrations`, and a lock table makes sure that only one process
+// migrates at a time, so that all replicas of a service can run them on start.
+//
+// The engines of the ext/db packages expose their *sql.DB:
+//
+//	m := migrate.New(xorm.MustDB().DB().DB, "mysql")
+//	if err := m.LoadDir("./migrations"); err != nil {
+//		faygo.Fatal(err)
+//	}
+//	m.UpBeforeRun() // runs the pending migrations before faygo.Run() listens
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/andeya/faygo"
+)
+
+// Default settings of the Migrator.
+const (
+	DefaultTable       = "schema_migrations"
+	DefaultLockTimeout = time.Minute
+	DefaultLockTTL     = 10 * time.Minute
+)
+
+type (
+	// Migration is a versioned schema change.
+	// The Go functions Up/Down take precedence over the SQL UpSQL/DownSQL.
+	Migration struct {
+		Version int64
+		Name    string
+		UpSQL   string
+		DownSQL string
+		Up      func(tx *sql.Tx) error
+		Down    func(tx *sql.Tx) error
+	}
+	// Status is the state of a known migration.
+	Status struct {
+		Version   int64      `json:"version"`
+		Name      string     `json:"name"`
+		AppliedAt *time.Time `json:"applied_at,omitempty"`
+	}
+	// Migrator applies and reverts the migrations of a database.
+	Migrator struct {
+		DB     *sql.DB
+		Driver string
+		// Table records the applied versions, the lock table is Table + "_lock".
+		Table string
+		// LockTimeout is how long to wait for another process to finish migrating.
+		LockTimeout time.Duration
+		// LockTTL is the age after which a lock left by a crashed process is removed.
+		LockTTL time.Duration
+		// DryRun logs the statements instead of executing them.
+		// Go migrations are only listed.
+		DryRun bool
+
+		migrations map[int64]*Migration
+	}
+)
+
+// New creates a Migrator of the db opened with the driver,
+// such as mysql, postgres or sqlite3.
+func New(db *sql.DB, driver string) *Migrator {
+	return &Migrator{
+		DB:          db,
+		Driver:      driver,
+		Table:       DefaultTable,
+		LockTimeout: DefaultLockTimeout,
+		LockTTL:     DefaultLockTTL,
+		migrations:  map[int64]*Migration{},
+	}
+}
+
+// Add adds migrations, the versions must be positive and unique.
+func (m *Migrator) Add(migrations ...*Migration) error {
+	for _, mig := range migrations {
+		if mig.Version <= 0 {
+			return fmt.Errorf("[migrate] invalid version %d of %q", mig.Version, mig.Name)
+		}
+		if old, ok := m.migrations[mig.Version]; ok {
+			return fmt.Errorf("[migrate] duplicate version %d: %q and %q", mig.Version, old.Name, mig.Name)
+		}
+		m.migrations[mig.Version] = mig
+	}
+	return nil
+}
+
+// Register adds a Go migration, down can be nil if it is irreversible.
+func (m *Migrator) Register(version int64, name string, up, down func(tx *sql.Tx) error) error {
+	if up == nil {
+		return fmt.Errorf("[migrate] version %d has no up function", version)
+	}
+	return m.Add(&Migration{Version: version, Name: name, Up: up, Down: down})
+}
+
+// Migrations returns the known migrations sorted by version.
+func (m *Migrator) Migrations() []*Migration {
+	list := make([]*Migration, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		list = append(list, mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// Status returns the state of the known migrations.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var list []Status
+	for _, mig := range m.Migrations() {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &at
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Up applies all pending migrations in version order.
+func (m *Migrator) Up() ([]*Migration, error) {
+	return m.UpTo(0)
+}
+
+// UpTo applies the pending migrations whose version <= version,
+// version <= 0 means all.
+func (m *Migrator) UpTo(version int64) (done []*Migration, err error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	for _, mig := range m.Migrations() {
+		if version > 0 && mig.Version > version {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if err = m.run(mig, true); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations, steps <= 0 means 1.
+func (m *Migrator) Down(steps int) (done []*Migration, err error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	unlock, err := m.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	for _, v := range versions {
+		if len(done) == steps {
+			break
+		}
+		mig, ok := m.migrations[v]
+		if !ok {
+			return done, fmt.Errorf("[migrate] applied version %d is unknown", v)
+		}
+		if mig.Down == nil && len(mig.DownSQL) == 0 {
+			return done, fmt.Errorf("[migrate] version %d %q is irreversible", v, mig.Name)
+		}
+		if err = m.run(mig, false); err != nil {
+			return done, err
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// UpBeforeRun applies the pending migrations before the faygo services start
+// listening, the process exits if it fails.
+// note: it should be called before faygo.Run()
+func (m *Migrator) UpBeforeRun() {
+	faygo.AddPreRunFunc(func() error {
+		_, err := m.Up()
+		return err
+	})
+}
+
+// run applies or reverts the migration and its record in a transaction.
+func (m *Migrator) run(mig *Migration, up bool) (err error) {
+	direction, fn, query := "up", mig.Up, mig.UpSQL
+	if !up {
+		direction, fn, query = "down", mig.Down, mig.DownSQL
+	}
+	if m.DryRun {
+		faygo.Infof("[migrate] [dry-run] %s %d %s", direction, mig.Version, mig.Name)
+		if fn == nil {
+			for _, stmt := range splitStatements(query) {
+				faygo.Infof("[migrate] [dry-run] %s;", stmt)
+			}
+		}
+		return nil
+	}
+	start := time.Now()
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			err = fmt.Errorf("[migrate] %s %d %q: %s", direction, mig.Version, mig.Name, err.Error())
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	if fn != nil {
+		err = fn(tx)
+	} else {
+		for _, stmt := range splitStatements(query) {
+			if _, err = tx.Exec(stmt); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return
+	}
+	if up {
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (%s, %s, %s)",
+			m.Table, m.placeholder(1), m.placeholder(2), m.placeholder(3)),
+			mig.Version, mig.Name, time.Now().Unix())
+	} else {
+		_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE version = %s", m.Table, m.placeholder(1)), mig.Version)
+	}
+	if err == nil {
+		faygo.Infof("[migrate] %s %d %s (%s)", direction, mig.Version, mig.Name, time.Since(start))
+	}
+	return
+}
+
+// applied returns the applied versions and when they were applied.
+func (m *Migrator) applied() (map[int64]time.Time, error) {
+	if err := m.ensureTable(m.Table, "version BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at BIGINT NOT NULL"); err != nil {
+		if m.DryRun {
+			return map[int64]time.Time{}, nil
+		}
+		return nil, err
+	}
+	rows, err := m.DB.Query(fmt.Sprintf("SELECT version, applied_at FROM %s", m.Table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version, at int64
+		if err = rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = time.Unix(at, 0)
+	}
+	return applied, rows.Err()
+}
+
+// ensureTable creates the table if it does not exist.
+// In dry-run mode, it only reports an error if the table does not exist.
+func (m *Migrator) ensureTable(table, columns string) error {
+	exists := func() bool {
+		rows, err := m.DB.Query("SELECT 1 FROM " + table + " WHERE 1 = 0")
+		if err != nil {
+			return false
+		}
+		rows.Close()
+		return true
+	}
+	if exists() {
+		return nil
+	}
+	if m.DryRun {
+		return errors.New("[migrate] table " + table + " does not exist")
+	}
+	_, err := m.DB.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", table, columns))
+	if err != nil && exists() {
+		// created by another process in the meantime
+		return nil
+	}
+	return err
+}
+
+// placeholder returns the bind variable of the i-th (from 1) parameter.
+func (m *Migrator) placeholder(i int) string {
+	switch m.Driver {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return fmt.Sprintf("$%d", i)
+	case "oci8", "goracle", "godror":
+		return fmt.Sprintf(":%d", i)
+	default:
+		return "?"
+	}
+}
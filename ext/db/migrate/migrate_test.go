@@ -0,0 +1,211 @@
+package migrate
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func versions(list []*Migration) []int64 {
+	var vs []int64
+	for _, mig := range list {
+		vs = append(vs, mig.Version)
+	}
+	return vs
+}
+
+func TestUpDown(t *testing.T) {
+	db := openDB(t)
+	m := New(db, "sqlite3")
+	if err := m.LoadDir("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	err := m.Register(3, "seed_user", func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO user (name, email) VALUES ('andeya', 'a@b.c')")
+		return err
+	}, func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM user")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := m.UpTo(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Fatalf("UpTo(2): got %v", got)
+	}
+	done, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{3}) {
+		t.Fatalf("Up: got %v", got)
+	}
+	var name string
+	if err = db.QueryRow("SELECT name FROM user WHERE email = 'a@b.c'").Scan(&name); err != nil || name != "andeya" {
+		t.Fatalf("seed: got %q, %v", name, err)
+	}
+
+	status, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			t.Fatalf("status: %d is not applied", s.Version)
+		}
+	}
+
+	// version 2 has no down file
+	done, err = m.Down(2)
+	if err == nil {
+		t.Fatal("Down(2): expect irreversible error")
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{3}) {
+		t.Fatalf("Down(2): got %v", got)
+	}
+	status, _ = m.Status()
+	if status[2].AppliedAt != nil || status[1].AppliedAt == nil {
+		t.Fatalf("status after down: %+v", status)
+	}
+}
+
+func TestFailedMigrationRollback(t *testing.T) {
+	db := openDB(t)
+	m := New(db, "sqlite3")
+	err := m.LoadFS(fstest.MapFS{
+		"sql/1_t.up.sql":   {Data: []byte("CREATE TABLE t (id INTEGER);")},
+		"sql/2_bad.up.sql": {Data: []byte("INSERT INTO t VALUES (1); INSERT INTO missing VALUES (1);")},
+	}, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, err := m.Up()
+	if err == nil {
+		t.Fatal("expect error")
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1}) {
+		t.Fatalf("Up: got %v", got)
+	}
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM t").Scan(&n)
+	if n != 0 {
+		t.Fatalf("the failed migration is not rolled back: %d rows", n)
+	}
+	status, _ := m.Status()
+	if status[1].AppliedAt != nil {
+		t.Fatal("the failed migration is recorded")
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	db := openDB(t)
+	m := New(db, "sqlite3")
+	m.DryRun = true
+	if err := m.LoadDir("testdata"); err != nil {
+		t.Fatal(err)
+	}
+	done, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Fatalf("dry-run: got %v", got)
+	}
+	if _, err = db.Exec("SELECT 1 FROM " + DefaultTable); err == nil {
+		t.Fatal("dry-run created the migrations table")
+	}
+}
+
+func TestLock(t *testing.T) {
+	db := openDB(t)
+	m := New(db, "sqlite3")
+	m.LockTimeout = 100 * time.Millisecond
+	unlock, err := m.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.lock(); err == nil {
+		t.Fatal("expect lock timeout")
+	}
+	unlock()
+	unlock, err = m.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+
+	// a stale lock is removed
+	db.Exec("INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, 'crashed', 0)")
+	if unlock, err = m.lock(); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+}
+
+func TestLockRenewed(t *testing.T) {
+	db := openDB(t)
+	m := New(db, "sqlite3")
+	m.LockTTL = time.Second
+	m.LockTimeout = 100 * time.Millisecond
+	unlock, err := m.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2500 * time.Millisecond)
+	// the lock held longer than LockTTL is not taken over
+	if _, err = m.lock(); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expect lock timeout, got %v", err)
+	}
+	unlock()
+}
+
+func TestLockError(t *testing.T) {
+	db := openDB(t)
+	if _, err := db.Exec("CREATE TABLE schema_migrations_lock (id INT PRIMARY KEY, locked_at BIGINT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	m := New(db, "sqlite3")
+	start := time.Now()
+	// the error other than the lock being held is returned at once
+	if _, err := m.lock(); err == nil || strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expect the insert error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("waited %s for a broken lock table", time.Since(start))
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	got := splitStatements(`
+-- comment; with semicolon
+INSERT INTO t VALUES ('a;b', "c;d"); /* block; comment */
+UPDATE t SET v = 1;
+-- trailing comment`)
+	want := []string{
+		"-- comment; with semicolon\nINSERT INTO t VALUES ('a;b', \"c;d\")",
+		"/* block; comment */\nUPDATE t SET v = 1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q", got)
+	}
+}
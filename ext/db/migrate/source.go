@@ -0,0 +1,139 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// fileRegexp matches the SQL migration file names, such as
+// 20220701120000_create_user.up.sql and 20220701120000_create_user.down.sql.
+var fileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadDir adds the SQL migrations of the directory.
+func (m *Migrator) LoadDir(dir string) error {
+	return m.LoadFS(os.DirFS(dir), ".")
+}
+
+// LoadFS adds the SQL migrations of the directory in fsys, such as an embed.FS.
+// The files are named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// the down file is optional. Other files are ignored.
+// The statements of a file are separated by semicolons.
+func (m *Migrator) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	found := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		sub := fileRegexp.FindStringSubmatch(entry.Name())
+		if sub == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(sub[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("[migrate] invalid version of %s: %s", entry.Name(), err.Error())
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		mig, ok := found[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: sub[2]}
+			found[version] = mig
+		} else if mig.Name != sub[2] {
+			return fmt.Errorf("[migrate] duplicate version %d: %q and %q", version, mig.Name, sub[2])
+		}
+		if sub[3] == "up" {
+			mig.UpSQL = string(b)
+		} else {
+			mig.DownSQL = string(b)
+		}
+	}
+	for _, mig := range found {
+		if len(strings.TrimSpace(mig.UpSQL)) == 0 {
+			return fmt.Errorf("[migrate] version %d %q has no up file", mig.Version, mig.Name)
+		}
+		if err := m.Add(mig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements splits the SQL script by the semicolons outside of
+// quotes and comments, and drops the empty statements.
+func splitStatements(script string) []string {
+	var (
+		list  []string
+		start int
+		quote byte
+	)
+	add := func(end int) {
+		if stmt := strings.TrimSpace(script[start:end]); len(stmt) > 0 && !isComment(stmt) {
+			list = append(list, stmt)
+		}
+		start = end + 1
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			if j := strings.IndexByte(script[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(script)
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			if j := strings.Index(script[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(script)
+			}
+		case c == ';':
+			add(i)
+		}
+	}
+	if start < len(script) {
+		add(len(script))
+	}
+	return list
+}
+
+// isComment reports whether the statement only consists of comments.
+func isComment(stmt string) bool {
+	for len(stmt) > 0 {
+		switch {
+		case strings.HasPrefix(stmt, "--"):
+			j := strings.IndexByte(stmt, '\n')
+			if j < 0 {
+				return true
+			}
+			stmt = stmt[j+1:]
+		case strings.HasPrefix(stmt, "/*"):
+			j := strings.Index(stmt, "*/")
+			if j < 0 {
+				return true
+			}
+			stmt = stmt[j+2:]
+		default:
+			return false
+		}
+		stmt = strings.TrimSpace(stmt)
+	}
+	return true
+}
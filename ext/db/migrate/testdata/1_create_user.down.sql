@@ -0,0 +1,2 @@
+DROP INDEX idx_user_name;
+DROP TABLE user;
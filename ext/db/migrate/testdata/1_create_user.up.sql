@@ -0,0 +1,6 @@
+-- the users
+CREATE TABLE user (
+	id INTEGER PRIMARY KEY,
+	name VARCHAR(64) NOT NULL DEFAULT 'a;b'
+);
+CREATE INDEX idx_user_name ON user (name);
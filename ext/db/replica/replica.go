@@ -0,0 +1,182 @@
+// Package replica balances the reads of a database config section over its
+// read-only replicas, and keeps read-your-writes stickiness per client.
+//
+// It is shared by the ext/db/xorm, ext/db/gorm and ext/db/sqlx packages,
+// which own the engines and only ask the Pool which replica to use.
+package replica
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/andeya/faygo"
+)
+
+// DefaultHealthCheckInterval is the default interval between two replica pings.
+const DefaultHealthCheckInterval = 10 * time.Second
+
+// Pool chooses the replicas of one config section in round-robin order,
+// skipping the ones whose last health check failed.
+type Pool struct {
+	name     string
+	dbs      []*sql.DB
+	healthy  []int32
+	next     uint32
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// Stats is the connection pool statistics of a config section.
+type Stats struct {
+	Primary  sql.DBStats    `json:"primary"`
+	Replicas []ReplicaStats `json:"replicas,omitempty"`
+}
+
+// ReplicaStats is the connection pool statistics of a replica.
+type ReplicaStats struct {
+	Index   int         `json:"index"`
+	Healthy bool        `json:"healthy"`
+	Stats   sql.DBStats `json:"stats"`
+}
+
+// NewPool creates a Pool for the replicas of the named section and starts
+// checking their health every interval (DefaultHealthCheckInterval if <= 0).
+// The replicas are considered healthy until a check fails, except the ones
+// whose indexes are in unhealthy, such as those unreachable at startup,
+// which are used once a check passes.
+func NewPool(name string, dbs []*sql.DB, interval time.Duration, unhealthy ...int) *Pool {
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+	p := &Pool{
+		name:    name,
+		dbs:     dbs,
+		healthy: make([]int32, len(dbs)),
+		stop:    make(chan struct{}),
+	}
+	for i := range p.healthy {
+		p.healthy[i] = 1
+	}
+	for _, i := range unhealthy {
+		if i >= 0 && i < len(p.healthy) {
+			p.healthy[i] = 0
+		}
+	}
+	if len(dbs) > 0 {
+		go p.healthCheck(interval)
+	}
+	return p
+}
+
+// Len returns the number of replicas.
+func (p *Pool) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.dbs)
+}
+
+// Next returns the index of the next healthy replica,
+// or -1 if there is none and the primary should be used.
+func (p *Pool) Next() int {
+	n := p.Len()
+	if n == 0 {
+		return -1
+	}
+	healthy := make([]int, 0, n)
+	for i := range p.healthy {
+		if atomic.LoadInt32(&p.healthy[i]) == 1 {
+			healthy = append(healthy, i)
+		}
+	}
+	if len(healthy) == 0 {
+		return -1
+	}
+	return healthy[atomic.AddUint32(&p.next, 1)%uint32(len(healthy))]
+}
+
+// Healthy reports whether the replica passed its last health check.
+func (p *Pool) Healthy(idx int) bool {
+	return atomic.LoadInt32(&p.healthy[idx]) == 1
+}
+
+// Check pings all replicas once and updates their health.
+func (p *Pool) Check() {
+	for i, db := range p.dbs {
+		err := db.Ping()
+		var healthy int32
+		if err == nil {
+			healthy = 1
+		}
+		if atomic.SwapInt32(&p.healthy[i], healthy) != healthy {
+			if err != nil {
+				faygo.Warningf("[replica] %s replica #%d is unhealthy: %s", p.name, i, err.Error())
+			} else {
+				faygo.Infof("[replica] %s replica #%d is healthy again", p.name, i)
+			}
+		}
+	}
+}
+
+// Stats returns the statistics of the primary and the replicas.
+func (p *Pool) Stats(primary *sql.DB) Stats {
+	s := Stats{Primary: primary.Stats()}
+	for i := 0; i < p.Len(); i++ {
+		s.Replicas = append(s.Replicas, ReplicaStats{
+			Index:   i,
+			Healthy: p.Healthy(i),
+			Stats:   p.dbs[i].Stats(),
+		})
+	}
+	return s
+}
+
+// Close stops the health check.
+func (p *Pool) Close() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+func (p *Pool) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.Check()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+const stickyCookiePrefix = "faygo_rw_"
+
+// MarkWrite records that the client of ctx wrote to the named section,
+// so that its reads go to the primary for the window.
+// It lasts for the current request and, through a cookie, for the
+// following requests of the same client within the window.
+func MarkWrite(ctx *faygo.Context, name string, window time.Duration) {
+	if ctx == nil || window <= 0 {
+		return
+	}
+	ctx.SetData(stickyCookiePrefix+name, true)
+	seconds := int(window / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	ctx.SetCookie(stickyCookiePrefix+name, "1", seconds, "/", nil, nil, true)
+}
+
+// Sticky reports whether the reads of the client of ctx should go to the
+// primary of the named section.
+func Sticky(ctx *faygo.Context, name string) bool {
+	if ctx == nil {
+		return false
+	}
+	return ctx.HasData(stickyCookiePrefix+name) || len(ctx.CookieParam(stickyCookiePrefix+name)) > 0
+}
@@ -0,0 +1,116 @@
+package replica
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+type fakeDriver struct{}
+
+// flakyDown makes the replica named "flaky" unreachable while it is 1.
+var flakyDown int32
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	if name == "down" || (name == "flaky" && atomic.LoadInt32(&flakyDown) == 1) {
+		return nil, errors.New("connection refused")
+	}
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not implemented") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not implemented") }
+
+func init() {
+	sql.Register("replica_fake", fakeDriver{})
+}
+
+func openDBs(t *testing.T, names ...string) []*sql.DB {
+	dbs := make([]*sql.DB, len(names))
+	for i, name := range names {
+		db, err := sql.Open("replica_fake", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbs[i] = db
+	}
+	return dbs
+}
+
+func TestPoolNext(t *testing.T) {
+	p := NewPool("test", openDBs(t, "a", "down", "b"), 0)
+	defer p.Close()
+
+	seen := map[int]int{}
+	for i := 0; i < 6; i++ {
+		seen[p.Next()]++
+	}
+	if seen[0] != 2 || seen[1] != 2 || seen[2] != 2 {
+		t.Fatalf("round-robin: got %v", seen)
+	}
+
+	p.Check()
+	seen = map[int]int{}
+	for i := 0; i < 6; i++ {
+		seen[p.Next()]++
+	}
+	if seen[1] != 0 || seen[0] != 3 || seen[2] != 3 {
+		t.Fatalf("skip unhealthy: got %v", seen)
+	}
+
+	stats := p.Stats(openDBs(t, "primary")[0])
+	if len(stats.Replicas) != 3 || stats.Replicas[1].Healthy || !stats.Replicas[0].Healthy {
+		t.Fatalf("stats: got %+v", stats)
+	}
+}
+
+func TestPoolNoReplica(t *testing.T) {
+	var p *Pool
+	if idx := p.Next(); idx != -1 {
+		t.Fatalf("nil pool: got %d", idx)
+	}
+	p = NewPool("test", openDBs(t, "down"), 0)
+	defer p.Close()
+	p.Check()
+	if idx := p.Next(); idx != -1 {
+		t.Fatalf("all unhealthy: got %d", idx)
+	}
+}
+
+func TestPoolUnhealthyAtStartup(t *testing.T) {
+	// the replica #1 failed the ping at startup
+	atomic.StoreInt32(&flakyDown, 1)
+	p := NewPool("test", openDBs(t, "a", "flaky", "b"), 0, 1)
+	defer p.Close()
+	seen := map[int]int{}
+	for i := 0; i < 6; i++ {
+		seen[p.Next()]++
+	}
+	if seen[1] != 0 || seen[0] != 3 || seen[2] != 3 {
+		t.Fatalf("skip the replica unhealthy at startup: got %v", seen)
+	}
+
+	// the health check brings it back
+	atomic.StoreInt32(&flakyDown, 0)
+	p.Check()
+	seen = map[int]int{}
+	for i := 0; i < 6; i++ {
+		seen[p.Next()]++
+	}
+	if seen[0] != 2 || seen[1] != 2 || seen[2] != 2 {
+		t.Fatalf("use the recovered replica: got %v", seen)
+	}
+
+	// the reads go to the primary if the only replica is unhealthy at startup
+	atomic.StoreInt32(&flakyDown, 1)
+	p2 := NewPool("test", openDBs(t, "flaky"), 0, 0)
+	defer p2.Close()
+	if idx := p2.Next(); idx != -1 {
+		t.Fatalf("only unhealthy replica: got %d", idx)
+	}
+}
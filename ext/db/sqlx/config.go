@@ -18,6 +18,11 @@ type DBConfig struct {
 	MaxIdleConns int    `ini:"max_idle_conns"`
 	ColumnSnake  bool   `ini:"column_snake" comment:"The column name uses the snake style or remains unchanged"`
 	StructTag    string `ini:"struct_tag" comment:"default is 'db'"`
+
+	// read/write splitting
+	Replicas          []string `ini:"replicas" delim:"|" comment:"Read-only replica connect strings, separated by |"`
+	HealthCheckSecond int      `ini:"health_check_second" comment:"Replica health check interval, default is 10s"`
+	StickySecond      int      `ini:"sticky_second" comment:"Read-your-writes window: the reads of a client go to the primary for seconds after its write, 0 disables it"`
 }
 
 // default constant
@@ -1,9 +1,11 @@
 package sqlx
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/jmoiron/sqlx/reflectx"
@@ -15,17 +17,23 @@ import (
 	// _ "github.com/mattn/go-sqlite3"      //sqlite
 
 	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/ext/db/replica"
 )
 
 // DBService is a database engine object.
 type DBService struct {
 	Default *sqlx.DB            // the default database engine
 	List    map[string]*sqlx.DB // database engine list
+	// read-only replica engines of each database
+	Replicas map[string][]*sqlx.DB
+	pools    map[string]*replica.Pool
 }
 
 var dbService = func() (serv *DBService) {
 	serv = &DBService{
-		List: map[string]*sqlx.DB{},
+		List:     map[string]*sqlx.DB{},
+		Replicas: map[string][]*sqlx.DB{},
+		pools:    map[string]*replica.Pool{},
 	}
 
 	var errs []string
@@ -48,24 +56,16 @@ var dbService = func() (serv *DBService) {
 		if !conf.Enable {
 			continue
 		}
-		db, err := sqlx.Connect(conf.Driver, conf.Connstring)
+		db, err := newDB(conf, conf.Connstring)
 		if err != nil {
+			if db != nil {
+				db.Close()
+			}
 			faygo.Critical("[sqlx]", err.Error())
 			errs = append(errs, err.Error())
 			continue
 		}
 
-		db.SetMaxOpenConns(conf.MaxOpenConns)
-		db.SetMaxIdleConns(conf.MaxIdleConns)
-
-		var strFunc = strings.ToLower
-		if conf.ColumnSnake {
-			strFunc = faygo.SnakeString
-		}
-
-		// Create a new mapper which will use the struct field tag "json" instead of "db"
-		db.Mapper = reflectx.NewMapperFunc(conf.StructTag, strFunc)
-
 		if conf.Driver == "sqlite3" && !faygo.FileExists(conf.Connstring) {
 			os.MkdirAll(filepath.Dir(conf.Connstring), 0777)
 			f, err := os.Create(conf.Connstring)
@@ -77,10 +77,51 @@ var dbService = func() (serv *DBService) {
 			}
 		}
 
+		var replicas []*sqlx.DB
+		var dbs []*sql.DB
+		var unhealthy []int
+		for i, connstring := range conf.Replicas {
+			r, err := newDB(conf, connstring)
+			if r == nil {
+				faygo.Warningf("[sqlx] skip the replica #%d of %s: %s", i, conf.Name, err.Error())
+				continue
+			}
+			if err != nil {
+				// the health check uses it once it is reachable
+				faygo.Warningf("[sqlx] the replica #%d of %s is unhealthy: %s", i, conf.Name, err.Error())
+				unhealthy = append(unhealthy, len(replicas))
+			}
+			replicas = append(replicas, r)
+			dbs = append(dbs, r.DB)
+		}
+
 		serv.List[conf.Name] = db
+		serv.Replicas[conf.Name] = replicas
+		serv.pools[conf.Name] = replica.NewPool(conf.Name, dbs, time.Duration(conf.HealthCheckSecond)*time.Second, unhealthy...)
 		if DEFAULTDB_NAME == conf.Name {
 			serv.Default = db
 		}
 	}
 	return
 }()
+
+// newDB creates a db of the config section connected to connstring.
+// If only the ping fails, the db is returned with the error.
+func newDB(conf *DBConfig, connstring string) (*sqlx.DB, error) {
+	db, err := sqlx.Open(conf.Driver, connstring)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(conf.MaxOpenConns)
+	db.SetMaxIdleConns(conf.MaxIdleConns)
+
+	var strFunc = strings.ToLower
+	if conf.ColumnSnake {
+		strFunc = faygo.SnakeString
+	}
+
+	// Create a new mapper which will use the struct field tag "json" instead of "db"
+	db.Mapper = reflectx.NewMapperFunc(conf.StructTag, strFunc)
+	return db, db.Ping()
+}
@@ -25,6 +25,11 @@ type DBConfig struct {
 	ColumnSnake  bool   `ini:"column_snake" comment:"the column name uses the snake style or remains unchanged"`
 	DisableCache bool   `ini:"disable_cache"`
 	ShowExecTime bool   `ini:"show_exec_time" comment:"print exec time"`
+
+	// read/write splitting
+	Replicas          []string `ini:"replicas" delim:"|" comment:"Read-only replica connect strings, separated by |"`
+	HealthCheckSecond int      `ini:"health_check_second" comment:"Replica health check interval, default is 10s"`
+	StickySecond      int      `ini:"sticky_second" comment:"Read-your-writes window: the reads of a client go to the primary for seconds after its write, 0 disables it"`
 }
 
 // default constant
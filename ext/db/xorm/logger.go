@@ -16,7 +16,7 @@ type ILogger struct {
 
 var iLogger = func() *ILogger {
 	log := &ILogger{
-		logging: faygo.NewLog(),
+		logging: faygo.NewModuleLog("xorm"),
 	}
 	log.logging.ExtraCalldepth++
 	return log
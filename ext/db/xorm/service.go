@@ -1,9 +1,11 @@
 package xorm
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"xorm.io/core"
 	"xorm.io/xorm"
@@ -15,17 +17,23 @@ import (
 	// _ "github.com/mattn/go-sqlite3"      //sqlite
 
 	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/ext/db/replica"
 )
 
 // DBService is a database engine object.
 type DBService struct {
 	Default *xorm.Engine            // the default database engine
 	List    map[string]*xorm.Engine // database engine list
+	// read-only replica engines of each database
+	Replicas map[string][]*xorm.Engine
+	pools    map[string]*replica.Pool
 }
 
 var dbService = func() (serv *DBService) {
 	serv = &DBService{
-		List: map[string]*xorm.Engine{},
+		List:     map[string]*xorm.Engine{},
+		Replicas: map[string][]*xorm.Engine{},
+		pools:    map[string]*replica.Pool{},
 	}
 	var errs []string
 	defer func() {
@@ -47,52 +55,15 @@ var dbService = func() (serv *DBService) {
 		if !conf.Enable {
 			continue
 		}
-		engine, err := xorm.NewEngine(conf.Driver, conf.Connstring)
-		if err != nil {
-			faygo.Critical("[xorm]", err.Error())
-			errs = append(errs, err.Error())
-			continue
-		}
-		err = engine.Ping()
+		engine, err := newEngine(conf, conf.Connstring)
 		if err != nil {
+			if engine != nil {
+				engine.Close()
+			}
 			faygo.Critical("[xorm]", err.Error())
 			errs = append(errs, err.Error())
 			continue
 		}
-		engine.SetLogger(iLogger)
-		engine.SetMaxOpenConns(conf.MaxOpenConns)
-		engine.SetMaxIdleConns(conf.MaxIdleConns)
-		engine.SetDisableGlobalCache(conf.DisableCache)
-		engine.ShowSQL(conf.ShowSql)
-		engine.ShowExecTime(conf.ShowExecTime)
-
-		if (conf.TableFix == "prefix" || conf.TableFix == "suffix") && len(conf.TableSpace) > 0 {
-			var impr core.IMapper
-			if conf.TableSnake {
-				impr = core.SnakeMapper{}
-			} else {
-				impr = core.SameMapper{}
-			}
-			if conf.TableFix == "prefix" {
-				engine.SetTableMapper(core.NewPrefixMapper(impr, conf.TableSpace))
-			} else {
-				engine.SetTableMapper(core.NewSuffixMapper(impr, conf.TableSpace))
-			}
-		}
-
-		if (conf.ColumnFix == "prefix" || conf.ColumnFix == "suffix") && len(conf.ColumnSpace) > 0 {
-			var impr core.IMapper
-			if conf.ColumnSnake {
-				impr = core.SnakeMapper{}
-			} else {
-				impr = core.SameMapper{}
-			}
-			if conf.ColumnFix == "prefix" {
-				engine.SetTableMapper(core.NewPrefixMapper(impr, conf.ColumnSpace))
-			} else {
-				engine.SetTableMapper(core.NewSuffixMapper(impr, conf.ColumnSpace))
-			}
-		}
 
 		if conf.Driver == "sqlite3" && !faygo.FileExists(conf.Connstring) {
 			os.MkdirAll(filepath.Dir(conf.Connstring), 0777)
@@ -105,10 +76,74 @@ var dbService = func() (serv *DBService) {
 			}
 		}
 
+		var replicas []*xorm.Engine
+		var dbs []*sql.DB
+		var unhealthy []int
+		for i, connstring := range conf.Replicas {
+			r, err := newEngine(conf, connstring)
+			if r == nil {
+				faygo.Warningf("[xorm] skip the replica #%d of %s: %s", i, conf.Name, err.Error())
+				continue
+			}
+			if err != nil {
+				// the health check uses it once it is reachable
+				faygo.Warningf("[xorm] the replica #%d of %s is unhealthy: %s", i, conf.Name, err.Error())
+				unhealthy = append(unhealthy, len(replicas))
+			}
+			replicas = append(replicas, r)
+			dbs = append(dbs, r.DB().DB)
+		}
+
 		serv.List[conf.Name] = engine
+		serv.Replicas[conf.Name] = replicas
+		serv.pools[conf.Name] = replica.NewPool(conf.Name, dbs, time.Duration(conf.HealthCheckSecond)*time.Second, unhealthy...)
 		if DEFAULTDB_NAME == conf.Name {
 			serv.Default = engine
 		}
 	}
 	return
 }()
+
+// newEngine creates an engine of the config section connected to connstring.
+// If only the ping fails, the engine is returned with the error.
+func newEngine(conf *DBConfig, connstring string) (*xorm.Engine, error) {
+	engine, err := xorm.NewEngine(conf.Driver, connstring)
+	if err != nil {
+		return nil, err
+	}
+	engine.SetLogger(iLogger)
+	engine.SetMaxOpenConns(conf.MaxOpenConns)
+	engine.SetMaxIdleConns(conf.MaxIdleConns)
+	engine.SetDisableGlobalCache(conf.DisableCache)
+	engine.ShowSQL(conf.ShowSql)
+	engine.ShowExecTime(conf.ShowExecTime)
+
+	if (conf.TableFix == "prefix" || conf.TableFix == "suffix") && len(conf.TableSpace) > 0 {
+		var impr core.IMapper
+		if conf.TableSnake {
+			impr = core.SnakeMapper{}
+		} else {
+			impr = core.SameMapper{}
+		}
+		if conf.TableFix == "prefix" {
+			engine.SetTableMapper(core.NewPrefixMapper(impr, conf.TableSpace))
+		} else {
+			engine.SetTableMapper(core.NewSuffixMapper(impr, conf.TableSpace))
+		}
+	}
+
+	if (conf.ColumnFix == "prefix" || conf.ColumnFix == "suffix") && len(conf.ColumnSpace) > 0 {
+		var impr core.IMapper
+		if conf.ColumnSnake {
+			impr = core.SnakeMapper{}
+		} else {
+			impr = core.SameMapper{}
+		}
+		if conf.ColumnFix == "prefix" {
+			engine.SetTableMapper(core.NewPrefixMapper(impr, conf.ColumnSpace))
+		} else {
+			engine.SetTableMapper(core.NewSuffixMapper(impr, conf.ColumnSpace))
+		}
+	}
+	return engine, engine.Ping()
+}
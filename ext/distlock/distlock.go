@@ -0,0 +1,61 @@
+// Package distlock makes sure that only one replica of a service runs a
+// scheduled job, by claiming each run of the job in a shared store.
+//
+// A run is identified by the job name and its scheduled time, the replica that
+// claims it first runs the job and the others skip it. A claim is never released,
+// it expires after the TTL so that a replica whose clock lags behind by less than
+// the TTL can not run the job again.
+//
+// The ext/cron and ext/task schedulers consult a Locker when it is set:
+//
+//	locker := distlock.NewSQLLocker(xorm.MustDB().DB().DB, "mysql")
+//	c := cron.New()
+//	c.Locker = locker
+//	task.SetLocker(locker, 0)
+//
+// NewRedisLocker uses a redis-protocol server instead, such as redis or the
+// bundled freecache server.
+package distlock
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultTTL is the default time a claim is kept.
+const DefaultTTL = 10 * time.Minute
+
+// Locker claims the runs of the scheduled jobs.
+type Locker interface {
+	// TryLock claims the run of the job name at the scheduled time for ttl,
+	// and reports whether this replica got it.
+	TryLock(name string, scheduled time.Time, ttl time.Duration) (bool, error)
+}
+
+// LockerFunc is an adapter to use a function as a Locker.
+type LockerFunc func(name string, scheduled time.Time, ttl time.Duration) (bool, error)
+
+// TryLock calls f(name, scheduled, ttl).
+func (f LockerFunc) TryLock(name string, scheduled time.Time, ttl time.Duration) (bool, error) {
+	return f(name, scheduled, ttl)
+}
+
+// Key returns the key of the run of the job name at the scheduled time.
+func Key(name string, scheduled time.Time) string {
+	return name + "@" + strconv.FormatInt(scheduled.Unix(), 10)
+}
+
+// NewOwner returns an identity of this process, used to tell which replica holds a claim.
+func NewOwner() string {
+	hostname, _ := os.Hostname()
+	return hostname + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(rand.Int63(), 36)
+}
+
+func ttlOrDefault(ttl time.Duration) time.Duration {
+	if ttl > 0 {
+		return ttl
+	}
+	return DefaultTTL
+}
@@ -0,0 +1,82 @@
+package distlock
+
+import (
+	"database/sql"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andeya/faygo/freecache/redisserver"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// testLocker checks that only one of the replicas claims a run.
+func testLocker(t *testing.T, replicas ...Locker) {
+	scheduled := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, run := range []time.Time{scheduled, scheduled.Add(time.Minute)} {
+		var claimed int
+		for _, l := range replicas {
+			ok, err := l.TryLock("job", run, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok {
+				claimed++
+			}
+		}
+		if claimed != 1 {
+			t.Fatalf("run at %s: claimed by %d replicas, want 1", run, claimed)
+		}
+	}
+	ok, err := replicas[len(replicas)-1].TryLock("other", scheduled, time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("another job: got %v, %v", ok, err)
+	}
+	// the owner claims the run again
+	if ok, err = replicas[len(replicas)-1].TryLock("other", scheduled, time.Minute); err != nil || !ok {
+		t.Fatalf("the owner claims again: got %v, %v", ok, err)
+	}
+}
+
+func TestSQLLocker(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	a, b := NewSQLLocker(db, "sqlite3"), NewSQLLocker(db, "sqlite3")
+	testLocker(t, a, b)
+
+	// the expired claims are removed
+	scheduled := time.Now()
+	if ok, _ := a.TryLock("expire", scheduled, time.Minute); !ok {
+		t.Fatal("want the claim")
+	}
+	if _, err = db.Exec("UPDATE faygo_locks SET expires_at = 0 WHERE name = 'expire'"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := b.TryLock("expire", scheduled, time.Minute); !ok {
+		t.Fatal("expired claim: want true")
+	}
+}
+
+func TestRedisLocker(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go redisserver.NewServer(0).Serve(l)
+
+	a, b := NewRedisLocker(l.Addr().String()), NewRedisLocker(l.Addr().String())
+	defer a.Close()
+	defer b.Close()
+	testLocker(t, a, b)
+
+	// reconnects after the connection is lost
+	a.conn.Close()
+	if ok, err := a.TryLock("reconnect", time.Now(), time.Second); err != nil || !ok {
+		t.Fatalf("reconnect: got %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,164 @@
+package distlock
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultPrefix is the default key prefix of the RedisLocker.
+const DefaultPrefix = "faygo:lock:"
+
+// RedisLocker claims the runs with SET key owner EX ttl NX on a redis-protocol
+// server, such as redis or the bundled freecache server.
+type RedisLocker struct {
+	Addr     string
+	Password string
+	Prefix   string
+	Owner    string
+	// Timeout is the dial, read and write timeout of a command.
+	Timeout time.Duration
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewRedisLocker creates a RedisLocker of the server at addr.
+func NewRedisLocker(addr string) *RedisLocker {
+	return &RedisLocker{
+		Addr:    addr,
+		Prefix:  DefaultPrefix,
+		Owner:   NewOwner(),
+		Timeout: 5 * time.Second,
+	}
+}
+
+// TryLock claims the run of the job name at the scheduled time for ttl,
+// which is rounded up to seconds.
+func (l *RedisLocker) TryLock(name string, scheduled time.Time, ttl time.Duration) (bool, error) {
+	seconds := int64((ttlOrDefault(ttl) + time.Second - 1) / time.Second)
+	key := l.Prefix + Key(name, scheduled)
+	reply, err := l.do("SET", key, l.Owner, "EX", strconv.FormatInt(seconds, 10), "NX")
+	if err != nil || reply != nil {
+		return err == nil, err
+	}
+	// a nil reply means the key exists
+	if reply, err = l.do("GET", key); err != nil {
+		return false, err
+	}
+	return reply == l.Owner, nil
+}
+
+// Close closes the connection.
+func (l *RedisLocker) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closeConn()
+}
+
+// do sends the command and returns the reply, retrying once on a new connection.
+func (l *RedisLocker) do(args ...string) (reply interface{}, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := 0; i < 2; i++ {
+		if l.conn == nil {
+			if err = l.connect(); err != nil {
+				continue
+			}
+		}
+		if reply, err = l.roundTrip(args); err == nil {
+			return reply, nil
+		}
+		if _, ok := err.(redisError); ok {
+			return nil, err
+		}
+		l.closeConn()
+	}
+	return nil, err
+}
+
+func (l *RedisLocker) connect() error {
+	conn, err := net.DialTimeout("tcp", l.Addr, l.Timeout)
+	if err != nil {
+		return err
+	}
+	l.conn, l.r = conn, bufio.NewReader(conn)
+	if len(l.Password) > 0 {
+		if _, err = l.roundTrip([]string{"AUTH", l.Password}); err != nil {
+			l.closeConn()
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *RedisLocker) closeConn() error {
+	if l.conn == nil {
+		return nil
+	}
+	err := l.conn.Close()
+	l.conn, l.r = nil, nil
+	return err
+}
+
+func (l *RedisLocker) roundTrip(args []string) (interface{}, error) {
+	if l.Timeout > 0 {
+		l.conn.SetDeadline(time.Now().Add(l.Timeout))
+	}
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, "$"+strconv.Itoa(len(arg))+"\r\n"+arg+"\r\n"...)
+	}
+	if _, err := l.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return readReply(l.r)
+}
+
+// redisError is an error reply of the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+var errProtocol = errors.New("redis: protocol error")
+
+// readReply reads a simple string, error, integer or bulk string reply,
+// a nil bulk string is returned as nil.
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errProtocol
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	}
+	return nil, fmt.Errorf("redis: unsupported reply %q", line)
+}
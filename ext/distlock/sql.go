@@ -0,0 +1,96 @@
+package distlock
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultTable is the default table of the SQLLocker.
+const DefaultTable = "faygo_locks"
+
+// SQLLocker claims the runs with the rows of a table whose primary key is the
+// job name and the scheduled time, the table is created on the first use.
+// The engines of the ext/db packages expose their *sql.DB:
+//
+//	locker := distlock.NewSQLLocker(xorm.MustDB().DB().DB, "mysql")
+type SQLLocker struct {
+	DB     *sql.DB
+	Driver string
+	Table  string
+	Owner  string
+
+	mu    sync.Mutex
+	ready bool
+}
+
+// NewSQLLocker creates a SQLLocker of the db opened with the driver,
+// such as mysql, postgres or sqlite3.
+func NewSQLLocker(db *sql.DB, driver string) *SQLLocker {
+	return &SQLLocker{
+		DB:     db,
+		Driver: driver,
+		Table:  DefaultTable,
+		Owner:  NewOwner(),
+	}
+}
+
+// TryLock claims the run of the job name at the scheduled time for ttl.
+func (l *SQLLocker) TryLock(name string, scheduled time.Time, ttl time.Duration) (bool, error) {
+	if err := l.ensureTable(); err != nil {
+		return false, err
+	}
+	now := time.Now()
+	l.DB.Exec(fmt.Sprintf("DELETE FROM %s WHERE expires_at < %s", l.Table, l.placeholder(1)), now.Unix())
+	_, err := l.DB.Exec(fmt.Sprintf("INSERT INTO %s (name, scheduled, owner, expires_at) VALUES (%s, %s, %s, %s)",
+		l.Table, l.placeholder(1), l.placeholder(2), l.placeholder(3), l.placeholder(4)),
+		name, scheduled.Unix(), l.Owner, now.Add(ttlOrDefault(ttl)).Unix())
+	if err == nil {
+		return true, nil
+	}
+	// the insert fails when another replica has claimed the run
+	var owner string
+	if e := l.DB.QueryRow(fmt.Sprintf("SELECT owner FROM %s WHERE name = %s AND scheduled = %s",
+		l.Table, l.placeholder(1), l.placeholder(2)), name, scheduled.Unix()).Scan(&owner); e != nil {
+		return false, err
+	}
+	return owner == l.Owner, nil
+}
+
+func (l *SQLLocker) ensureTable() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ready {
+		return nil
+	}
+	exists := func() bool {
+		rows, err := l.DB.Query("SELECT 1 FROM " + l.Table + " WHERE 1 = 0")
+		if err != nil {
+			return false
+		}
+		rows.Close()
+		return true
+	}
+	if !exists() {
+		_, err := l.DB.Exec(fmt.Sprintf("CREATE TABLE %s (name VARCHAR(191) NOT NULL, scheduled BIGINT NOT NULL, "+
+			"owner VARCHAR(128) NOT NULL, expires_at BIGINT NOT NULL, PRIMARY KEY (name, scheduled))", l.Table))
+		// the table may be created by another replica in the meantime
+		if err != nil && !exists() {
+			return err
+		}
+	}
+	l.ready = true
+	return nil
+}
+
+func (l *SQLLocker) placeholder(i int) string {
+	switch l.Driver {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return fmt.Sprintf("$%d", i)
+	case "oci8", "goracle", "godror":
+		return fmt.Sprintf(":%d", i)
+	default:
+		return "?"
+	}
+}
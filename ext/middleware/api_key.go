@@ -0,0 +1,122 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/andeya/faygo"
+)
+
+type (
+	// APIKeyConfig is the configuration of the APIKey middleware.
+	APIKeyConfig struct {
+		// Header is the request header of the key, defaults to "X-API-Key".
+		Header string
+		// Query is the query parameter of the key, the query is not read if it is empty.
+		// Note: the keys in the URLs may be logged by the proxies.
+		Query string
+		// Store looks up the owners of the keys.
+		Store APIKeyStore
+	}
+	// APIKeyStore looks up the owner of an API key.
+	APIKeyStore interface {
+		Lookup(key string) (owner string, ok bool)
+	}
+	// APIKeyFunc is an adapter to allow the use of ordinary functions as APIKeyStore.
+	APIKeyFunc func(key string) (owner string, ok bool)
+	// APIKeys is an APIKeyStore of the owners by key.
+	APIKeys map[string]string
+)
+
+// Lookup implements the APIKeyStore.
+func (fn APIKeyFunc) Lookup(key string) (string, bool) {
+	return fn(key)
+}
+
+// Lookup implements the APIKeyStore.
+// The SHA-256 of the key is compared with that of every key in constant time,
+// so the time taken does not reveal the keys.
+func (keys APIKeys) Lookup(key string) (string, bool) {
+	sum := sha256.Sum256([]byte(key))
+	var owner string
+	var found int
+	for k, o := range keys {
+		ksum := sha256.Sum256([]byte(k))
+		if subtle.ConstantTimeCompare(sum[:], ksum[:]) == 1 {
+			owner, found = o, 1
+		}
+	}
+	return owner, found == 1
+}
+
+// APIKey is the API key authentication middleware.
+// The owner of the key is set to the data key AuthUserKey.
+type APIKey struct {
+	config APIKeyConfig
+}
+
+var _ faygo.SecurityDefiner = new(APIKey)
+
+// NewAPIKey creates the API key authentication middleware.
+func NewAPIKey(config APIKeyConfig) *APIKey {
+	if config.Store == nil {
+		faygo.Panic("middleware: the APIKeyStore is nil")
+	}
+	if config.Header == "" {
+		config.Header = "X-API-Key"
+	}
+	return &APIKey{config: config}
+}
+
+// Serve implements the faygo.Handler.
+func (a *APIKey) Serve(ctx *faygo.Context) error {
+	key := ctx.HeaderParam(a.config.Header)
+	if key == "" && a.config.Query != "" {
+		key = ctx.QueryParam(a.config.Query)
+	}
+	if key == "" {
+		ctx.Error(http.StatusUnauthorized, "missing API key")
+		return nil
+	}
+	owner, ok := a.config.Store.Lookup(key)
+	if !ok {
+		ctx.Error(http.StatusUnauthorized, "invalid API key")
+		return nil
+	}
+	ctx.SetData(AuthUserKey, owner)
+	return nil
+}
+
+// SecurityDefinitions implements the faygo.SecurityDefiner.
+func (a *APIKey) SecurityDefinitions() map[string]map[string]interface{} {
+	defs := map[string]map[string]interface{}{
+		"apiKeyHeader": {
+			"type": "apiKey",
+			"in":   "header",
+			"name": a.config.Header,
+		},
+	}
+	if a.config.Query != "" {
+		defs["apiKeyQuery"] = map[string]interface{}{
+			"type": "apiKey",
+			"in":   "query",
+			"name": a.config.Query,
+		}
+	}
+	return defs
+}
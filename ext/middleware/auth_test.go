@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andeya/faygo"
+)
+
+func user(ctx *faygo.Context) error {
+	return ctx.String(http.StatusOK, ctx.Data(AuthUserKey).(string))
+}
+
+func TestBasicAuth(t *testing.T) {
+	app := startFrame(t, "basic-auth-test", func(frame *faygo.Framework) {
+		frame.GET("/basic", faygo.HandlerFunc(user)).Use(NewBasicAuth("admin", BasicAuthUsers{"admin": "secret"}))
+	})
+	var tests = []struct {
+		username, password string
+		code               int
+	}{
+		{"admin", "secret", http.StatusOK},
+		{"admin", "wrong", http.StatusUnauthorized},
+		{"admin", "", http.StatusUnauthorized},
+		{"other", "secret", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", app+"/basic", nil)
+		if test.username != "" {
+			req.SetBasicAuth(test.username, test.password)
+		}
+		resp, body := do(t, req.Method, req.URL.String(), req.Header, "")
+		if resp.StatusCode != test.code {
+			t.Errorf("%s:%s: expected %d, got %d", test.username, test.password, test.code, resp.StatusCode)
+		}
+		if test.code == http.StatusOK && body != "admin" {
+			t.Errorf("expected the authenticated user, got %q", body)
+		}
+		if test.code == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != `Basic realm="admin", charset="UTF-8"` {
+			t.Errorf("expected the challenge, got %q", resp.Header.Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	app := startFrame(t, "api-key-test", func(frame *faygo.Framework) {
+		frame.GET("/header", faygo.HandlerFunc(user)).Use(NewAPIKey(APIKeyConfig{Store: APIKeys{"k1": "alice"}}))
+		frame.GET("/query", faygo.HandlerFunc(user)).Use(NewAPIKey(APIKeyConfig{Query: "api_key", Store: APIKeys{"k1": "alice"}}))
+	})
+	var tests = []struct {
+		path   string
+		header http.Header
+		code   int
+	}{
+		{"/header", http.Header{"X-Api-Key": {"k1"}}, http.StatusOK},
+		{"/header", http.Header{"X-Api-Key": {"k2"}}, http.StatusUnauthorized},
+		{"/header", http.Header{"X-Api-Key": {"k"}}, http.StatusUnauthorized},
+		{"/header", http.Header{"X-Api-Key": {"k10"}}, http.StatusUnauthorized},
+		{"/header", nil, http.StatusUnauthorized},
+		{"/header?api_key=k1", nil, http.StatusUnauthorized},
+		{"/query?api_key=k1", nil, http.StatusOK},
+		{"/query?api_key=k2", nil, http.StatusUnauthorized},
+		{"/query", http.Header{"X-Api-Key": {"k1"}}, http.StatusOK},
+	}
+	for _, test := range tests {
+		resp, body := do(t, "GET", app+test.path, test.header, "")
+		if resp.StatusCode != test.code {
+			t.Errorf("%s %v: expected %d, got %d", test.path, test.header, test.code, resp.StatusCode)
+		}
+		if test.code == http.StatusOK && body != "alice" {
+			t.Errorf("%s: expected the owner of the key, got %q", test.path, body)
+		}
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	secret := []byte("secret")
+	app := startFrame(t, "hmac-test", func(frame *faygo.Framework) {
+		frame.POST("/signed", faygo.HandlerFunc(func(ctx *faygo.Context) error {
+			body, err := ioutil.ReadAll(ctx.R.Body)
+			if err != nil {
+				return err
+			}
+			return ctx.String(http.StatusOK, ctx.Data(AuthUserKey).(string)+":"+string(body))
+		})).Use(NewHMAC(HMACConfig{
+			Store:       HMACKeys{"k1": string(secret)},
+			MaxSkew:     time.Minute,
+			MaxBodySize: 16,
+		}))
+	})
+
+	signed := func(body string) *http.Request {
+		req, _ := http.NewRequest("POST", app+"/signed?a=1", strings.NewReader(body))
+		if err := SignRequest(req, "k1", secret); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	send := func(req *http.Request, body string) (int, string) {
+		resp, respBody := do(t, req.Method, req.URL.String(), req.Header, body)
+		return resp.StatusCode, respBody
+	}
+
+	req := signed("hello")
+	if code, body := send(req, "hello"); code != http.StatusOK || body != "k1:hello" {
+		t.Fatalf("expected the signed request accepted, got %d %q", code, body)
+	}
+	// the nonce is used
+	if code, body := send(req, "hello"); code != http.StatusUnauthorized || !strings.Contains(body, "replayed") {
+		t.Fatalf("expected the replay rejected, got %d %q", code, body)
+	}
+	// the body is signed
+	if code, _ := send(signed("hello"), "hellO"); code != http.StatusUnauthorized {
+		t.Fatalf("expected the changed body rejected, got %d", code)
+	}
+	// so is the request URI
+	req = signed("hello")
+	req.URL.RawQuery = "a=2"
+	if code, _ := send(req, "hello"); code != http.StatusUnauthorized {
+		t.Fatalf("expected the changed query rejected, got %d", code)
+	}
+	// a bad secret or key
+	req, _ = http.NewRequest("POST", app+"/signed?a=1", strings.NewReader("hello"))
+	SignRequest(req, "k1", []byte("wrong"))
+	if code, _ := send(req, "hello"); code != http.StatusUnauthorized {
+		t.Fatalf("expected the bad secret rejected, got %d", code)
+	}
+	req, _ = http.NewRequest("POST", app+"/signed?a=1", strings.NewReader("hello"))
+	SignRequest(req, "k2", secret)
+	if code, _ := send(req, "hello"); code != http.StatusUnauthorized {
+		t.Fatalf("expected the unknown key rejected, got %d", code)
+	}
+	if resp, _ := do(t, "POST", app+"/signed?a=1", nil, "hello"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the unsigned request rejected, got %d", resp.StatusCode)
+	}
+
+	// the clock skew
+	for _, skew := range []time.Duration{-2 * time.Minute, 2 * time.Minute, 30 * time.Second} {
+		timestamp := strconv.FormatInt(time.Now().Add(skew).Unix(), 10)
+		nonce := "skew" + strconv.Itoa(int(skew/time.Second))
+		header := http.Header{
+			HMACKeyHeader:       {"k1"},
+			HMACTimestampHeader: {timestamp},
+			HMACNonceHeader:     {nonce},
+			HMACSignatureHeader: {hex.EncodeToString(hmacSum(secret, HMACStringToSign("POST", "/signed", timestamp, nonce, []byte("hello"))))},
+		}
+		resp, body := do(t, "POST", app+"/signed", header, "hello")
+		code := resp.StatusCode
+		if skew > time.Minute || skew < -time.Minute {
+			if code != http.StatusUnauthorized || !strings.Contains(body, "expired") {
+				t.Errorf("skew %s: expected the signature expired, got %d %q", skew, code, body)
+			}
+		} else if code != http.StatusOK {
+			t.Errorf("skew %s: expected the signature accepted, got %d %q", skew, code, body)
+		}
+	}
+
+	// the body size
+	if code, _ := send(signed(strings.Repeat("x", 17)), strings.Repeat("x", 17)); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected the large body rejected with 413, got %d", code)
+	}
+	// a body that can't be read
+	req = signed("")
+	conn, err := net.Dial("tcp", req.URL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	raw := "POST /signed?a=1 HTTP/1.1\r\nHost: " + req.URL.Host + "\r\nTransfer-Encoding: chunked\r\n"
+	for k := range req.Header {
+		raw += k + ": " + req.Header.Get(k) + "\r\n"
+	}
+	raw += "\r\nzz\r\nhello\r\n0\r\n\r\n"
+	if _, err = conn.Write([]byte(raw)); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected the unreadable body rejected with 400, got %d", resp.StatusCode)
+	}
+}
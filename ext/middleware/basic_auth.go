@@ -0,0 +1,102 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+
+	"github.com/andeya/faygo"
+)
+
+// AuthUserKey is the data key of the user authenticated by the BasicAuth,
+// APIKey or HMAC middlewares, see ctx.Data.
+const AuthUserKey = "AUTH_USER"
+
+type (
+	// BasicAuthStore verifies the credentials of the Basic auth.
+	BasicAuthStore interface {
+		Verify(username, password string) bool
+	}
+	// BasicAuthFunc is an adapter to allow the use of ordinary functions as BasicAuthStore,
+	// such as a lookup of the password hashes in a database.
+	BasicAuthFunc func(username, password string) bool
+	// BasicAuthUsers is a BasicAuthStore of the passwords by user name,
+	// compared in constant time.
+	BasicAuthUsers map[string]string
+)
+
+// Verify implements the BasicAuthStore.
+func (fn BasicAuthFunc) Verify(username, password string) bool {
+	return fn(username, password)
+}
+
+// Verify implements the BasicAuthStore.
+func (users BasicAuthUsers) Verify(username, password string) bool {
+	want, ok := users[username]
+	// compare even if the user is unknown, so that the time does not tell it
+	return secureCompare(want, password) == 1 && ok
+}
+
+// secureCompare compares the strings in constant time, whatever their lengths.
+func secureCompare(a, b string) int {
+	x, y := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(x[:], y[:])
+}
+
+// BasicAuth is the HTTP Basic authentication middleware.
+// The authenticated user name is set to the data key AuthUserKey.
+type BasicAuth struct {
+	realm string
+	store BasicAuthStore
+}
+
+var _ faygo.SecurityDefiner = new(BasicAuth)
+
+// NewBasicAuth creates the HTTP Basic authentication middleware.
+// The realm defaults to "Authorization Required".
+func NewBasicAuth(realm string, store BasicAuthStore) *BasicAuth {
+	if store == nil {
+		faygo.Panic("middleware: the BasicAuthStore is nil")
+	}
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	return &BasicAuth{realm: realm, store: store}
+}
+
+// Serve implements the faygo.Handler.
+func (b *BasicAuth) Serve(ctx *faygo.Context) error {
+	username, password, ok := ctx.R.BasicAuth()
+	if !ok || !b.store.Verify(username, password) {
+		ctx.SetHeader("WWW-Authenticate", "Basic realm="+strconv.Quote(b.realm)+", charset=\"UTF-8\"")
+		ctx.Error(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+		return nil
+	}
+	ctx.SetData(AuthUserKey, username)
+	return nil
+}
+
+// SecurityDefinitions implements the faygo.SecurityDefiner.
+func (b *BasicAuth) SecurityDefinitions() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"basicAuth": {
+			"type":        "basic",
+			"description": b.realm,
+		},
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// define common middlewares.
+
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andeya/faygo"
+)
+
+// CORSConfig is the configuration of the CORS middleware.
+type CORSConfig struct {
+	// AllowOrigins are the allowed origins, such as "https://example.com".
+	// A "*" in the host matches the subdomains, e.g. "https://*.example.com",
+	// and a single "*" matches any origin, without credentials.
+	AllowOrigins []string
+	// AllowOriginPatterns are the regular expressions of the allowed origins,
+	// which must match the whole origin.
+	AllowOriginPatterns []*regexp.Regexp
+	// AllowOriginFunc reports whether the origin is allowed.
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods are the methods allowed by the preflight requests,
+	// DefaultCORSMethods is used when it is empty.
+	AllowMethods []string
+	// AllowHeaders are the request headers allowed by the preflight requests,
+	// "*" allows any header. Only the CORS-safelisted headers are allowed when it is empty.
+	AllowHeaders []string
+	// ExposeHeaders are the response headers readable by the scripts.
+	ExposeHeaders []string
+	// AllowCredentials allows the cookies and the HTTP authentication of the
+	// origins matched by the lists, the patterns or the func, never of "*".
+	AllowCredentials bool
+	// MaxAge is how long the result of a preflight request can be cached.
+	MaxAge time.Duration
+}
+
+// DefaultCORSMethods are the methods allowed by default.
+var DefaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// CORS is the Cross-Origin Resource Sharing middleware.
+// It answers the preflight requests of the routes without the OPTIONS method
+// when RouterConfig.HandleOPTIONS is enabled, otherwise add the OPTIONS method.
+type CORS struct {
+	config     CORSConfig
+	anyOrigin  bool
+	origins    map[string]bool
+	subdomains []corsSubdomain
+	patterns   []*regexp.Regexp
+	methods    map[string]bool
+	allMethods string
+	anyHeader  bool
+	headers    map[string]bool
+	allHeaders string
+	expose     string
+	maxAge     string
+}
+
+type corsSubdomain struct {
+	prefix, suffix string
+}
+
+var _ faygo.Preflighter = new(CORS)
+
+// NewCORS creates the CORS middleware.
+func NewCORS(config CORSConfig) *CORS {
+	c := &CORS{
+		config:  config,
+		origins: make(map[string]bool),
+		methods: make(map[string]bool),
+		headers: make(map[string]bool),
+	}
+	for _, o := range config.AllowOrigins {
+		o = strings.ToLower(strings.TrimSuffix(o, "/"))
+		if o == "*" {
+			c.anyOrigin = true
+		} else if i := strings.Index(o, "://*."); i >= 0 {
+			c.subdomains = append(c.subdomains, corsSubdomain{prefix: o[:i+3], suffix: o[i+4:]})
+		} else {
+			c.origins[o] = true
+		}
+	}
+	for _, re := range config.AllowOriginPatterns {
+		c.patterns = append(c.patterns, regexp.MustCompile(`^(?:`+re.String()+`)$`))
+	}
+	methods := config.AllowMethods
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods
+	}
+	var names []string
+	for _, m := range methods {
+		m = strings.ToUpper(m)
+		c.methods[m] = true
+		names = append(names, m)
+	}
+	c.allMethods = strings.Join(names, ", ")
+	names = names[:0]
+	for _, h := range config.AllowHeaders {
+		if h == "*" {
+			c.anyHeader = true
+			continue
+		}
+		c.headers[strings.ToLower(h)] = true
+		names = append(names, http.CanonicalHeaderKey(h))
+	}
+	c.allHeaders = strings.Join(names, ", ")
+	c.expose = strings.Join(config.ExposeHeaders, ", ")
+	if config.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(config.MaxAge / time.Second))
+	}
+	return c
+}
+
+// Serve implements the faygo.Handler.
+func (c *CORS) Serve(ctx *faygo.Context) error {
+	if ctx.IsOptions() && ctx.HeaderParam(faygo.HeaderAccessControlRequestMethod) != "" {
+		c.Preflight(ctx)
+		if !ctx.Committed() {
+			ctx.W.WriteHeader(http.StatusNoContent)
+		}
+		ctx.Stop()
+		return nil
+	}
+	origin := ctx.HeaderParam(faygo.HeaderOrigin)
+	c.vary(ctx)
+	if origin == "" {
+		return nil
+	}
+	ok, credentials := c.matchOrigin(origin)
+	if !ok {
+		return nil
+	}
+	c.allowOrigin(ctx, origin, credentials)
+	if c.expose != "" {
+		ctx.SetHeader(faygo.HeaderAccessControlExposeHeaders, c.expose)
+	}
+	return nil
+}
+
+// Preflight implements the faygo.Preflighter.
+// It sets the headers of the preflight response if the origin, the method and
+// the headers are allowed, and sets none of them otherwise.
+func (c *CORS) Preflight(ctx *faygo.Context) {
+	h := ctx.W.Header()
+	h.Add(faygo.HeaderVary, faygo.HeaderOrigin)
+	h.Add(faygo.HeaderVary, faygo.HeaderAccessControlRequestMethod)
+	h.Add(faygo.HeaderVary, faygo.HeaderAccessControlRequestHeaders)
+	origin := ctx.HeaderParam(faygo.HeaderOrigin)
+	if origin == "" {
+		return
+	}
+	ok, credentials := c.matchOrigin(origin)
+	if !ok {
+		return
+	}
+	method := strings.ToUpper(ctx.HeaderParam(faygo.HeaderAccessControlRequestMethod))
+	if !c.methods[method] {
+		return
+	}
+	reqHeaders := ctx.HeaderParam(faygo.HeaderAccessControlRequestHeaders)
+	for _, header := range strings.Split(reqHeaders, ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header != "" && !c.anyHeader && !c.headers[header] {
+			return
+		}
+	}
+	c.allowOrigin(ctx, origin, credentials)
+	ctx.SetHeader(faygo.HeaderAccessControlAllowMethods, c.allMethods)
+	if c.anyHeader {
+		// "*" is a literal header name for the requests with credentials
+		if reqHeaders != "" {
+			ctx.SetHeader(faygo.HeaderAccessControlAllowHeaders, reqHeaders)
+		}
+	} else if c.allHeaders != "" {
+		ctx.SetHeader(faygo.HeaderAccessControlAllowHeaders, c.allHeaders)
+	}
+	if c.maxAge != "" {
+		ctx.SetHeader(faygo.HeaderAccessControlMaxAge, c.maxAge)
+	}
+}
+
+func (c *CORS) vary(ctx *faygo.Context) {
+	if !c.anyOrigin || c.hasMatchers() {
+		ctx.W.Header().Add(faygo.HeaderVary, faygo.HeaderOrigin)
+	}
+}
+
+func (c *CORS) hasMatchers() bool {
+	return len(c.origins) > 0 || len(c.subdomains) > 0 ||
+		len(c.config.AllowOriginPatterns) > 0 || c.config.AllowOriginFunc != nil
+}
+
+func (c *CORS) allowOrigin(ctx *faygo.Context, origin string, credentials bool) {
+	if credentials {
+		ctx.SetHeader(faygo.HeaderAccessControlAllowOrigin, origin)
+		ctx.SetHeader(faygo.HeaderAccessControlAllowCredentials, "true")
+	} else if c.anyOrigin && !c.hasMatchers() {
+		ctx.SetHeader(faygo.HeaderAccessControlAllowOrigin, "*")
+	} else {
+		ctx.SetHeader(faygo.HeaderAccessControlAllowOrigin, origin)
+	}
+}
+
+// matchOrigin reports whether the origin is allowed, and whether the credentials
+// are allowed for it.
+func (c *CORS) matchOrigin(origin string) (ok, credentials bool) {
+	if c.matchListed(origin) {
+		return true, c.config.AllowCredentials
+	}
+	return c.anyOrigin, false
+}
+
+func (c *CORS) matchListed(origin string) bool {
+	lower := strings.ToLower(origin)
+	if c.origins[lower] {
+		return true
+	}
+	for _, s := range c.subdomains {
+		if len(lower) > len(s.prefix)+len(s.suffix) &&
+			strings.HasPrefix(lower, s.prefix) && strings.HasSuffix(lower, s.suffix) &&
+			!strings.ContainsAny(lower[len(s.prefix):], "/@") {
+			return true
+		}
+	}
+	for _, re := range c.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return c.config.AllowOriginFunc != nil && c.config.AllowOriginFunc(origin)
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/andeya/faygo"
+)
+
+func TestCORS(t *testing.T) {
+	app := startFrame(t, "cors-test", func(frame *faygo.Framework) {
+		strict := frame.Group("strict", NewCORS(CORSConfig{
+			AllowOrigins:        []string{"https://app.example.com", "https://*.example.org"},
+			AllowOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`https://[a-z]+\.example\.net`)},
+			AllowMethods:        []string{"GET", "PUT"},
+			AllowHeaders:        []string{"X-Token"},
+			ExposeHeaders:       []string{"X-Total"},
+			AllowCredentials:    true,
+			MaxAge:              time.Hour,
+		}))
+		strict.GET("data", faygo.HandlerFunc(ok))
+		strict.PUT("data", faygo.HandlerFunc(ok))
+		open := frame.Group("open", NewCORS(CORSConfig{
+			AllowOrigins:     []string{"*"},
+			AllowHeaders:     []string{"*"},
+			AllowCredentials: true,
+		}))
+		open.GET("data", faygo.HandlerFunc(ok))
+		open.OPTIONS("data", faygo.HandlerFunc(ok))
+	})
+
+	var origins = []struct {
+		path, origin string
+		allowed      string
+		credentials  bool
+	}{
+		{"/strict/data", "https://app.example.com", "https://app.example.com", true},
+		{"/strict/data", "https://api.example.org", "https://api.example.org", true},
+		{"/strict/data", "https://api.example.net", "https://api.example.net", true},
+		{"/strict/data", "https://evil.com", "", false},
+		{"/strict/data", "https://example.org", "", false},
+		{"/strict/data", "https://a.b@x.example.org", "", false},
+		// the patterns match the whole origin
+		{"/strict/data", "https://api.example.net.evil.com", "", false},
+		{"/strict/data", "https://evil.com/https://api.example.net", "", false},
+		// credentials are never allowed with the wildcard
+		{"/open/data", "https://any.com", "*", false},
+	}
+	for _, test := range origins {
+		resp, body := do(t, "GET", app+test.path, http.Header{"Origin": {test.origin}}, "")
+		if resp.StatusCode != http.StatusOK || body != "ok" {
+			t.Fatalf("GET %s from %s: got %d %q", test.path, test.origin, resp.StatusCode, body)
+		}
+		if got := resp.Header.Get(faygo.HeaderAccessControlAllowOrigin); got != test.allowed {
+			t.Errorf("GET %s from %s: expected the allowed origin %q, got %q", test.path, test.origin, test.allowed, got)
+		}
+		if got := resp.Header.Get(faygo.HeaderAccessControlAllowCredentials) == "true"; got != test.credentials {
+			t.Errorf("GET %s from %s: expected the credentials %v, got %v", test.path, test.origin, test.credentials, got)
+		}
+		if test.allowed != "" && test.path == "/strict/data" && resp.Header.Get(faygo.HeaderAccessControlExposeHeaders) != "X-Total" {
+			t.Errorf("GET %s from %s: expected the exposed headers", test.path, test.origin)
+		}
+	}
+
+	var preflights = []struct {
+		path, origin, method, headers string
+		allowed                       bool
+	}{
+		// the automatic OPTIONS responses of the routes without the OPTIONS method
+		{"/strict/data", "https://app.example.com", "PUT", "X-Token", true},
+		{"/strict/data", "https://app.example.com", "GET", "", true},
+		{"/strict/data", "https://app.example.com", "PUT", "x-token, X-Other", false},
+		{"/strict/data", "https://app.example.com", "DELETE", "", false},
+		{"/strict/data", "https://evil.com", "PUT", "X-Token", false},
+		// the OPTIONS route
+		{"/open/data", "https://any.com", "GET", "X-Anything", true},
+	}
+	for _, test := range preflights {
+		header := http.Header{"Origin": {test.origin}, "Access-Control-Request-Method": {test.method}}
+		if test.headers != "" {
+			header.Set("Access-Control-Request-Headers", test.headers)
+		}
+		resp, _ := do(t, "OPTIONS", app+test.path, header, "")
+		if resp.StatusCode >= 300 {
+			t.Fatalf("OPTIONS %s %s: got %d", test.path, test.method, resp.StatusCode)
+		}
+		allowed := resp.Header.Get(faygo.HeaderAccessControlAllowOrigin)
+		if (allowed != "") != test.allowed {
+			t.Errorf("OPTIONS %s %s %q from %s: expected allowed %v, got %q", test.path, test.method, test.headers, test.origin, test.allowed, allowed)
+			continue
+		}
+		if !test.allowed {
+			if resp.Header.Get(faygo.HeaderAccessControlAllowMethods) != "" {
+				t.Errorf("OPTIONS %s %s from %s: expected no allowed methods", test.path, test.method, test.origin)
+			}
+			continue
+		}
+		if test.path == "/open/data" {
+			if allowed != "*" || resp.Header.Get(faygo.HeaderAccessControlAllowHeaders) != test.headers {
+				t.Errorf("OPTIONS %s: expected any origin and the requested headers, got %q %q", test.path, allowed, resp.Header.Get(faygo.HeaderAccessControlAllowHeaders))
+			}
+			continue
+		}
+		if got := resp.Header.Get(faygo.HeaderAccessControlAllowMethods); got != "GET, PUT" {
+			t.Errorf("OPTIONS %s %s: expected the allowed methods, got %q", test.path, test.method, got)
+		}
+		if got := resp.Header.Get(faygo.HeaderAccessControlAllowHeaders); got != "X-Token" {
+			t.Errorf("OPTIONS %s %s: expected the allowed headers, got %q", test.path, test.method, got)
+		}
+		if resp.Header.Get(faygo.HeaderAccessControlAllowCredentials) != "true" || resp.Header.Get(faygo.HeaderAccessControlMaxAge) != "3600" {
+			t.Errorf("OPTIONS %s %s: expected the credentials and the max age", test.path, test.method)
+		}
+	}
+}
@@ -22,6 +22,8 @@ import (
 
 // CrossOrigin creates Cross-Domain middleware.
 // Note: The router node should add the OPTIONS method.
+//
+// Deprecated: CrossOrigin allows any origin with credentials, use NewCORS instead.
 var CrossOrigin faygo.HandlerFunc = func(ctx *faygo.Context) error {
 	ctx.SetHeader(faygo.HeaderAccessControlAllowOrigin, ctx.HeaderParam(faygo.HeaderOrigin))
 	ctx.SetHeader(faygo.HeaderAccessControlAllowCredentials, "true")
@@ -0,0 +1,265 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andeya/faygo"
+)
+
+// The request headers of the HMAC signature.
+const (
+	HMACKeyHeader       = "X-Signature-Key"
+	HMACTimestampHeader = "X-Signature-Timestamp"
+	HMACNonceHeader     = "X-Signature-Nonce"
+	HMACSignatureHeader = "X-Signature"
+)
+
+type (
+	// HMACConfig is the configuration of the HMAC middleware.
+	HMACConfig struct {
+		// Store looks up the secrets of the keys.
+		Store HMACKeyStore
+		// MaxSkew is the maximum difference between the timestamp of a request
+		// and the server time, defaults to 5 minutes.
+		MaxSkew time.Duration
+		// Nonces remembers the nonces of the requests for MaxSkew*2 to reject
+		// the replays, defaults to a memory cache. A shared cache is required by
+		// several instances behind a load balancer.
+		Nonces NonceCache
+		// MaxBodySize is the maximum size of the signed body, defaults to 32MB.
+		MaxBodySize int64
+	}
+	// HMACKeyStore looks up the secret of a key.
+	HMACKeyStore interface {
+		Secret(keyID string) (secret []byte, ok bool)
+	}
+	// HMACKeyFunc is an adapter to allow the use of ordinary functions as HMACKeyStore.
+	HMACKeyFunc func(keyID string) (secret []byte, ok bool)
+	// HMACKeys is an HMACKeyStore of the secrets by key ID.
+	HMACKeys map[string]string
+	// NonceCache remembers the nonces of the signed requests.
+	NonceCache interface {
+		// Add remembers the nonce for the ttl, and returns false if it is already remembered.
+		Add(nonce string, ttl time.Duration) bool
+	}
+)
+
+// Secret implements the HMACKeyStore.
+func (fn HMACKeyFunc) Secret(keyID string) ([]byte, bool) {
+	return fn(keyID)
+}
+
+// Secret implements the HMACKeyStore.
+func (keys HMACKeys) Secret(keyID string) ([]byte, bool) {
+	secret, ok := keys[keyID]
+	return []byte(secret), ok
+}
+
+// MemoryNonceCache is a NonceCache in memory.
+type MemoryNonceCache struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	purged time.Time
+}
+
+var _ NonceCache = new(MemoryNonceCache)
+
+// NewMemoryNonceCache creates a NonceCache in memory.
+func NewMemoryNonceCache() *MemoryNonceCache {
+	return &MemoryNonceCache{nonces: make(map[string]time.Time)}
+}
+
+// Add implements the NonceCache.
+func (m *MemoryNonceCache) Add(nonce string, ttl time.Duration) bool {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.purged) > time.Minute {
+		for k, expire := range m.nonces {
+			if now.After(expire) {
+				delete(m.nonces, k)
+			}
+		}
+		m.purged = now
+	}
+	if expire, ok := m.nonces[nonce]; ok && now.Before(expire) {
+		return false
+	}
+	m.nonces[nonce] = now.Add(ttl)
+	return true
+}
+
+// HMAC is the HMAC-SHA256 request signing middleware.
+// The signature is the hex HMAC of HMACStringToSign, sent with the key ID,
+// the unix timestamp and a unique nonce in the headers, see SignRequest.
+// The key ID is set to the data key AuthUserKey.
+type HMAC struct {
+	config HMACConfig
+}
+
+var _ faygo.SecurityDefiner = new(HMAC)
+
+// NewHMAC creates the HMAC request signing middleware.
+func NewHMAC(config HMACConfig) *HMAC {
+	if config.Store == nil {
+		faygo.Panic("middleware: the HMACKeyStore is nil")
+	}
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = 5 * time.Minute
+	}
+	if config.Nonces == nil {
+		config.Nonces = NewMemoryNonceCache()
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 32 << 20
+	}
+	return &HMAC{config: config}
+}
+
+// Serve implements the faygo.Handler.
+func (h *HMAC) Serve(ctx *faygo.Context) error {
+	keyID := ctx.HeaderParam(HMACKeyHeader)
+	timestamp := ctx.HeaderParam(HMACTimestampHeader)
+	nonce := ctx.HeaderParam(HMACNonceHeader)
+	signature, err := hex.DecodeString(ctx.HeaderParam(HMACSignatureHeader))
+	if keyID == "" || timestamp == "" || nonce == "" || err != nil || len(signature) == 0 {
+		ctx.Error(http.StatusUnauthorized, "missing or malformed signature")
+		return nil
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		ctx.Error(http.StatusUnauthorized, "malformed signature timestamp")
+		return nil
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > h.config.MaxSkew || skew < -h.config.MaxSkew {
+		ctx.Error(http.StatusUnauthorized, "signature expired")
+		return nil
+	}
+	secret, ok := h.config.Store.Secret(keyID)
+	if !ok {
+		ctx.Error(http.StatusUnauthorized, "invalid signature")
+		return nil
+	}
+	body, err := h.readBody(ctx)
+	switch {
+	case err == errBodyTooLarge:
+		ctx.Error(http.StatusRequestEntityTooLarge, err.Error())
+		return nil
+	case err != nil:
+		if ctx.Committed() {
+			// the body rejected by the request limits is answered already
+			ctx.Stop()
+		} else {
+			ctx.Error(http.StatusBadRequest, "unable to read the request body")
+		}
+		return nil
+	}
+	want := hmacSum(secret, HMACStringToSign(ctx.Method(), ctx.R.URL.RequestURI(), timestamp, nonce, body))
+	if !hmac.Equal(signature, want) {
+		ctx.Error(http.StatusUnauthorized, "invalid signature")
+		return nil
+	}
+	// the nonce is remembered after the verification, so that the forged
+	// requests cannot fill the cache
+	if !h.config.Nonces.Add(keyID+":"+nonce, h.config.MaxSkew*2) {
+		ctx.Error(http.StatusUnauthorized, "replayed signature")
+		return nil
+	}
+	ctx.SetData(AuthUserKey, keyID)
+	return nil
+}
+
+// readBody reads the body and puts it back for the handlers.
+func (h *HMAC) readBody(ctx *faygo.Context) ([]byte, error) {
+	if ctx.R.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(ctx.R.Body, h.config.MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > h.config.MaxBodySize {
+		return nil, errBodyTooLarge
+	}
+	ctx.R.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// SecurityDefinitions implements the faygo.SecurityDefiner.
+func (h *HMAC) SecurityDefinitions() map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"hmacSignature": {
+			"type": "apiKey",
+			"in":   "header",
+			"name": HMACSignatureHeader,
+			"description": "Hex HMAC-SHA256 of \"METHOD\\nREQUEST_URI\\nTIMESTAMP\\nNONCE\\nhex(SHA256(BODY))\", " +
+				"with the " + HMACKeyHeader + ", " + HMACTimestampHeader + " (unix seconds) and " + HMACNonceHeader + " headers.",
+		},
+	}
+}
+
+var errBodyTooLarge = errors.New("the request body is too large")
+
+// HMACStringToSign returns the string signed by the HMAC middleware:
+// the method, the request URI, the timestamp, the nonce and the hex SHA-256
+// of the body, separated by newlines.
+func HMACStringToSign(method, requestURI, timestamp, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return method + "\n" + requestURI + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(sum[:])
+}
+
+func hmacSum(secret []byte, s string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+// SignRequest signs the request for the HMAC middleware, the body is read and put back.
+func SignRequest(req *http.Request, keyID string, secret []byte) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(b[:])
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(HMACKeyHeader, keyID)
+	req.Header.Set(HMACTimestampHeader, timestamp)
+	req.Header.Set(HMACNonceHeader, nonce)
+	req.Header.Set(HMACSignatureHeader, hex.EncodeToString(hmacSum(secret, HMACStringToSign(req.Method, req.URL.RequestURI(), timestamp, nonce, body))))
+	return nil
+}
@@ -17,55 +17,40 @@
 package middleware
 
 import (
-	"net/http"
 	"strings"
 
 	"github.com/andeya/faygo"
 )
 
-// NewIPFilter creates middleware that intercepts the specified IP prefix.
+// NewIPFilter creates middleware that only allows the IPs in the whitelist,
+// such as '192.168.0.0/16', '2001:db8::/32', '202.122.246.170' or the prefix '10.*'.
+// The other entries, such as '192.168.1' or '192.16*', are matched as the string
+// prefixes of the IPs, as the old versions did.
+// Use faygo.NewIPFilter for a blacklist and the hot reload.
 func NewIPFilter(whitelist []string, realIP bool) faygo.HandlerFunc {
-	var noAccess bool
-	var match []string
-	var prefix []string
-
-	if len(whitelist) == 0 {
-		noAccess = true
-	} else {
-		for _, s := range whitelist {
-			if strings.HasSuffix(s, "*") {
-				prefix = append(prefix, s[:len(s)-1])
-			} else {
-				match = append(match, s)
-			}
+	var list, prefixes []string
+	for _, s := range whitelist {
+		if _, err := faygo.ParseIPSet([]string{s}); err != nil {
+			faygo.Warningf("middleware.NewIPFilter: %s, matched as a prefix of the IPs", err.Error())
+			prefixes = append(prefixes, strings.TrimSuffix(strings.TrimSpace(s), "*"))
+			continue
 		}
+		list = append(list, s)
+	}
+	f, _ := faygo.NewIPFilter(list, nil, realIP)
+	if len(prefixes) == 0 {
+		return f.Serve
 	}
-
 	return func(ctx *faygo.Context) error {
-		if noAccess {
-			ctx.Error(http.StatusForbidden, "no access")
-			return nil
-		}
-
-		var ip string
+		ip := ctx.IP()
 		if realIP {
 			ip = ctx.RealIP()
-		} else {
-			ip = ctx.IP()
-		}
-		for _, ipMatch := range match {
-			if ipMatch == ip {
-				ctx.Next()
-				return nil
-			}
 		}
-		for _, ipPrefix := range prefix {
-			if strings.HasPrefix(ip, ipPrefix) {
-				ctx.Next()
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(ip, prefix) {
 				return nil
 			}
 		}
-		ctx.Error(http.StatusForbidden, "not allow to access: "+ip)
-		return nil
+		return f.Serve(ctx)
 	}
 }
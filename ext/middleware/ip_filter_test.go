@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/andeya/faygo"
+)
+
+func TestIPFilter(t *testing.T) {
+	app := startFrame(t, "ip-filter-test", func(frame *faygo.Framework) {
+		frame.GET("/cidr", faygo.HandlerFunc(ok)).Use(NewIPFilter([]string{"127.0.0.0/8"}, false))
+		frame.GET("/prefix", faygo.HandlerFunc(ok)).Use(NewIPFilter([]string{"127.*"}, false))
+		// the string prefixes of the old versions
+		frame.GET("/legacy", faygo.HandlerFunc(ok)).Use(NewIPFilter([]string{"12*", "10.0.0"}, false))
+		frame.GET("/legacy-denied", faygo.HandlerFunc(ok)).Use(NewIPFilter([]string{"10.0.0", "192.16*"}, false))
+		frame.GET("/none", faygo.HandlerFunc(ok)).Use(NewIPFilter(nil, false))
+	})
+	for path, code := range map[string]int{
+		"/cidr":          http.StatusOK,
+		"/prefix":        http.StatusOK,
+		"/legacy":        http.StatusOK,
+		"/legacy-denied": http.StatusForbidden,
+		"/none":          http.StatusForbidden,
+	} {
+		if resp, _ := do(t, "GET", app+path, nil, ""); resp.StatusCode != code {
+			t.Errorf("%s: expected %d, got %d", path, code, resp.StatusCode)
+		}
+	}
+}
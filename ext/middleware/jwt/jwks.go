@@ -0,0 +1,276 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultJWKSCacheTTL is how long a KeySet caches the JWKS document by default.
+const DefaultJWKSCacheTTL = 10 * time.Minute
+
+// a KeySet refetches the document for an unknown kid, or after a failed fetch,
+// at most once in this interval.
+const jwksMinRefresh = 30 * time.Second
+
+var (
+	// ErrUnknownKeyID indicates no verification key has the kid of the token
+	ErrUnknownKeyID = errors.New("unknown key id")
+
+	// ErrInvalidJWK indicates a JSON Web Key can't be used
+	ErrInvalidJWK = errors.New("invalid JSON Web Key")
+)
+
+// JWK is a JSON Web Key (RFC 7517) of a public key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set document.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK creates the JSON Web Key of a RSA, ECDSA or Ed25519 public key,
+// the private keys are converted to their public keys.
+func NewJWK(kid, alg string, key crypto.PublicKey) (JWK, error) {
+	jwk := JWK{Kid: kid, Alg: alg, Use: "sig"}
+	switch k := publicKeyOf(key).(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBigInt(k.N)
+		jwk.E = encodeBigInt(big.NewInt(int64(k.E)))
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = k.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(k)
+	default:
+		return jwk, ErrInvalidJWK
+	}
+	return jwk, nil
+}
+
+// PublicKey returns the public key of the JSON Web Key.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err1 := decodeBigInt(k.N)
+		e, err2 := decodeBigInt(k.E)
+		if err1 != nil || err2 != nil || !e.IsInt64() {
+			return nil, ErrInvalidJWK
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrInvalidJWK
+		}
+		x, err1 := decodeBigInt(k.X)
+		y, err2 := decodeBigInt(k.Y)
+		if err1 != nil || err2 != nil || !curve.IsOnCurve(x, y) {
+			return nil, ErrInvalidJWK
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrInvalidJWK
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, ErrInvalidJWK
+}
+
+func encodeBigInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, ErrInvalidJWK
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// KeySet is a JWKS document read from a file or an URL, whose keys verify the
+// tokens by their kid. The document is cached for TTL, and refetched early when
+// a token has an unknown kid, so that the rotated keys are picked up.
+// A failed fetch is not retried for 30s, the cached keys are used meanwhile.
+type KeySet struct {
+	// URL of the JWKS document, such as "https://example.com/.well-known/jwks.json".
+	URL string
+	// File of the JWKS document, used when URL is empty.
+	File string
+	// TTL is how long the document is cached. Optional, defaults to DefaultJWKSCacheTTL.
+	TTL time.Duration
+	// Client fetches the URL. Optional, defaults to a client with a 10s timeout.
+	Client *http.Client
+
+	mu         sync.Mutex
+	keys       map[string]jwkKey
+	fetched    time.Time
+	failed     time.Time     // the time of the last failed fetch
+	err        error         // the error of the last fetch
+	refreshing chan struct{} // closed when the fetch in progress is done
+}
+
+type jwkKey struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// NewKeySet creates a KeySet of a JWKS document, source is an URL or a file path.
+func NewKeySet(source string, ttl time.Duration) *KeySet {
+	s := &KeySet{TTL: ttl}
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		s.URL = source
+	} else {
+		s.File = source
+	}
+	return s
+}
+
+// Key returns the public key with the kid, and the algorithm of the key if the
+// document specifies it.
+func (s *KeySet) Key(kid string) (crypto.PublicKey, string, error) {
+	s.mu.Lock()
+	k, ok := s.keys[kid]
+	if s.expired(ok) {
+		if s.refreshing == nil {
+			s.refresh()
+			k, ok = s.keys[kid]
+		} else if !ok {
+			// wait for the fetch of another request
+			done := s.refreshing
+			s.mu.Unlock()
+			<-done
+			s.mu.Lock()
+			k, ok = s.keys[kid]
+		}
+		// otherwise serve the cached key while the document is refetched
+	}
+	err := s.err
+	s.mu.Unlock()
+	if ok {
+		return k.key, k.alg, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return nil, "", ErrUnknownKeyID
+}
+
+// expired reports whether the document should be refetched, found reports
+// whether the requested kid is cached.
+// note: the caller must hold s.mu
+func (s *KeySet) expired(found bool) bool {
+	if time.Since(s.failed) < jwksMinRefresh {
+		return false
+	}
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = DefaultJWKSCacheTTL
+	}
+	age := time.Since(s.fetched)
+	return s.keys == nil || age > ttl || (!found && age > jwksMinRefresh)
+}
+
+// refresh fetches the document without holding s.mu, the other requests of the
+// unknown kids wait for it.
+// note: the caller must hold s.mu
+func (s *KeySet) refresh() {
+	done := make(chan struct{})
+	s.refreshing = done
+	s.mu.Unlock()
+	keys, err := s.fetch()
+	s.mu.Lock()
+	if err != nil {
+		s.failed, s.err = time.Now(), err
+	} else {
+		s.keys, s.fetched, s.err = keys, time.Now(), nil
+	}
+	s.refreshing = nil
+	close(done)
+}
+
+func (s *KeySet) fetch() (map[string]jwkKey, error) {
+	var data []byte
+	var err error
+	if s.URL != "" {
+		data, err = s.get()
+	} else {
+		data, err = ioutil.ReadFile(s.File)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var doc JWKS
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]jwkKey, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// skip the keys of the unsupported types
+			continue
+		}
+		keys[jwk.Kid] = jwkKey{alg: jwk.Alg, key: key}
+	}
+	return keys, nil
+}
+
+func (s *KeySet) get() ([]byte, error) {
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: GET %s: %s", s.URL, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
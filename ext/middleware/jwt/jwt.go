@@ -1,10 +1,12 @@
 package jwt
 
 import (
-	"crypto/rsa"
+	"crypto"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -21,10 +23,23 @@ type FaygoJWTMiddleware struct {
 	// Realm name to display to the user. Required.
 	Realm string
 
-	// signing algorithm - possible values are HS256, HS384, HS512
+	// signing algorithm - possible values are HS256, HS384, HS512, RS256, RS384, RS512,
+	// PS256, PS384, PS512, ES256, ES384, ES512 and EdDSA.
 	// Optional, default is HS256.
 	SigningAlgorithm string
 
+	// Key ID set in the "kid" header of the signed tokens. Optional.
+	// The tokens with another kid are verified by VerifyKeys or KeySet.
+	KeyID string
+
+	// Verification keys selected by the "kid" header of the tokens, such as the keys
+	// retired by a rotation. The values are []byte for HMAC, or the *rsa.PublicKey,
+	// *ecdsa.PublicKey and ed25519.PublicKey. Optional.
+	VerifyKeys map[string]interface{}
+
+	// JWKS document that verifies the tokens with a kid not in VerifyKeys. Optional.
+	KeySet *KeySet
+
 	// Secret key used for signing. Required.
 	Key []byte
 
@@ -86,17 +101,25 @@ type FaygoJWTMiddleware struct {
 	// Check error (e) to determine the appropriate error message.
 	HTTPStatusMessageFunc func(e error, c *faygo.Context) string
 
-	// Private key file for asymmetric algorithms
+	// Private key file for asymmetric algorithms, PEM encoded.
 	PrivKeyFile string
 
-	// Public key file for asymmetric algorithms
+	// Public key file for asymmetric algorithms, PEM encoded.
+	// Optional when PrivKeyFile is set.
 	PubKeyFile string
 
+	// RefreshStore enables the opaque rotating refresh tokens, which are issued by
+	// LoginHandler and exchanged by RefreshTokenHandler. Optional.
+	RefreshStore RefreshStore
+
+	// Duration that a refresh token is valid. Optional, defaults to DefaultRefreshTimeout.
+	RefreshTimeout time.Duration
+
 	// Private key
-	privKey *rsa.PrivateKey
+	privKey crypto.Signer
 
 	// Public key
-	pubKey *rsa.PublicKey
+	pubKey crypto.PublicKey
 }
 
 var (
@@ -152,6 +175,10 @@ var (
 	ErrInvalidPubKey = errors.New("public key invalid")
 )
 
+// RefreshTokenKey is the key of the issued refresh token in the context data,
+// which may be used by LoginResponse and RefreshResponse.
+const RefreshTokenKey = "JWT_REFRESH_TOKEN"
+
 // Login form structure.
 type Login struct {
 	Usercode string `form:"usercode" json:"usercode" binding:"required"`
@@ -159,15 +186,21 @@ type Login struct {
 }
 
 func (mw *FaygoJWTMiddleware) readKeys() error {
-	err := mw.privateKey()
-	if err != nil {
-		return err
+	if mw.PrivKeyFile == "" && mw.PubKeyFile == "" && mw.hasVerifyKeys() {
+		// verifies the tokens of others only
+		return nil
 	}
-	err = mw.publicKey()
-	if err != nil {
-		return err
+	if mw.PrivKeyFile != "" || mw.PubKeyFile == "" {
+		err := mw.privateKey()
+		if err != nil {
+			return err
+		}
 	}
-	return nil
+	if mw.PubKeyFile == "" {
+		mw.pubKey = mw.privKey.Public()
+		return nil
+	}
+	return mw.publicKey()
 }
 
 func (mw *FaygoJWTMiddleware) privateKey() error {
@@ -175,8 +208,8 @@ func (mw *FaygoJWTMiddleware) privateKey() error {
 	if err != nil {
 		return ErrNoPrivKeyFile
 	}
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(keyData)
-	if err != nil {
+	key, err := ParsePrivateKeyPEM(keyData)
+	if err != nil || !algMatchesKey(mw.SigningAlgorithm, key) {
 		return ErrInvalidPrivKey
 	}
 	mw.privKey = key
@@ -188,8 +221,8 @@ func (mw *FaygoJWTMiddleware) publicKey() error {
 	if err != nil {
 		return ErrNoPubKeyFile
 	}
-	key, err := jwt.ParseRSAPublicKeyFromPEM(keyData)
-	if err != nil {
+	key, err := ParsePublicKeyPEM(keyData)
+	if err != nil || !algMatchesKey(mw.SigningAlgorithm, key) {
 		return ErrInvalidPubKey
 	}
 	mw.pubKey = key
@@ -198,12 +231,16 @@ func (mw *FaygoJWTMiddleware) publicKey() error {
 
 func (mw *FaygoJWTMiddleware) usingPublicKeyAlgo() bool {
 	switch mw.SigningAlgorithm {
-	case "RS256", "RS512", "RS384":
+	case "RS256", "RS512", "RS384", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA":
 		return true
 	}
 	return false
 }
 
+func (mw *FaygoJWTMiddleware) hasVerifyKeys() bool {
+	return len(mw.VerifyKeys) > 0 || mw.KeySet != nil
+}
+
 // MiddlewareInit initialize jwt configs.
 func (mw *FaygoJWTMiddleware) MiddlewareInit() error {
 
@@ -223,6 +260,10 @@ func (mw *FaygoJWTMiddleware) MiddlewareInit() error {
 		mw.TimeFunc = time.Now
 	}
 
+	if mw.RefreshTimeout == 0 {
+		mw.RefreshTimeout = DefaultRefreshTimeout
+	}
+
 	mw.TokenHeadName = strings.TrimSpace(mw.TokenHeadName)
 	if len(mw.TokenHeadName) == 0 {
 		mw.TokenHeadName = "Bearer"
@@ -245,21 +286,29 @@ func (mw *FaygoJWTMiddleware) MiddlewareInit() error {
 
 	if mw.LoginResponse == nil {
 		mw.LoginResponse = func(c *faygo.Context, code int, token string, expire time.Time) error {
-			return c.JSON(http.StatusOK, faygo.Map{
+			resp := faygo.Map{
 				"code":   http.StatusOK,
 				"token":  token,
 				"expire": expire.Format(time.RFC3339),
-			})
+			}
+			if c.HasData(RefreshTokenKey) {
+				resp["refresh_token"] = c.Data(RefreshTokenKey)
+			}
+			return c.JSON(http.StatusOK, resp)
 		}
 	}
 
 	if mw.RefreshResponse == nil {
 		mw.RefreshResponse = func(c *faygo.Context, code int, token string, expire time.Time) error {
-			return c.JSON(http.StatusOK, faygo.Map{
+			resp := faygo.Map{
 				"code":   http.StatusOK,
 				"token":  token,
 				"expire": expire.Format(time.RFC3339),
-			})
+			}
+			if c.HasData(RefreshTokenKey) {
+				resp["refresh_token"] = c.Data(RefreshTokenKey)
+			}
+			return c.JSON(http.StatusOK, resp)
 		}
 	}
 
@@ -283,7 +332,7 @@ func (mw *FaygoJWTMiddleware) MiddlewareInit() error {
 		return mw.readKeys()
 	}
 
-	if mw.Key == nil {
+	if mw.Key == nil && !mw.hasVerifyKeys() {
 		return ErrMissingSecretKey
 	}
 	return nil
@@ -377,13 +426,27 @@ func (mw *FaygoJWTMiddleware) LoginHandler(c *faygo.Context) error {
 
 	}
 
+	if mw.RefreshStore != nil {
+		refreshToken, err := mw.issueRefreshToken(claims, "")
+		if err != nil {
+			return mw.unauthorized(c, http.StatusInternalServerError, mw.HTTPStatusMessageFunc(ErrFailedTokenCreation, c))
+		}
+		c.SetData(RefreshTokenKey, refreshToken)
+	}
+
 	return mw.LoginResponse(c, http.StatusOK, tokenString, expire)
 }
 
 func (mw *FaygoJWTMiddleware) signedString(token *jwt.Token) (string, error) {
 	var tokenString string
 	var err error
+	if mw.KeyID != "" {
+		token.Header["kid"] = mw.KeyID
+	}
 	if mw.usingPublicKeyAlgo() {
+		if mw.privKey == nil {
+			return "", ErrNoPrivKeyFile
+		}
 		tokenString, err = token.SignedString(mw.privKey)
 	} else {
 		tokenString, err = token.SignedString(mw.Key)
@@ -525,15 +588,47 @@ func (mw *FaygoJWTMiddleware) parseToken(c *faygo.Context) (*jwt.Token, error) {
 		return nil, err
 	}
 
-	return jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		if jwt.GetSigningMethod(mw.SigningAlgorithm) != token.Method {
+	return jwt.Parse(token, mw.verificationKey)
+}
+
+// verificationKey returns the key that verifies the token: the own key for the
+// tokens without a kid or with KeyID, otherwise the key with the kid in VerifyKeys
+// or KeySet, whose type must match the algorithm of the token.
+func (mw *FaygoJWTMiddleware) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" || kid == mw.KeyID {
+		var key interface{}
+		if mw.usingPublicKeyAlgo() {
+			key = mw.pubKey
+		} else if mw.Key != nil {
+			key = mw.Key
+		}
+		if key != nil {
+			if jwt.GetSigningMethod(mw.SigningAlgorithm) != token.Method {
+				return nil, ErrInvalidSigningAlgorithm
+			}
+			return key, nil
+		}
+	}
+	alg := token.Method.Alg()
+	if key, ok := mw.VerifyKeys[kid]; ok {
+		key = publicKeyOf(key)
+		if !algMatchesKey(alg, key) {
 			return nil, ErrInvalidSigningAlgorithm
 		}
-		if mw.usingPublicKeyAlgo() {
-			return mw.pubKey, nil
+		return key, nil
+	}
+	if mw.KeySet != nil {
+		key, keyAlg, err := mw.KeySet.Key(kid)
+		if err != nil {
+			return nil, err
 		}
-		return mw.Key, nil
-	})
+		if (keyAlg != "" && keyAlg != alg) || !algMatchesKey(alg, key) {
+			return nil, ErrInvalidSigningAlgorithm
+		}
+		return key, nil
+	}
+	return nil, ErrUnknownKeyID
 }
 
 func (mw *FaygoJWTMiddleware) unauthorized(c *faygo.Context, code int, message string) error {
@@ -547,3 +642,165 @@ func (mw *FaygoJWTMiddleware) unauthorized(c *faygo.Context, code int, message s
 	mw.Unauthorized(c, code, message)
 	return nil
 }
+
+// issueRefreshToken saves a new refresh token of the claims in the RefreshStore,
+// family is the family of the token it is rotated from.
+func (mw *FaygoJWTMiddleware) issueRefreshToken(claims map[string]interface{}, family string) (string, error) {
+	token, id, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if family == "" {
+		family = id
+	}
+	payload := make(map[string]interface{}, len(claims))
+	for key, value := range claims {
+		switch key {
+		case "exp", "iat", "orig_iat":
+		default:
+			payload[key] = value
+		}
+	}
+	err = mw.RefreshStore.Save(&RefreshToken{
+		ID:     id,
+		UserID: fmt.Sprint(claims["id"]),
+		Family: family,
+		Claims: payload,
+		Expire: mw.TimeFunc().Add(mw.RefreshTimeout),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// refreshTokenForm is the body of RefreshTokenHandler and RevokeHandler.
+type refreshTokenForm struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshTokenHandler exchanges an opaque refresh token issued by LoginHandler
+// for a new access token and a new refresh token; the used refresh token can't be
+// exchanged again, and doing so revokes all the refresh tokens of the user.
+// Payload needs to be json in the form of {"refresh_token": "REFRESH_TOKEN"}.
+// Reply will be of the form {"token": "TOKEN", "refresh_token": "REFRESH_TOKEN"}.
+func (mw *FaygoJWTMiddleware) RefreshTokenHandler(c *faygo.Context) error {
+	if mw.RefreshStore == nil {
+		return mw.unauthorized(c, http.StatusInternalServerError, mw.HTTPStatusMessageFunc(ErrMissingRefreshStore, c))
+	}
+	var form refreshTokenForm
+	if c.BindJSON(&form) != nil || form.RefreshToken == "" {
+		return mw.unauthorized(c, http.StatusBadRequest, mw.HTTPStatusMessageFunc(ErrMissingRefreshToken, c))
+	}
+	old, err := mw.RefreshStore.Use(RefreshTokenID(form.RefreshToken))
+	if err != nil {
+		faygo.Error(err)
+		return mw.unauthorized(c, http.StatusInternalServerError, mw.HTTPStatusMessageFunc(ErrFailedTokenCreation, c))
+	}
+	if old == nil || mw.TimeFunc().After(old.Expire) {
+		return mw.unauthorized(c, http.StatusUnauthorized, mw.HTTPStatusMessageFunc(ErrInvalidRefreshToken, c))
+	}
+	if old.Used {
+		// the token may be stolen
+		if err = mw.RefreshStore.RevokeUser(old.UserID); err != nil {
+			faygo.Error(err)
+		}
+		return mw.unauthorized(c, http.StatusUnauthorized, mw.HTTPStatusMessageFunc(ErrRefreshTokenReused, c))
+	}
+
+	// Create the token
+	newToken := jwt.New(jwt.GetSigningMethod(mw.SigningAlgorithm))
+	newClaims := newToken.Claims.(jwt.MapClaims)
+	for key, value := range old.Claims {
+		newClaims[key] = value
+	}
+	expire := mw.TimeFunc().Add(mw.Timeout)
+	newClaims["exp"] = expire.Unix()
+	newClaims["orig_iat"] = mw.TimeFunc().Unix()
+	tokenString, err := mw.signedString(newToken)
+	if err != nil {
+		return mw.unauthorized(c, http.StatusUnauthorized, mw.HTTPStatusMessageFunc(ErrFailedTokenCreation, c))
+	}
+	refreshToken, err := mw.issueRefreshToken(newClaims, old.Family)
+	if err != nil {
+		return mw.unauthorized(c, http.StatusInternalServerError, mw.HTTPStatusMessageFunc(ErrFailedTokenCreation, c))
+	}
+	c.SetData(RefreshTokenKey, refreshToken)
+
+	return mw.RefreshResponse(c, http.StatusOK, tokenString, expire)
+}
+
+// RevokeHandler revokes a refresh token, such as on logout.
+// Payload needs to be json in the form of {"refresh_token": "REFRESH_TOKEN"}.
+func (mw *FaygoJWTMiddleware) RevokeHandler(c *faygo.Context) error {
+	var form refreshTokenForm
+	if c.BindJSON(&form) != nil || form.RefreshToken == "" {
+		return mw.unauthorized(c, http.StatusBadRequest, mw.HTTPStatusMessageFunc(ErrMissingRefreshToken, c))
+	}
+	if err := mw.RevokeRefreshToken(form.RefreshToken); err != nil {
+		return mw.unauthorized(c, http.StatusInternalServerError, mw.HTTPStatusMessageFunc(err, c))
+	}
+	return c.JSON(http.StatusOK, faygo.Map{"code": http.StatusOK})
+}
+
+// RevokeRefreshToken revokes the refresh token.
+func (mw *FaygoJWTMiddleware) RevokeRefreshToken(refreshToken string) error {
+	if mw.RefreshStore == nil {
+		return ErrMissingRefreshStore
+	}
+	return mw.RefreshStore.Revoke(RefreshTokenID(refreshToken))
+}
+
+// RevokeUser revokes all the refresh tokens of the user, such as when the
+// password is changed. The issued access tokens are valid until they expire.
+func (mw *FaygoJWTMiddleware) RevokeUser(userID string) error {
+	if mw.RefreshStore == nil {
+		return ErrMissingRefreshStore
+	}
+	return mw.RefreshStore.RevokeUser(userID)
+}
+
+// JWKS returns the JSON Web Key Set of the public keys, that is the own key and
+// the asymmetric keys of VerifyKeys.
+func (mw *FaygoJWTMiddleware) JWKS() (JWKS, error) {
+	doc := JWKS{Keys: []JWK{}}
+	if mw.usingPublicKeyAlgo() && mw.pubKey != nil {
+		jwk, err := NewJWK(mw.KeyID, mw.SigningAlgorithm, mw.pubKey)
+		if err != nil {
+			return doc, err
+		}
+		doc.Keys = append(doc.Keys, jwk)
+	}
+	kids := make([]string, 0, len(mw.VerifyKeys))
+	for kid := range mw.VerifyKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	for _, kid := range kids {
+		key := mw.VerifyKeys[kid]
+		if _, ok := key.([]byte); ok {
+			// the secrets are never published
+			continue
+		}
+		jwk, err := NewJWK(kid, "", key)
+		if err != nil {
+			return doc, err
+		}
+		doc.Keys = append(doc.Keys, jwk)
+	}
+	return doc, nil
+}
+
+// JWKSHandler publishes the JWKS of the public keys, so that others can verify
+// the tokens, usually at "/.well-known/jwks.json".
+func (mw *FaygoJWTMiddleware) JWKSHandler(c *faygo.Context) error {
+	doc, err := mw.JWKS()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, faygo.Map{
+			"code":    http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+	}
+	c.SetHeader(faygo.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(http.StatusOK, doc)
+}
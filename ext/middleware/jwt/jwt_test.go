@@ -0,0 +1,360 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andeya/faygo"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+func TestEdDSA(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(SigningMethodEdDSA, jwt.MapClaims{"id": "admin"})
+	signed, err := token.SignedString(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return pub, nil })
+	if err != nil || !parsed.Valid || parsed.Claims.(jwt.MapClaims)["id"] != "admin" {
+		t.Fatalf("expected the EdDSA token verified, got %v", err)
+	}
+	parts := strings.Split(signed, ".")
+	tampered := parts[0] + "." + jwt.EncodeSegment([]byte(`{"id":"root"}`)) + "." + parts[2]
+	if _, err = jwt.Parse(tampered, func(*jwt.Token) (interface{}, error) { return pub, nil }); err == nil {
+		t.Fatal("expected the tampered token rejected")
+	}
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err = jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return other, nil }); err == nil {
+		t.Fatal("expected the token rejected by another key")
+	}
+	if _, err = SigningMethodEdDSA.Sign("x", []byte("secret")); err != jwt.ErrInvalidKeyType {
+		t.Fatalf("expected ErrInvalidKeyType, got %v", err)
+	}
+}
+
+func TestAlgMatchesKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	var tests = []struct {
+		alg   string
+		key   interface{}
+		match bool
+	}{
+		{"HS256", []byte("secret"), true},
+		{"HS256", &rsaKey.PublicKey, false},
+		{"HS256", edPub, false},
+		{"RS256", &rsaKey.PublicKey, true},
+		{"PS384", rsaKey, true},
+		{"RS256", []byte("secret"), false},
+		{"ES256", &ecKey.PublicKey, true},
+		{"ES384", &ecKey.PublicKey, false},
+		{"RS256", &ecKey.PublicKey, false},
+		{"EdDSA", edPub, true},
+		{"EdDSA", &rsaKey.PublicKey, false},
+		{"none", &rsaKey.PublicKey, false},
+	}
+	for _, test := range tests {
+		if got := algMatchesKey(test.alg, test.key); got != test.match {
+			t.Errorf("algMatchesKey(%s, %T): got %v, want %v", test.alg, test.key, got, test.match)
+		}
+	}
+
+	// a HS256 token signed with the RSA public key is rejected
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw := &FaygoJWTMiddleware{Realm: "test", VerifyKeys: map[string]interface{}{"rsa": &rsaKey.PublicKey}}
+	if err = mw.MiddlewareInit(); err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": "admin"})
+	forged.Header["kid"] = "rsa"
+	signed, err := forged.SignedString(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = jwt.Parse(signed, mw.verificationKey); err == nil {
+		t.Fatal("expected the HS256 token of the RSA key rejected")
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"id": "admin"})
+	token.Header["kid"] = "rsa"
+	signed, _ = token.SignedString(rsaKey)
+	if _, err = jwt.Parse(signed, mw.verificationKey); err != nil {
+		t.Fatalf("expected the RS256 token verified, got %v", err)
+	}
+}
+
+// jwksServer serves a JWKS document that can be replaced, and counts the requests.
+type jwksServer struct {
+	mu      sync.Mutex
+	doc     JWKS
+	status  int
+	fetches int
+	block   chan struct{} // blocks the requests until closed, if not nil
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.fetches++
+	doc, status, block := s.doc, s.status, s.block
+	s.mu.Unlock()
+	if block != nil {
+		<-block
+	}
+	if status != 0 {
+		w.WriteHeader(status)
+		return
+	}
+	json.NewEncoder(w).Encode(doc)
+}
+
+func (s *jwksServer) set(f func(s *jwksServer)) {
+	s.mu.Lock()
+	f(s)
+	s.mu.Unlock()
+}
+
+func (s *jwksServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+func serveJWKS(t *testing.T, handler http.Handler) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return "http://" + l.Addr().String() + "/jwks.json"
+}
+
+func newEdJWK(t *testing.T, kid string) (JWK, ed25519.PrivateKey) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := NewJWK(kid, "EdDSA", priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwk, priv
+}
+
+func TestKeySet(t *testing.T) {
+	key1, priv1 := newEdJWK(t, "k1")
+	key2, priv2 := newEdJWK(t, "k2")
+	server := &jwksServer{doc: JWKS{Keys: []JWK{key1}}}
+	set := NewKeySet(serveJWKS(t, server), time.Hour)
+
+	key, alg, err := set.Key("k1")
+	if err != nil || alg != "EdDSA" || !key.(ed25519.PublicKey).Equal(priv1.Public()) {
+		t.Fatalf("expected the key k1, got %v %s", err, alg)
+	}
+	if _, _, err = set.Key("k2"); err != ErrUnknownKeyID || server.count() != 1 {
+		t.Fatalf("expected k2 unknown without a refetch, got %v after %d fetches", err, server.count())
+	}
+
+	// rotation: k2 is picked up once the document may be refetched
+	server.set(func(s *jwksServer) { s.doc = JWKS{Keys: []JWK{key2}} })
+	set.mu.Lock()
+	set.fetched = set.fetched.Add(-jwksMinRefresh - time.Second)
+	set.mu.Unlock()
+	key, _, err = set.Key("k2")
+	if err != nil || !key.(ed25519.PublicKey).Equal(priv2.Public()) || server.count() != 2 {
+		t.Fatalf("expected the rotated key k2, got %v after %d fetches", err, server.count())
+	}
+	if _, _, err = set.Key("k1"); err != ErrUnknownKeyID {
+		t.Fatalf("expected the retired key k1 unknown, got %v", err)
+	}
+
+	// the tokens are verified by the kid
+	mw := &FaygoJWTMiddleware{Realm: "test", SigningAlgorithm: "EdDSA", KeySet: set}
+	if err = mw.MiddlewareInit(); err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(SigningMethodEdDSA, jwt.MapClaims{"id": "admin"})
+	token.Header["kid"] = "k2"
+	signed, _ := token.SignedString(priv2)
+	if _, err = jwt.Parse(signed, mw.verificationKey); err != nil {
+		t.Fatalf("expected the token of k2 verified, got %v", err)
+	}
+	forged := jwt.NewWithClaims(SigningMethodEdDSA, jwt.MapClaims{"id": "admin"})
+	forged.Header["kid"] = "k2"
+	signed, _ = forged.SignedString(priv1)
+	if _, err = jwt.Parse(signed, mw.verificationKey); err == nil {
+		t.Fatal("expected the token signed by another key rejected")
+	}
+}
+
+func TestKeySetRefresh(t *testing.T) {
+	key1, _ := newEdJWK(t, "k1")
+	server := &jwksServer{doc: JWKS{Keys: []JWK{key1}}}
+	set := NewKeySet(serveJWKS(t, server), time.Minute)
+	if _, _, err := set.Key("k1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the requests of the unknown kids share one fetch, without holding the lock
+	block := make(chan struct{})
+	server.set(func(s *jwksServer) { s.block = block })
+	set.mu.Lock()
+	set.fetched = set.fetched.Add(-time.Hour)
+	set.mu.Unlock()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			set.Key("unknown")
+		}()
+	}
+	for i := 0; i < 100 && server.count() < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, _, err := set.Key("k1"); err != nil {
+		t.Fatalf("expected the cached key served while the document is fetched, got %v", err)
+	}
+	close(block)
+	wg.Wait()
+	if n := server.count(); n != 2 {
+		t.Fatalf("expected a single refetch, got %d fetches", n-1)
+	}
+
+	// a failed fetch is not retried within the backoff window, the cached key is served
+	server.set(func(s *jwksServer) { s.block, s.status = nil, http.StatusInternalServerError })
+	set.mu.Lock()
+	set.fetched = set.fetched.Add(-time.Hour)
+	set.mu.Unlock()
+	if _, _, err := set.Key("k1"); err != nil {
+		t.Fatalf("expected the stale key served, got %v", err)
+	}
+	if _, _, err := set.Key("k2"); err == nil || err == ErrUnknownKeyID {
+		t.Fatalf("expected the fetch error, got %v", err)
+	}
+	if _, _, err := set.Key("k1"); err != nil {
+		t.Fatalf("expected the stale key served, got %v", err)
+	}
+	if n := server.count(); n != 3 {
+		t.Fatalf("expected no retry within the backoff window, got %d fetches", n)
+	}
+	server.set(func(s *jwksServer) { s.status = 0 })
+	set.mu.Lock()
+	set.failed = set.failed.Add(-jwksMinRefresh - time.Second)
+	set.mu.Unlock()
+	if _, _, err := set.Key("k1"); err != nil || server.count() != 4 {
+		t.Fatalf("expected a retry after the backoff window, got %v after %d fetches", err, server.count())
+	}
+}
+
+// revokeStore records the revoked users of a MemoryRefreshStore.
+type revokeStore struct {
+	*MemoryRefreshStore
+	revoked []string
+}
+
+func (s *revokeStore) RevokeUser(userID string) error {
+	s.revoked = append(s.revoked, userID)
+	return s.MemoryRefreshStore.RevokeUser(userID)
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	store := &revokeStore{MemoryRefreshStore: NewMemoryRefreshStore()}
+	mw := &FaygoJWTMiddleware{
+		Realm: "test",
+		Key:   []byte("secret"),
+		Authenticator: func(userID string, password string, c *faygo.Context) (interface{}, bool) {
+			return userID, userID == "admin" && password == "admin"
+		},
+		RefreshStore: store,
+	}
+	if err := mw.MiddlewareInit(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	config := faygo.NewDefaultConfig()
+	config.Addrs = []string{addr}
+	config.APIdoc.Enable = false
+	frame := faygo.NewWithConfig(config, "jwt-refresh-test")
+	frame.POST("/login", faygo.HandlerFunc(mw.LoginHandler))
+	frame.POST("/refresh", faygo.HandlerFunc(mw.RefreshTokenHandler))
+	go frame.Run()
+	for i := 0; i < 50; i++ {
+		if c, err := net.Dial("tcp", addr); err == nil {
+			c.Close()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	post := func(path, body string) (int, map[string]interface{}) {
+		resp, err := http.Post("http://"+addr+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		var m map[string]interface{}
+		json.Unmarshal(b, &m)
+		return resp.StatusCode, m
+	}
+	refresh := func(token interface{}) (int, map[string]interface{}) {
+		b, _ := json.Marshal(refreshTokenForm{RefreshToken: token.(string)})
+		return post("/refresh", string(b))
+	}
+
+	code, login := post("/login", `{"usercode":"admin","password":"admin"}`)
+	if code != http.StatusOK || login["refresh_token"] == nil {
+		t.Fatalf("expected the tokens of the login, got %d %v", code, login)
+	}
+	code, rotated := refresh(login["refresh_token"])
+	if code != http.StatusOK || rotated["token"] == nil || rotated["refresh_token"] == nil || rotated["refresh_token"] == login["refresh_token"] {
+		t.Fatalf("expected the rotated tokens, got %d %v", code, rotated)
+	}
+	parsed, err := jwt.Parse(rotated["token"].(string), mw.verificationKey)
+	if err != nil || parsed.Claims.(jwt.MapClaims)["id"] != "admin" {
+		t.Fatalf("expected the access token of admin, got %v", err)
+	}
+
+	// the used token is reused, all the tokens of the user are revoked
+	code, resp := refresh(login["refresh_token"])
+	if code != http.StatusUnauthorized || resp["message"] != ErrRefreshTokenReused.Error() {
+		t.Fatalf("expected the reuse detected, got %d %v", code, resp)
+	}
+	if len(store.revoked) != 1 || store.revoked[0] != "admin" {
+		t.Fatalf("expected the tokens of admin revoked, got %v", store.revoked)
+	}
+	code, resp = refresh(rotated["refresh_token"])
+	if code != http.StatusUnauthorized || resp["message"] != ErrInvalidRefreshToken.Error() {
+		t.Fatalf("expected the rotated token revoked, got %d %v", code, resp)
+	}
+	if code, _ = refresh("unknown"); code != http.StatusUnauthorized {
+		t.Fatalf("expected an unknown token rejected, got %d", code)
+	}
+}
@@ -0,0 +1,143 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+
+	"gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+// SigningMethodEd25519 implements the EdDSA signing method with Ed25519 keys.
+// Expects ed25519.PrivateKey for signing and ed25519.PublicKey for verification.
+type SigningMethodEd25519 struct{}
+
+// SigningMethodEdDSA is the EdDSA signing method, registered as "EdDSA".
+var SigningMethodEdDSA = &SigningMethodEd25519{}
+
+// ErrEd25519Verification indicates the EdDSA signature is invalid
+var ErrEd25519Verification = errors.New("ed25519: verification error")
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+// Alg returns "EdDSA".
+func (m *SigningMethodEd25519) Alg() string {
+	return "EdDSA"
+}
+
+// Verify implements the jwt.SigningMethod.
+func (m *SigningMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok || len(pub) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, []byte(signingString), sig) {
+		return ErrEd25519Verification
+	}
+	return nil
+}
+
+// Sign implements the jwt.SigningMethod.
+func (m *SigningMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok || len(priv) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(priv, []byte(signingString))), nil
+}
+
+// ParsePrivateKeyPEM parses a PEM encoded RSA, ECDSA or Ed25519 private key,
+// in the PKCS #1, SEC 1 or PKCS #8 form.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, ErrInvalidPrivKey
+	}
+	return signer, nil
+}
+
+// ParsePublicKeyPEM parses a PEM encoded RSA, ECDSA or Ed25519 public key,
+// in the PKIX or PKCS #1 form, or the public key of a certificate.
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return cert.PublicKey, nil
+}
+
+// publicKeyOf returns the public key of a private key, or the key itself.
+func publicKeyOf(key interface{}) interface{} {
+	if s, ok := key.(crypto.Signer); ok {
+		return s.Public()
+	}
+	return key
+}
+
+// algMatchesKey reports whether the key is of the type used by the signing algorithm,
+// to prevent a token from choosing another algorithm for the key.
+func algMatchesKey(alg string, key interface{}) bool {
+	switch k := key.(type) {
+	case []byte:
+		return strings.HasPrefix(alg, "HS")
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		return alg == ecdsaAlg(k)
+	case *ecdsa.PrivateKey:
+		return alg == ecdsaAlg(&k.PublicKey)
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+// ecdsaAlg returns the algorithm of the curve of the key.
+func ecdsaAlg(key *ecdsa.PublicKey) string {
+	switch key.Curve.Params().BitSize {
+	case 256:
+		return "ES256"
+	case 384:
+		return "ES384"
+	case 521:
+		return "ES512"
+	}
+	return ""
+}
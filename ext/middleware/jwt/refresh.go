@@ -0,0 +1,136 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshTimeout is how long a refresh token is valid by default.
+const DefaultRefreshTimeout = 7 * 24 * time.Hour
+
+var (
+	// ErrMissingRefreshToken indicates the refresh token is not given
+	ErrMissingRefreshToken = errors.New("refresh token is missing")
+
+	// ErrInvalidRefreshToken indicates the refresh token is unknown, expired or revoked
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid")
+
+	// ErrRefreshTokenReused indicates a refresh token is used twice, all the tokens of the user are revoked
+	ErrRefreshTokenReused = errors.New("refresh token is reused")
+
+	// ErrMissingRefreshStore indicates FaygoJWTMiddleware.RefreshStore is required
+	ErrMissingRefreshStore = errors.New("FaygoJWTMiddleware.RefreshStore is undefined")
+)
+
+// RefreshToken is the state of an opaque refresh token.
+// A refresh token is exchanged once for a new access token and a new refresh
+// token, using it again revokes all the tokens of the user.
+type RefreshToken struct {
+	// ID is the SHA-256 of the token, the token itself is not stored.
+	ID string
+	// UserID is the id claim of the access tokens.
+	UserID string
+	// Family is the ID of the first token, shared by the tokens rotated from it.
+	Family string
+	// Claims are the payload of the access tokens issued by the token.
+	Claims map[string]interface{}
+	// Expire is the time the token expires.
+	Expire time.Time
+	// Used reports whether the token has been exchanged.
+	Used bool
+}
+
+// RefreshStore stores the refresh tokens, it can be shared by the replicas.
+type RefreshStore interface {
+	// Save saves a new refresh token.
+	Save(token *RefreshToken) error
+	// Use marks the token as used, and returns it as it was before.
+	// It returns nil if the token is not found or revoked.
+	Use(id string) (*RefreshToken, error)
+	// Revoke revokes the token.
+	Revoke(id string) error
+	// RevokeUser revokes all the tokens of the user.
+	RevokeUser(userID string) error
+}
+
+// MemoryRefreshStore is a RefreshStore in memory, for a single process.
+type MemoryRefreshStore struct {
+	mu     sync.Mutex
+	tokens map[string]*RefreshToken
+}
+
+var _ RefreshStore = new(MemoryRefreshStore)
+
+// NewMemoryRefreshStore creates a RefreshStore in memory.
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{tokens: make(map[string]*RefreshToken)}
+}
+
+// Save implements the RefreshStore, and removes the expired tokens.
+func (s *MemoryRefreshStore) Save(token *RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, t := range s.tokens {
+		if now.After(t.Expire) {
+			delete(s.tokens, id)
+		}
+	}
+	t := *token
+	s.tokens[t.ID] = &t
+	return nil
+}
+
+// Use implements the RefreshStore.
+func (s *MemoryRefreshStore) Use(id string) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[id]
+	if !ok {
+		return nil, nil
+	}
+	before := *t
+	t.Used = true
+	return &before, nil
+}
+
+// Revoke implements the RefreshStore.
+func (s *MemoryRefreshStore) Revoke(id string) error {
+	s.mu.Lock()
+	delete(s.tokens, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// RevokeUser implements the RefreshStore.
+func (s *MemoryRefreshStore) RevokeUser(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, t := range s.tokens {
+		if t.UserID == userID {
+			delete(s.tokens, id)
+		}
+	}
+	return nil
+}
+
+// newOpaqueToken returns a random token and its ID in the RefreshStore.
+func newOpaqueToken() (token, id string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, RefreshTokenID(token), nil
+}
+
+// RefreshTokenID returns the ID of the refresh token in the RefreshStore.
+func RefreshTokenID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
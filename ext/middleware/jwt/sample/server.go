@@ -80,6 +80,10 @@ func main() {
 
 		// TimeFunc provides the current time. You can override it to use another time value. This is useful for testing or if your server uses a different time zone than your tokens.
 		TimeFunc: time.Now,
+
+		// 可轮换、可吊销的刷新令牌
+		RefreshStore:   jwt.NewMemoryRefreshStore(),
+		RefreshTimeout: time.Hour * 24,
 	}
 	// 调用初始设置函数，必须的。
 	err := authMiddleware.MiddlewareInit()
@@ -88,6 +92,10 @@ func main() {
 	}
 
 	r.POST("/login", faygo.HandlerFunc(authMiddleware.LoginHandler))
+	r.POST("/refresh_token", faygo.HandlerFunc(authMiddleware.RefreshTokenHandler))
+	r.POST("/logout", faygo.HandlerFunc(authMiddleware.RevokeHandler))
+	// 非对称算法时发布公钥，供其他服务验证令牌
+	r.GET("/.well-known/jwks.json", faygo.HandlerFunc(authMiddleware.JWKSHandler))
 	auth := r.Group("/auth")
 	// auth.Use(authMiddleware.MiddlewareFunc())
 	{
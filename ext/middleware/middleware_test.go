@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andeya/faygo"
+)
+
+// startFrame runs a faygo application with the routes added by route,
+// and returns its URL.
+func startFrame(t *testing.T, name string, route func(frame *faygo.Framework)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	config := faygo.NewDefaultConfig()
+	config.Addrs = []string{addr}
+	config.APIdoc.Enable = false
+	frame := faygo.NewWithConfig(config, name)
+	route(frame)
+	go frame.Run()
+	for i := 0; i < 50; i++ {
+		if c, err := net.Dial("tcp", addr); err == nil {
+			c.Close()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return "http://" + addr
+}
+
+// do sends the request, returns the response and its body.
+func do(t *testing.T, method, u string, header http.Header, body string) (*http.Response, string) {
+	req, err := http.NewRequest(method, u, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func ok(ctx *faygo.Context) error {
+	return ctx.String(http.StatusOK, "ok")
+}
@@ -0,0 +1,392 @@
+// Package oidc provides the OpenID Connect login of faygo, with the
+// authorization code flow and PKCE.
+//
+// The state, the nonce and the PKCE verifier of a login are kept in the faygo
+// session, so the session must be enabled. After the callback, the identity of
+// the user is kept in the session, and set on the faygo.Context by the
+// Authenticate and Identify middlewares:
+//
+//	provider := oidc.New(oidc.Config{
+//		Issuer:       "https://accounts.example.com",
+//		ClientID:     "app",
+//		ClientSecret: "secret",
+//		RedirectURL:  "https://app.example.com/oidc/callback",
+//	})
+//	provider.Register(frame.Group("oidc"))
+//	frame.GET("/home", home).Use(provider.Authenticate())
+//
+//	func home(ctx *faygo.Context) error {
+//		return ctx.String(200, "hello "+oidc.GetIdentity(ctx).Name)
+//	}
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/ext/middleware/jwt"
+)
+
+// IdentityKey is the key of the *Identity in the context data.
+const IdentityKey = "OIDC_IDENTITY"
+
+// the keys in the session
+const (
+	sessionState    = "oidc_state"
+	sessionNonce    = "oidc_nonce"
+	sessionVerifier = "oidc_verifier"
+	sessionReturnTo = "oidc_return_to"
+	sessionIdentity = "oidc_identity"
+)
+
+var (
+	// ErrInvalidState indicates the state of the callback does not match the login
+	ErrInvalidState = errors.New("oidc: invalid state")
+
+	// ErrInvalidIDToken indicates the ID token is invalid
+	ErrInvalidIDToken = errors.New("oidc: invalid ID token")
+
+	// ErrInvalidNonce indicates the nonce of the ID token does not match the login
+	ErrInvalidNonce = errors.New("oidc: invalid nonce")
+
+	// ErrInvalidUserInfo indicates the subject of the userinfo does not match the ID token
+	ErrInvalidUserInfo = errors.New("oidc: invalid userinfo")
+
+	// ErrNotLoggedIn indicates the user is not logged in
+	ErrNotLoggedIn = errors.New("oidc: not logged in")
+)
+
+// Config is the configuration of an OpenID Connect client.
+type Config struct {
+	// Issuer is the URL of the provider, its configuration is discovered at
+	// Issuer + "/.well-known/openid-configuration". Required.
+	Issuer string
+	// ClientID is the client identifier registered at the provider. Required.
+	ClientID string
+	// ClientSecret authenticates the client at the token endpoint. Optional for
+	// the public clients, which are protected by PKCE.
+	ClientSecret string
+	// RedirectURL is the absolute URL of the callback route. Required.
+	RedirectURL string
+	// Scopes are requested on login. Optional, defaults to openid, profile and email.
+	Scopes []string
+	// UserInfo fetches the userinfo endpoint after the callback, and adds its
+	// claims to the identity. Optional.
+	UserInfo bool
+	// LoginURL is the path of the login route, where Authenticate redirects the
+	// users who are not logged in. Optional, defaults to "/oidc/login".
+	LoginURL string
+	// PostLoginURL is where the users go after the callback, when the login was
+	// not started by Authenticate. Optional, defaults to "/".
+	PostLoginURL string
+	// PostLogoutURL is where the users go after the logout; it is passed to the
+	// end_session_endpoint of the provider if it is absolute. Optional, defaults to "/".
+	PostLogoutURL string
+	// HTTPClient calls the provider. Optional, defaults to a client with a 10s timeout.
+	HTTPClient *http.Client
+	// JWKSCacheTTL is how long the keys of the provider are cached.
+	// Optional, defaults to jwt.DefaultJWKSCacheTTL.
+	JWKSCacheTTL time.Duration
+}
+
+// Identity is the user logged in through the provider.
+type Identity struct {
+	// Subject is the "sub" claim, the user identifier at the provider.
+	Subject string `json:"sub"`
+	// Email is the "email" claim.
+	Email string `json:"email,omitempty"`
+	// Name is the "name" claim.
+	Name string `json:"name,omitempty"`
+	// Claims are the claims of the ID token and the userinfo.
+	Claims map[string]interface{} `json:"claims,omitempty"`
+	// IDToken is the raw ID token, used as a hint on logout.
+	IDToken string `json:"id_token,omitempty"`
+	// AccessToken calls the APIs of the provider.
+	AccessToken string `json:"access_token,omitempty"`
+	// Expiry is the time the access token expires, zero if unknown.
+	Expiry time.Time `json:"expiry,omitempty"`
+}
+
+// Provider is an OpenID Connect provider, which logs the users in.
+// The configuration of the provider is discovered on the first use.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keySet    *jwt.KeySet
+}
+
+// discovery is the OpenID Provider metadata.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// New creates a Provider.
+func New(config Config) *Provider {
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+	if config.LoginURL == "" {
+		config.LoginURL = "/oidc/login"
+	}
+	if config.PostLoginURL == "" {
+		config.PostLoginURL = "/"
+	}
+	if config.PostLogoutURL == "" {
+		config.PostLogoutURL = "/"
+	}
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{config: config, client: client}
+}
+
+// Register adds the login, callback and logout routes to the group,
+// the RedirectURL should point to the callback route.
+// The logout accepts only POST, so that it is checked by the XSRF filter when enabled.
+func (p *Provider) Register(group *faygo.MuxAPI) {
+	group.NamedGET("oidc login", "/login", faygo.HandlerFunc(p.LoginHandler))
+	group.NamedGET("oidc callback", "/callback", faygo.HandlerFunc(p.CallbackHandler))
+	group.NamedPOST("oidc logout", "/logout", faygo.HandlerFunc(p.LogoutHandler))
+}
+
+// discover returns the provider metadata, fetched on the first call.
+func (p *Provider) discover() (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var d discovery
+	err := p.getJSON(p.config.Issuer+"/.well-known/openid-configuration", "", &d)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("oidc: issuer %q does not match the configured %q", d.Issuer, p.config.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JwksURI == "" {
+		return nil, errors.New("oidc: incomplete provider configuration")
+	}
+	p.keySet = jwt.NewKeySet(d.JwksURI, p.config.JWKSCacheTTL)
+	p.keySet.Client = p.client
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// LoginHandler redirects the user to the provider to log in.
+// The optional query parameter return_to is the local path to go after the login.
+func (p *Provider) LoginHandler(ctx *faygo.Context) error {
+	d, err := p.discover()
+	if err != nil {
+		ctx.Log().Errorf("%s", err.Error())
+		ctx.Error(http.StatusBadGateway, "OpenID Connect provider is unavailable")
+		return nil
+	}
+	state, nonce, verifier := randomString(), randomString(), randomString()
+	ctx.SetSession(sessionState, state)
+	ctx.SetSession(sessionNonce, nonce)
+	ctx.SetSession(sessionVerifier, verifier)
+	if returnTo := ctx.QueryParam("return_to"); isLocalPath(returnTo) {
+		ctx.SetSession(sessionReturnTo, returnTo)
+	} else {
+		ctx.DelSession(sessionReturnTo)
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	return ctx.Redirect(http.StatusFound, withQuery(d.AuthorizationEndpoint, query))
+}
+
+// CallbackHandler completes the login: it checks the state, exchanges the code
+// for the tokens, validates the ID token, and keeps the identity in the session.
+func (p *Provider) CallbackHandler(ctx *faygo.Context) error {
+	state, _ := ctx.GetSession(sessionState).(string)
+	nonce, _ := ctx.GetSession(sessionNonce).(string)
+	verifier, _ := ctx.GetSession(sessionVerifier).(string)
+	returnTo, _ := ctx.GetSession(sessionReturnTo).(string)
+	ctx.DelSession(sessionState)
+	ctx.DelSession(sessionNonce)
+	ctx.DelSession(sessionVerifier)
+	ctx.DelSession(sessionReturnTo)
+
+	if e := ctx.QueryParam("error"); e != "" {
+		ctx.Error(http.StatusUnauthorized, "OpenID Connect login failed: "+e)
+		return nil
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(ctx.QueryParam("state"))) != 1 {
+		ctx.Error(http.StatusUnauthorized, ErrInvalidState.Error())
+		return nil
+	}
+	identity, err := p.exchange(ctx.QueryParam("code"), verifier, nonce)
+	if err != nil {
+		ctx.Log().Warningf("%s", err.Error())
+		ctx.Error(http.StatusUnauthorized, "OpenID Connect login failed")
+		return nil
+	}
+	data, err := json.Marshal(identity)
+	if err != nil {
+		return err
+	}
+	// a new session for the logged-in user, against session fixation
+	ctx.SessionRegenerateID()
+	ctx.SetSession(sessionIdentity, string(data))
+	ctx.SetData(IdentityKey, identity)
+	if returnTo == "" {
+		returnTo = p.config.PostLoginURL
+	}
+	return ctx.Redirect(http.StatusFound, returnTo)
+}
+
+// LogoutHandler logs the user out of the application, and of the provider if it
+// supports the RP-initiated logout.
+// It accepts only POST, so that a cross-site link or image can not log the user out;
+// enable the XSRF filter to check that the request comes from the application.
+func (p *Provider) LogoutHandler(ctx *faygo.Context) error {
+	if ctx.Method() != "POST" {
+		ctx.W.Header().Set("Allow", "POST")
+		ctx.Error(http.StatusMethodNotAllowed, "logout requires POST")
+		return nil
+	}
+	identity := p.identity(ctx)
+	ctx.DestroySession()
+	target := p.config.PostLogoutURL
+	d, err := p.discover()
+	if err == nil && d.EndSessionEndpoint != "" {
+		query := url.Values{"client_id": {p.config.ClientID}}
+		if identity != nil && identity.IDToken != "" {
+			query.Set("id_token_hint", identity.IDToken)
+		}
+		if u, err := url.Parse(target); err == nil && u.IsAbs() {
+			query.Set("post_logout_redirect_uri", target)
+		}
+		target = withQuery(d.EndSessionEndpoint, query)
+	}
+	return ctx.Redirect(http.StatusSeeOther, target)
+}
+
+// Authenticate returns the middleware that requires a logged-in user.
+// The GET requests of the users who are not logged in are redirected to the
+// LoginURL, and the others are answered with 401.
+func (p *Provider) Authenticate() faygo.HandlerFunc {
+	return func(ctx *faygo.Context) error {
+		if identity := p.identity(ctx); identity != nil {
+			ctx.SetData(IdentityKey, identity)
+			return nil
+		}
+		ctx.Stop()
+		if ctx.IsGet() && !ctx.IsAjax() {
+			return ctx.Redirect(http.StatusFound, withQuery(p.config.LoginURL, url.Values{
+				"return_to": {ctx.R.URL.RequestURI()},
+			}))
+		}
+		ctx.Error(http.StatusUnauthorized, ErrNotLoggedIn.Error())
+		return nil
+	}
+}
+
+// Identify returns the middleware that sets the identity of the logged-in
+// user on the context, without requiring one.
+func (p *Provider) Identify() faygo.HandlerFunc {
+	return func(ctx *faygo.Context) error {
+		if identity := p.identity(ctx); identity != nil {
+			ctx.SetData(IdentityKey, identity)
+		}
+		return nil
+	}
+}
+
+// identity returns the identity in the session, nil if the user is not logged in.
+func (p *Provider) identity(ctx *faygo.Context) *Identity {
+	data, _ := ctx.GetSession(sessionIdentity).(string)
+	if data == "" {
+		return nil
+	}
+	var identity Identity
+	if json.Unmarshal([]byte(data), &identity) != nil {
+		return nil
+	}
+	return &identity
+}
+
+// GetIdentity returns the identity set by the middlewares of the provider,
+// nil if the user is not logged in.
+func GetIdentity(ctx *faygo.Context) *Identity {
+	identity, _ := ctx.Data(IdentityKey).(*Identity)
+	return identity
+}
+
+// randomString returns 32 random bytes in base64url.
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// isLocalPath reports whether the path stays on this site, against open redirects.
+func isLocalPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.HasPrefix(path, "/\\")
+}
+
+func withQuery(endpoint string, query url.Values) string {
+	if strings.Contains(endpoint, "?") {
+		return endpoint + "&" + query.Encode()
+	}
+	return endpoint + "?" + query.Encode()
+}
+
+// getJSON gets the JSON document of the URL, with the bearer token if it is not empty.
+func (p *Provider) getJSON(u, token string, v interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s: %s", u, resp.Status)
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,313 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/ext/middleware/jwt"
+	jwtgo "gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+// fakeProvider is an in-process OpenID Connect provider.
+type fakeProvider struct {
+	*httptest.Server
+	key *ecdsa.PrivateKey
+
+	mu        sync.Mutex
+	codes     map[string]url.Values // code -> the authorization request
+	nonce     string                // overrides the nonce of the ID token
+	audience  string                // overrides the audience of the ID token
+	userinfos int
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProvider{key: key, codes: make(map[string]url.Values)}
+	mux := http.NewServeMux()
+	p.Server = httptest.NewServer(mux)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"userinfo_endpoint":      p.URL + "/userinfo",
+			"jwks_uri":               p.URL + "/jwks",
+			"end_session_endpoint":   p.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		jwk, _ := jwt.NewJWK("k1", "ES256", &p.key.PublicKey)
+		json.NewEncoder(w).Encode(jwt.JWKS{Keys: []jwt.JWK{jwk}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("code_challenge_method") != "S256" || q.Get("response_type") != "code" {
+			http.Error(w, "bad request", 400)
+			return
+		}
+		code := base64.RawURLEncoding.EncodeToString([]byte(q.Get("state")))
+		p.mu.Lock()
+		p.codes[code] = q
+		p.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		p.mu.Lock()
+		q, ok := p.codes[r.PostForm.Get("code")]
+		delete(p.codes, r.PostForm.Get("code"))
+		nonce, audience := p.nonce, p.audience
+		p.mu.Unlock()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		id, secret, _ := r.BasicAuth()
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != q.Get("code_challenge") ||
+			id != "app" || secret != "secret" || r.PostForm.Get("redirect_uri") != q.Get("redirect_uri") {
+			w.WriteHeader(400)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		if nonce == "" {
+			nonce = q.Get("nonce")
+		}
+		if audience == "" {
+			audience = "app"
+		}
+		token := jwtgo.NewWithClaims(jwtgo.SigningMethodES256, jwtgo.MapClaims{
+			"iss":   p.URL,
+			"sub":   "user-1",
+			"aud":   audience,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": nonce,
+			"email": "user@example.com",
+		})
+		token.Header["kid"] = "k1"
+		idToken, _ := token.SignedString(p.key)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "at-1",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at-1" {
+			w.WriteHeader(401)
+			return
+		}
+		p.mu.Lock()
+		p.userinfos++
+		p.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"sub": "user-1", "name": "User One"})
+	})
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("logged out"))
+	})
+	return p
+}
+
+// startApp runs a faygo application protected by the provider.
+func startApp(t *testing.T, name string, issuer string, configure ...func(*faygo.Config)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	app := "http://" + addr
+
+	config := faygo.NewDefaultConfig()
+	config.Addrs = []string{addr}
+	config.Session.Enable = true
+	config.APIdoc.Enable = false
+	for _, fn := range configure {
+		fn(config)
+	}
+	frame := faygo.NewWithConfig(config, name)
+	provider := New(Config{
+		Issuer:       issuer,
+		ClientID:     "app",
+		ClientSecret: "secret",
+		RedirectURL:  app + "/oidc/callback",
+		UserInfo:     true,
+	})
+	provider.Register(frame.Group("oidc"))
+	frame.GET("/home", faygo.HandlerFunc(func(ctx *faygo.Context) error {
+		identity := GetIdentity(ctx)
+		return ctx.String(200, identity.Subject+" "+identity.Email+" "+identity.Name)
+	})).Use(provider.Authenticate())
+	go frame.Run()
+	for i := 0; i < 50; i++ {
+		if c, err := net.Dial("tcp", addr); err == nil {
+			c.Close()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return app
+}
+
+func newClient() *http.Client {
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{Jar: jar, Timeout: 10 * time.Second}
+}
+
+func get(t *testing.T, client *http.Client, u string) (int, string) {
+	resp, err := client.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func post(t *testing.T, client *http.Client, u string, header http.Header) (int, string) {
+	req, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestLoginFlow(t *testing.T) {
+	provider := newFakeProvider(t)
+	defer provider.Close()
+	app := startApp(t, "oidc-login", provider.URL)
+
+	client := newClient()
+	code, body := get(t, client, app+"/home")
+	if code != 200 || body != "user-1 user@example.com User One" {
+		t.Fatalf("expected the identity after the login, got %d %q", code, body)
+	}
+	if provider.userinfos != 1 {
+		t.Fatalf("expected the userinfo fetched once, got %d", provider.userinfos)
+	}
+	// logged in
+	code, _ = get(t, client, app+"/home")
+	if code != 200 || provider.userinfos != 1 {
+		t.Fatalf("expected the session kept, got %d", code)
+	}
+
+	// a cross-site link can not log the user out
+	code, _ = get(t, client, app+"/oidc/logout")
+	if code == 200 {
+		t.Fatal("expected the GET logout rejected")
+	}
+	if code, _ = get(t, client, app+"/home"); code != 200 {
+		t.Fatalf("expected the session kept, got %d", code)
+	}
+
+	code, body = post(t, client, app+"/oidc/logout", nil)
+	if code != 200 || body != "logged out" {
+		t.Fatalf("expected the provider logout, got %d %q", code, body)
+	}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(app + "/home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || !strings.HasPrefix(resp.Header.Get("Location"), "/oidc/login?return_to=") {
+		t.Fatalf("expected a redirect to the login after the logout, got %d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+func TestCallbackRejects(t *testing.T) {
+	provider := newFakeProvider(t)
+	defer provider.Close()
+	app := startApp(t, "oidc-reject", provider.URL)
+
+	// a callback without a login
+	code, _ := get(t, newClient(), app+"/oidc/callback?code=x&state=y")
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an unknown state, got %d", code)
+	}
+
+	provider.nonce = "another"
+	code, _ = get(t, newClient(), app+"/home")
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong nonce, got %d", code)
+	}
+
+	provider.nonce, provider.audience = "", "another-app"
+	code, _ = get(t, newClient(), app+"/home")
+	if code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong audience, got %d", code)
+	}
+}
+
+func TestIsLocalPath(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/home":            true,
+		"/a?b=c":           true,
+		"":                 false,
+		"//evil.com":       false,
+		"/\\evil.com":      false,
+		"https://evil.com": false,
+	} {
+		if got := isLocalPath(path); got != want {
+			t.Errorf("isLocalPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestLogoutXSRF(t *testing.T) {
+	provider := newFakeProvider(t)
+	defer provider.Close()
+	app := startApp(t, "oidc-logout-xsrf", provider.URL, func(config *faygo.Config) {
+		config.XSRF.Enable = true
+		config.XSRF.Mode = faygo.XSRF_MODE_HEADER
+	})
+
+	client := newClient()
+	if code, _ := get(t, client, app+"/home"); code != 200 {
+		t.Fatalf("expected the login, got %d", code)
+	}
+	if code, _ := post(t, client, app+"/oidc/logout", nil); code != http.StatusForbidden {
+		t.Fatalf("expected the logout without the XSRF token rejected, got %d", code)
+	}
+	if code, _ := get(t, client, app+"/home"); code != 200 {
+		t.Fatalf("expected the session kept, got %d", code)
+	}
+	var token string
+	u, _ := url.Parse(app)
+	for _, c := range client.Jar.Cookies(u) {
+		if c.Name == "XSRF-TOKEN" {
+			token = c.Value
+		}
+	}
+	if token == "" {
+		t.Fatal("expected the XSRF cookie")
+	}
+	if code, body := post(t, client, app+"/oidc/logout", http.Header{"X-Xsrf-Token": {token}}); code != 200 || body != "logged out" {
+		t.Fatalf("expected the provider logout, got %d %q", code, body)
+	}
+}
@@ -0,0 +1,162 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	jwtgo "gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+// tokenResponse is the response of the token endpoint.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchange exchanges the authorization code for the tokens, and returns the
+// identity of the validated ID token.
+func (p *Provider) exchange(code, verifier, nonce string) (*Identity, error) {
+	d, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest("POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var tr tokenResponse
+	if err = json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("oidc: token endpoint: %s: %v", resp.Status, err)
+	}
+	if tr.Error != "" || resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token endpoint: %s: %s %s", resp.Status, tr.Error, tr.ErrorDescription)
+	}
+	if tr.IDToken == "" {
+		return nil, ErrInvalidIDToken
+	}
+	claims, err := p.verifyIDToken(tr.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	identity := &Identity{
+		Claims:      map[string]interface{}(claims),
+		IDToken:     tr.IDToken,
+		AccessToken: tr.AccessToken,
+	}
+	if tr.ExpiresIn > 0 {
+		identity.Expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	if p.config.UserInfo && d.UserinfoEndpoint != "" && tr.AccessToken != "" {
+		var info map[string]interface{}
+		if err = p.getJSON(d.UserinfoEndpoint, tr.AccessToken, &info); err != nil {
+			return nil, err
+		}
+		if info["sub"] != claims["sub"] {
+			return nil, ErrInvalidUserInfo
+		}
+		for k, v := range info {
+			if _, ok := identity.Claims[k]; !ok {
+				identity.Claims[k] = v
+			}
+		}
+	}
+	identity.Subject, _ = identity.Claims["sub"].(string)
+	identity.Email, _ = identity.Claims["email"].(string)
+	identity.Name, _ = identity.Claims["name"].(string)
+	return identity, nil
+}
+
+// verifyIDToken validates the signature of the ID token against the JWKS of the
+// provider, and its issuer, audience, expiry and nonce.
+func (p *Provider) verifyIDToken(raw, nonce string) (jwtgo.MapClaims, error) {
+	token, err := jwtgo.Parse(raw, func(token *jwtgo.Token) (interface{}, error) {
+		alg := token.Method.Alg()
+		if alg == "none" || strings.HasPrefix(alg, "HS") {
+			return nil, ErrInvalidIDToken
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, keyAlg, err := p.keySet.Key(kid)
+		if err != nil {
+			return nil, err
+		}
+		if keyAlg != "" && keyAlg != alg {
+			return nil, ErrInvalidIDToken
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", ErrInvalidIDToken, err)
+	}
+	claims := token.Claims.(jwtgo.MapClaims)
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("%v: issuer %q", ErrInvalidIDToken, iss)
+	}
+	if !p.validAudience(claims) {
+		return nil, fmt.Errorf("%v: audience", ErrInvalidIDToken)
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%v: no expiry", ErrInvalidIDToken)
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("%v: no subject", ErrInvalidIDToken)
+	}
+	if n, _ := claims["nonce"].(string); nonce == "" || n != nonce {
+		return nil, ErrInvalidNonce
+	}
+	return claims, nil
+}
+
+// validAudience reports whether the client is an audience of the ID token,
+// and the authorized party if there are several audiences.
+func (p *Provider) validAudience(claims jwtgo.MapClaims) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == p.config.ClientID
+	case []interface{}:
+		found := false
+		for _, a := range aud {
+			if a == p.config.ClientID {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+		if len(aud) > 1 {
+			azp, _ := claims["azp"].(string)
+			return azp == p.config.ClientID
+		}
+		return true
+	}
+	return false
+}
@@ -15,70 +15,27 @@
 package task
 
 import (
+	"context"
 	"log"
-	"math"
 	"sort"
-	"strconv"
-	"strings"
+	"sync"
 	"time"
+
+	"github.com/andeya/faygo"
+	"github.com/andeya/faygo/ext/cron"
+	"github.com/andeya/faygo/ext/distlock"
 )
 
-// bounds provides a range of acceptable values (plus a map of name to value).
-type bounds struct {
-	min, max uint
-	names    map[string]uint
-}
+// AdminTaskList the global task list
+var AdminTaskList = make(map[string]Tasker)
 
-// The bounds for each field.
+// the global scheduler of the tasks
 var (
-	AdminTaskList map[string]Tasker
-	stop          chan bool
-	changed       chan bool
-	isstart       bool
-	seconds       = bounds{0, 59, nil}
-	minutes       = bounds{0, 59, nil}
-	hours         = bounds{0, 23, nil}
-	days          = bounds{1, 31, nil}
-	months        = bounds{1, 12, map[string]uint{
-		"jan": 1,
-		"feb": 2,
-		"mar": 3,
-		"apr": 4,
-		"may": 5,
-		"jun": 6,
-		"jul": 7,
-		"aug": 8,
-		"sep": 9,
-		"oct": 10,
-		"nov": 11,
-		"dec": 12,
-	}}
-	weeks = bounds{0, 6, map[string]uint{
-		"sun": 0,
-		"mon": 1,
-		"tue": 2,
-		"wed": 3,
-		"thu": 4,
-		"fri": 5,
-		"sat": 6,
-	}}
-)
-
-const (
-	// Set the top bit if a star was included in the expression.
-	starBit = 1 << 63
+	scheduler = cron.New()
+	taskIDs   = make(map[string]cron.EntryID)
+	taskLock  sync.Mutex
 )
 
-// Schedule time taks schedule
-type Schedule struct {
-	Second uint64
-	Minute uint64
-	Hour   uint64
-	Day    uint64
-	Month  uint64
-	Week   uint64
-}
-
 // TaskFunc task func type
 type TaskFunc func() error
 
@@ -102,7 +59,7 @@ type taskerr struct {
 // Task task struct
 type Task struct {
 	Taskname string
-	Spec     *Schedule
+	Spec     cron.Schedule
 	SpecStr  string
 	DoFunc   TaskFunc
 	Prev     time.Time
@@ -201,261 +158,152 @@ func (t *Task) GetPrev() time.Time {
 //	0 0 * * * *　　　　　　　　               0 min of hour in 1 hour duration
 //	0 2 8-20/3 * * *　　　　　　             8:02, 11:02, 14:02, 17:02, 20:02
 //	0 30 5 1,15 * *　　　　　　              5:30 on the 1st day and 15th day of month
+//
+// The spec is parsed by ext/cron, which also accepts the descriptors such as
+// @daily and @every 1h30m, and the time zone prefix CRON_TZ=Asia/Shanghai.
 func (t *Task) SetCron(spec string) {
-	t.Spec = t.parse(spec)
-}
-
-func (t *Task) parse(spec string) *Schedule {
-	if len(spec) > 0 && spec[0] == '@' {
-		return t.parseSpec(spec)
-	}
-	// Split on whitespace.  We require 5 or 6 fields.
-	// (second) (minute) (hour) (day of month) (month) (day of week, optional)
-	fields := strings.Fields(spec)
-	if len(fields) != 5 && len(fields) != 6 {
-		log.Panicf("Expected 5 or 6 fields, found %d: %s", len(fields), spec)
-	}
-
-	// If a sixth field is not provided (DayOfWeek), then it is equivalent to star.
-	if len(fields) == 5 {
-		fields = append(fields, "*")
-	}
-
-	schedule := &Schedule{
-		Second: getField(fields[0], seconds),
-		Minute: getField(fields[1], minutes),
-		Hour:   getField(fields[2], hours),
-		Day:    getField(fields[3], days),
-		Month:  getField(fields[4], months),
-		Week:   getField(fields[5], weeks),
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		log.Panic(err)
 	}
-
-	return schedule
+	t.Spec = schedule
 }
 
-func (t *Task) parseSpec(spec string) *Schedule {
-	switch spec {
-	case "@yearly", "@annually":
-		return &Schedule{
-			Second: 1 << seconds.min,
-			Minute: 1 << minutes.min,
-			Hour:   1 << hours.min,
-			Day:    1 << days.min,
-			Month:  1 << months.min,
-			Week:   all(weeks),
-		}
-
-	case "@monthly":
-		return &Schedule{
-			Second: 1 << seconds.min,
-			Minute: 1 << minutes.min,
-			Hour:   1 << hours.min,
-			Day:    1 << days.min,
-			Month:  all(months),
-			Week:   all(weeks),
-		}
-
-	case "@weekly":
-		return &Schedule{
-			Second: 1 << seconds.min,
-			Minute: 1 << minutes.min,
-			Hour:   1 << hours.min,
-			Day:    all(days),
-			Month:  all(months),
-			Week:   1 << weeks.min,
-		}
-
-	case "@daily", "@midnight":
-		return &Schedule{
-			Second: 1 << seconds.min,
-			Minute: 1 << minutes.min,
-			Hour:   1 << hours.min,
-			Day:    all(days),
-			Month:  all(months),
-			Week:   all(weeks),
-		}
-
-	case "@hourly":
-		return &Schedule{
-			Second: 1 << seconds.min,
-			Minute: 1 << minutes.min,
-			Hour:   all(hours),
-			Day:    all(days),
-			Month:  all(months),
-			Week:   all(weeks),
-		}
-	}
-	log.Panicf("Unrecognized descriptor: %s", spec)
-	return nil
+// Schedule is the schedule of the six fields spec.
+//
+// Deprecated: the spec is parsed by ext/cron, Task.Spec holds a cron.Schedule.
+type Schedule struct {
+	Second uint64
+	Minute uint64
+	Hour   uint64
+	Day    uint64
+	Month  uint64
+	Week   uint64
 }
 
-// Next set schedule to next time
+// Next returns the next time this schedule is activated, greater than the given time.
 func (s *Schedule) Next(t time.Time) time.Time {
-
-	// Start at the earliest possible time (the upcoming second).
-	t = t.Add(1*time.Second - time.Duration(t.Nanosecond())*time.Nanosecond)
-
-	// This flag indicates whether a field has been incremented.
-	added := false
-
-	// If no time is found within five years, return zero.
-	yearLimit := t.Year() + 5
-
-WRAP:
-	if t.Year() > yearLimit {
-		return time.Time{}
-	}
-
-	// Find the first applicable month.
-	// If it's this month, then do nothing.
-	for 1<<uint(t.Month())&s.Month == 0 {
-		// If we have to add a month, reset the other parts to 0.
-		if !added {
-			added = true
-			// Otherwise, set the date at the beginning (since the current time is irrelevant).
-			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
-		}
-		t = t.AddDate(0, 1, 0)
-
-		// Wrapped around.
-		if t.Month() == time.January {
-			goto WRAP
-		}
-	}
-
-	// Now get a day in that month.
-	for !dayMatches(s, t) {
-		if !added {
-			added = true
-			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
-		}
-		t = t.AddDate(0, 0, 1)
-
-		if t.Day() == 1 {
-			goto WRAP
-		}
-	}
-
-	for 1<<uint(t.Hour())&s.Hour == 0 {
-		if !added {
-			added = true
-			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
-		}
-		t = t.Add(1 * time.Hour)
-
-		if t.Hour() == 0 {
-			goto WRAP
-		}
-	}
-
-	for 1<<uint(t.Minute())&s.Minute == 0 {
-		if !added {
-			added = true
-			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
-		}
-		t = t.Add(1 * time.Minute)
-
-		if t.Minute() == 0 {
-			goto WRAP
+	spec := cron.SpecSchedule{
+		Second: s.Second,
+		Minute: s.Minute,
+		Hour:   s.Hour,
+		Dom:    s.Day,
+		Month:  s.Month,
+		Dow:    s.Week,
+	}
+	return spec.Next(t)
+}
+
+// stateLock guards the Prev and Next of the scheduled tasks.
+var stateLock sync.Mutex
+
+// schedule adds the task to the scheduler, replacing the task of the same name.
+// note: the caller must hold taskLock
+func schedule(taskname string, t Tasker) {
+	if id, ok := taskIDs[taskname]; ok {
+		scheduler.Remove(id)
+		delete(taskIDs, taskname)
+	}
+	var spec cron.Schedule
+	if task, ok := t.(*Task); ok && task.Spec != nil {
+		spec = task.Spec
+	} else {
+		var err error
+		if spec, err = cron.Parse(t.GetSpec()); err != nil {
+			faygo.Errorf("task %s: %v", taskname, err)
+			return
 		}
 	}
-
-	for 1<<uint(t.Second())&s.Second == 0 {
-		if !added {
-			added = true
-			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, t.Location())
-		}
-		t = t.Add(1 * time.Second)
-
-		if t.Second() == 0 {
-			goto WRAP
+	stateLock.Lock()
+	t.SetNext(time.Now())
+	stateLock.Unlock()
+	taskIDs[taskname] = scheduler.Schedule(spec, cron.ContextFunc(func(context.Context) error {
+		now := time.Now()
+		stateLock.Lock()
+		if prev := t.GetNext(); !prev.IsZero() && !prev.After(now) {
+			t.SetPrev(prev)
+		} else {
+			t.SetPrev(now)
 		}
-	}
-
-	return t
+		t.SetNext(now)
+		stateLock.Unlock()
+		return t.Run()
+	}), cron.WithName(taskname))
 }
 
-func dayMatches(s *Schedule, t time.Time) bool {
-	var (
-		domMatch = 1<<uint(t.Day())&s.Day > 0
-		dowMatch = 1<<uint(t.Weekday())&s.Week > 0
-	)
-
-	if s.Day&starBit > 0 || s.Week&starBit > 0 {
-		return domMatch && dowMatch
-	}
-	return domMatch || dowMatch
+// Cron returns the scheduler of the tasks, which may be used to add jobs with the
+// ext/cron options, inspect the runs or set a Locker and a Store.
+func Cron() *cron.Cron {
+	return scheduler
 }
 
 // StartTask start all tasks
 func StartTask() {
-	if isstart {
-		//If already started， no need to start another goroutine.
-		return
+	taskLock.Lock()
+	// the tasks may be added to AdminTaskList directly
+	for taskname, t := range AdminTaskList {
+		if _, ok := taskIDs[taskname]; !ok {
+			schedule(taskname, t)
+		}
 	}
-	isstart = true
-	go run()
+	taskLock.Unlock()
+	scheduler.Start()
 }
 
-func run() {
-	now := time.Now().Local()
-	for _, t := range AdminTaskList {
-		t.SetNext(now)
-	}
-
-	for {
-		sortList := NewMapSorter(AdminTaskList)
-		sortList.Sort()
-		var effective time.Time
-		if len(AdminTaskList) == 0 || sortList.Vals[0].GetNext().IsZero() {
-			// If there are no entries yet, just sleep - it still handles new entries
-			// and stop requests.
-			effective = now.AddDate(10, 0, 0)
-		} else {
-			effective = sortList.Vals[0].GetNext()
+// RunWithFramework starts the tasks and the crons before faygo.Run() listens,
+// and stops them before faygo.Shutdown() or faygo.Reboot() closes the services,
+// waiting for the running jobs to finish.
+// note: it should be called before faygo.Run()
+func RunWithFramework(crons ...*cron.Cron) {
+	faygo.AddPreRunFunc(func() error {
+		StartTask()
+		for _, c := range crons {
+			c.Start()
 		}
-		select {
-		case now = <-time.After(effective.Sub(now)):
-			// Run every entry whose next time was this effective time.
-			for _, e := range sortList.Vals {
-				if e.GetNext() != effective {
-					break
-				}
-				go e.Run()
-				e.SetPrev(e.GetNext())
-				e.SetNext(effective)
-			}
-			continue
-		case <-changed:
-			continue
-		case <-stop:
-			return
+		return nil
+	})
+	faygo.AddPreCloseFunc(func() error {
+		StopTask()
+		for _, c := range crons {
+			c.Stop()
 		}
-	}
+		scheduler.Wait()
+		for _, c := range crons {
+			c.Wait()
+		}
+		return nil
+	})
+}
+
+// SetLocker sets the locker that claims each run of the tasks, so that only one
+// replica runs it; the runs are keyed by the task name and the scheduled time.
+// ttl is how long a claim is kept, distlock.DefaultTTL is used when it is 0.
+// It should be called before StartTask.
+func SetLocker(l distlock.Locker, ttl time.Duration) {
+	scheduler.Locker, scheduler.LockTTL = l, ttl
 }
 
 // StopTask stop all tasks
 func StopTask() {
-	if isstart {
-		isstart = false
-		stop <- true
-	}
-
+	scheduler.Stop()
 }
 
 // AddTask add task with name
 func AddTask(taskname string, t Tasker) {
+	taskLock.Lock()
+	defer taskLock.Unlock()
 	AdminTaskList[taskname] = t
-	if isstart {
-		changed <- true
-	}
+	schedule(taskname, t)
 }
 
 // DeleteTask delete task with name
 func DeleteTask(taskname string) {
+	taskLock.Lock()
+	defer taskLock.Unlock()
 	delete(AdminTaskList, taskname)
-	if isstart {
-		changed <- true
+	if id, ok := taskIDs[taskname]; ok {
+		scheduler.Remove(id)
+		delete(taskIDs, taskname)
 	}
 }
 
@@ -497,118 +345,3 @@ func (ms *MapSorter) Swap(i, j int) {
 	ms.Vals[i], ms.Vals[j] = ms.Vals[j], ms.Vals[i]
 	ms.Keys[i], ms.Keys[j] = ms.Keys[j], ms.Keys[i]
 }
-
-func getField(field string, r bounds) uint64 {
-	// list = range {"," range}
-	var bits uint64
-	ranges := strings.FieldsFunc(field, func(r rune) bool { return r == ',' })
-	for _, expr := range ranges {
-		bits |= getRange(expr, r)
-	}
-	return bits
-}
-
-// getRange returns the bits indicated by the given expression:
-//   number | number "-" number [ "/" number ]
-func getRange(expr string, r bounds) uint64 {
-
-	var (
-		start, end, step uint
-		rangeAndStep     = strings.Split(expr, "/")
-		lowAndHigh       = strings.Split(rangeAndStep[0], "-")
-		singleDigit      = len(lowAndHigh) == 1
-	)
-
-	var extrastar uint64
-	if lowAndHigh[0] == "*" || lowAndHigh[0] == "?" {
-		start = r.min
-		end = r.max
-		extrastar = starBit
-	} else {
-		start = parseIntOrName(lowAndHigh[0], r.names)
-		switch len(lowAndHigh) {
-		case 1:
-			end = start
-		case 2:
-			end = parseIntOrName(lowAndHigh[1], r.names)
-		default:
-			log.Panicf("Too many hyphens: %s", expr)
-		}
-	}
-
-	switch len(rangeAndStep) {
-	case 1:
-		step = 1
-	case 2:
-		step = mustParseInt(rangeAndStep[1])
-
-		// Special handling: "N/step" means "N-max/step".
-		if singleDigit {
-			end = r.max
-		}
-	default:
-		log.Panicf("Too many slashes: %s", expr)
-	}
-
-	if start < r.min {
-		log.Panicf("Beginning of range (%d) below minimum (%d): %s", start, r.min, expr)
-	}
-	if end > r.max {
-		log.Panicf("End of range (%d) above maximum (%d): %s", end, r.max, expr)
-	}
-	if start > end {
-		log.Panicf("Beginning of range (%d) beyond end of range (%d): %s", start, end, expr)
-	}
-
-	return getBits(start, end, step) | extrastar
-}
-
-// parseIntOrName returns the (possibly-named) integer contained in expr.
-func parseIntOrName(expr string, names map[string]uint) uint {
-	if names != nil {
-		if namedInt, ok := names[strings.ToLower(expr)]; ok {
-			return namedInt
-		}
-	}
-	return mustParseInt(expr)
-}
-
-// mustParseInt parses the given expression as an int or panics.
-func mustParseInt(expr string) uint {
-	num, err := strconv.Atoi(expr)
-	if err != nil {
-		log.Panicf("Failed to parse int from %s: %s", expr, err)
-	}
-	if num < 0 {
-		log.Panicf("Negative number (%d) not allowed: %s", num, expr)
-	}
-
-	return uint(num)
-}
-
-// getBits sets all bits in the range [min, max], modulo the given step size.
-func getBits(min, max, step uint) uint64 {
-	var bits uint64
-
-	// If step is 1, use shifts.
-	if step == 1 {
-		return ^(math.MaxUint64 << (max + 1)) & (math.MaxUint64 << min)
-	}
-
-	// Else, use a simple loop.
-	for i := min; i <= max; i += step {
-		bits |= 1 << i
-	}
-	return bits
-}
-
-// all returns all bits within the given bounds.  (plus the star bit)
-func all(r bounds) uint64 {
-	return getBits(r.min, r.max, 1) | starBit
-}
-
-func init() {
-	AdminTaskList = make(map[string]Tasker)
-	stop = make(chan bool)
-	changed = make(chan bool)
-}
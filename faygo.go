@@ -92,6 +92,23 @@ func Run() {
 	select {}
 }
 
+// AddPreRunFunc adds a function which is called before the services start
+// listening, such as running the pending database migrations.
+// The functions are called once in the order they are added,
+// and the process exits if one of them returns an error.
+// note: it should be called before Run()
+func AddPreRunFunc(fn func() error) {
+	global.preRunFuncs = append(global.preRunFuncs, fn)
+}
+
+// AddPreCloseFunc adds a function which is called before the services are closed
+// by Shutdown or Reboot, such as stopping the scheduled tasks.
+// The functions are called in the reverse order they are added, before the
+// function set by SetShutdown, and are not guaranteed to be completed.
+func AddPreCloseFunc(fn func() error) {
+	global.preCloseFuncs = append(global.preCloseFuncs, fn)
+}
+
 // Running returns whether the frame service is running.
 func Running(name string, version ...string) bool {
 	frame, ok := GetFrame(name, version...)
@@ -135,7 +152,7 @@ func Shutdown(timeout ...time.Duration) {
 		go func() {
 			defer close(endCh)
 
-			var graceful = true
+			var graceful = global.preClose("shutdown")
 
 			if global.preCloseFunc != nil {
 				if err := global.preCloseFunc(); err != nil {
@@ -156,6 +173,19 @@ func Shutdown(timeout ...time.Duration) {
 	})
 }
 
+// preClose calls the functions added by AddPreCloseFunc in reverse order,
+// and reports whether all of them succeeded.
+func (g *GlobalVariables) preClose(action string) bool {
+	var ok = true
+	for i := len(g.preCloseFuncs) - 1; i >= 0; i-- {
+		if err := g.preCloseFuncs[i](); err != nil {
+			Errorf("[%s-preClose] %s", action, err.Error())
+			ok = false
+		}
+	}
+	return ok
+}
+
 func contextExec(timeout []time.Duration, action string, deferCallback func(ctxTimeout context.Context) <-chan struct{}) {
 	if len(timeout) > 0 {
 		SetShutdown(timeout[0], global.preCloseFunc, global.postCloseFunc)
@@ -314,6 +344,7 @@ func StaticDir() string {
 func CloseLog() {
 	global.bizlog.Close()
 	global.syslog.Close()
+	closeRemoteBackends()
 }
 
 // Fatal is equivalent to l.Critical(fmt.Sprint()) followed by a call to os.Exit(1).
@@ -453,6 +484,10 @@ type (
 		preCloseFunc func() error
 		// executed after services are closed, but not guaranteed to be completed.
 		postCloseFunc func() error
+		// executed in order before the services start listening.
+		preRunFuncs []func() error
+		// executed in reverse order before closing services.
+		preCloseFuncs []func() error
 
 		beforeRunOnce sync.Once
 	}
@@ -573,5 +608,10 @@ func (g *GlobalVariables) beforeRun() {
 		resetFlag()
 		WritePid(LogDir() + "app.pid")
 		go graceSignal()
+		for _, fn := range g.preRunFuncs {
+			if err := fn(); err != nil {
+				g.syslog.Fatalf("[preRun] %s", err.Error())
+			}
+		}
 	})
 }
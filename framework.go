@@ -33,6 +33,9 @@ import (
 
 // Framework is the faygo web framework.
 type Framework struct {
+	// the counts of the requests rejected by the request limits, accessed
+	// atomically and kept first for the 64-bit alignment
+	limitRejections [limitKinds]int64
 	// name of the application
 	name string
 	// version of the application
@@ -53,8 +56,15 @@ type Framework struct {
 	// for user bissness
 	bizlog         *logging.Logger
 	apidoc         *swagger.Swagger
+	apidocLock     sync.Mutex
+	apidocFns      []func(*swagger.Swagger)
 	dynamicSrcTree map[string]*node // dynamic resource router tree
 	staticSrcTree  map[string]*node // dynamic resource router tree
+	preflightTree  map[string]*node // preflight router tree of the CORS middlewares
+	authorizer     Authorizer       // checks the permissions required by the routes
+	// IP filters of the system routes, nil if not restricted
+	apidocIPFilter   *IPFilter
+	logAdminIPFilter *IPFilter
 	// Redirect from 'http://hostname:port1' to 'https://hostname:port2'
 	httpRedirectHttps bool
 	// One of the https ports to be listened
@@ -214,6 +224,14 @@ func (frame *Framework) build() {
 			if frame.config.APIdoc.Enable {
 				frame.regAPIdoc()
 			}
+			// log level admin
+			if frame.config.LogAdmin.Enable {
+				frame.regLogAdmin()
+			}
+			// security headers
+			if frame.config.SecureHeaders.Enable {
+				frame.regSecureHeaders()
+			}
 			// static
 			frame.presetSystemMuxes()
 		}
@@ -225,8 +243,12 @@ func (frame *Framework) build() {
 		if frame.staticSrcTree == nil {
 			frame.staticSrcTree = make(map[string]*node)
 		}
+		if frame.preflightTree == nil {
+			frame.preflightTree = make(map[string]*node)
+		}
 		for _, api := range frame.MuxAPIsForRouter() {
-			handle := frame.makeHandle(api.handlers)
+			handle := frame.makeHandle(api)
+			preflight := makePreflight(api)
 			for _, method := range api.methods {
 				if api.path[0] != '/' {
 					Panic("path must begin with '/' in path '" + api.path + "'")
@@ -251,8 +273,19 @@ func (frame *Framework) build() {
 					}
 				}
 				root.addRoute(api.path, handle)
+				if preflight != nil && method != "OPTIONS" {
+					root = frame.preflightTree[method]
+					if root == nil {
+						root = new(node)
+						frame.preflightTree[method] = root
+					}
+					root.addRoute(api.path, preflight)
+				}
 				frame.syslog.Criticalf("\x1b[46m[SYS]\x1b[0m %7s | %-30s", method, api.path)
 			}
+			if len(api.requires) > 0 && frame.authorizer == nil {
+				frame.syslog.Warningf("[Authorize] no authorizer is set, the requests to %s requiring %v will be denied", api.path, api.requires)
+			}
 		}
 
 		// new server
@@ -265,11 +298,17 @@ func (frame *Framework) build() {
 				tlsKeyFile:      frame.config.TLSKeyFile,
 				letsencryptDir:  frame.config.LetsencryptDir,
 				unixFileMode:    frame.config.unixFileMode,
+
+				tlsClientCAFile:   frame.config.TLSClientCAFile,
+				tlsClientAuth:     frame.config.TLSClientAuth,
+				tlsReloadInterval: frame.config.TLSReloadInterval,
 				Server: &http.Server{
-					Addr:         frame.config.Addrs[i],
-					Handler:      frame,
-					ReadTimeout:  frame.config.ReadTimeout,
-					WriteTimeout: frame.config.WriteTimeout,
+					Addr:           frame.config.Addrs[i],
+					Handler:        frame,
+					ReadTimeout:    frame.config.ReadTimeout,
+					WriteTimeout:   frame.config.WriteTimeout,
+					MaxHeaderBytes: frame.config.RequestLimit.MaxHeaderBytes,
+					ConnContext:    connContext,
 				},
 				log: frame.syslog,
 			}
@@ -561,6 +600,9 @@ func (frame *Framework) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 }
 
 func (frame *Framework) serveHTTP(ctx *Context) {
+	if !ctx.checkHeaderLimits() {
+		return
+	}
 	if frame.httpRedirectHttps && !ctx.IsSecure() {
 		u := ctx.URL()
 		u.Scheme = "https"
@@ -628,7 +670,10 @@ func (frame *Framework) tryHandle(ctx *Context, path, method string, tree map[st
 		if frame.handleOPTIONS {
 			if allow := frame.allowed(path, method); len(allow) > 0 {
 				ctx.SetHeader("Allow", allow)
-				ctx.W.WriteHeader(204)
+				frame.preflight(ctx, path)
+				if !ctx.Committed() {
+					ctx.W.WriteHeader(204)
+				}
 				return true
 			}
 		}
@@ -683,9 +728,67 @@ func (frame *Framework) allowed(path, reqMethod string) (allow string) {
 	return
 }
 
+// preflight calls the Preflighter middlewares of the route requested by the
+// CORS preflight request.
+func (frame *Framework) preflight(ctx *Context, path string) {
+	reqMethod := ctx.HeaderParam(HeaderAccessControlRequestMethod)
+	if reqMethod == "" || ctx.HeaderParam(HeaderOrigin) == "" {
+		return
+	}
+	if root := frame.preflightTree[reqMethod]; root != nil {
+		if handle, ps, _ := root.getValue(path); handle != nil {
+			handle(ctx, ps)
+		}
+	}
+}
+
+// makePreflight makes the Handle of the Preflighter middlewares of the api,
+// returns nil if there is none.
+func makePreflight(api *MuxAPI) Handle {
+	var preflighters []Preflighter
+	for _, h := range api.handlers {
+		if p, ok := h.(Preflighter); ok {
+			preflighters = append(preflighters, p)
+		}
+	}
+	if len(preflighters) == 0 {
+		return nil
+	}
+	return func(ctx *Context, pathParams PathParams) {
+		ctx.pathParams = pathParams
+		for _, p := range preflighters {
+			p.Preflight(ctx)
+		}
+	}
+}
+
 // makeHandle makes an *apiware.ParamsAPI implements the Handle interface.
-func (frame *Framework) makeHandle(handlerChain HandlerChain) Handle {
+func (frame *Framework) makeHandle(api *MuxAPI) Handle {
+	handlerChain := frame.withAuthorization(api)
+	printBody := api.printBody
+	xsrfExempt := api.xsrfExempt != nil && *api.xsrfExempt
+	maxBodySize := frame.config.RequestLimit.maxBodySize
+	if api.maxBodySize != nil {
+		maxBodySize = *api.maxBodySize
+	}
+	if printBody == nil {
+		return func(ctx *Context, pathParams PathParams) {
+			ctx.xsrfExempt = xsrfExempt
+			if !ctx.limitBodySize(maxBodySize) {
+				return
+			}
+			ctx.doHandler(handlerChain, pathParams)
+		}
+	}
 	return func(ctx *Context, pathParams PathParams) {
+		ctx.xsrfExempt = xsrfExempt
+		if !ctx.limitBodySize(maxBodySize) {
+			return
+		}
+		ctx.printBody = printBody.enable && sampled(printBody.sampleRate)
+		if ctx.printBody && !ctx.IsUpload() {
+			ctx.LimitedBodyBytes()
+		}
 		ctx.doHandler(handlerChain, pathParams)
 	}
 }
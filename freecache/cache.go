@@ -46,6 +46,22 @@ func (cache *Cache) Set(key, value []byte, expireSeconds int) (err error) {
 	return
 }
 
+// SetNX sets the entry only if the key does not exist or has expired,
+// and reports whether it was set.
+func (cache *Cache) SetNX(key, value []byte, expireSeconds int) (ok bool, err error) {
+	hashVal := hashFunc(key)
+	segId := hashVal & 255
+	cache.locks[segId].Lock()
+	defer cache.locks[segId].Unlock()
+	if _, err = cache.segments[segId].get(key, hashVal); err != ErrNotFound {
+		return false, nil
+	}
+	if err = cache.segments[segId].set(key, value, hashVal, expireSeconds); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // Get the value or not found error.
 func (cache *Cache) Get(key []byte) (value []byte, err error) {
 	hashVal := hashFunc(key)
@@ -151,6 +151,24 @@ func TestExpire(t *testing.T) {
 	}
 }
 
+func TestSetNX(t *testing.T) {
+	cache := NewCache(1024)
+	key := []byte("abcd")
+	if ok, err := cache.SetNX(key, []byte("efgh"), 1); !ok || err != nil {
+		t.Fatal("first SetNX should set the key", err)
+	}
+	if ok, _ := cache.SetNX(key, []byte("ijkl"), 1); ok {
+		t.Fatal("SetNX should not overwrite an existing key")
+	}
+	if val, _ := cache.Get(key); string(val) != "efgh" {
+		t.Fatal("value should not be changed", string(val))
+	}
+	time.Sleep(time.Second)
+	if ok, _ := cache.SetNX(key, []byte("ijkl"), 0); !ok {
+		t.Fatal("SetNX should set an expired key")
+	}
+}
+
 func TestAverageAccessTimeWhenUpdateInplace(t *testing.T) {
 	cache := NewCache(1024)
 
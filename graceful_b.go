@@ -62,6 +62,8 @@ func Reboot(timeout ...time.Duration) {
 
 			var reboot = true
 
+			graceful = global.preClose("reboot") && graceful
+
 			if global.preCloseFunc != nil {
 				if err := global.preCloseFunc(); err != nil {
 					Errorf("[reboot-preClose] %s", err.Error())
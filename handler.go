@@ -51,6 +51,20 @@ type (
 	APIDoc interface {
 		Doc() Doc
 	}
+	// Preflighter is a middleware that answers the CORS preflight requests.
+	// When RouterConfig.HandleOPTIONS is enabled and the OPTIONS method is not
+	// routed, the automatic reply calls the Preflight of the middlewares used by
+	// the route of the Access-Control-Request-Method.
+	Preflighter interface {
+		Preflight(ctx *Context)
+	}
+	// SecurityDefiner is a middleware that declares its security schemes in the API doc.
+	// The schemes of one middleware are alternatives, and those of several
+	// middlewares used by a route are all required.
+	SecurityDefiner interface {
+		// SecurityDefinitions returns the swagger securityDefinitions, by name.
+		SecurityDefinitions() map[string]map[string]interface{}
+	}
 	// ParamInfo is the request parameter information
 	ParamInfo struct {
 		Name     string      // Parameter name
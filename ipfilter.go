@@ -0,0 +1,298 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faygo
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// IPSet is a set of IPv4 and IPv6 ranges.
+type IPSet struct {
+	any  bool
+	nets []*net.IPNet
+	// deprecated entries of the config, such as '192.16*', matched as the string prefixes
+	prefixes []string
+}
+
+// ParseIPSet parses the list of the CIDR ranges, e.g. '10.0.0.0/8' or '2001:db8::/32',
+// the single IPs, e.g. '202.122.246.170' or '::1', the octet prefixes, e.g. '192.168.*',
+// and '*' which matches any IP.
+func ParseIPSet(list []string) (*IPSet, error) {
+	s := new(IPSet)
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+			continue
+		case item == "*":
+			s.any = true
+			continue
+		case strings.HasSuffix(item, "*"):
+			cidr, err := octetPrefixToCIDR(item)
+			if err != nil {
+				return nil, err
+			}
+			item = cidr
+		case !strings.Contains(item, "/"):
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP: %q", item)
+			}
+			if ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		s.nets = append(s.nets, ipnet)
+	}
+	return s, nil
+}
+
+// octetPrefixToCIDR converts the IPv4 prefix such as '192.168.*' to '192.168.0.0/16'.
+func octetPrefixToCIDR(prefix string) (string, error) {
+	octets := strings.Split(strings.TrimSuffix(prefix, ".*"), ".")
+	if !strings.HasSuffix(prefix, ".*") || len(octets) > 3 {
+		return "", fmt.Errorf("invalid IP prefix: %q", prefix)
+	}
+	ip := make(net.IP, net.IPv4len)
+	for i, o := range octets {
+		var b int
+		if _, err := fmt.Sscanf(o, "%d", &b); err != nil || b < 0 || b > 255 || fmt.Sprint(b) != o {
+			return "", fmt.Errorf("invalid IP prefix: %q", prefix)
+		}
+		ip[i] = byte(b)
+	}
+	return fmt.Sprintf("%s/%d", ip, len(octets)*8), nil
+}
+
+// Contains reports whether the IP is in the set.
+func (s *IPSet) Contains(ip net.IP) bool {
+	if s == nil || ip == nil {
+		return false
+	}
+	if s.any {
+		return true
+	}
+	for _, n := range s.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	if len(s.prefixes) > 0 {
+		str := ip.String()
+		for _, prefix := range s.prefixes {
+			if strings.HasPrefix(str, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *IPSet) empty() bool {
+	return s == nil || (!s.any && len(s.nets) == 0 && len(s.prefixes) == 0)
+}
+
+// ContainsString reports whether the IP string is in the set.
+func (s *IPSet) ContainsString(ip string) bool {
+	return s.Contains(net.ParseIP(ip))
+}
+
+// IPFilter is the middleware that allows the IPs in the whitelist and not in
+// the blacklist, the lists can be reloaded while serving.
+type IPFilter struct {
+	realIP bool
+	mu     sync.RWMutex
+	allow  *IPSet
+	deny   *IPSet
+}
+
+// NewIPFilter creates the middleware that allows the IPs in the whitelist and
+// not in the blacklist, see ParseIPSet for the formats. An empty whitelist
+// allows no IP. If realIP is true, the client IP is ctx.RealIP(), otherwise ctx.IP().
+func NewIPFilter(whitelist, blacklist []string, realIP bool) (*IPFilter, error) {
+	f := &IPFilter{realIP: realIP}
+	if err := f.Reload(whitelist, blacklist); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload replaces the whitelist and the blacklist.
+func (f *IPFilter) Reload(whitelist, blacklist []string) error {
+	allow, err := ParseIPSet(whitelist)
+	if err != nil {
+		return err
+	}
+	deny, err := ParseIPSet(blacklist)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.allow, f.deny = allow, deny
+	f.mu.Unlock()
+	return nil
+}
+
+// Allowed reports whether the IP is allowed.
+func (f *IPFilter) Allowed(ip string) bool {
+	parsed := net.ParseIP(ip)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.allow.Contains(parsed) && !f.deny.Contains(parsed)
+}
+
+// Serve implements the Handler.
+func (f *IPFilter) Serve(ctx *Context) error {
+	var ip string
+	if f.realIP {
+		ip = ctx.RealIP()
+	} else {
+		ip = ctx.IP()
+	}
+	if f.Allowed(ip) {
+		return nil
+	}
+	ctx.Log().Warningf("[IPFilter] %s %s %s: not allowed", ip, ctx.Method(), ctx.Path())
+	if f.noAccess() {
+		ctx.Error(http.StatusForbidden, "no access")
+	} else {
+		ctx.Error(http.StatusForbidden, "not allow to access: "+ip)
+	}
+	return nil
+}
+
+// noAccess reports whether the whitelist is empty.
+func (f *IPFilter) noAccess() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.allow.empty()
+}
+
+// newIPFilter creates the IPFilter of a config section, such as 'apidoc'.
+// The invalid entries are matched as the string prefixes of the IPs with a
+// deprecation warning, as middleware.NewIPFilter and the old versions do.
+func (frame *Framework) newIPFilter(section string, whitelist, blacklist []string, realIP bool) *IPFilter {
+	return &IPFilter{
+		realIP: realIP,
+		allow:  frame.parseConfigIPSet(section+"::whitelist", whitelist),
+		deny:   frame.parseConfigIPSet(section+"::blacklist", blacklist),
+	}
+}
+
+func (frame *Framework) parseConfigIPSet(item string, list []string) *IPSet {
+	var valid, prefixes []string
+	for _, s := range list {
+		if _, err := ParseIPSet([]string{s}); err != nil {
+			frame.syslog.Warningf("The config item `%s` is deprecated: %s, matched as a prefix of the IPs, use a CIDR range instead", item, err.Error())
+			prefixes = append(prefixes, strings.TrimSuffix(strings.TrimSpace(s), "*"))
+			continue
+		}
+		valid = append(valid, s)
+	}
+	set, _ := ParseIPSet(valid)
+	set.prefixes = prefixes
+	return set
+}
+
+// APIdocIPFilter returns the IP filter of the API doc, to reload its lists
+// while serving, nil if it is not registered or not restricted.
+func (frame *Framework) APIdocIPFilter() *IPFilter {
+	return frame.apidocIPFilter
+}
+
+// LogAdminIPFilter returns the IP filter of the runtime log level control API,
+// to reload its lists while serving, nil if it is not registered or not restricted.
+func (frame *Framework) LogAdminIPFilter() *IPFilter {
+	return frame.logAdminIPFilter
+}
+
+// isTrustedProxy reports whether the peer IP is a trusted proxy.
+func (frame *Framework) isTrustedProxy(ip string) bool {
+	return frame.config.trustedProxies.ContainsString(ip)
+}
+
+// walkProxies returns the nearest untrusted IP of the proxy chain, such as
+// X-Forwarded-For, the peer IP if it is empty or invalid.
+func (frame *Framework) walkProxies(peer string, chain []string) string {
+	ip := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop := forwardedNodeIP(strings.TrimSpace(chain[i]))
+		if hop == "" {
+			break
+		}
+		ip = hop
+		if !frame.isTrustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// forwardedElement is an element of the RFC 7239 Forwarded header.
+type forwardedElement struct {
+	forIP string // empty if unknown or obfuscated
+	proto string
+}
+
+// parseForwarded parses the RFC 7239 Forwarded headers, in order.
+func parseForwarded(values []string) []forwardedElement {
+	var elems []forwardedElement
+	for _, value := range values {
+		for _, e := range strings.Split(value, ",") {
+			var elem forwardedElement
+			for _, pair := range strings.Split(e, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				v := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+				switch strings.ToLower(kv[0]) {
+				case "for":
+					elem.forIP = forwardedNodeIP(v)
+				case "proto":
+					elem.proto = strings.ToLower(v)
+				}
+			}
+			elems = append(elems, elem)
+		}
+	}
+	return elems
+}
+
+// forwardedNodeIP returns the IP of the node such as '192.0.2.43:47011' or
+// '[2001:db8:cafe::17]:4711', empty if it is 'unknown' or obfuscated.
+func forwardedNodeIP(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if i := strings.IndexByte(node, ']'); i > 0 {
+			node = node[1:i]
+		}
+	} else if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+	if net.ParseIP(node) == nil {
+		return ""
+	}
+	return node
+}
@@ -0,0 +1,134 @@
+package faygo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseIPSet(t *testing.T) {
+	set, err := ParseIPSet([]string{"10.*", "192.168.1.0/24", "202.122.246.170", "2001:db8::/32", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		ip       string
+		contains bool
+	}{
+		{"10.1.2.3", true},
+		{"100.1.2.3", false},
+		{"192.168.1.200", true},
+		{"192.168.2.1", false},
+		{"202.122.246.170", true},
+		{"202.122.246.171", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"::1", true},
+		{"invalid", false},
+	}
+	for _, test := range tests {
+		if got := set.ContainsString(test.ip); got != test.contains {
+			t.Errorf("Contains(%s): got %v, want %v", test.ip, got, test.contains)
+		}
+	}
+	for _, invalid := range []string{"192.16*", "1.2.3.4.*", "300.*", "10.0.0.0/33", "example.com"} {
+		if _, err := ParseIPSet([]string{invalid}); err == nil {
+			t.Errorf("expected an error of %q", invalid)
+		}
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	f, err := NewIPFilter([]string{"10.0.0.0/8", "fd00::/8"}, []string{"10.9.0.0/16"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, allowed := range map[string]bool{"10.1.0.1": true, "10.9.0.1": false, "fd00::1": true, "127.0.0.1": false} {
+		if f.Allowed(ip) != allowed {
+			t.Errorf("Allowed(%s): expected %v", ip, allowed)
+		}
+	}
+	if err = f.Reload([]string{"*"}, []string{"10.1.0.0/16"}); err != nil {
+		t.Fatal(err)
+	}
+	for ip, allowed := range map[string]bool{"10.1.0.1": false, "10.9.0.1": true, "127.0.0.1": true} {
+		if f.Allowed(ip) != allowed {
+			t.Errorf("Allowed(%s) after reload: expected %v", ip, allowed)
+		}
+	}
+	if err = f.Reload([]string{"bad"}, nil); err == nil || !f.Allowed("127.0.0.1") {
+		t.Error("expected the invalid reload rejected and the lists kept")
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	config := NewDefaultConfig()
+	config.APIdoc.Enable = false
+	config.TrustedProxies = []string{"10.0.0.0/8", "fd00::/8"}
+	frame := NewWithConfig(config, "realip-test")
+	frame.GET("/ip", HandlerFunc(func(ctx *Context) error {
+		return ctx.String(200, ctx.RealIP()+" "+ctx.Scheme())
+	}))
+	frame.build()
+
+	var tests = []struct {
+		remote string
+		header http.Header
+		result string
+	}{
+		// untrusted peers can not spoof
+		{"203.0.113.7:1234", http.Header{HeaderXRealIP: {"1.1.1.1"}, HeaderXForwardedProto: {"https"}}, "203.0.113.7 http"},
+		{"[2001:db8::7]:1234", http.Header{HeaderXForwardedFor: {"1.1.1.1"}}, "2001:db8::7 http"},
+		{"10.0.0.1:1234", http.Header{HeaderXRealIP: {"198.51.100.1"}}, "198.51.100.1 http"},
+		// X-Real-IP is not trusted over the chains
+		{"10.0.0.1:1234", http.Header{HeaderXRealIP: {"127.0.0.1"}, HeaderXForwardedFor: {"198.51.100.1"}}, "198.51.100.1 http"},
+		{"10.0.0.1:1234", http.Header{HeaderXRealIP: {"127.0.0.1"}, HeaderForwarded: {"for=198.51.100.1"}}, "198.51.100.1 http"},
+		// the spoofed leftmost hops are skipped
+		{"10.0.0.1:1234", http.Header{HeaderXForwardedFor: {"1.1.1.1, 198.51.100.1, 10.0.0.2"}, HeaderXForwardedProto: {"https"}}, "198.51.100.1 https"},
+		{"10.0.0.1:1234", http.Header{HeaderXForwardedFor: {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3 http"},
+		{"[fd00::1]:1234", http.Header{HeaderForwarded: {`for=1.1.1.1;proto=http, for="[2001:db8:cafe::17]:4711";proto=https`, "for=10.0.0.2;proto=http"}}, "2001:db8:cafe::17 https"},
+		{"10.0.0.1:1234", http.Header{HeaderForwarded: {"for=198.51.100.1:8080"}, HeaderXForwardedProto: {"HTTPS"}}, "198.51.100.1 https"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/ip", nil)
+		req.RemoteAddr = test.remote
+		for k, v := range test.header {
+			for _, s := range v {
+				req.Header.Add(k, s)
+			}
+		}
+		w := httptest.NewRecorder()
+		frame.ServeHTTP(w, req)
+		if w.Body.String() != test.result {
+			t.Errorf("%s %v: got %q, want %q", test.remote, test.header, w.Body.String(), test.result)
+		}
+	}
+}
+
+func TestConfigIPFilterPrefixes(t *testing.T) {
+	config := NewDefaultConfig()
+	config.APIdoc.Enable = false
+	config.LogAdmin.Enable = true
+	// the entries of the old versions are matched as prefixes instead of failing
+	config.LogAdmin.Whitelist = []string{"192.16*", "10.0.0.0/8"}
+	config.LogAdmin.Blacklist = []string{"192.168.9"}
+	frame := NewWithConfig(config, "config-ipfilter-test")
+	frame.build()
+	f := frame.LogAdminIPFilter()
+	if f == nil {
+		t.Fatal("expected the log admin IP filter")
+	}
+	for ip, allowed := range map[string]bool{
+		"192.168.1.1":  true,
+		"192.16.0.1":   true,
+		"192.169.0.1":  true,
+		"192.17.0.1":   false,
+		"192.168.90.1": false,
+		"10.1.2.3":     true,
+		"127.0.0.1":    false,
+	} {
+		if f.Allowed(ip) != allowed {
+			t.Errorf("Allowed(%s): expected %v", ip, allowed)
+		}
+	}
+}
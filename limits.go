@@ -0,0 +1,215 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faygo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// MaxBodySize overrides the config item `request_limit::max_body_size_mb` for the
+// node and its progeny, size is in bytes and 0 means not limited, such as a
+// small limit for the JSON APIs and a large one for the uploads.
+// note: it should be called before Run()
+func (mux *MuxAPI) MaxBodySize(size int64) *MuxAPI {
+	if size < 0 {
+		size = 0
+	}
+	mux.maxBodySize = &size
+	return mux
+}
+
+// the kinds of the requests rejected by the request limits
+const (
+	limitBodySize = iota
+	limitReadRate
+	limitHeaderSize
+	limitHeaderCount
+	limitKinds
+)
+
+var (
+	errBodyTooLarge = errors.New("request body too large")
+	errBodyTooSlow  = errors.New("request body read too slowly")
+)
+
+// connContextKey is the request context key of the net.Conn, to enforce the
+// minimum read rate and cut off the rejected bodies with the read deadlines.
+type connContextKey struct{}
+
+// connContext is the http.Server.ConnContext.
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
+
+// rejectByLimit denies the request exceeding a limit and logs it with the count of its kind.
+func (ctx *Context) rejectByLimit(kind int, status int, message string) {
+	n := atomic.AddInt64(&ctx.frame.limitRejections[kind], 1)
+	ctx.frame.syslog.Warningf("[Limit] %s %s %s: %s (%d rejected)", ctx.RealIP(), ctx.Method(), ctx.Path(), message, n)
+	if ctx.W.Committed() {
+		ctx.Stop()
+		return
+	}
+	// the rest of the body is not read
+	ctx.W.Header().Set("Connection", "close")
+	ctx.Error(status, message)
+}
+
+// checkHeaderLimits checks the config items `request_limit::max_header_bytes`
+// and `request_limit::max_header_count`.
+// The server itself rejects the headers far beyond max_header_bytes before they are logged.
+func (ctx *Context) checkHeaderLimits() bool {
+	conf := &ctx.frame.config.RequestLimit
+	count := 0
+	size := len("Host: \r\n") + len(ctx.R.Host)
+	for k, vv := range ctx.R.Header {
+		count += len(vv)
+		for _, v := range vv {
+			size += len(k) + len(v) + len(": \r\n")
+		}
+	}
+	if conf.MaxHeaderCount > 0 && count > conf.MaxHeaderCount {
+		ctx.rejectByLimit(limitHeaderCount, http.StatusRequestHeaderFieldsTooLarge,
+			"too many request header fields: "+strconv.Itoa(count))
+		return false
+	}
+	if size > conf.MaxHeaderBytes {
+		ctx.rejectByLimit(limitHeaderSize, http.StatusRequestHeaderFieldsTooLarge,
+			"request headers too large: "+strconv.Itoa(size)+" bytes")
+		return false
+	}
+	return true
+}
+
+// requestBody enforces the body size limit and the minimum read rate of the request body.
+type requestBody struct {
+	io.ReadCloser
+	ctx   *Context
+	limit int64    // 0 means not limited
+	n     int64    // the bytes read
+	err   error    // the rejection
+	conn  net.Conn // the connection of HTTP/1, nil for HTTP/2 whose connection is shared by the streams
+	// whether the minimum read rate is enforced
+	rate     bool
+	start    time.Time
+	deadline time.Time // the read deadline of the server to restore
+}
+
+// limitBody wraps the request body, the size limit is set after routing.
+func (frame *Framework) limitBody(ctx *Context) {
+	r := ctx.R
+	if r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	ctx.body = requestBody{ReadCloser: r.Body, ctx: ctx}
+	if conn, ok := r.Context().Value(connContextKey{}).(net.Conn); ok && r.ProtoMajor == 1 {
+		ctx.body.conn = conn
+		if frame.config.RequestLimit.MinReadRate > 0 {
+			ctx.body.rate = true
+			ctx.body.start = time.Now()
+			if frame.config.ReadTimeout > 0 {
+				ctx.body.deadline = ctx.body.start.Add(frame.config.ReadTimeout)
+			}
+		}
+	}
+	r.Body = &ctx.body
+}
+
+// limitBodySize sets the body size limit of the route, and rejects the request
+// if its Content-Length or the body read before routing exceeds it.
+func (ctx *Context) limitBodySize(limit int64) bool {
+	if limit <= 0 || ctx.body.ReadCloser == nil {
+		return true
+	}
+	ctx.body.limit = limit
+	if ctx.R.ContentLength > limit || ctx.body.n > limit {
+		ctx.body.reject(errBodyTooLarge)
+		return false
+	}
+	return true
+}
+
+// Read implements the io.Reader.
+func (b *requestBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	// read one more byte to find the excess
+	if b.limit > 0 && int64(len(p)) > b.limit-b.n+1 {
+		p = p[:b.limit-b.n+1]
+	}
+	if b.rate {
+		b.conn.SetReadDeadline(b.rateDeadline())
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if b.limit > 0 && b.n > b.limit {
+		n -= int(b.n - b.limit)
+		b.n = b.limit
+		b.reject(errBodyTooLarge)
+		return n, b.err
+	}
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() && b.rate {
+			b.reject(errBodyTooSlow)
+			return n, b.err
+		}
+		b.release()
+	}
+	return n, err
+}
+
+// rateDeadline returns the time when the body would be read slower than the minimum rate.
+func (b *requestBody) rateDeadline() time.Time {
+	conf := &b.ctx.frame.config.RequestLimit
+	d := b.start.Add(conf.MinReadRateGrace + time.Duration(float64(b.n+1)/float64(conf.MinReadRate)*float64(time.Second)))
+	if !b.deadline.IsZero() && b.deadline.Before(d) {
+		return b.deadline
+	}
+	return d
+}
+
+// release restores the read deadline of the server.
+func (b *requestBody) release() {
+	if b.rate {
+		b.conn.SetReadDeadline(b.deadline)
+		b.rate = false
+	}
+}
+
+// reject denies the request and fails the subsequent reads.
+func (b *requestBody) reject(err error) {
+	b.err = err
+	b.rate = false
+	if b.conn != nil {
+		// the server closes the connection instead of reading the rest of the body
+		b.conn.SetReadDeadline(time.Unix(1, 0))
+	}
+	switch err {
+	case errBodyTooLarge:
+		b.ctx.rejectByLimit(limitBodySize, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("%s: the limit is %d bytes", err.Error(), b.limit))
+	case errBodyTooSlow:
+		b.ctx.rejectByLimit(limitReadRate, http.StatusRequestTimeout,
+			fmt.Sprintf("%s: %d bytes in %s", err.Error(), b.n, time.Since(b.start).Truncate(time.Millisecond)))
+	}
+}
@@ -0,0 +1,122 @@
+package faygo
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chunkedReader hides the length of the body.
+type chunkedReader struct{ io.Reader }
+
+func TestMaxBodySize(t *testing.T) {
+	config := NewDefaultConfig()
+	config.APIdoc.Enable = false
+	config.PrintBody = true
+	config.RequestLimit.MaxBodySizeMB = 1
+	config.RequestLimit.MaxHeaderCount = 5
+	config.RequestLimit.MaxHeaderBytes = 256
+	frame := NewWithConfig(config, "limits-test")
+	echo := HandlerFunc(func(ctx *Context) error {
+		b, err := ioutil.ReadAll(ctx.R.Body)
+		if err != nil {
+			return err
+		}
+		return ctx.String(200, string(b))
+	})
+	frame.POST("/echo", echo)
+	frame.Group("small").MaxBodySize(10).POST("/echo", echo)
+	frame.Group("unlimited").MaxBodySize(0).POST("/echo", echo)
+	frame.build()
+
+	var tests = []struct {
+		path    string
+		body    io.Reader
+		headers int
+		code    int
+	}{
+		{"/echo", strings.NewReader(strings.Repeat("a", 100)), 0, 200},
+		{"/echo", strings.NewReader(strings.Repeat("a", MB+1)), 0, 413},
+		{"/small/echo", strings.NewReader("0123456789"), 0, 200},
+		{"/small/echo", strings.NewReader("0123456789a"), 0, 413},
+		{"/small/echo", chunkedReader{strings.NewReader("0123456789a")}, 0, 413},
+		{"/unlimited/echo", chunkedReader{strings.NewReader(strings.Repeat("a", MB+1))}, 0, 200},
+		{"/echo", nil, 6, 431},
+		{"/echo", nil, 0, 431}, // 256 bytes of the headers
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("POST", test.path, test.body)
+		if _, ok := test.body.(chunkedReader); ok {
+			req.ContentLength = -1
+		}
+		for j := 0; j < test.headers; j++ {
+			req.Header.Add("X-Test", "1")
+		}
+		if i == len(tests)-1 {
+			req.Header.Set("X-Large", strings.Repeat("a", 256))
+		}
+		w := httptest.NewRecorder()
+		frame.ServeHTTP(w, req)
+		if w.Code != test.code {
+			t.Errorf("#%d %s: got %d, want %d", i, test.path, w.Code, test.code)
+		}
+	}
+	if n := frame.limitRejections[limitBodySize]; n != 3 {
+		t.Errorf("expected 3 rejections of the body size, got %d", n)
+	}
+}
+
+func TestMinReadRate(t *testing.T) {
+	config := NewDefaultConfig()
+	config.APIdoc.Enable = false
+	config.RequestLimit.MinReadRate = 1000
+	config.RequestLimit.MinReadRateGrace = 100 * time.Millisecond
+	config.RequestLimit.MaxBodySizeMB = 1
+	frame := NewWithConfig(config, "min-read-rate-test")
+	frame.POST("/upload", HandlerFunc(func(ctx *Context) error {
+		b, err := ioutil.ReadAll(ctx.R.Body)
+		if err != nil {
+			return nil
+		}
+		return ctx.String(200, string(b))
+	}))
+	frame.build()
+	srv := httptest.NewUnstartedServer(frame)
+	srv.Config.ConnContext = connContext
+	srv.Start()
+	defer srv.Close()
+
+	// a fast client
+	resp, err := http.Post(srv.URL+"/upload", "text/plain", strings.NewReader(strings.Repeat("a", 10000)))
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("expected the fast client served, got %v %v", resp, err)
+	}
+	resp.Body.Close()
+
+	// send the headers and a little of the body, then stall
+	stall := func(contentLength string, code int) {
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		io.WriteString(conn, "POST /upload HTTP/1.1\r\nHost: example.com\r\nContent-Length: "+contentLength+"\r\n\r\n0123456789")
+		start := time.Now()
+		conn.SetReadDeadline(start.Add(5 * time.Second))
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != code || time.Since(start) > 2*time.Second {
+			t.Errorf("Content-Length %s: expected %d, got %d after %s", contentLength, code, resp.StatusCode, time.Since(start))
+		}
+	}
+	stall("10000", http.StatusRequestTimeout)
+	stall("2000000", http.StatusRequestEntityTooLarge)
+}
@@ -30,12 +30,25 @@ func NewLog() *logging.Logger {
 	return &newlog
 }
 
+// NewModuleLog gets a global logger with its own module name,
+// and registers it under that name for runtime level control.
+func NewModuleLog(module string) *logging.Logger {
+	newlog := NewLog()
+	newlog.Module = module
+	RegisterLogger(module, newlog)
+	return newlog
+}
+
 var (
 	consoleLogBackend = &logging.LogBackend{
 		Logger: log.New(color.NewColorableStdout(), "", 0),
 		Color:  true,
 	}
 	fileBackend *logging.FileBackend
+	// remote log shipping backends
+	syslogBackend *logging.SyslogBackend
+	shipBackend   *logging.NetworkBackend
+	httpBackend   *logging.HTTPBackend
 )
 
 func (global *GlobalVariables) initLogger() {
@@ -50,6 +63,7 @@ func (global *GlobalVariables) initLogger() {
 	} else {
 		os.MkdirAll(global.logDir, 0777)
 	}
+	global.initRemoteBackends()
 	consoleFormat := logging.MustStringFormatter("[%{time:2006/01/02 15:04:05.000}] %{message}")
 	consoleBackendLevel := logging.AddModuleLevel(logging.NewBackendFormatter(consoleLogBackend, consoleFormat))
 	level, err := logging.LogLevel(global.config.Log.ConsoleLevel)
@@ -76,6 +90,8 @@ func (global *GlobalVariables) initLogger() {
 		fileFormatString,
 	)
 	global.bizlog.ExtraCalldepth++
+	RegisterLogger("faygo.syslog", global.syslog)
+	RegisterLogger("faygo.bizlog", global.bizlog)
 }
 
 func (frame *Framework) initSysLogger() {
@@ -94,6 +110,7 @@ func (frame *Framework) initSysLogger() {
 		consoleFormat,
 		fileFormat,
 	)
+	RegisterLogger(frame.syslog.Module+".syslog", frame.syslog)
 }
 
 func (frame *Framework) initBizLogger() {
@@ -112,6 +129,7 @@ func (frame *Framework) initBizLogger() {
 		consoleFormat,
 		fileFormat,
 	)
+	RegisterLogger(frame.bizlog.Module+".bizlog", frame.bizlog)
 }
 
 func (global *GlobalVariables) newLogger(module string, consoleFormatString, fileFormatString string) *logging.Logger {
@@ -139,6 +157,27 @@ func (global *GlobalVariables) newLogger(module string, consoleFormatString, fil
 		backends = append(backends, fileBackendLevel)
 	}
 
+	for _, remote := range []struct {
+		enable  bool
+		level   string
+		backend logging.Backend
+	}{
+		{global.config.Log.SyslogEnable, global.config.Log.SyslogLevel, syslogBackend},
+		{global.config.Log.ShipEnable, global.config.Log.ShipLevel, shipBackend},
+		{global.config.Log.HTTPEnable, global.config.Log.HTTPLevel, httpBackend},
+	} {
+		if !remote.enable {
+			continue
+		}
+		remoteLevel, err := logging.LogLevel(remote.level)
+		if err != nil {
+			panic(err)
+		}
+		remoteBackendLevel := logging.AddModuleLevel(logging.NewBackendFormatter(remote.backend, fileFormat))
+		remoteBackendLevel.SetLevel(remoteLevel, "")
+		backends = append(backends, remoteBackendLevel)
+	}
+
 	newLog := logging.NewLogger(module)
 	switch len(backends) {
 	case 1:
@@ -148,3 +187,44 @@ func (global *GlobalVariables) newLogger(module string, consoleFormatString, fil
 	}
 	return newLog
 }
+
+func (global *GlobalVariables) initRemoteBackends() {
+	var err error
+	conf := global.config.Log
+	if conf.SyslogEnable {
+		facility, err := logging.SyslogFacility(conf.SyslogFacility)
+		if err != nil {
+			panic(err)
+		}
+		syslogBackend, err = logging.NewSyslogBackend(conf.SyslogNetwork, conf.SyslogAddr, facility, conf.SyslogTag, conf.RemoteBufferLen)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if conf.ShipEnable {
+		shipBackend, err = logging.NewNetworkBackend(conf.ShipNetwork, conf.ShipAddr, conf.RemoteBufferLen)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if conf.HTTPEnable {
+		httpBackend, err = logging.NewHTTPBackend(conf.HTTPURL, conf.HTTPBatchSize, conf.HTTPFlushInterval, conf.RemoteBufferLen)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// closeRemoteBackends sends the buffered records of the remote log shipping
+// backends, such as the last batch of the HTTP backend, and closes their connections.
+func closeRemoteBackends() {
+	if syslogBackend != nil {
+		syslogBackend.Close()
+	}
+	if shipBackend != nil {
+		shipBackend.Close()
+	}
+	if httpBackend != nil {
+		httpBackend.Close()
+	}
+}
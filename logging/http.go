@@ -0,0 +1,228 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Default settings of the HTTP batch backend.
+const (
+	DefaultHTTPBatchSize     = 100
+	DefaultHTTPFlushInterval = time.Second
+	DefaultHTTPMaxRetries    = 3
+)
+
+// HTTPBackend ships log records in batches to an HTTP collector.
+// Each batch is POSTed as newline-delimited JSON (application/x-ndjson),
+// one object per record with the fields time, level, module, host and message.
+// A batch is sent when it reaches BatchSize records or every FlushInterval.
+// Failed batches are retried with exponential backoff up to MaxRetries times,
+// then dropped. After Close, each batch left gets a single attempt.
+type HTTPBackend struct {
+	URL           string
+	Header        http.Header
+	Client        *http.Client
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	MinBackoff    time.Duration
+	MaxBackoff    time.Duration
+
+	hostname  string
+	queue     chan *httpEntry
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+	dropped   uint64
+	mu        sync.Mutex
+}
+
+type httpEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Module  string    `json:"module"`
+	Host    string    `json:"host,omitempty"`
+	Message string    `json:"message"`
+}
+
+// NewHTTPBackend creates a HTTPBackend which posts to url.
+// batchSize <= 0 means DefaultHTTPBatchSize, flushInterval <= 0 means
+// DefaultHTTPFlushInterval and bufferLen <= 0 means DefaultRemoteBufferLen.
+func NewHTTPBackend(url string, batchSize int, flushInterval time.Duration, bufferLen int) (*HTTPBackend, error) {
+	if len(url) == 0 {
+		return nil, errors.New("logging: HTTPBackend must have url")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultHTTPBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultHTTPFlushInterval
+	}
+	if bufferLen <= 0 {
+		bufferLen = DefaultRemoteBufferLen
+	}
+	hostname, _ := os.Hostname()
+	b := &HTTPBackend{
+		URL:           url,
+		Header:        http.Header{},
+		Client:        &http.Client{Timeout: 10 * time.Second},
+		BatchSize:     batchSize,
+		FlushInterval: flushInterval,
+		MaxRetries:    DefaultHTTPMaxRetries,
+		MinBackoff:    DefaultMinBackoff,
+		MaxBackoff:    DefaultMaxBackoff,
+		hostname:      hostname,
+		queue:         make(chan *httpEntry, bufferLen),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	go b.run()
+	return b, nil
+}
+
+// Log implements the Backend interface.
+func (b *HTTPBackend) Log(calldepth int, rec *Record) {
+	select {
+	case <-b.done:
+		return
+	default:
+	}
+	msg := colorRegexp.ReplaceAllString(rec.Formatted(calldepth+1, false), "")
+	for len(msg) > 0 && msg[len(msg)-1] == '\n' {
+		msg = msg[:len(msg)-1]
+	}
+	entry := &httpEntry{
+		Time:    rec.Time,
+		Level:   rec.Level.String(),
+		Module:  rec.Module,
+		Host:    b.hostname,
+		Message: msg,
+	}
+	select {
+	case b.queue <- entry:
+	default:
+		b.drop(1)
+	}
+}
+
+// Close sends the buffered records and stops the backend.
+func (b *HTTPBackend) Close() {
+	b.closeOnce.Do(func() {
+		close(b.done)
+		<-b.stopped
+	})
+}
+
+// Dropped returns the number of records discarded because the buffer was full
+// or the collector kept failing.
+func (b *HTTPBackend) Dropped() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+func (b *HTTPBackend) drop(n int) {
+	b.mu.Lock()
+	b.dropped += uint64(n)
+	b.mu.Unlock()
+}
+
+func (b *HTTPBackend) run() {
+	defer close(b.stopped)
+	ticker := time.NewTicker(b.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]*httpEntry, 0, b.BatchSize)
+	for {
+		select {
+		case entry := <-b.queue:
+			batch = append(batch, entry)
+			if len(batch) < b.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-b.done:
+			for len(b.queue) > 0 {
+				batch = append(batch, <-b.queue)
+				if len(batch) >= b.BatchSize {
+					b.send(batch)
+					batch = batch[:0]
+				}
+			}
+			if len(batch) > 0 {
+				b.send(batch)
+			}
+			return
+		}
+		b.send(batch)
+		batch = batch[:0]
+	}
+}
+
+// send posts the batch, retrying on failure. Once the backend is closed,
+// the next attempt is the last one and Close does not wait for the backoff.
+func (b *HTTPBackend) send(batch []*httpEntry) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range batch {
+		enc.Encode(entry)
+	}
+	body := buf.Bytes()
+	backoff := b.MinBackoff
+	for i := 0; ; i++ {
+		closed := b.closed()
+		err := b.post(body)
+		if err == nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "unable to ship %d log records to %s [error]%s\n", len(batch), b.URL, err.Error())
+		if closed || i >= b.MaxRetries {
+			b.drop(len(batch))
+			return
+		}
+		select {
+		case <-b.done:
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > b.MaxBackoff {
+			backoff = b.MaxBackoff
+		}
+	}
+}
+
+func (b *HTTPBackend) closed() bool {
+	select {
+	case <-b.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *HTTPBackend) post(body []byte) error {
+	req, err := http.NewRequest("POST", b.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range b.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
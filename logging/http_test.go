@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPBackend(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		entries []httpEntry
+		batches int
+		failed  bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !failed {
+			// the first attempt fails to exercise the retry
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/x-ndjson" || r.Header.Get("Authorization") != "token" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		batches++
+		s := bufio.NewScanner(r.Body)
+		for s.Scan() {
+			var e httpEntry
+			if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+				t.Error(err)
+			}
+			entries = append(entries, e)
+		}
+	}))
+	defer srv.Close()
+
+	backend, err := NewHTTPBackend(srv.URL, 2, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.MinBackoff = 10 * time.Millisecond
+	backend.Header.Set("Authorization", "token")
+	log := NewLogger("TestHTTPBackend")
+	log.SetBackend(AddModuleLevel(NewBackendFormatter(backend, MustStringFormatter("%{message}"))))
+	log.Info("one")
+	log.Warning("two")
+	log.Error("three")
+	// the full batch is retried before Close, which makes a single attempt
+	for i := 0; i < 500; i++ {
+		mu.Lock()
+		n := len(entries)
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	backend.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(entries) != 3 || batches != 2 {
+		t.Fatalf("expected 3 entries in 2 batches, got %d in %d", len(entries), batches)
+	}
+	if entries[1].Message != "two" || entries[1].Level != "WARNING" || entries[1].Module != "TestHTTPBackend" {
+		t.Errorf("unexpected entry %+v", entries[1])
+	}
+	if backend.Dropped() != 0 {
+		t.Errorf("unexpected dropped records: %d", backend.Dropped())
+	}
+}
+
+func TestHTTPBackendCloseFailing(t *testing.T) {
+	attempts := make(chan struct{}, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts <- struct{}{}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	backend, err := NewHTTPBackend(srv.URL, 2, time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.MinBackoff = time.Hour
+	backend.MaxBackoff = time.Hour
+	log := NewLogger("TestHTTPBackendCloseFailing")
+	log.SetBackend(AddModuleLevel(NewBackendFormatter(backend, MustStringFormatter("%{message}"))))
+	log.Info("one")
+	log.Info("two")
+	log.Info("three")
+	// the first batch is waiting for the backoff
+	select {
+	case <-attempts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the first batch sent")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		backend.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Close to return while the collector is failing")
+	}
+	if backend.Dropped() != 3 {
+		t.Errorf("expected the 3 records dropped, got %d", backend.Dropped())
+	}
+	// one final attempt for each batch
+	if n := len(attempts); n != 2 {
+		t.Errorf("expected 2 more attempts, got %d", n)
+	}
+}
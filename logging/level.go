@@ -66,6 +66,7 @@ type LeveledBackend interface {
 }
 
 type moduleLeveled struct {
+	mu        sync.RWMutex
 	levels    map[string]Level
 	backend   Backend
 	formatter Formatter
@@ -88,6 +89,8 @@ func AddModuleLevel(backend Backend) LeveledBackend {
 
 // GetLevel returns the log level for the given module.
 func (l *moduleLeveled) GetLevel(module string) Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 	level, exists := l.levels[module]
 	if exists == false {
 		level, exists = l.levels[""]
@@ -101,7 +104,31 @@ func (l *moduleLeveled) GetLevel(module string) Level {
 
 // SetLevel sets the log level for the given module.
 func (l *moduleLeveled) SetLevel(level Level, module string) {
+	l.mu.Lock()
 	l.levels[module] = level
+	l.mu.Unlock()
+}
+
+// ResetLevel removes the log level of the given module, so that the default
+// level applies again.
+func (l *moduleLeveled) ResetLevel(module string) {
+	l.mu.Lock()
+	delete(l.levels, module)
+	l.mu.Unlock()
+}
+
+// levelResetter is implemented by the leveled backends which can remove
+// a module level.
+type levelResetter interface {
+	ResetLevel(string)
+}
+
+// ResetLevel removes the log level of the given module from the backend,
+// if the backend supports it.
+func ResetLevel(backend LeveledBackend, module string) {
+	if r, ok := backend.(levelResetter); ok {
+		r.ResetLevel(module)
+	}
 }
 
 // IsEnabledFor will return true if logging is enabled for the given module.
@@ -74,3 +74,31 @@ func TestLevelModuleLevel(t *testing.T) {
 		}
 	}
 }
+
+func TestLoggerSetLevel(t *testing.T) {
+	leveled1 := AddModuleLevel(NewMemoryBackend(8))
+	leveled1.SetLevel(NOTICE, "")
+	leveled2 := AddModuleLevel(NewMemoryBackend(8))
+	leveled2.SetLevel(ERROR, "")
+	multi := MultiLogger(leveled1, leveled2)
+
+	log := NewLogger("foo")
+	log.SetBackend(multi)
+	other := NewLogger("bar")
+	other.SetBackend(multi)
+
+	if log.GetLevel() != NOTICE {
+		t.Errorf("unexpected initial level: %s", log.GetLevel())
+	}
+	log.SetLevel(DEBUG)
+	if log.GetLevel() != DEBUG || leveled2.GetLevel("foo") != DEBUG {
+		t.Errorf("level not propagated: %s", log.GetLevel())
+	}
+	if other.GetLevel() != NOTICE {
+		t.Errorf("other module changed: %s", other.GetLevel())
+	}
+	log.ResetLevel()
+	if leveled1.GetLevel("foo") != NOTICE || leveled2.GetLevel("foo") != ERROR {
+		t.Errorf("levels not reset: %s %s", leveled1.GetLevel("foo"), leveled2.GetLevel("foo"))
+	}
+}
@@ -141,6 +141,30 @@ func (l *Logger) IsEnabledFor(level Level) bool {
 	return defaultBackend.IsEnabledFor(level, l.Module)
 }
 
+func (l *Logger) leveledBackend() LeveledBackend {
+	if l.haveBackend {
+		return l.backend
+	}
+	return defaultBackend
+}
+
+// GetLevel returns the level of the logger's module on its backend.
+func (l *Logger) GetLevel() Level {
+	return l.leveledBackend().GetLevel(l.Module)
+}
+
+// SetLevel sets the level of the logger's module on its backend.
+// Other loggers sharing the backend with a different module are not affected.
+func (l *Logger) SetLevel(level Level) {
+	l.leveledBackend().SetLevel(level, l.Module)
+}
+
+// ResetLevel removes the level set by SetLevel,
+// so the default levels of the backend apply again.
+func (l *Logger) ResetLevel() {
+	ResetLevel(l.leveledBackend(), l.Module)
+}
+
 // Close waits until all records in the buffered channel have been processed and close service.
 func (l *Logger) Close() {
 	l.lock.Lock()
@@ -58,6 +58,13 @@ func (b *multiLogger) SetLevel(level Level, module string) {
 	}
 }
 
+// ResetLevel removes the module level from all backends.
+func (b *multiLogger) ResetLevel(module string) {
+	for _, backend := range b.backends {
+		ResetLevel(backend, module)
+	}
+}
+
 // IsEnabledFor returns true if any of the backends are enabled for it.
 func (b *multiLogger) IsEnabledFor(level Level, module string) bool {
 	for _, backend := range b.backends {
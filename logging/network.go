@@ -0,0 +1,231 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Default settings of the remote log backends.
+const (
+	DefaultRemoteBufferLen = 1024
+	DefaultDialTimeout     = 5 * time.Second
+	DefaultMinBackoff      = 100 * time.Millisecond
+	DefaultMaxBackoff      = 30 * time.Second
+)
+
+// NetworkBackend ships formatted log lines to a remote collector over
+// tcp, udp or unix sockets.
+// Messages are buffered in memory and written asynchronously, the connection
+// is re-established with exponential backoff when it fails.
+// When the buffer is full, new messages are dropped rather than blocking the caller.
+type NetworkBackend struct {
+	shipper *shipper
+}
+
+// NewNetworkBackend creates a NetworkBackend which writes newline-delimited
+// log lines to the given address.
+// network is one of tcp, tcp4, tcp6, udp, udp4, udp6, unix or unixgram.
+// bufferLen <= 0 means DefaultRemoteBufferLen.
+func NewNetworkBackend(network, addr string, bufferLen int) (*NetworkBackend, error) {
+	if err := checkNetwork(network, addr); err != nil {
+		return nil, err
+	}
+	return &NetworkBackend{
+		shipper: newShipper(network, addr, bufferLen, func(msg []byte, _ bool) []byte {
+			if len(msg) == 0 || msg[len(msg)-1] != '\n' {
+				msg = append(msg, '\n')
+			}
+			return msg
+		}),
+	}, nil
+}
+
+// Log implements the Backend interface.
+func (b *NetworkBackend) Log(calldepth int, rec *Record) {
+	b.shipper.push(colorRegexp.ReplaceAll([]byte(rec.Formatted(calldepth+1, false)), []byte{}))
+}
+
+// Close flushes the buffered messages and closes the connection.
+func (b *NetworkBackend) Close() {
+	b.shipper.close()
+}
+
+// Dropped returns the number of messages discarded because the buffer was full
+// or the collector could not be reached.
+func (b *NetworkBackend) Dropped() uint64 {
+	return b.shipper.droppedCount()
+}
+
+func checkNetwork(network, addr string) error {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6", "unix", "unixgram":
+	default:
+		return fmt.Errorf("logging: unsupported network %q", network)
+	}
+	if len(addr) == 0 {
+		return errors.New("logging: remote backend must have address")
+	}
+	return nil
+}
+
+func isStreamNetwork(network string) bool {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		return true
+	}
+	return false
+}
+
+// shipper is the asynchronous, reconnecting writer shared by the socket backends.
+type shipper struct {
+	network     string
+	addr        string
+	stream      bool
+	dialTimeout time.Duration
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	// encode frames a message before writing, stream reports whether
+	// the connection is stream oriented.
+	encode func(msg []byte, stream bool) []byte
+
+	queue     chan []byte
+	closeOnce sync.Once
+	done      chan struct{}
+	stopped   chan struct{}
+
+	conn    net.Conn
+	dropped uint64
+	mu      sync.Mutex
+}
+
+func newShipper(network, addr string, bufferLen int, encode func([]byte, bool) []byte) *shipper {
+	if bufferLen <= 0 {
+		bufferLen = DefaultRemoteBufferLen
+	}
+	s := &shipper{
+		network:     network,
+		addr:        addr,
+		stream:      isStreamNetwork(network),
+		dialTimeout: DefaultDialTimeout,
+		minBackoff:  DefaultMinBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+		encode:      encode,
+		queue:       make(chan []byte, bufferLen),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *shipper) push(msg []byte) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	select {
+	case s.queue <- msg:
+	default:
+		s.drop(1)
+	}
+}
+
+func (s *shipper) drop(n uint64) {
+	s.mu.Lock()
+	s.dropped += n
+	s.mu.Unlock()
+}
+
+func (s *shipper) droppedCount() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+func (s *shipper) run() {
+	defer close(s.stopped)
+	backoff := s.minBackoff
+	for {
+		var msg []byte
+		select {
+		case msg = <-s.queue:
+		case <-s.done:
+			s.flush()
+			return
+		}
+		for !s.write(msg) {
+			select {
+			case <-time.After(backoff):
+			case <-s.done:
+				s.drop(1)
+				s.flush()
+				return
+			}
+			if backoff *= 2; backoff > s.maxBackoff {
+				backoff = s.maxBackoff
+			}
+		}
+		backoff = s.minBackoff
+	}
+}
+
+// flush writes the remaining messages once, without retrying.
+func (s *shipper) flush() {
+	for {
+		select {
+		case msg := <-s.queue:
+			if !s.write(msg) {
+				s.drop(uint64(len(s.queue)) + 1)
+				s.closeConn()
+				return
+			}
+		default:
+			s.closeConn()
+			return
+		}
+	}
+}
+
+// write writes one message, dialing when there is no connection.
+// It returns false if the message should be retried.
+func (s *shipper) write(msg []byte) bool {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.addr, s.dialTimeout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to dial log collector %s://%s [error]%s\n", s.network, s.addr, err.Error())
+			return false
+		}
+		s.conn = conn
+	}
+	_, err := s.conn.Write(s.encode(msg, s.stream))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to ship log to %s://%s [error]%s\n", s.network, s.addr, err.Error())
+		s.closeConn()
+		// datagrams are not retried, the collector may simply be absent
+		if !s.stream {
+			s.drop(1)
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+func (s *shipper) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *shipper) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		<-s.stopped
+	})
+}
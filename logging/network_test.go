@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNetworkBackendTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 4)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s := bufio.NewScanner(conn)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+	}()
+
+	backend, err := NewNetworkBackend("tcp", ln.Addr().String(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewLogger("TestNetworkBackendTCP")
+	log.SetBackend(AddModuleLevel(NewBackendFormatter(backend, MustStringFormatter("%{level} %{message}"))))
+	log.Info("first")
+	log.Error("second")
+	backend.Close()
+
+	for _, expected := range []string{"INFO first", "ERROR second"} {
+		select {
+		case line := <-lines:
+			if line != expected {
+				t.Errorf("expected %q, got %q", expected, line)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timeout waiting for %q", expected)
+		}
+	}
+}
+
+func TestNetworkBackendReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	backend, err := NewNetworkBackend("tcp", addr, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewLogger("TestNetworkBackendReconnect")
+	log.SetBackend(AddModuleLevel(NewBackendFormatter(backend, MustStringFormatter("%{message}"))))
+	log.Info("queued while down")
+
+	time.Sleep(50 * time.Millisecond)
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	ln.(*net.TCPListener).SetDeadline(time.Now().Add(3 * time.Second))
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "queued while down\n" {
+		t.Errorf("unexpected line %q", line)
+	}
+	backend.Close()
+}
+
+func TestNetworkBackendInvalid(t *testing.T) {
+	if _, err := NewNetworkBackend("ip", "127.0.0.1:514", 0); err == nil {
+		t.Error("expected error for unsupported network")
+	}
+	if _, err := NewNetworkBackend("udp", "", 0); err == nil {
+		t.Error("expected error for empty address")
+	}
+}
@@ -0,0 +1,169 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Facility is the syslog facility code.
+type Facility int
+
+// Syslog facilities, see RFC 5424 section 6.2.1.
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthpriv
+	FacilityFtp
+	_
+	_
+	_
+	_
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+var facilityNames = map[string]Facility{
+	"kern":     FacilityKern,
+	"user":     FacilityUser,
+	"mail":     FacilityMail,
+	"daemon":   FacilityDaemon,
+	"auth":     FacilityAuth,
+	"syslog":   FacilitySyslog,
+	"lpr":      FacilityLpr,
+	"news":     FacilityNews,
+	"uucp":     FacilityUucp,
+	"cron":     FacilityCron,
+	"authpriv": FacilityAuthpriv,
+	"ftp":      FacilityFtp,
+	"local0":   FacilityLocal0,
+	"local1":   FacilityLocal1,
+	"local2":   FacilityLocal2,
+	"local3":   FacilityLocal3,
+	"local4":   FacilityLocal4,
+	"local5":   FacilityLocal5,
+	"local6":   FacilityLocal6,
+	"local7":   FacilityLocal7,
+}
+
+// SyslogFacility returns the facility from a string representation,
+// such as "user" or "local0".
+func SyslogFacility(name string) (Facility, error) {
+	if f, ok := facilityNames[name]; ok {
+		return f, nil
+	}
+	return FacilityUser, fmt.Errorf("logging: invalid syslog facility %q", name)
+}
+
+// severities maps the log levels to syslog severities.
+var severities = []int{
+	CRITICAL: 2,
+	ERROR:    3,
+	WARNING:  4,
+	NOTICE:   5,
+	INFO:     6,
+	DEBUG:    7,
+}
+
+// SyslogBackend sends RFC 5424 messages to a syslog server over udp, tcp or
+// unix sockets.
+// Stream transports use the octet-counting framing of RFC 6587.
+// The module of the record is used as MSGID.
+type SyslogBackend struct {
+	Facility Facility
+	Hostname string
+	AppName  string
+	shipper  *shipper
+}
+
+// NewSyslogBackend creates a SyslogBackend.
+// network is one of tcp, tcp4, tcp6, udp, udp4, udp6, unix or unixgram.
+// An empty appName means the executable name; bufferLen <= 0 means DefaultRemoteBufferLen.
+func NewSyslogBackend(network, addr string, facility Facility, appName string, bufferLen int) (*SyslogBackend, error) {
+	if err := checkNetwork(network, addr); err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	if len(appName) == 0 {
+		appName = filepath.Base(os.Args[0])
+	}
+	return &SyslogBackend{
+		Facility: facility,
+		Hostname: hostname,
+		AppName:  appName,
+		shipper: newShipper(network, addr, bufferLen, func(msg []byte, stream bool) []byte {
+			if !stream {
+				return msg
+			}
+			return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}),
+	}, nil
+}
+
+// Log implements the Backend interface.
+func (b *SyslogBackend) Log(calldepth int, rec *Record) {
+	msg := colorRegexp.ReplaceAll([]byte(rec.Formatted(calldepth+1, false)), []byte{})
+	b.shipper.push(b.encode(rec, msg))
+}
+
+// Close flushes the buffered messages and closes the connection.
+func (b *SyslogBackend) Close() {
+	b.shipper.close()
+}
+
+// Dropped returns the number of messages discarded because the buffer was full
+// or the server could not be reached.
+func (b *SyslogBackend) Dropped() uint64 {
+	return b.shipper.droppedCount()
+}
+
+// encode builds the RFC 5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID - MSG
+func (b *SyslogBackend) encode(rec *Record, msg []byte) []byte {
+	severity := severities[DEBUG]
+	if int(rec.Level) >= 0 && int(rec.Level) < len(severities) {
+		severity = severities[rec.Level]
+	}
+	for len(msg) > 0 && msg[len(msg)-1] == '\n' {
+		msg = msg[:len(msg)-1]
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s %d %s - ",
+		int(b.Facility)*8+severity,
+		rec.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogField(b.Hostname, 255),
+		syslogField(b.AppName, 48),
+		os.Getpid(),
+		syslogField(rec.Module, 32),
+	)
+	return append([]byte(header), msg...)
+}
+
+// syslogField returns s limited to printable US-ASCII and maxLen,
+// or the NILVALUE "-" when s is empty.
+func syslogField(s string, maxLen int) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < maxLen; i++ {
+		if c := s[i]; c > 32 && c < 127 {
+			b = append(b, c)
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"bufio"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var rfc5424Regexp = regexp.MustCompile(`^<(\d+)>1 \S+ \S+ myapp \d+ TestSyslog - (.*)$`)
+
+func TestSyslogBackendUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	backend, err := NewSyslogBackend("udp", pc.LocalAddr().String(), FacilityLocal0, "myapp", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewLogger("TestSyslog")
+	log.SetBackend(AddModuleLevel(NewBackendFormatter(backend, MustStringFormatter("%{message}"))))
+	log.Warning("disk almost full")
+	defer backend.Close()
+
+	pc.SetReadDeadline(time.Now().Add(3 * time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := rfc5424Regexp.FindStringSubmatch(string(buf[:n]))
+	if m == nil {
+		t.Fatalf("not a RFC 5424 message: %q", buf[:n])
+	}
+	// local0(16)*8 + warning(4)
+	if m[1] != "132" {
+		t.Errorf("expected PRI 132, got %s", m[1])
+	}
+	if m[2] != "disk almost full" {
+		t.Errorf("unexpected MSG %q", m[2])
+	}
+}
+
+func TestSyslogBackendTCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	msgs := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			size, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(size))
+			if err != nil {
+				return
+			}
+			b := make([]byte, n)
+			if _, err = r.Read(b); err != nil {
+				return
+			}
+			msgs <- string(b)
+		}
+	}()
+
+	backend, err := NewSyslogBackend("tcp", ln.Addr().String(), FacilityUser, "myapp", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewLogger("TestSyslog")
+	log.SetBackend(AddModuleLevel(NewBackendFormatter(backend, MustStringFormatter("%{message}"))))
+	log.Critical("multi\nline")
+	log.Debug("next")
+	backend.Close()
+
+	for _, expected := range []string{"<10>1 ", "<15>1 "} {
+		select {
+		case msg := <-msgs:
+			if !strings.HasPrefix(msg, expected) {
+				t.Errorf("expected prefix %q, got %q", expected, msg)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestSyslogFacility(t *testing.T) {
+	if f, err := SyslogFacility("local7"); err != nil || f != FacilityLocal7 {
+		t.Errorf("local7: %v %v", f, err)
+	}
+	if _, err := SyslogFacility("nope"); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faygo
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andeya/faygo/logging"
+)
+
+type (
+	// LoggerLevel is the runtime level information of a registered logger.
+	LoggerLevel struct {
+		Name     string     `json:"name"`
+		Module   string     `json:"module"`
+		Level    string     `json:"level"`
+		RevertAt *time.Time `json:"revert_at,omitempty"`
+	}
+	registeredLogger struct {
+		name   string
+		logger *logging.Logger
+		// level set without time limit, nil means the backend defaults
+		base *logging.Level
+		// pending revert of a temporary level
+		revert   *time.Timer
+		revertAt time.Time
+	}
+)
+
+var loggerRegistry = struct {
+	list []*registeredLogger
+	sync.Mutex
+}{}
+
+// RegisterLogger registers a logger whose level can be changed at runtime
+// by name, such as through the log admin API.
+// Registering an existing name replaces the previous logger.
+func RegisterLogger(name string, logger *logging.Logger) {
+	loggerRegistry.Lock()
+	defer loggerRegistry.Unlock()
+	for _, r := range loggerRegistry.list {
+		if r.name == name {
+			r.logger = logger
+			return
+		}
+	}
+	loggerRegistry.list = append(loggerRegistry.list, &registeredLogger{name: name, logger: logger})
+}
+
+// LoggerLevels returns the current levels of the registered loggers.
+func LoggerLevels() []LoggerLevel {
+	loggerRegistry.Lock()
+	defer loggerRegistry.Unlock()
+	levels := make([]LoggerLevel, 0, len(loggerRegistry.list))
+	for _, r := range loggerRegistry.list {
+		l := LoggerLevel{
+			Name:   r.name,
+			Module: r.logger.Module,
+			Level:  strings.ToLower(r.logger.GetLevel().String()),
+		}
+		if r.revert != nil {
+			revertAt := r.revertAt
+			l.RevertAt = &revertAt
+		}
+		levels = append(levels, l)
+	}
+	return levels
+}
+
+// SetLoggerLevel changes the level of the registered logger.
+// If ttl > 0, the level reverts to the previous one after ttl.
+func SetLoggerLevel(name string, level logging.Level, ttl time.Duration) error {
+	loggerRegistry.Lock()
+	defer loggerRegistry.Unlock()
+	r := getRegisteredLogger(name)
+	if r == nil {
+		return fmt.Errorf("logger not found: %s", name)
+	}
+	r.stopRevert()
+	r.logger.SetLevel(level)
+	if ttl <= 0 {
+		r.base = &level
+		return nil
+	}
+	r.revertAt = time.Now().Add(ttl)
+	var timer *time.Timer
+	timer = time.AfterFunc(ttl, func() {
+		loggerRegistry.Lock()
+		defer loggerRegistry.Unlock()
+		if r.revert != timer {
+			return
+		}
+		r.revert = nil
+		r.restore()
+	})
+	r.revert = timer
+	return nil
+}
+
+// ResetLoggerLevel restores the configured level of the registered logger.
+func ResetLoggerLevel(name string) error {
+	loggerRegistry.Lock()
+	defer loggerRegistry.Unlock()
+	r := getRegisteredLogger(name)
+	if r == nil {
+		return fmt.Errorf("logger not found: %s", name)
+	}
+	r.stopRevert()
+	r.base = nil
+	r.restore()
+	return nil
+}
+
+func getRegisteredLogger(name string) *registeredLogger {
+	for _, r := range loggerRegistry.list {
+		if r.name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+func (r *registeredLogger) stopRevert() {
+	if r.revert != nil {
+		r.revert.Stop()
+		r.revert = nil
+	}
+}
+
+func (r *registeredLogger) restore() {
+	if r.base != nil {
+		r.logger.SetLevel(*r.base)
+	} else {
+		r.logger.ResetLevel()
+	}
+}
+
+// register the log level admin router.
+func (frame *Framework) regLogAdmin() {
+	conf := frame.config.LogAdmin
+	var handlers []Handler
+	if !conf.NoLimit {
+		frame.logAdminIPFilter = frame.newIPFilter("log_admin", conf.Whitelist, conf.Blacklist, conf.RealIP)
+		handlers = append(handlers, frame.logAdminIPFilter)
+	}
+	frame.MuxAPI.NamedGET("LogAdmin-Levels", conf.Path, append(handlers, newLogLevelsHandler())...)
+	frame.MuxAPI.NamedPUT("LogAdmin-SetLevel", conf.Path, append(handlers, newSetLogLevelHandler())...)
+
+	tip := `LogAdmin's URL path is '` + conf.Path
+	if conf.NoLimit {
+		frame.syslog.Criticalf(tip + `' [free access]`)
+	} else if len(conf.Whitelist) == 0 {
+		frame.syslog.Criticalf(tip + `' [no access]`)
+	} else if conf.RealIP {
+		frame.syslog.Criticalf(tip + `' [check real ip for filter]`)
+	} else {
+		frame.syslog.Criticalf(tip + `' [check direct ip for filter]`)
+	}
+}
+
+func newLogLevelsHandler() HandlerFunc {
+	return func(ctx *Context) error {
+		return ctx.JSON(http.StatusOK, LoggerLevels(), true)
+	}
+}
+
+// newSetLogLevelHandler changes a logger level with the parameters:
+// name: the registered logger name;
+// level: critical|error|warning|notice|info|debug, empty means restoring the configured level;
+// ttl: optional duration after which the level reverts, such as 10m.
+func newSetLogLevelHandler() HandlerFunc {
+	return func(ctx *Context) error {
+		name := ctx.BizParam("name")
+		levelStr := ctx.BizParam("level")
+		if len(levelStr) == 0 {
+			if err := ResetLoggerLevel(name); err != nil {
+				return ctx.JSONMsg(http.StatusNotFound, http.StatusNotFound, err.Error())
+			}
+			ctx.frame.syslog.Warningf("[LogAdmin] %s reset logger level: %s", ctx.IP(), name)
+			return ctx.JSON(http.StatusOK, LoggerLevels(), true)
+		}
+		level, err := logging.LogLevel(levelStr)
+		if err != nil {
+			return ctx.JSONMsg(http.StatusBadRequest, http.StatusBadRequest, err.Error())
+		}
+		var ttl time.Duration
+		if s := ctx.BizParam("ttl"); len(s) > 0 {
+			if ttl, err = time.ParseDuration(s); err != nil {
+				return ctx.JSONMsg(http.StatusBadRequest, http.StatusBadRequest, err.Error())
+			}
+		}
+		if err = SetLoggerLevel(name, level, ttl); err != nil {
+			return ctx.JSONMsg(http.StatusNotFound, http.StatusNotFound, err.Error())
+		}
+		ctx.frame.syslog.Warningf("[LogAdmin] %s set logger level: %s=%s ttl=%s", ctx.IP(), name, level, ttl)
+		return ctx.JSON(http.StatusOK, LoggerLevels(), true)
+	}
+}
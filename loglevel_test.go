@@ -0,0 +1,141 @@
+package faygo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andeya/faygo/logging"
+)
+
+func TestSetLoggerLevel(t *testing.T) {
+	backend := logging.AddModuleLevel(logging.NewMemoryBackend(8))
+	backend.SetLevel(logging.WARNING, "")
+	log := logging.NewLogger("TestSetLoggerLevel")
+	log.SetBackend(backend)
+	RegisterLogger("test.loglevel", log)
+
+	if err := SetLoggerLevel("test.nologger", logging.DEBUG, 0); err == nil {
+		t.Fatal("expected error for unknown logger")
+	}
+	if err := SetLoggerLevel("test.loglevel", logging.ERROR, 0); err != nil {
+		t.Fatal(err)
+	}
+	if log.GetLevel() != logging.ERROR {
+		t.Fatalf("expected ERROR, got %s", log.GetLevel())
+	}
+	if err := SetLoggerLevel("test.loglevel", logging.DEBUG, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if log.GetLevel() != logging.DEBUG || levelOf("test.loglevel").RevertAt == nil {
+		t.Fatalf("expected temporary DEBUG, got %+v", levelOf("test.loglevel"))
+	}
+	time.Sleep(100 * time.Millisecond)
+	if log.GetLevel() != logging.ERROR || levelOf("test.loglevel").RevertAt != nil {
+		t.Fatalf("expected revert to ERROR, got %+v", levelOf("test.loglevel"))
+	}
+	if err := ResetLoggerLevel("test.loglevel"); err != nil {
+		t.Fatal(err)
+	}
+	if log.GetLevel() != logging.WARNING {
+		t.Fatalf("expected configured WARNING, got %s", log.GetLevel())
+	}
+}
+
+func TestLogAdmin(t *testing.T) {
+	backend := logging.AddModuleLevel(logging.NewMemoryBackend(8))
+	backend.SetLevel(logging.WARNING, "")
+	log := logging.NewLogger("TestLogAdmin")
+	log.SetBackend(backend)
+	RegisterLogger("test.logadmin", log)
+
+	config := NewDefaultConfig()
+	config.APIdoc.Enable = false
+	config.LogAdmin.Enable = true
+	config.LogAdmin.Whitelist = []string{"10.0.0.0/8"}
+	frame := NewWithConfig(config, "logadmin-test")
+	frame.build()
+	call := func(method, query, remote string) (int, []LoggerLevel) {
+		req := httptest.NewRequest(method, "/loglevel"+query, nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		frame.ServeHTTP(w, req)
+		var levels []LoggerLevel
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &levels); err != nil {
+				t.Fatalf("%s %s: %v: %s", method, query, err, w.Body.String())
+			}
+		}
+		return w.Code, levels
+	}
+	find := func(levels []LoggerLevel) LoggerLevel {
+		for _, l := range levels {
+			if l.Name == "test.logadmin" {
+				return l
+			}
+		}
+		return LoggerLevel{}
+	}
+
+	// the IP whitelist
+	for _, method := range []string{"GET", "PUT"} {
+		if code, _ := call(method, "?name=test.logadmin&level=debug", "203.0.113.7:1234"); code != http.StatusForbidden {
+			t.Fatalf("%s: expected 403 out of the whitelist, got %d", method, code)
+		}
+	}
+	if log.GetLevel() != logging.WARNING {
+		t.Fatalf("expected the level unchanged, got %s", log.GetLevel())
+	}
+	if code, levels := call("GET", "", "10.0.0.1:1234"); code != http.StatusOK || find(levels).Level != "warning" {
+		t.Fatalf("expected the levels, got %d %+v", code, levels)
+	}
+
+	for _, test := range []struct {
+		query string
+		code  int
+	}{
+		{"?name=test.logadmin&level=verbose", http.StatusBadRequest},
+		{"?name=test.logadmin&level=debug&ttl=soon", http.StatusBadRequest},
+		{"?name=test.nologger&level=debug", http.StatusNotFound},
+		{"?name=test.nologger", http.StatusNotFound},
+	} {
+		if code, _ := call("PUT", test.query, "10.0.0.1:1234"); code != test.code {
+			t.Errorf("%s: expected %d, got %d", test.query, test.code, code)
+		}
+	}
+	if log.GetLevel() != logging.WARNING {
+		t.Fatalf("expected the level unchanged, got %s", log.GetLevel())
+	}
+
+	// a level without time limit
+	if code, levels := call("PUT", "?name=test.logadmin&level=error", "10.0.0.1:1234"); code != http.StatusOK || find(levels).Level != "error" || find(levels).RevertAt != nil {
+		t.Fatalf("expected ERROR, got %d %+v", code, find(levels))
+	}
+	// a temporary level reverts after the ttl
+	code, levels := call("PUT", "?name=test.logadmin&level=debug&ttl=50ms", "10.0.0.1:1234")
+	if code != http.StatusOK || find(levels).Level != "debug" || find(levels).RevertAt == nil {
+		t.Fatalf("expected temporary DEBUG, got %d %+v", code, find(levels))
+	}
+	time.Sleep(200 * time.Millisecond)
+	if _, levels = call("GET", "", "10.0.0.1:1234"); find(levels).Level != "error" || find(levels).RevertAt != nil {
+		t.Fatalf("expected revert to ERROR, got %+v", find(levels))
+	}
+	// an empty level restores the configured one
+	if code, levels = call("PUT", "?name=test.logadmin", "10.0.0.1:1234"); code != http.StatusOK || find(levels).Level != "warning" {
+		t.Fatalf("expected configured WARNING, got %d %+v", code, find(levels))
+	}
+	if log.GetLevel() != logging.WARNING {
+		t.Fatalf("expected configured WARNING, got %s", log.GetLevel())
+	}
+}
+
+func levelOf(name string) LoggerLevel {
+	for _, l := range LoggerLevels() {
+		if l.Name == name {
+			return l
+		}
+	}
+	return LoggerLevel{}
+}
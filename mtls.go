@@ -0,0 +1,251 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faygo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TLS_CLIENT_AUTH_NONE does not ask for the client certificates.
+	TLS_CLIENT_AUTH_NONE = "none"
+	// TLS_CLIENT_AUTH_REQUEST verifies the client certificate if the client sends one,
+	// the routes can require it with MuxAPI.RequireClientCert.
+	TLS_CLIENT_AUTH_REQUEST = "request"
+	// TLS_CLIENT_AUTH_REQUIRE rejects the TLS handshakes without a valid client certificate.
+	TLS_CLIENT_AUTH_REQUIRE = "require"
+
+	__tlsClientAuths__ = "none | request | require"
+)
+
+// PeerIdentityKey is the data key of the identity of the verified client certificate.
+const PeerIdentityKey = "PEER_IDENTITY"
+
+// PeerIdentity is the identity of the verified client certificate.
+type PeerIdentity struct {
+	Subject        string   // the distinguished name of the subject
+	CommonName     string   // the common name of the subject
+	DNSNames       []string // the DNS names of the subject alternative names
+	EmailAddresses []string // the email addresses of the subject alternative names
+	IPAddresses    []string // the IP addresses of the subject alternative names
+	URIs           []string // the URIs of the subject alternative names, such as the SPIFFE IDs
+	Certificate    *x509.Certificate
+}
+
+// PeerIdentity returns the identity of the client certificate verified by the
+// TLS handshake, and false if there is none.
+func (ctx *Context) PeerIdentity() (*PeerIdentity, bool) {
+	if id, ok := ctx.Data(PeerIdentityKey).(*PeerIdentity); ok {
+		return id, true
+	}
+	if ctx.R.TLS == nil || len(ctx.R.TLS.VerifiedChains) == 0 || len(ctx.R.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	cert := ctx.R.TLS.VerifiedChains[0][0]
+	id := &PeerIdentity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Certificate:    cert,
+	}
+	for _, ip := range cert.IPAddresses {
+		id.IPAddresses = append(id.IPAddresses, ip.String())
+	}
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	ctx.SetData(PeerIdentityKey, id)
+	return id, true
+}
+
+// Match reports whether the common name or a subject alternative name is one of the names.
+func (id *PeerIdentity) Match(names ...string) bool {
+	for _, name := range names {
+		if name == id.CommonName {
+			return true
+		}
+		for _, list := range [][]string{id.DNSNames, id.EmailAddresses, id.IPAddresses, id.URIs} {
+			for _, s := range list {
+				if strings.EqualFold(s, name) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// RequireClientCert requires a verified client certificate for the node and its progeny,
+// whose common name or subject alternative name is one of the names if any.
+// It works with the config item `tls_client_auth = request` or `require`.
+// note: it should be called before Run()
+func (mux *MuxAPI) RequireClientCert(names ...string) *MuxAPI {
+	return mux.Use(newClientCertFilter(names))
+}
+
+func newClientCertFilter(names []string) HandlerFunc {
+	return func(ctx *Context) error {
+		id, ok := ctx.PeerIdentity()
+		if !ok {
+			ctx.Log().Warningf("[mTLS] %s %s %s: no verified client certificate", ctx.RealIP(), ctx.Method(), ctx.Path())
+			ctx.Error(http.StatusForbidden, "client certificate required")
+			return nil
+		}
+		if len(names) > 0 && !id.Match(names...) {
+			ctx.Log().Warningf("[mTLS] %s %s %s: client certificate %q not allowed", ctx.RealIP(), ctx.Method(), ctx.Path(), id.Subject)
+			ctx.Error(http.StatusForbidden, "client certificate not allowed")
+			return nil
+		}
+		return nil
+	}
+}
+
+// tlsFiles holds the server certificate and the client CAs loaded from the
+// files, and reloads them when the files change.
+type tlsFiles struct {
+	certFile, keyFile, clientCAFile string
+	interval                        time.Duration // 0 means only ReloadTLS
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  [3]time.Time
+	checked   time.Time
+	log       func(format string, args ...interface{})
+}
+
+var errNoClientCA = errors.New("no certificate in the client CA file")
+
+// load loads the files if they are modified since the last load.
+func (f *tlsFiles) load() error {
+	files := [3]string{f.certFile, f.keyFile, f.clientCAFile}
+	var modTimes [3]time.Time
+	for i, name := range files {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	f.mu.RLock()
+	unchanged := f.modTimes == modTimes && (f.cert != nil || f.certFile == "")
+	f.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	var cert *tls.Certificate
+	if f.certFile != "" {
+		c, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+		if err != nil {
+			return err
+		}
+		cert = &c
+	}
+	var pool *x509.CertPool
+	if f.clientCAFile != "" {
+		pem, err := ioutil.ReadFile(f.clientCAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errNoClientCA
+		}
+	}
+	f.mu.Lock()
+	f.cert, f.clientCAs, f.modTimes = cert, pool, modTimes
+	f.mu.Unlock()
+	return nil
+}
+
+// maybeReload reloads the modified files at most once per interval,
+// keeping the loaded ones on failure.
+func (f *tlsFiles) maybeReload() {
+	if f.interval <= 0 {
+		return
+	}
+	now := time.Now()
+	f.mu.Lock()
+	if now.Sub(f.checked) < f.interval {
+		f.mu.Unlock()
+		return
+	}
+	f.checked = now
+	f.mu.Unlock()
+	if err := f.load(); err != nil {
+		f.log("[TLS] reload: %v", err)
+	}
+}
+
+func (f *tlsFiles) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.maybeReload()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.cert, nil
+}
+
+// getConfigForClient returns the config with the current client CAs.
+func (f *tlsFiles) getConfigForClient(base *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		f.maybeReload()
+		f.mu.RLock()
+		pool := f.clientCAs
+		f.mu.RUnlock()
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.ClientCAs = pool
+		return config, nil
+	}
+}
+
+// tlsClientAuthType returns the tls.ClientAuthType of the config item `tls_client_auth`.
+func tlsClientAuthType(mode string) tls.ClientAuthType {
+	switch mode {
+	case TLS_CLIENT_AUTH_REQUEST:
+		return tls.VerifyClientCertIfGiven
+	case TLS_CLIENT_AUTH_REQUIRE:
+		return tls.RequireAndVerifyClientCert
+	}
+	return tls.NoClientCert
+}
+
+// ReloadTLS reloads the modified TLS certificate, key and client CA files of
+// the https servers without a restart, such as on SIGHUP.
+// They are also checked each config item `tls_reload_interval`.
+func (frame *Framework) ReloadTLS() error {
+	frame.lock.RLock()
+	defer frame.lock.RUnlock()
+	for _, server := range frame.servers {
+		if server.tlsFiles == nil {
+			continue
+		}
+		if err := server.tlsFiles.load(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
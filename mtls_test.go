@@ -0,0 +1,186 @@
+package faygo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber, _ = rand.Int(rand.Reader, big.NewInt(1<<62))
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (c *testCert) keyPEM() []byte {
+	der, _ := x509.MarshalECPrivateKey(c.key)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	cert, _ := tls.X509KeyPair(c.pem, c.keyPEM())
+	return cert
+}
+
+func newTestCA(t *testing.T, name string) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+}
+
+func newTestLeaf(t *testing.T, ca *testCert, name string, usage x509.ExtKeyUsage) *testCert {
+	spiffe, _ := url.Parse("spiffe://example.com/" + name)
+	return newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		URIs:        []*url.URL{spiffe},
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+	}, ca)
+}
+
+// writeFile writes the file with a later modification time.
+func writeFile(t *testing.T, name string, data []byte, modTime time.Time) {
+	if err := ioutil.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(name, modTime, modTime)
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+	ca := newTestCA(t, "ca1")
+	server := newTestLeaf(t, ca, "server", x509.ExtKeyUsageServerAuth)
+	client := newTestLeaf(t, ca, "svc-a", x509.ExtKeyUsageClientAuth)
+	other := newTestLeaf(t, ca, "svc-b", x509.ExtKeyUsageClientAuth)
+	now := time.Now()
+	writeFile(t, certFile, server.pem, now)
+	writeFile(t, keyFile, server.keyPEM(), now)
+	writeFile(t, caFile, ca.pem, now)
+
+	config := NewDefaultConfig()
+	config.APIdoc.Enable = false
+	frame := NewWithConfig(config, "mtls-test")
+	frame.GET("/open", HandlerFunc(func(ctx *Context) error {
+		if id, ok := ctx.PeerIdentity(); ok {
+			return ctx.String(200, id.CommonName+" "+id.URIs[0])
+		}
+		return ctx.String(200, "anonymous")
+	}))
+	frame.Group("secure").RequireClientCert("spiffe://example.com/svc-a").GET("/data", HandlerFunc(func(ctx *Context) error {
+		return ctx.String(200, "ok")
+	}))
+	frame.build()
+
+	srv := &Server{
+		netType:           NETTYPE_HTTPS,
+		tlsCertFile:       certFile,
+		tlsKeyFile:        keyFile,
+		tlsClientCAFile:   caFile,
+		tlsClientAuth:     TLS_CLIENT_AUTH_REQUEST,
+		tlsReloadInterval: time.Nanosecond,
+		log:               frame.syslog,
+		Server:            &http.Server{Handler: frame},
+	}
+	tlsConfig, err := srv.tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Server.Serve(ln)
+	defer srv.Server.Close()
+	base := "https://" + ln.Addr().String()
+
+	var serverCert *x509.Certificate
+	get := func(path string, cert *testCert, roots *testCert) (int, string, error) {
+		pool := x509.NewCertPool()
+		pool.AddCert(roots.cert)
+		tlsClient := &tls.Config{RootCAs: pool}
+		if cert != nil {
+			tlsClient.Certificates = []tls.Certificate{cert.tlsCertificate()}
+		}
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsClient}}
+		resp, err := c.Get(base + path)
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		serverCert = resp.TLS.PeerCertificates[0]
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body), nil
+	}
+
+	var tests = []struct {
+		path   string
+		cert   *testCert
+		code   int
+		result string
+	}{
+		{"/open", nil, 200, "anonymous"},
+		{"/open", client, 200, "svc-a spiffe://example.com/svc-a"},
+		{"/secure/data", nil, 403, ""},
+		{"/secure/data", other, 403, ""},
+		{"/secure/data", client, 200, "ok"},
+	}
+	for _, test := range tests {
+		code, body, err := get(test.path, test.cert, ca)
+		if err != nil || code != test.code || (test.result != "" && body != test.result) {
+			t.Errorf("GET %s: got %d %q %v", test.path, code, body, err)
+		}
+	}
+
+	// hot reload of the server certificate and the client CAs
+	ca2 := newTestCA(t, "ca2")
+	server2 := newTestLeaf(t, ca2, "server", x509.ExtKeyUsageServerAuth)
+	client2 := newTestLeaf(t, ca2, "svc-a", x509.ExtKeyUsageClientAuth)
+	later := now.Add(time.Minute)
+	writeFile(t, certFile, server2.pem, later)
+	writeFile(t, keyFile, server2.keyPEM(), later)
+	writeFile(t, caFile, ca2.pem, later)
+	if code, _, err := get("/secure/data", client2, ca2); err != nil || code != 200 || !serverCert.Equal(server2.cert) {
+		t.Errorf("expected the reloaded files, got %d %v", code, err)
+	}
+	// the client does not send a certificate of a CA unknown to the server
+	if code, _, err := get("/secure/data", client, ca2); err == nil && code != 403 {
+		t.Errorf("expected the client certificate of the old CA rejected, got %d", code)
+	}
+}
@@ -76,6 +76,12 @@ type (
 		parent     *MuxAPI
 		children   []*MuxAPI
 		frame      *Framework
+		printBody  *printBodyOption // nil means following the parent or config
+		xsrfExempt *bool            // nil means following the parent
+		// the maximum size of the request body, nil means following the parent or config
+		maxBodySize *int64
+		// the permissions required by the node and its progeny
+		requires []string
 	}
 	// Methodset is the methods string of request
 	Methodset string
@@ -321,6 +327,21 @@ func (mux *MuxAPI) Use(handlers ...Handler) *MuxAPI {
 	return mux
 }
 
+// Require declares the permissions required by the node and its progeny,
+// which are checked by the Authorizer of the Framework right before the final
+// handler of the node, after all the middlewares, such as the authentication.
+// A request must have all the permissions required by the node and its ancestors.
+func (mux *MuxAPI) Require(permissions ...string) *MuxAPI {
+	mux.requires = append(mux.requires, permissions...)
+	return mux
+}
+
+// Requires returns the permissions required by the node, including those of
+// its ancestors after the router is built.
+func (mux *MuxAPI) Requires() []string {
+	return mux.requires
+}
+
 // comb mux.handlers, mux.paramInfos, mux.notes and mux.path,.
 // sort children by path.
 // note: can only be executed once before HTTP serving.
@@ -376,6 +397,16 @@ func (mux *MuxAPI) comb() {
 		mux.notes = append(mux.parent.notes, mux.notes...)
 		mux.paramInfos = append(mux.parent.paramInfos, mux.paramInfos...)
 		mux.handlers = append(mux.parent.handlers, mux.handlers...)
+		mux.requires = distinctPermissions(append(append([]string{}, mux.parent.requires...), mux.requires...))
+		if mux.xsrfExempt == nil {
+			mux.xsrfExempt = mux.parent.xsrfExempt
+		}
+		if mux.printBody == nil {
+			mux.printBody = mux.parent.printBody
+		}
+		if mux.maxBodySize == nil {
+			mux.maxBodySize = mux.parent.maxBodySize
+		}
 	}
 
 	// check path params defined, and panic if there is any error.
@@ -1,12 +1,21 @@
 package router
 
 import (
+	"time"
+
 	"github.com/andeya/faygo"
 	mw "github.com/andeya/faygo/ext/middleware"
 	"github.com/andeya/faygo/samples/demo/handler"
 	"github.com/andeya/faygo/samples/demo/middleware"
 )
 
+// cors allows the cross-origin requests of the search API,
+// the preflight requests are answered by the automatic OPTIONS reply.
+var cors = mw.NewCORS(mw.CORSConfig{
+	AllowOrigins: []string{"http://localhost:8080", "http://*.localhost:8080"},
+	MaxAge:       time.Hour,
+})
+
 // Register the route in a tree style
 func Route1(frame *faygo.Framework) {
 	frame.
@@ -33,7 +42,7 @@ func Route1(frame *faygo.Framework) {
 			frame.NewNamedStaticFS("markdown fs test", "/md", faygo.MarkdownFS(
 				"./static/markdown",
 			)),
-			frame.NewNamedAPI("reverse proxy", "GETOPTIONS", "/search", handler.Search(0)).Use(mw.CrossOrigin),
+			frame.NewNamedAPI("reverse proxy", "GET", "/search", handler.Search(0)).Use(cors),
 		)
 }
 
@@ -53,7 +62,7 @@ func Route2(frame *faygo.Framework) {
 	frame.StaticFS("/public", faygo.DirFS("./static/public"))
 	frame.Static("/syso", "../../_syso")
 
-	frame.NamedAPI("reverse proxy", "GET OPTIONS", "/search", handler.Search(0)).Use(mw.CrossOrigin)
+	frame.NamedAPI("reverse proxy", "GET", "/search", handler.Search(0)).Use(cors)
 
 	frame.NamedStaticFS("render fs test", "/renderfs", faygo.RenderFS(
 		"./static/renderfs",
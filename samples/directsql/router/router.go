@@ -21,6 +21,8 @@ func Route(frame *faygo.Framework) {
 	frame.NamedAPI("Home", "GET", "/", handler.Index())
 	// bos 执行SQL定义的路由
 	frame.NamedAPI("DirectSQL", "POST", "/bos/*path", directsql.DirectSQL())
+	// 根据SQL配置生成 /bos 的API文档
+	directsql.RegAPIdoc(frame, "/bos/*path")
 	frame.NamedGET("DirectSQL ModelSql Reload", "/bom/reloadall", directsql.DirectSQLReloadAll())
 	frame.NamedGET("DirectSQL ModelSql Reload", "/bom/reload/*path", directsql.DirectSQLReloadModel())
 	frame.NamedAPI("Pongo2", "GET", "/pongo2", handler.Pongo2())
@@ -0,0 +1,232 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faygo
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CSPNonceKey is the data key and the template variable of the nonce of the
+// Content-Security-Policy, e.g. `<script nonce="{{ csp_nonce }}">`.
+const CSPNonceKey = "csp_nonce"
+
+// cspNoncePlaceholder is replaced with the nonce source in the policy.
+const cspNoncePlaceholder = "{nonce}"
+
+// the headers managed by the SecureHeaders
+const (
+	headerStrictTransportSecurity = "Strict-Transport-Security"
+	headerContentTypeOptions      = "X-Content-Type-Options"
+	headerFrameOptions            = "X-Frame-Options"
+	headerReferrerPolicy          = "Referrer-Policy"
+	headerPermissionsPolicy       = "Permissions-Policy"
+	headerCSP                     = "Content-Security-Policy"
+	headerCSPReportOnly           = "Content-Security-Policy-Report-Only"
+)
+
+// SecureHeaders is the middleware that sets the security response headers.
+// When the config item `secure_headers::enable` is true, it is used by all
+// the routes of the Framework; a SecureHeaders used by a MuxAPI replaces the
+// headers set before it, including removing those it does not set.
+type SecureHeaders struct {
+	hsts         string
+	autoHSTS     bool
+	nosniff      bool
+	frameOptions string
+	referrer     string
+	permissions  string
+	cspHeader    string
+	cspParts     []string // the policy split by the nonce placeholder
+}
+
+// NewSecureHeaders creates the middleware that sets the security response headers.
+func NewSecureHeaders(conf SecureHeadersConfig) *SecureHeaders {
+	s := &SecureHeaders{
+		nosniff:      conf.ContentTypeNosniff,
+		frameOptions: conf.FrameOptions,
+		referrer:     conf.ReferrerPolicy,
+		permissions:  conf.PermissionsPolicy,
+	}
+	switch {
+	case conf.HSTSMaxAge == 0:
+		s.autoHSTS = true
+		s.hsts = "max-age=31536000"
+	case conf.HSTSMaxAge > 0:
+		s.hsts = "max-age=" + strconv.Itoa(conf.HSTSMaxAge)
+	}
+	if s.hsts != "" {
+		if conf.HSTSIncludeSubdomains {
+			s.hsts += "; includeSubDomains"
+		}
+		if conf.HSTSPreload {
+			s.hsts += "; preload"
+		}
+	}
+	if csp := strings.TrimSpace(conf.CSP); csp != "" {
+		if conf.CSPReportPath != "" && !strings.Contains(csp, "report-uri") {
+			csp = strings.TrimRight(csp, "; ") + "; report-uri " + conf.CSPReportPath
+		}
+		s.cspParts = strings.Split(csp, cspNoncePlaceholder)
+		s.cspHeader = headerCSP
+		if conf.CSPReportOnly {
+			s.cspHeader = headerCSPReportOnly
+		}
+	}
+	return s
+}
+
+// Serve implements the Handler.
+func (s *SecureHeaders) Serve(ctx *Context) error {
+	header := ctx.W.Header()
+	setOrDel := func(key, value string) {
+		if value == "" {
+			header.Del(key)
+		} else {
+			header.Set(key, value)
+		}
+	}
+	// the browsers ignore HSTS over http
+	if ctx.IsSecure() && (!s.autoHSTS || ctx.frame.hasTLS()) {
+		setOrDel(headerStrictTransportSecurity, s.hsts)
+	} else {
+		header.Del(headerStrictTransportSecurity)
+	}
+	if s.nosniff {
+		header.Set(headerContentTypeOptions, "nosniff")
+	} else {
+		header.Del(headerContentTypeOptions)
+	}
+	setOrDel(headerFrameOptions, s.frameOptions)
+	setOrDel(headerReferrerPolicy, s.referrer)
+	setOrDel(headerPermissionsPolicy, s.permissions)
+	header.Del(headerCSP)
+	header.Del(headerCSPReportOnly)
+	switch len(s.cspParts) {
+	case 0:
+	case 1:
+		header.Set(s.cspHeader, s.cspParts[0])
+	default:
+		header.Set(s.cspHeader, strings.Join(s.cspParts, "'nonce-"+ctx.CSPNonce()+"'"))
+	}
+	return nil
+}
+
+// CSPNonce returns the nonce of the Content-Security-Policy of the request,
+// created on the first call.
+func (ctx *Context) CSPNonce() string {
+	if nonce, ok := ctx.Data(CSPNonceKey).(string); ok {
+		return nonce
+	}
+	var b [16]byte
+	rand.Read(b[:])
+	nonce := base64.StdEncoding.EncodeToString(b[:])
+	ctx.SetData(CSPNonceKey, nonce)
+	return nonce
+}
+
+// hasTLS reports whether the framework listens on https or letsencrypt.
+func (frame *Framework) hasTLS() bool {
+	for _, t := range frame.config.NetTypes {
+		switch t {
+		case NETTYPE_HTTPS, NETTYPE_UNIX_HTTPS, NETTYPE_LETSENCRYPT, NETTYPE_UNIX_LETSENCRYPT:
+			return true
+		}
+	}
+	return false
+}
+
+// register the secure headers and the CSP report collector.
+func (frame *Framework) regSecureHeaders() {
+	conf := frame.config.SecureHeaders
+	frame.MuxAPI.Use(NewSecureHeaders(conf))
+	if conf.CSP != "" && conf.CSPReportPath != "" {
+		frame.MuxAPI.NamedPOST("CSP-Report", conf.CSPReportPath, NewCSPReportHandler()).
+			XSRFExempt(true).MaxBodySize(maxCSPReportSize)
+		frame.syslog.Criticalf(`CSP report's URL path is '` + conf.CSPReportPath + `'`)
+	}
+}
+
+const (
+	// maxCSPReportSize is the maximum size of a CSP violation report.
+	maxCSPReportSize = 64 << 10
+	// maxCSPReportsPerMinute is the maximum number of the CSP violation reports
+	// logged in a minute, the others are counted only.
+	maxCSPReportsPerMinute = 100
+)
+
+// NewCSPReportHandler creates the handler that logs the CSP violation reports
+// posted by the browsers, of the Content-Type application/csp-report or application/reports+json.
+// The reports are logged at Info level, a report is logged once a minute, and at
+// most maxCSPReportsPerMinute reports are logged in a minute.
+func NewCSPReportHandler() HandlerFunc {
+	var reports cspReports
+	return func(ctx *Context) error {
+		body, _ := ioutil.ReadAll(io.LimitReader(ctx.R.Body, maxCSPReportSize+1))
+		if len(body) > maxCSPReportSize {
+			ctx.NoContent(http.StatusRequestEntityTooLarge)
+			return nil
+		}
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, body); err != nil || buf.Len() == 0 {
+			ctx.NoContent(http.StatusBadRequest)
+			return nil
+		}
+		if log, dropped := reports.add(buf.Bytes()); log {
+			if dropped > 0 {
+				ctx.frame.syslog.Infof("[CSP] %d reports were not logged in the last minute", dropped)
+			}
+			ctx.frame.syslog.Infof("[CSP] %s %s %s", ctx.RealIP(), ctx.HeaderParam(HeaderUserAgent), buf.String())
+		}
+		ctx.NoContent(http.StatusNoContent)
+		return nil
+	}
+}
+
+// cspReports deduplicates and rate-limits the logged CSP violation reports.
+type cspReports struct {
+	mu      sync.Mutex
+	start   time.Time
+	seen    map[[sha256.Size]byte]bool
+	dropped int
+}
+
+// add reports whether the report should be logged, and the number of the reports
+// dropped in the last minute when a new minute starts.
+func (r *cspReports) add(report []byte) (log bool, dropped int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now := time.Now(); now.Sub(r.start) >= time.Minute {
+		dropped = r.dropped
+		r.start, r.seen, r.dropped = now, make(map[[sha256.Size]byte]bool), 0
+	}
+	sum := sha256.Sum256(report)
+	if r.seen[sum] || len(r.seen) >= maxCSPReportsPerMinute {
+		r.dropped++
+		return false, dropped
+	}
+	r.seen[sum] = true
+	return true, dropped
+}
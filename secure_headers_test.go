@@ -0,0 +1,102 @@
+package faygo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	config := NewDefaultConfig()
+	config.APIdoc.Enable = false
+	config.SecureHeaders.Enable = true
+	config.SecureHeaders.CSP = "default-src 'self'; script-src 'self' {nonce}"
+	config.SecureHeaders.CSPReportOnly = true
+	config.TrustedProxies = []string{"192.0.2.1"} // the peer of httptest.NewRequest
+	frame := NewWithConfig(config, "secure-headers-test")
+	nonce := HandlerFunc(func(ctx *Context) error {
+		return ctx.String(200, ctx.CSPNonce())
+	})
+	frame.GET("/page", nonce)
+	frame.Group("embed").Use(NewSecureHeaders(SecureHeadersConfig{
+		HSTSMaxAge:   3600,
+		FrameOptions: "DENY",
+	})).GET("/widget", nonce)
+	frame.build()
+
+	serve := func(method, path string, header http.Header, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		frame.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("GET", "/page", nil, "")
+	h := w.Header()
+	want := "default-src 'self'; script-src 'self' 'nonce-" + w.Body.String() + "'; report-uri /csp_report"
+	if got := h.Get(headerCSPReportOnly); got != want || h.Get(headerCSP) != "" {
+		t.Errorf("unexpected CSP:\n got %q\nwant %q", got, want)
+	}
+	if h.Get(headerContentTypeOptions) != "nosniff" || h.Get(headerFrameOptions) != "SAMEORIGIN" ||
+		h.Get(headerReferrerPolicy) != "strict-origin-when-cross-origin" || h.Get(headerStrictTransportSecurity) != "" {
+		t.Errorf("unexpected headers: %v", h)
+	}
+	if w2 := serve("GET", "/page", nil, ""); w2.Body.String() == w.Body.String() {
+		t.Error("expected a nonce per request")
+	}
+
+	// no auto HSTS without a https net type
+	w = serve("GET", "/page", http.Header{HeaderXForwardedProto: {"https"}}, "")
+	if hsts := w.Header().Get(headerStrictTransportSecurity); hsts != "" {
+		t.Errorf("unexpected HSTS: %s", hsts)
+	}
+
+	// the headers of the group replace those of the framework
+	w = serve("GET", "/embed/widget", http.Header{HeaderXForwardedProto: {"https"}}, "")
+	h = w.Header()
+	if h.Get(headerFrameOptions) != "DENY" || h.Get(headerCSPReportOnly) != "" || h.Get(headerContentTypeOptions) != "" ||
+		h.Get(headerStrictTransportSecurity) != "max-age=3600" {
+		t.Errorf("unexpected headers of the group: %v", h)
+	}
+
+	w = serve("POST", "/csp_report", http.Header{HeaderContentType: {"application/csp-report"}},
+		`{"csp-report": {"document-uri": "http://example.com/page", "violated-directive": "script-src"}}`)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected the report collected, got %d", w.Code)
+	}
+	if w = serve("POST", "/csp_report", nil, "not json"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a bad report rejected, got %d", w.Code)
+	}
+	large := `{"csp-report": {"blocked-uri": "` + strings.Repeat("x", maxCSPReportSize) + `"}}`
+	if w = serve("POST", "/csp_report", nil, large); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected a large report rejected, got %d", w.Code)
+	}
+}
+
+func TestCSPReports(t *testing.T) {
+	var r cspReports
+	if log, _ := r.add([]byte("a")); !log {
+		t.Fatal("expected the first report logged")
+	}
+	if log, _ := r.add([]byte("a")); log {
+		t.Fatal("expected the same report not logged twice")
+	}
+	for i := 1; i < maxCSPReportsPerMinute; i++ {
+		if log, _ := r.add([]byte(strconv.Itoa(i))); !log {
+			t.Fatalf("expected the report %d logged", i)
+		}
+	}
+	if log, _ := r.add([]byte("b")); log {
+		t.Fatal("expected the reports over the limit not logged")
+	}
+	r.start = r.start.Add(-time.Minute)
+	if log, dropped := r.add([]byte("a")); !log || dropped != 2 {
+		t.Fatalf("expected the report logged in the next minute and 2 reports dropped, got %v %d", log, dropped)
+	}
+}
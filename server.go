@@ -77,7 +77,12 @@ type Server struct {
 	tlsCertFile     string
 	tlsKeyFile      string
 	letsencryptDir  string
-	unixFileMode    os.FileMode
+	// mTLS
+	tlsClientCAFile   string
+	tlsClientAuth     string
+	tlsReloadInterval time.Duration
+	tlsFiles          *tlsFiles
+	unixFileMode      os.FileMode
 	*http.Server
 	log *logging.Logger
 }
@@ -146,31 +151,11 @@ func (server *Server) setNet() {
 var grace = new(gracenet.Net)
 
 func (server *Server) listen() net.Listener {
-	switch server.netType {
-	case NETTYPE_HTTPS, NETTYPE_UNIX_HTTPS:
-		var cert tls.Certificate
-		cert, err := tls.LoadX509KeyPair(server.tlsCertFile, server.tlsKeyFile)
-		if err != nil {
-			server.log.Fatalf("%v\n", err)
-			return nil
-		}
-		server.TLSConfig = &tls.Config{
-			Certificates:             []tls.Certificate{cert},
-			NextProtos:               []string{"http/1.1", "h2"},
-			PreferServerCipherSuites: true,
-		}
-
-	case NETTYPE_LETSENCRYPT, NETTYPE_UNIX_LETSENCRYPT:
-		m := autocert.Manager{
-			Prompt: autocert.AcceptTOS,
-		}
-
-		if server.letsencryptDir == "" {
-			// then the user passed empty by own will, then I guess user doesnt' want any cache directory
-		} else {
-			m.Cache = autocert.DirCache(server.letsencryptDir)
-		}
-		server.TLSConfig = &tls.Config{GetCertificate: m.GetCertificate}
+	var err error
+	server.TLSConfig, err = server.tlsConfig()
+	if err != nil {
+		server.log.Fatalf("%v\n", err)
+		return nil
 	}
 
 	switch server.netType {
@@ -200,6 +185,67 @@ func (server *Server) listen() net.Listener {
 	return ln
 }
 
+// tlsConfig creates the TLS config of the https and letsencrypt servers,
+// returns nil for the others.
+func (server *Server) tlsConfig() (*tls.Config, error) {
+	var config *tls.Config
+	switch server.netType {
+	case NETTYPE_HTTPS, NETTYPE_UNIX_HTTPS:
+		server.tlsFiles = server.newTLSFiles(server.tlsCertFile, server.tlsKeyFile)
+		config = &tls.Config{
+			GetCertificate:           server.tlsFiles.getCertificate,
+			NextProtos:               []string{"http/1.1", "h2"},
+			PreferServerCipherSuites: true,
+		}
+
+	case NETTYPE_LETSENCRYPT, NETTYPE_UNIX_LETSENCRYPT:
+		m := autocert.Manager{
+			Prompt: autocert.AcceptTOS,
+		}
+
+		if server.letsencryptDir == "" {
+			// then the user passed empty by own will, then I guess user doesnt' want any cache directory
+		} else {
+			m.Cache = autocert.DirCache(server.letsencryptDir)
+		}
+		config = &tls.Config{GetCertificate: m.GetCertificate}
+		if server.verifyClient() {
+			server.tlsFiles = server.newTLSFiles("", "")
+		}
+
+	default:
+		return nil, nil
+	}
+	if server.tlsFiles != nil {
+		if err := server.tlsFiles.load(); err != nil {
+			return nil, err
+		}
+	}
+	if server.verifyClient() {
+		config.ClientAuth = tlsClientAuthType(server.tlsClientAuth)
+		config.GetConfigForClient = server.tlsFiles.getConfigForClient(config)
+	}
+	return config, nil
+}
+
+// verifyClient reports whether the server verifies the client certificates.
+func (server *Server) verifyClient() bool {
+	return tlsClientAuthType(server.tlsClientAuth) != tls.NoClientCert
+}
+
+func (server *Server) newTLSFiles(certFile, keyFile string) *tlsFiles {
+	f := &tlsFiles{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: server.tlsReloadInterval,
+		log:      server.log.Errorf,
+	}
+	if server.verifyClient() {
+		f.clientCAFile = server.tlsClientCAFile
+	}
+	return f
+}
+
 // tcpKeepAliveListener sets TCP keep-alive timeouts on accepted
 // connections. It's used by ListenAndServe and ListenAndServeTLS so
 // dead TCP connections (e.g. closing laptop mid-download) eventually
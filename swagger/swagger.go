@@ -72,6 +72,7 @@ type (
 		Parameters  []*Parameter          `json:"parameters,omitempty"`
 		Responses   map[string]*Resp      `json:"responses"` // {"httpcode":resp}
 		Security    []map[string][]string `json:"security,omitempty"`
+		Permissions []string              `json:"x-permissions,omitempty"` // required by the route
 	}
 	// Parameter object
 	Parameter struct {
@@ -110,6 +111,7 @@ type (
 	Definition struct {
 		Type       string               `json:"type,omitempty"` // "object"
 		Properties map[string]*Property `json:"properties,omitempty"`
+		Required   []string             `json:"required,omitempty"`
 		Xml        *Xml                 `json:"xml,omitempty"`
 	}
 	// Property object
@@ -120,6 +122,12 @@ type (
 		Enum        []string    `json:"enum,omitempty"`
 		Example     interface{} `json:"example,omitempty"`
 		Default     interface{} `json:"default,omitempty"`
+		Ref         string      `json:"$ref,omitempty"`
+		Items       *Items      `json:"items,omitempty"`
+		MinLength   int         `json:"minLength,omitempty"`
+		MaxLength   int         `json:"maxLength,omitempty"`
+		Minimum     float64     `json:"minimum,omitempty"`
+		Maximum     float64     `json:"maximum,omitempty"`
 	}
 	// Xml object
 	Xml struct {
@@ -0,0 +1,181 @@
+// Copyright 2022 AndeyaLee. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faygo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// XSRF_MODE_FORM keeps the token in a secure cookie, and checks it against
+	// the form field '_xsrf', or the headers 'X-Xsrftoken' and 'X-Csrftoken'.
+	XSRF_MODE_FORM = "form"
+	// XSRF_MODE_HEADER keeps the signed token in a cookie readable by the scripts,
+	// and checks it against the same value sent in a request header, for the
+	// single-page applications.
+	XSRF_MODE_HEADER = "header"
+
+	__xsrfModes__ = "form | header"
+)
+
+// XSRFExempt overrides the config item `xsrf::enable` for the node and its progeny,
+// such as the webhooks and the APIs authenticated by tokens, which are not sent by the browsers.
+// note: it should be called before Run()
+func (mux *MuxAPI) XSRFExempt(exempt bool) *MuxAPI {
+	mux.xsrfExempt = &exempt
+	return mux
+}
+
+// xsrfError is the structured error of the XSRF check in header mode.
+type xsrfError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// xsrfFail denies the request.
+func (ctx *Context) xsrfFail(code, message string) bool {
+	if ctx.frame.config.XSRF.Mode == XSRF_MODE_HEADER {
+		ctx.JSON(http.StatusForbidden, map[string]xsrfError{"error": {Code: code, Message: message}})
+		ctx.Stop()
+	} else {
+		ctx.Error(http.StatusForbidden, message)
+	}
+	ctx.frame.syslog.Warningf("[XSRF] %s %s %s: %s", ctx.RealIP(), ctx.Method(), ctx.Path(), message)
+	return false
+}
+
+// isXSRFUnsafe reports whether the request method must be checked.
+func (ctx *Context) isXSRFUnsafe() bool {
+	if ctx.frame.config.XSRF.Mode == XSRF_MODE_HEADER {
+		switch ctx.R.Method {
+		case "GET", "HEAD", "OPTIONS", "TRACE":
+			return false
+		}
+		return true
+	}
+	switch ctx.R.Method {
+	case "POST", "DELETE", "PUT":
+		return true
+	}
+	switch ctx.BizParam("_method") {
+	case "POST", "DELETE", "PUT":
+		return true
+	}
+	return false
+}
+
+// checkXSRFOrigin checks that the Origin, or the Referer if there is no Origin,
+// is the host of the request or a trusted origin, the X-Forwarded-Host of a
+// trusted proxy is the host of the request too.
+// The requests without both are passed, such as those of the non-browser clients.
+func (ctx *Context) checkXSRFOrigin() bool {
+	if !ctx.frame.config.XSRF.CheckOrigin {
+		return true
+	}
+	origin := ctx.R.Header.Get(HeaderOrigin)
+	if origin == "" {
+		referer := ctx.R.Referer()
+		if referer == "" {
+			return true
+		}
+		u, err := url.Parse(referer)
+		if err != nil || u.Host == "" {
+			return ctx.xsrfFail("xsrf_origin_mismatch", "XSRF check failed: invalid Referer")
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	for _, trusted := range ctx.frame.config.XSRF.TrustedOrigins {
+		if strings.EqualFold(origin, trusted) {
+			return true
+		}
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host != "" {
+		if strings.EqualFold(u.Host, ctx.R.Host) {
+			return true
+		}
+		// the host requested from the proxy, which may rewrite the Host header
+		if ctx.fromTrustedProxy() && strings.EqualFold(u.Host, ctx.R.Header.Get(HeaderXForwardedHost)) {
+			return true
+		}
+	}
+	return ctx.xsrfFail("xsrf_origin_mismatch", "XSRF check failed: cross-site request from "+origin)
+}
+
+// checkXSRFHeader checks that the token of the header is the signed token of the cookie.
+func (ctx *Context) checkXSRFHeader() bool {
+	conf := &ctx.frame.config.XSRF
+	token := ctx.R.Header.Get(conf.HeaderName)
+	if token == "" {
+		return ctx.xsrfFail("xsrf_token_missing", "XSRF token missing from the header "+conf.HeaderName)
+	}
+	cookie, ok := ctx.xsrfCookie()
+	if !ok || !hmac.Equal([]byte(token), []byte(cookie)) {
+		return ctx.xsrfFail("xsrf_token_mismatch", "XSRF token of the header "+conf.HeaderName+" does not match the cookie "+conf.CookieName)
+	}
+	return true
+}
+
+// xsrfCookie returns the token of the request cookie.
+func (ctx *Context) xsrfCookie() (string, bool) {
+	conf := &ctx.frame.config.XSRF
+	if conf.Mode != XSRF_MODE_HEADER {
+		return ctx.SecureCookieParam(conf.Key, "_xsrf")
+	}
+	cookie := ctx.CookieParam(conf.CookieName)
+	i := strings.LastIndexByte(cookie, '.')
+	if i <= 0 || !hmac.Equal([]byte(cookie[i+1:]), []byte(ctx.signXSRFToken(cookie[:i]))) {
+		return "", false
+	}
+	return cookie, true
+}
+
+// newXSRFToken creates a token, signed in header mode, so that a cookie
+// injected by a sibling subdomain is not accepted.
+func (ctx *Context) newXSRFToken() string {
+	token := RandomString(32)
+	if ctx.frame.config.XSRF.Mode == XSRF_MODE_HEADER {
+		token += "." + ctx.signXSRFToken(token)
+	}
+	return token
+}
+
+func (ctx *Context) signXSRFToken(token string) string {
+	mac := hmac.New(sha256.New, []byte(ctx.frame.config.XSRF.Key))
+	mac.Write([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// setXSRFCookie sets the new token to the response cookie.
+func (ctx *Context) setXSRFCookie() {
+	conf := &ctx.frame.config.XSRF
+	if conf.Mode != XSRF_MODE_HEADER {
+		ctx.SetSecureCookie(conf.Key, "_xsrf", ctx._xsrfToken, ctx.xsrfExpire)
+		return
+	}
+	http.SetCookie(ctx.W, &http.Cookie{
+		Name:     conf.CookieName,
+		Value:    ctx._xsrfToken,
+		Path:     "/",
+		MaxAge:   ctx.xsrfExpire,
+		Secure:   ctx.IsSecure(),
+		HttpOnly: false, // read by the scripts
+		SameSite: http.SameSiteLaxMode,
+	})
+}
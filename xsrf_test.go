@@ -0,0 +1,84 @@
+package faygo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestXSRFHeaderMode(t *testing.T) {
+	config := NewDefaultConfig()
+	config.APIdoc.Enable = false
+	config.XSRF.Enable = true
+	config.XSRF.Mode = XSRF_MODE_HEADER
+	config.XSRF.CheckOrigin = true
+	config.XSRF.TrustedOrigins = []string{"https://app.example.com"}
+	config.TrustedProxies = []string{"10.0.0.1"}
+	frame := NewWithConfig(config, "xsrf-test")
+	ok := HandlerFunc(func(ctx *Context) error {
+		return ctx.String(200, "ok")
+	})
+	frame.GET("/form", ok)
+	frame.POST("/save", ok)
+	frame.Group("hooks").XSRFExempt(true).POST("/github", ok)
+	frame.build()
+
+	serve := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://example.com"+path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		frame.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("GET", "/form", nil)
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "XSRF-TOKEN" {
+			cookie = c
+		}
+	}
+	if w.Code != 200 || cookie == nil || cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected a readable token cookie, got %d %v", w.Code, w.Header()["Set-Cookie"])
+	}
+
+	var tests = []struct {
+		name   string
+		path   string
+		header http.Header
+		code   int
+		error  string
+	}{
+		{"missing header", "/save", http.Header{"Cookie": {"XSRF-TOKEN=" + cookie.Value}}, 403, "xsrf_token_missing"},
+		{"matched", "/save", http.Header{"Cookie": {"XSRF-TOKEN=" + cookie.Value}, "X-Xsrf-Token": {cookie.Value}}, 200, ""},
+		{"unsigned cookie", "/save", http.Header{"Cookie": {"XSRF-TOKEN=abc.def"}, "X-Xsrf-Token": {"abc.def"}}, 403, "xsrf_token_mismatch"},
+		{"cross-site", "/save", http.Header{"Cookie": {"XSRF-TOKEN=" + cookie.Value}, "X-Xsrf-Token": {cookie.Value}, "Origin": {"https://evil.com"}}, 403, "xsrf_origin_mismatch"},
+		{"same host", "/save", http.Header{"Cookie": {"XSRF-TOKEN=" + cookie.Value}, "X-Xsrf-Token": {cookie.Value}, "Referer": {"https://example.com/form"}}, 200, ""},
+		{"trusted origin", "/save", http.Header{"Cookie": {"XSRF-TOKEN=" + cookie.Value}, "X-Xsrf-Token": {cookie.Value}, "Origin": {"https://app.example.com"}}, 200, ""},
+		{"exempted", "/hooks/github", nil, 200, ""},
+	}
+	for _, test := range tests {
+		w := serve("POST", test.path, test.header)
+		if w.Code != test.code || !strings.Contains(w.Body.String(), test.error) {
+			t.Errorf("%s: got %d %s", test.name, w.Code, w.Body.String())
+		}
+	}
+
+	// X-Forwarded-Host is the host only from the trusted proxies
+	for peer, code := range map[string]int{"192.0.2.1:1234": 403, "10.0.0.1:1234": 200} {
+		req := httptest.NewRequest("POST", "http://backend:8080/save", nil)
+		req.RemoteAddr = peer
+		req.Header.Set("Cookie", "XSRF-TOKEN="+cookie.Value)
+		req.Header.Set("X-Xsrf-Token", cookie.Value)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("X-Forwarded-Host", "example.com")
+		w := httptest.NewRecorder()
+		frame.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Errorf("X-Forwarded-Host from %s: expected %d, got %d %s", peer, code, w.Code, w.Body.String())
+		}
+	}
+}